@@ -2,23 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/jurabek/cart-api/cmd/config"
+	"github.com/jurabek/cart-api/internal/catalog"
+	"github.com/jurabek/cart-api/internal/coupons"
 	"github.com/jurabek/cart-api/internal/database"
 	"github.com/jurabek/cart-api/internal/events"
+	"github.com/jurabek/cart-api/internal/flags"
 	grpcsvc "github.com/jurabek/cart-api/internal/grpc"
 	"github.com/jurabek/cart-api/internal/handlers"
 	"github.com/jurabek/cart-api/internal/instrumentation"
+	"github.com/jurabek/cart-api/internal/models"
 	pbv1 "github.com/jurabek/cart-api/pb/v1"
+	"github.com/jurabek/cart-api/pkg/breaker"
+	producer "github.com/jurabek/cart-api/pkg/publisher"
 	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/jurabek/cart-api/pkg/shutdown"
+	"github.com/jurabek/cart-api/pkg/snapshot"
 	"github.com/redis/go-redis/v9"
 	"github.com/swaggo/swag/example/basic/docs"
 	"google.golang.org/grpc"
@@ -47,6 +60,37 @@ var (
 	Version   string
 )
 
+// consumerGroup is the kafka consumer group of order events
+const consumerGroup = "cart-api"
+
+// pricesConsumerGroup is the kafka consumer group of price events
+const pricesConsumerGroup = "cart-api-prices"
+
+// cartStore is the part of the cart repository spanning carts, served by
+// the primary repository, its shards or event sourced carts
+type cartStore interface {
+	handlers.CartTransferer
+	handlers.NamedCartStore
+	handlers.TotalRecomputer
+	handlers.CartsItemAdder
+	handlers.CartsCreator
+	events.ItemRepricer
+	events.ItemDiscontinuer
+	ImportFile(ctx context.Context, path string) ([]string, error)
+}
+
+// cartIndexes is the part of the cart repository kept next to key-value
+// carts, served by the primary repository or by its shards. Event sourced
+// carts don't maintain the indexes, routes reading them aren't served then
+type cartIndexes interface {
+	handlers.Pinger
+	handlers.CartMutationCounter
+	handlers.CartSharer
+	handlers.RecentCartsLister
+	handlers.CartVersioner
+	handlers.ReservationCounter
+}
+
 //	@title			Cart API
 //	@version		1.0
 //	@description	This is a rest api for cart which saves items to redis server
@@ -59,66 +103,528 @@ var (
 // @license.name	Apache 2.0
 // @license.url	http://www.apache.org/licenses/LICENSE-2.0.html
 func main() {
+	replaySince := flag.String("replay-since", "", "replay order events produced since RFC3339 timestamp")
+	flag.Parse()
+
 	ctx := context.Background()
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
 	basePath, _ := os.LookupEnv("BASE_PATH")
 	docs.SwaggerInfo.BasePath = basePath
 
-	close, err := instrumentation.StartOTEL(ctx)	
+	// the service runs without telemetry rather than not at all
+	closeOTEL, err := instrumentation.StartOTEL(ctx)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Error starting otel")
+		log.Warn().Err(err).Msg("Error starting otel, telemetry is disabled")
+		closeOTEL = func(context.Context) error { return nil }
 	}
-	defer close() 
+	// log.Fatal and panics skip the shutdown sequence, the flusher exports
+	// spans leading to the crash before the process dies
+	crashFlusher := instrumentation.NewCrashFlusher(closeOTEL, 5*time.Second)
+	log.Logger = log.Logger.Hook(crashFlusher)
+	defer crashFlusher.Recover()
 
-	handleSigterm()
 	router := http.NewServeMux()
 	cfg := config.Init()
 
-	redisClient, err := initRedis(cfg.RedisHost)
+	// every redis node shares the breaker, so one failing node rejects
+	// commands to all of them while open
+	redisBreaker := breaker.New(cfg.RedisBreakerFailures, cfg.RedisBreakerOpenTimeout)
+	var redisClients []*redis.Client
+	connectRedis := func(host string) *redis.Client {
+		client, err := initRedis(host)
+		if err != nil {
+			fmt.Print(err)
+		}
+		client.AddHook(database.NewCircuitBreakerHook(redisBreaker))
+		if cfg.RedisSlowThreshold > 0 {
+			client.AddHook(database.NewSlowLogHook(cfg.RedisSlowThreshold))
+		}
+		redisClients = append(redisClients, client)
+		return client
+	}
+	redisClient := connectRedis(cfg.RedisHost)
+	repositoryOpts := []repositories.Option{}
+	if err := database.ObserveCircuitBreaker(redisBreaker); err != nil {
+		log.Error().Err(err).Msg("Error registering circuit breaker metric")
+	}
+	cartCodec, err := repositories.NewCodec(cfg.CartCodec)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid CART_CODEC")
+	}
+	itemIDStrategy, err := repositories.ParseItemIDStrategy(cfg.ItemIDStrategy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid ITEM_ID_STRATEGY")
+	}
+	repositoryOpts = append(repositoryOpts,
+		repositories.WithLimits(repositories.Limits{
+			MaxItemPrice: models.FromMajor(cfg.MaxItemPrice, ""),
+			MaxCartTotal: models.FromMajor(cfg.MaxCartTotal, ""),
+		}),
+		repositories.WithCodec(cartCodec),
+		repositories.WithKeyPrefix(cfg.RedisKeyPrefix),
+		repositories.WithCartTTL(cfg.CartTTL),
+		repositories.WithVersions(cfg.CartVersions),
+		repositories.WithHistory(cfg.CartHistory),
+		repositories.WithReservations(cfg.ReservationTTL),
+		repositories.WithArchive(cfg.ArchiveRetention),
+		repositories.WithItemPolicy(repositories.StaticItemPolicy(cfg.ItemMaxQuantities)),
+		repositories.WithWriteBehind(cfg.WriteBehindWindow),
+		repositories.WithItemIDStrategy(itemIDStrategy),
+	)
+	primaryOpts := append([]repositories.Option{}, repositoryOpts...)
+	if cfg.RedisReadHost != "" {
+		primaryOpts = append(primaryOpts, repositories.WithReadReplica(connectRedis(cfg.RedisReadHost)))
+	}
+	cartRepository := repositories.NewCartRepository(redisClient, primaryOpts...)
+	flushCarts := cartRepository.FlushAll
+
+	// carts handled by id are spread across shards when configured
+	var carts handlers.GetCreateDeleter = cartRepository
+	var counter handlers.CartCounter = cartRepository
+	var summarizer handlers.CartSummarizer = cartRepository
+	var historian handlers.CartHistorian = cartRepository
+	var archive handlers.ArchiveCounter = cartRepository
+	var watcher handlers.CartWatcher = cartRepository
+	var couponCarts handlers.CouponUpdater = cartRepository
+	var store cartStore = cartRepository
+	var indexes cartIndexes = cartRepository
+	sweepers := []*repositories.CartRepository{cartRepository}
+	if len(cfg.RedisShards) > 0 {
+		shards := make(map[string]*repositories.CartRepository, len(cfg.RedisShards))
+		for _, host := range cfg.RedisShards {
+			shards[host] = repositories.NewCartRepository(connectRedis(host), repositoryOpts...)
+			sweepers = append(sweepers, shards[host])
+		}
+		sharded := repositories.NewShardedRepository(shards)
+		carts = sharded
+		counter = sharded
+		summarizer = sharded
+		historian = sharded
+		archive = sharded
+		watcher = sharded
+		couponCarts = sharded
+		store = sharded
+		indexes = sharded
+		flushCarts = func(ctx context.Context) error {
+			return errors.Join(cartRepository.FlushAll(ctx), sharded.FlushAll(ctx))
+		}
+	}
+	if cfg.CartEventSourcing {
+		if len(cfg.RedisShards) > 0 {
+			log.Fatal().Msg("CART_EVENT_SOURCING can't be combined with REDIS_SHARDS")
+		}
+		if cfg.ArchiveRetention > 0 {
+			log.Fatal().Msg("CART_EVENT_SOURCING can't be combined with CART_ARCHIVE_RETENTION")
+		}
+		eventSourced := repositories.NewEventSourcedRepository(redisClient, cfg.CartSnapshotEvery, repositoryOpts...)
+		carts = eventSourced
+		counter = eventSourced
+		store = eventSourced
+		summarizer = eventSourced
+		historian = eventSourced
+		watcher = eventSourced
+		couponCarts = eventSourced
+	}
+	if cfg.SeedFile != "" {
+		imported, err := store.ImportFile(ctx, cfg.SeedFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Error importing seed carts")
+		}
+		log.Info().Int("count", len(imported)).Str("file", cfg.SeedFile).Msg("imported seed carts")
+	}
+
+	eventCodec, err := events.NewCodec(cfg.EventCodec)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid EVENT_CODEC")
+	}
+	events.UseCodec(eventCodec)
+
+	kafkaConfig := sarama.NewConfig()
+	kafkaConfig.Consumer.Offsets.AutoCommit.Enable = true
+	kafkaConfig.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
+
+	// consumers stop on shutdown after the servers, consuming tracks
+	// messages still being handled
+	consumeCtx, stopConsuming := context.WithCancel(ctx)
+	var consuming sync.WaitGroup
+	consume := func(r *reciever.MessageReciever, handler reciever.MessageHandler) {
+		consuming.Add(1)
+		go func() {
+			defer consuming.Done()
+			if err := r.Recieve(consumeCtx, handler); !errors.Is(err, context.Canceled) {
+				log.Error().Err(err).Msg("Error recieving messages")
+			}
+		}()
+	}
+
+	kafkaConsumer, err := sarama.NewConsumerGroup([]string{cfg.KafkaBroker}, consumerGroup, kafkaConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg("new consumer failed!")
+	}
+	kafkaAdmin, err := sarama.NewClusterAdmin([]string{cfg.KafkaBroker}, kafkaConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg("new kafka admin failed!")
+	}
+	kafkaClient, err := sarama.NewClient([]string{cfg.KafkaBroker}, kafkaConfig)
 	if err != nil {
-		fmt.Print(err)
+		log.Fatal().Err(err).Msg("new kafka client failed!")
+	}
+	lagChecker := reciever.NewLagChecker(kafkaClient, kafkaAdmin, consumerGroup, cfg.OrdersTopic)
+	lagMonitor := reciever.NewLagMonitor(lagChecker)
+	if err := lagMonitor.Observe(); err != nil {
+		log.Error().Err(err).Msg("Error registering consumer lag metric")
+	}
+	go lagMonitor.Run(consumeCtx, cfg.KafkaLagInterval)
+	for _, sweeper := range sweepers {
+		go sweeper.RunArchiveSweeper(consumeCtx, cfg.ArchiveSweepInterval)
+	}
+	recieverOpts := []reciever.Option{
+		reciever.WithBackoff(reciever.DefaultInitialBackoff, cfg.KafkaMaxBackoff),
+		reciever.WithWorkers(cfg.KafkaWorkers),
+	}
+	if *replaySince != "" {
+		offsets, err := replayOffsets(cfg, kafkaConfig, *replaySince)
+		if err != nil {
+			log.Fatal().Err(err).Msg("resolving replay offsets failed!")
+		}
+		recieverOpts = append(recieverOpts, reciever.WithReplayOffsets(offsets))
+	}
+	msgReciever := reciever.NewMessageReciever(kafkaConsumer, cfg.OrdersTopic, recieverOpts...)
+	var eventOpts []events.Option
+	var snapshotReader events.SnapshotReader
+	if cfg.SnapshotBucket != "" {
+		snapshotStore, err := snapshot.NewS3Store(snapshot.S3Config{
+			Endpoint:  cfg.SnapshotEndpoint,
+			Bucket:    cfg.SnapshotBucket,
+			Region:    cfg.SnapshotRegion,
+			AccessKey: cfg.SnapshotAccessKey,
+			SecretKey: cfg.SnapshotSecretKey,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid snapshot store configuration")
+		}
+		eventOpts = append(eventOpts, events.WithSnapshotStore(snapshotStore))
+		snapshotReader = snapshotStore
 	}
-	cartRepository := repositories.NewCartRepository(redisClient)
+	orderCompletedHandler := events.NewOrderCompletedEventHandler(carts, eventOpts...)
+	orderCancelledHandler := events.NewOrderCancelledEventHandler(carts, snapshotReader)
+	// messages without event type predate routing and are completed orders
+	ordersRouter := reciever.NewRouter(reciever.WithSkipUnknown()).
+		Register("", orderCompletedHandler).
+		Register(events.OrderCompletedEventType, orderCompletedHandler).
+		Register(events.OrderCancelledEventType, orderCancelledHandler)
+	consume(msgReciever, ordersRouter)
+	kafkaClosers := []io.Closer{kafkaConsumer, kafkaAdmin, kafkaClient}
+	if cfg.PricesTopic != "" {
+		pricesConsumer, err := sarama.NewConsumerGroup([]string{cfg.KafkaBroker}, pricesConsumerGroup, kafkaConfig)
+		if err != nil {
+			log.Fatal().Err(err).Msg("new prices consumer failed!")
+		}
+		pricesReciever := reciever.NewMessageReciever(pricesConsumer, cfg.PricesTopic,
+			reciever.WithBackoff(reciever.DefaultInitialBackoff, cfg.KafkaMaxBackoff),
+			reciever.WithWorkers(cfg.KafkaWorkers),
+		)
+		var cartNotifier events.CartNotifier
+		if cfg.CartEventsTopic != "" {
+			producerConfig := sarama.NewConfig()
+			producerConfig.Producer.Return.Successes = true
+			syncProducer, err := sarama.NewSyncProducer([]string{cfg.KafkaBroker}, producerConfig)
+			if err != nil {
+				log.Fatal().Err(err).Msg("new cart events producer failed!")
+			}
+			cartProducer := producer.NewProducer(syncProducer, producerConfig)
+			cartNotifier = events.NewCartEventPublisher(cartProducer, cfg.CartEventsTopic)
+			kafkaClosers = append(kafkaClosers, cartProducer)
+		}
+		priceChangedHandler := events.NewPriceChangedEventHandler(store)
+		// messages without event type predate routing and are price changes
+		pricesRouter := reciever.NewRouter(reciever.WithSkipUnknown()).
+			Register("", priceChangedHandler).
+			Register(events.PriceChangedEventType, priceChangedHandler).
+			Register(events.ItemDiscontinuedEventType, events.NewItemDiscontinuedEventHandler(store, cartNotifier))
+		consume(pricesReciever, pricesRouter)
+		kafkaClosers = append(kafkaClosers, pricesConsumer)
+	}
+
+	grpcSrv := grpcServer(grpcsvc.NewCartGrpcService(carts))
 
-	config := sarama.NewConfig()
-	config.Consumer.Offsets.AutoCommit.Enable = true
-	config.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
+	idGenerator, err := handlers.NewIDGenerator(cfg.CartIDGenerator)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid CART_ID_GENERATOR")
+	}
+	marshaler, err := handlers.NewMarshaler(cfg.JSONEncoder)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid JSON_ENCODER")
+	}
+	handlers.UseMarshaler(marshaler)
+	handlers.UsePrettyJSON(cfg.JSONPretty)
+	fieldCase, err := handlers.ParseFieldCase(cfg.JSONFieldCase)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid JSON_FIELD_CASE")
+	}
+	handlers.UseFieldCase(fieldCase)
+	featureFlags := flags.Static(cfg.Flags)
+	couponFinder := coupons.NewStatic(cfg.Coupons)
+	handlerOpts := []handlers.Option{
+		handlers.WithCoupons(couponFinder),
+		handlers.WithFlags(featureFlags),
+		handlers.WithIDGenerator(idGenerator),
+		handlers.WithCartIDAttribute(cfg.TraceCartID),
+		handlers.WithClampQuantity(cfg.ClampQuantity),
+		handlers.WithDefaultQuantity(cfg.DefaultQuantity),
+		handlers.WithStockClamp(cfg.ClampStock),
+		handlers.WithFeeCalculator(handlers.FlatFees{
+			Shipping:  models.FromMajor(cfg.ShippingFee, ""),
+			Service:   models.FromMajor(cfg.ServiceFee, ""),
+			Packaging: models.FromMajor(cfg.PackagingFee, ""),
+		}),
+	}
+	if cfg.PriceSource == config.PriceSourceCatalog {
+		handlerOpts = append(handlerOpts, handlers.WithPriceProvider(catalog.NewClient(cfg.CatalogURL)))
+	}
+	cartHandler := handlers.NewCartHandler(carts, handlerOpts...)
 
-	
+	// handle registers h for method and path, tagging request spans with the route
+	handle := func(method, path string, h handlers.HandlerFunc) {
+		router.Handle(method+" "+path, otelhttp.WithRouteTag(path, http.HandlerFunc(h)))
+	}
 
-	kafkaConsumer, error := sarama.NewConsumerGroup([]string{cfg.KafkaBroker}, "cart-api", config)
-	if error != nil {
-		log.Fatal().Err(error).Msg("new consumer failed!")
+	// jsonBody guards handlers decoding a json request body
+	jsonBody := func(f func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) error {
+		return handlers.DecompressBody(cfg.MaxDecompressedBody, handlers.RequireJSON(f))
 	}
-	msgReciever := reciever.NewMessageReciever(kafkaConsumer, cfg.OrdersTopic)
-	go func() {
-		recieveErr := msgReciever.Recieve(ctx, events.NewOrderCompletedEventHandler(cartRepository))
-		log.Error().Err(recieveErr).Msg("Error recieving messages")
-	}()
 
-	go grpcServer(grpcsvc.NewCartGrpcService(cartRepository))
+	// mutation guards handlers changing a cart against hot carts
+	mutation := func(f func(http.ResponseWriter, *http.Request) error) func(http.ResponseWriter, *http.Request) error {
+		return f
+	}
+	if cfg.CartRateLimit > 0 {
+		mutation = handlers.NewCartRateLimiter(indexes, cfg.CartRateLimit, cfg.CartRateWindow).Limit
+	}
 
-	cartHandler := handlers.NewCartHandler(cartRepository)
+	// ids of carts migrated from an older id scheme resolve by their alias
+	cartIDs := handlers.NewCartIDs(cartRepository)
 
 	cartBasePath := basePath + "/api/v1/cart"
-	router.HandleFunc("POST "+cartBasePath, handlers.ErrorHandler(cartHandler.Create))
-	router.HandleFunc("GET "+cartBasePath+"/{id}", handlers.ErrorHandler(cartHandler.Get))
-	router.HandleFunc("DELETE "+cartBasePath+"/{id}", handlers.ErrorHandler(cartHandler.Delete))
-	router.HandleFunc("PUT "+cartBasePath+"/{id}", handlers.ErrorHandler(cartHandler.Update))
-	router.HandleFunc("POST "+cartBasePath+"/{id}/item", handlers.ErrorHandler(cartHandler.AddItem))           // adds item or increments quantity by CartID
-	router.HandleFunc("PUT "+cartBasePath+"/{id}/item/{itemID}", handlers.ErrorHandler(cartHandler.UpdateItem)) // updates line item item_id is ignored
-	router.HandleFunc("DELETE "+cartBasePath+"/{id}/item/{itemID}", handlers.ErrorHandler(cartHandler.DeleteItem))
-
-	otelRouter := otelhttp.NewHandler(router, "server",
+	handle("POST", cartBasePath, handlers.ErrorHandler(jsonBody(cartHandler.Create)))
+	handle("GET", cartBasePath+"/{id}", handlers.ErrorHandler(cartIDs.Require(cartHandler.Get)))
+	handle("DELETE", cartBasePath+"/{id}", handlers.ErrorHandler(cartIDs.Require(mutation(cartHandler.Delete))))
+	handle("PUT", cartBasePath+"/{id}", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(cartHandler.Update)))))
+	handle("POST", cartBasePath+"/{id}/touch", handlers.ErrorHandler(cartIDs.Require(mutation(cartHandler.Touch))))
+	handle("POST", cartBasePath+"/{id}/item", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(cartHandler.AddItem)))))            // adds item or increments quantity by CartID
+	handle("PUT", cartBasePath+"/{id}/item/{itemID}", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(cartHandler.UpdateItem))))) // updates line item item_id is ignored
+	handle("DELETE", cartBasePath+"/{id}/item/{itemID}", handlers.ErrorHandler(cartIDs.Require(mutation(cartHandler.DeleteItem))))
+	handle("POST", cartBasePath+"/{id}/items", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(cartHandler.AddItems)))))      // bulk add, ?mode=partial reports per item
+	handle("DELETE", cartBasePath+"/{id}/items", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(cartHandler.DeleteItems))))) // bulk delete, ?mode=partial reports per item
+	handle("POST", cartBasePath+"/{id}/item/{itemID}/move", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(cartHandler.MoveItem)))))
+	handle("POST", cartBasePath+"/{id}/item/{itemID}/decrement", handlers.ErrorHandler(cartIDs.Require(mutation(cartHandler.DecrementItem))))
+	handle("POST", cartBasePath+"/{id}/item/{itemID}/quantity", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(cartHandler.AdjustItemQuantity)))))
+
+	// shared carts, versions, recent carts and reservations are indexes of
+	// key-value carts only
+	indexed := !cfg.CartEventSourcing
+	var getShared func(http.ResponseWriter, *http.Request) error
+	if indexed {
+		shareHandler := handlers.NewShareHandler(indexes, cfg.ShareTTL)
+		handle("POST", cartBasePath+"/{id}/share", handlers.ErrorHandler(cartIDs.Require(shareHandler.Share)))
+		getShared = shareHandler.GetShared
+	}
+
+	minimumOrder := handlers.MinimumOrder{
+		Default:     cfg.MinOrderValue,
+		PerCurrency: make(map[string]models.Money, len(cfg.MinOrderValues)),
+	}
+	for currency, value := range cfg.MinOrderValues {
+		currency = strings.ToUpper(currency)
+		minimumOrder.PerCurrency[currency] = models.FromMajor(value, currency)
+	}
+	checkoutHandler := handlers.NewCheckoutHandler(carts, minimumOrder)
+	handle("POST", cartBasePath+"/{id}/checkout", handlers.ErrorHandler(cartIDs.Require(mutation(checkoutHandler.Checkout))))
+
+	transferHandler := handlers.NewTransferHandler(store, cfg.TransferReplaceActive)
+	handle("POST", cartBasePath+"/{id}/transfer", handlers.ErrorHandler(cartIDs.Require(mutation(jsonBody(transferHandler.Transfer)))))
+
+	if indexed {
+		recentHandler := handlers.NewRecentHandler(indexes)
+		handle("GET", cartBasePath+"/user/{userID}/recent", handlers.ErrorHandler(recentHandler.Recent))
+	}
+
+	listsHandler := handlers.NewListsHandler(store, idGenerator)
+	handle("POST", cartBasePath+"/user/{userID}/lists", handlers.ErrorHandler(jsonBody(listsHandler.Create)))
+	handle("GET", cartBasePath+"/user/{userID}/lists", handlers.ErrorHandler(listsHandler.List))
+
+	// serves GET /share/{token}, /{id}/diff, /{id}/export, /{id}/summary,
+	// /{id}/history and /{id}/eta
+	exportHandler := handlers.NewExportHandler(carts)
+	summaryHandler := handlers.NewSummaryHandler(summarizer)
+	historyHandler := handlers.NewHistoryHandler(historian)
+	subresources := handlers.NewSubresourceRouter(getShared).
+		Register("export", cartIDs.Require(exportHandler.Export)).
+		Register("summary", cartIDs.Require(summaryHandler.Summary)).
+		Register("history", cartIDs.Require(historyHandler.History))
+	if indexed {
+		diffHandler := handlers.NewDiffHandler(indexes)
+		subresources.Register("diff", cartIDs.Require(diffHandler.Diff))
+	}
+	if cfg.ETAEnabled() {
+		etaHandler := handlers.NewETAHandler(carts, handlers.PerItemETA{
+			Base:           cfg.ETABase,
+			PerItem:        cfg.ETAPerItem,
+			MaxPreparation: cfg.ETAMaxPreparation,
+			Delivery:       cfg.ETADelivery,
+		})
+		subresources.Register("eta", cartIDs.Require(etaHandler.ETA))
+	}
+	handle("GET", cartBasePath+"/{id}/{resource}", handlers.ErrorHandler(subresources.Handle))
+
+	eventsHandler := handlers.NewEventsHandler(carts, watcher, cfg.EventsHeartbeat)
+	eventsRoute := "GET " + cartBasePath + "/{id}/events"
+	handle("GET", cartBasePath+"/{id}/events", handlers.ErrorHandler(cartIDs.Require(eventsHandler.Events)))
+
+	capabilitiesHandler := handlers.NewCapabilitiesHandler(cfg.Capabilities())
+	handle("GET", basePath+"/api/v1/capabilities", handlers.ErrorHandler(capabilitiesHandler.Get))
+
+	couponHandler := handlers.NewCouponHandler(couponCarts, couponFinder)
+	handle("GET", basePath+"/api/v1/coupons/{code}/validate", handlers.ErrorHandler(handlers.RequireFlag(featureFlags, flags.Coupons, couponHandler.Validate)))
+	handle("POST", cartBasePath+"/{id}/coupons", handlers.ErrorHandler(handlers.RequireFlag(featureFlags, flags.Coupons, cartIDs.Require(mutation(jsonBody(couponHandler.Apply))))))
+	handle("DELETE", cartBasePath+"/{id}/coupons/{code}", handlers.ErrorHandler(handlers.RequireFlag(featureFlags, flags.Coupons, cartIDs.Require(mutation(couponHandler.Remove)))))
+
+	if indexed {
+		reservationHandler := handlers.NewReservationHandler(indexes)
+		handle("GET", basePath+"/api/v1/reservations/{productID}", handlers.ErrorHandler(reservationHandler.Get))
+	}
+
+	diagnosticsHandler := handlers.NewDiagnosticsHandler(indexes, lagChecker, orderCompletedHandler, msgReciever, archive)
+	handle("GET", basePath+"/api/v1/admin/diagnostics", handlers.ErrorHandler(diagnosticsHandler.Get))
+
+	consumerHandler := handlers.NewConsumerHandler(msgReciever)
+	handle("POST", basePath+"/api/v1/admin/consumer/pause", handlers.ErrorHandler(consumerHandler.Pause))
+	handle("POST", basePath+"/api/v1/admin/consumer/resume", handlers.ErrorHandler(consumerHandler.Resume))
+
+	flagsHandler := handlers.NewFlagsHandler(featureFlags)
+	handle("GET", basePath+"/api/v1/admin/flags", handlers.ErrorHandler(flagsHandler.Get))
+
+	adminHandler := handlers.NewAdminHandler(store)
+	adminBasePath := basePath + "/api/v1/admin/carts"
+	handle("POST", adminBasePath+"/recompute", handlers.ErrorHandler(adminHandler.RecomputeTotals))
+	handle("POST", adminBasePath+"/{id}/recompute", handlers.ErrorHandler(cartIDs.Require(adminHandler.RecomputeTotal)))
+
+	batchItemHandler := handlers.NewBatchItemHandler(store)
+	handle("POST", adminBasePath+":addItem", handlers.ErrorHandler(batchItemHandler.AddItem))
+
+	countHandler := handlers.NewCountHandler(counter)
+	handle("GET", adminBasePath+"/count", handlers.ErrorHandler(countHandler.Count))
+
+	if cfg.LoadTestEndpoints {
+		bulkCreateHandler := handlers.NewBulkCreateHandler(store, idGenerator)
+		handle("POST", adminBasePath+":bulkCreate", handlers.ErrorHandler(bulkCreateHandler.BulkCreate))
+	}
+
+	clientIPResolver, err := handlers.NewClientIPResolver(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid trusted proxies")
+	}
+
+	var server http.Handler = clientIPResolver.Middleware(handlers.ActorMiddleware(handlers.TenantMiddleware(handlers.OptionsMiddleware(router))))
+	if cfg.MaxPathSegment > 0 {
+		server = handlers.PathLengthMiddleware(cfg.MaxPathSegment, server)
+	}
+	routeTimeouts := handlers.RouteTimeouts{Router: router, Default: cfg.RequestTimeout, Routes: map[string]time.Duration{}}
+	// streams last until the client leaves, the default deadline would cut them
+	routeTimeouts.Routes[eventsRoute] = 0
+	for route, timeout := range cfg.RouteTimeouts {
+		method, path, _ := strings.Cut(route, " ")
+		routeTimeouts.Routes[method+" "+basePath+path] = timeout
+	}
+	if cfg.MaxRequestTimeout > 0 || cfg.RequestTimeout > 0 || len(cfg.RouteTimeouts) > 0 {
+		server = handlers.RouteDeadlineMiddleware(routeTimeouts, cfg.MaxRequestTimeout, server)
+	}
+	if cfg.MaxInFlight > 0 {
+		limiter := handlers.NewConcurrencyLimiter(cfg.MaxInFlight).Exempt(router, eventsRoute)
+		if err := limiter.Observe(); err != nil {
+			log.Error().Err(err).Msg("Error registering in-flight requests metric")
+		}
+		server = limiter.Middleware(server)
+	}
+	if cfg.ProblemJSON {
+		server = handlers.ProblemMiddleware(server)
+	}
+	if cfg.EnvelopeResponses {
+		server = handlers.EnvelopeMiddleware(server)
+	}
+
+	otelRouter := otelhttp.NewHandler(server, "server",
 		otelhttp.WithMessageEvents(otelhttp.ReadEvents, otelhttp.WriteEvents),
 	)
 
-	log.Info().Msg("Starting server on port 8080...")
-	log.Fatal().Err(http.ListenAndServe(":5200", otelRouter))
+	httpServer := &http.Server{Addr: ":5200", Handler: otelRouter}
+	httpServer.RegisterOnShutdown(eventsHandler.Close)
+	go func() {
+		log.Info().Msg("Starting server on port 8080...")
+		if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("Error serving http")
+		}
+	}()
+
+	signals, stopSignals := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
+	<-signals.Done()
+	log.Info().Msg("Shutting down...")
+
+	// requests and messages in flight still use redis, so it is closed only
+	// after they are drained and buffered carts are flushed
+	sequence := shutdown.Sequence{
+		{Name: "servers", Timeout: cfg.ShutdownTimeout, Stop: func(ctx context.Context) error {
+			grpcStopped := make(chan struct{})
+			go func() {
+				grpcSrv.GracefulStop()
+				close(grpcStopped)
+			}()
+			err := httpServer.Shutdown(ctx)
+			select {
+			case <-grpcStopped:
+			case <-ctx.Done():
+				grpcSrv.Stop()
+			}
+			return err
+		}},
+		{Name: "consumers", Timeout: cfg.ShutdownTimeout, Stop: func(ctx context.Context) error {
+			stopConsuming()
+			drained := make(chan struct{})
+			go func() {
+				consuming.Wait()
+				close(drained)
+			}()
+			// groups are closed on timeout too, abandoning stuck messages
+			select {
+			case <-drained:
+			case <-ctx.Done():
+			}
+			var errs []error
+			for _, c := range kafkaClosers {
+				errs = append(errs, c.Close())
+			}
+			return errors.Join(errs...)
+		}},
+		{Name: "carts", Timeout: cfg.ShutdownTimeout, Stop: flushCarts},
+		{Name: "redis", Timeout: cfg.ShutdownTimeout, Stop: func(ctx context.Context) error {
+			var errs []error
+			for _, client := range redisClients {
+				errs = append(errs, client.Close())
+			}
+			return errors.Join(errs...)
+		}},
+		{Name: "telemetry", Timeout: cfg.ShutdownTimeout, Stop: closeOTEL},
+	}
+	if err := sequence.Run(context.Background()); err != nil {
+		log.Error().Err(err).Msg("Error shutting down")
+		os.Exit(1)
+	}
 }
 
-func grpcServer(svc pbv1.CartServiceServer) {
+// grpcServer starts serving svc on port 8081 and returns the server
+func grpcServer(svc pbv1.CartServiceServer) *grpc.Server {
 	lis, err := net.Listen("tcp", ":8081")
 	if err != nil {
 		log.Fatal().Err(err)
@@ -132,19 +638,12 @@ func grpcServer(svc pbv1.CartServiceServer) {
 	pbv1.RegisterCartServiceServer(server, svc)
 
 	log.Info().Msg("Starting gRPC server on port 8081...")
-	if err := server.Serve(lis); err != nil {
-		log.Fatal().Err(err)
-	}
-}
-
-func handleSigterm() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGTERM)
 	go func() {
-		<-c
-		time.Sleep(10 * time.Second)
-		os.Exit(0)
+		if err := server.Serve(lis); err != nil {
+			log.Fatal().Err(err)
+		}
 	}()
+	return server
 }
 
 func initTracer(ctx context.Context) (*sdktrace.TracerProvider, error) {
@@ -170,10 +669,10 @@ func initTracer(ctx context.Context) (*sdktrace.TracerProvider, error) {
 	if otelExportEndpoint == "" {
 		otelExportEndpoint = "localhost:4317"
 	}
-	conn, err := grpc.DialContext(ctx, otelExportEndpoint,
+	// connects lazily, so an unreachable collector does not hold up startup
+	conn, err := grpc.NewClient(otelExportEndpoint,
 		// Note the use of insecure transport here. TLS is recommended in production.
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
@@ -219,6 +718,20 @@ func initMeter(ctx context.Context) (*sdkmetric.MeterProvider, error) {
 	return mp, nil
 }
 
+// replayOffsets resolves offsets of the orders topic for an RFC3339 timestamp
+func replayOffsets(cfg *config.Configuration, kafkaConfig *sarama.Config, since string) (map[int32]int64, error) {
+	ts, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replay timestamp: %w", err)
+	}
+	client, err := sarama.NewClient([]string{cfg.KafkaBroker}, kafkaConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return reciever.ResolveOffsets(client, cfg.OrdersTopic, ts)
+}
+
 func initRedis(redisHost string) (*redis.Client, error) {
 	if redisHost == "" {
 		redisHost = ":6379"
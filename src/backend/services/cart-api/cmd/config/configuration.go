@@ -1,15 +1,263 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/flags"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/rs/zerolog/log"
+)
+
+// Price sources supported by PRICE_SOURCE
+const (
+	PriceSourceClient  = "client"
+	PriceSourceCatalog = "catalog"
 )
 
 // Configuration injects all environment variables into object
 type Configuration struct {
 	ServerPort  string
 	RedisHost   string
+
+	// RedisReadHost is a read-only replica serving cart reads, empty reads
+	// from RedisHost
+	RedisReadHost string
+
+	// RedisShards are redis hosts carts are spread across by consistent
+	// hashing of cart ids, empty keeps every cart on RedisHost. Data spanning
+	// carts stays on RedisHost
+	RedisShards []string
 	KafkaBroker string
 	OrdersTopic string
+
+	// PricesTopic carries menu events, PriceChanged repricing items in carts
+	// and ItemDiscontinued removing them, empty disables the consumer
+	PricesTopic string
+
+	// CartEventsTopic receives CartUpdated events of carts changed by menu
+	// events, empty publishes none
+	CartEventsTopic string
+
+	// EventCodec is a format of kafka events, json or protobuf
+	EventCodec string
+
+	// PriceSource decides who is trusted for line item prices, the client
+	// sending the request or the catalog api
+	PriceSource string
+	CatalogURL  string
+
+	// RedisBreakerFailures consecutive redis failures open the circuit
+	// breaker for RedisBreakerOpenTimeout
+	RedisBreakerFailures    int
+	RedisBreakerOpenTimeout time.Duration
+
+	// ShareTTL is a lifetime of shared read-only cart snapshots
+	ShareTTL time.Duration
+
+	// TraceCartID records cart.id attribute on request spans
+	TraceCartID bool
+
+	// MaxItemPrice and MaxCartTotal reject item mutations above them in major
+	// units of the currency of the cart, zero disables the limit
+	MaxItemPrice float64
+	MaxCartTotal float64
+
+	// ShippingFee, ServiceFee and PackagingFee are charged to every cart with
+	// items in the breakdown of its total in major units of the currency of
+	// the cart, zero fees are left out
+	ShippingFee  float64
+	ServiceFee   float64
+	PackagingFee float64
+
+	// ETABase and ETAPerItem for every unit of a cart, capped at
+	// ETAMaxPreparation when set, estimate preparation of its order which is
+	// delivered in ETADelivery. GET /cart/{id}/eta is served when ETABase or
+	// ETAPerItem is set
+	ETABase           time.Duration
+	ETAPerItem        time.Duration
+	ETAMaxPreparation time.Duration
+	ETADelivery       time.Duration
+
+	// MinOrderValue is the least total of items a cart is checked out with,
+	// MinOrderValues overrides it per currency, e.g. {"JPY": 2000}. Zero
+	// disables the check
+	MinOrderValue  float64
+	MinOrderValues map[string]float64
+
+	// RedisKeyPrefix namespaces every redis key, e.g. cart:
+	RedisKeyPrefix string
+
+	// CartTTL expires carts not changed or touched for the duration, zero
+	// keeps carts forever
+	CartTTL time.Duration
+
+	// WriteBehindWindow coalesces writes of a cart within the window into one,
+	// zero writes every mutation immediately
+	WriteBehindWindow time.Duration
+
+	// CartVersions is a number of recent versions kept per cart for diffs,
+	// zero disables history
+	CartVersions int
+
+	// CartHistory is a number of audit entries kept per cart, zero disables
+	// the audit log
+	CartHistory int
+
+	// CartEventSourcing stores carts as redis streams of item events folded
+	// on read, snapshotted every CartSnapshotEvery events. The stream is the
+	// history of the cart, side indexes such as versions, shared carts,
+	// recent carts and reservations are not maintained and their routes are
+	// not served. Can't be combined with RedisShards or ArchiveRetention
+	CartEventSourcing bool
+	CartSnapshotEvery int
+
+	// ArchiveRetention keeps completed and cancelled carts for the duration,
+	// deleted by a sweeper running every ArchiveSweepInterval. Zero disables
+	// the archive, such carts expire with CartTTL
+	ArchiveRetention     time.Duration
+	ArchiveSweepInterval time.Duration
+
+	// EventsHeartbeat is the interval of comments sent on idle streams of
+	// GET /cart/{id}/events so proxies don't close them, zero sends none.
+	// Streams are left out of MaxInFlight and get no deadline unless
+	// RouteTimeouts has one for the route
+	EventsHeartbeat time.Duration
+
+	// ReservationTTL soft reserves quantity of items in carts for the duration,
+	// zero disables reservations
+	ReservationTTL time.Duration
+
+	// CartIDGenerator generates ids of created carts, uuidv4 or uuidv7
+	CartIDGenerator string
+
+	// ItemIDStrategy assigns ids of added line items, product uses the
+	// product id and sequence numbers lines per cart
+	ItemIDStrategy string
+
+	// CartCodec is a format of carts stored in redis, json or msgpack
+	CartCodec string
+
+	// ClampQuantity removes an item when quantity delta goes below zero
+	// instead of rejecting the request
+	ClampQuantity bool
+
+	// ItemMaxQuantities caps quantity per order by product id, read from
+	// ITEM_MAX_QUANTITIES as json object e.g. {"42": 2}
+	ItemMaxQuantities map[int]int
+
+	// DefaultQuantity is used for items added without quantity, zero rejects
+	// such items
+	DefaultQuantity int
+
+	// MaxDecompressedBody limits size in bytes of gzip request bodies after
+	// decoding
+	MaxDecompressedBody int64
+
+	// TrustedProxies are CIDRs whose X-Forwarded-For is honored when
+	// resolving client ip, comma separated in TRUSTED_PROXIES
+	TrustedProxies []string
+
+	// KafkaMaxBackoff caps the delay between reconnect attempts of the consumer
+	KafkaMaxBackoff time.Duration
+
+	// KafkaWorkers handles up to that many messages of a partition at once,
+	// one keeps messages strictly ordered
+	KafkaWorkers int
+
+	// KafkaLagInterval is how often consumer group lag is computed for the
+	// kafka.consumer.lag metric
+	KafkaLagInterval time.Duration
+
+	// Snapshot* address an S3 compatible bucket receiving a copy of every
+	// checked out cart, empty SnapshotBucket disables snapshots
+	SnapshotEndpoint  string
+	SnapshotBucket    string
+	SnapshotRegion    string
+	SnapshotAccessKey string
+	SnapshotSecretKey string
+
+	// ProblemJSON writes every error as application/problem+json, otherwise
+	// only clients accepting it get problem details
+	ProblemJSON bool
+
+	// EnvelopeResponses wraps every successful response as {"data", "meta"},
+	// otherwise only clients asking with Accept: application/json; envelope=true
+	EnvelopeResponses bool
+
+	// RedisSlowThreshold logs redis commands taking longer than it, zero
+	// disables the slow log
+	RedisSlowThreshold time.Duration
+
+	// ClampStock reduces quantity of added items to available stock instead
+	// of rejecting them with 409
+	ClampStock bool
+
+	// MaxRequestTimeout caps deadlines callers set with X-Timeout-Ms, zero
+	// ignores the header
+	MaxRequestTimeout time.Duration
+
+	// RequestTimeout is the deadline of requests without X-Timeout-Ms, zero
+	// leaves them without deadline
+	RequestTimeout time.Duration
+
+	// RouteTimeouts override RequestTimeout by route, read from ROUTE_TIMEOUTS
+	// as a json object from route to duration where routes are method and
+	// path as registered without BASE_PATH, e.g.
+	// {"POST /api/v1/cart/{id}/items": "20s", "GET /api/v1/cart/{id}": "2s"}
+	RouteTimeouts map[string]time.Duration
+
+	// MaxInFlight caps simultaneous requests, above it requests get 503,
+	// zero disables the limit
+	MaxInFlight int
+
+	// CartRateLimit caps mutations of a single cart per CartRateWindow,
+	// above it requests get 429, zero disables the limit
+	CartRateLimit  int
+	CartRateWindow time.Duration
+
+	// MaxPathSegment caps length of path segments such as cart and user ids,
+	// longer ones get 400, 0 disables the check
+	MaxPathSegment int
+
+	// ShutdownTimeout bounds every stage of the shutdown, e.g. draining
+	// in-flight requests or consumed messages
+	ShutdownTimeout time.Duration
+
+	// TransferReplaceActive lets a cart transfer to a user having another
+	// active cart, otherwise such transfers are rejected with 409
+	TransferReplaceActive bool
+
+	// SeedFile is a json array of carts imported at startup for local
+	// development, carts already in redis are skipped
+	SeedFile string
+
+	// LoadTestEndpoints serves admin endpoints for load tests, e.g. bulk
+	// creation of carts. It must stay disabled in production
+	LoadTestEndpoints bool
+
+	// JSONEncoder encodes response bodies, std or jsoniter
+	JSONEncoder string
+
+	// JSONPretty indents response bodies for local debugging, production
+	// keeps them compact
+	JSONPretty bool
+
+	// JSONFieldCase is casing of response fields, snake or camel, empty keeps
+	// the json tags. Clients may ask for either one with the case parameter
+	// of Accept
+	JSONFieldCase string
+
+	// Flags are feature flags read from FLAG_<NAME>, e.g. FLAG_COUPONS=false
+	// or FLAG_TAX=false, unset flags keep their defaults
+	Flags map[string]bool
+
+	// Coupons are read from COUPONS as json array of models.Coupon
+	Coupons []models.Coupon
 }
 
 // Init initializes environment variables into config
@@ -20,6 +268,9 @@ func Init() *Configuration {
 		cfg.RedisHost = redisHost
 	}
 
+	cfg.RedisReadHost = lookupString("REDIS_READ_HOST", "")
+	cfg.RedisShards = lookupList("REDIS_SHARDS")
+
 	if kafkaBroker, ok := os.LookupEnv("KAFKA_BROKER"); ok {
 		cfg.KafkaBroker = kafkaBroker
 	}
@@ -28,5 +279,244 @@ func Init() *Configuration {
 		cfg.OrdersTopic = ordersTopic
 	}
 
+	cfg.PricesTopic = lookupString("PRICES_TOPIC", "")
+	cfg.CartEventsTopic = lookupString("CART_EVENTS_TOPIC", "")
+	cfg.EventCodec = lookupString("EVENT_CODEC", "json")
+
+	cfg.PriceSource = PriceSourceClient
+	if priceSource, ok := os.LookupEnv("PRICE_SOURCE"); ok {
+		cfg.PriceSource = priceSource
+	}
+
+	if catalogURL, ok := os.LookupEnv("CATALOG_URL"); ok {
+		cfg.CatalogURL = catalogURL
+	}
+
+	cfg.RedisBreakerFailures = lookupInt("REDIS_BREAKER_FAILURES", 5)
+	cfg.RedisBreakerOpenTimeout = lookupDuration("REDIS_BREAKER_OPEN_TIMEOUT", 10*time.Second)
+
+	cfg.ShareTTL = lookupDuration("SHARE_TTL", 24*time.Hour)
+	cfg.TraceCartID = lookupBool("TRACE_CART_ID", true)
+	cfg.MaxItemPrice = lookupFloat("MAX_ITEM_PRICE", 0)
+	cfg.MaxCartTotal = lookupFloat("MAX_CART_TOTAL", 0)
+	cfg.ShippingFee = lookupFloat("SHIPPING_FEE", 0)
+	cfg.ServiceFee = lookupFloat("SERVICE_FEE", 0)
+	cfg.PackagingFee = lookupFloat("PACKAGING_FEE", 0)
+	cfg.ETABase = lookupDuration("ETA_BASE", 0)
+	cfg.ETAPerItem = lookupDuration("ETA_PER_ITEM", 0)
+	cfg.ETAMaxPreparation = lookupDuration("ETA_MAX_PREPARATION", 0)
+	cfg.ETADelivery = lookupDuration("ETA_DELIVERY", 0)
+	cfg.MinOrderValue = lookupFloat("MIN_ORDER_VALUE", 0)
+	cfg.MinOrderValues = lookupFloatMap("MIN_ORDER_VALUES")
+	cfg.CartCodec = lookupString("CART_CODEC", "json")
+	cfg.CartIDGenerator = lookupString("CART_ID_GENERATOR", "uuidv4")
+	cfg.ItemIDStrategy = lookupString("ITEM_ID_STRATEGY", "product")
+	cfg.RedisKeyPrefix = lookupString("REDIS_KEY_PREFIX", "")
+	cfg.CartTTL = lookupDuration("CART_TTL", 0)
+	cfg.ReservationTTL = lookupDuration("RESERVATION_TTL", 0)
+	cfg.ArchiveRetention = lookupDuration("CART_ARCHIVE_RETENTION", 0)
+	cfg.ArchiveSweepInterval = lookupDuration("CART_ARCHIVE_SWEEP_INTERVAL", time.Minute)
+	cfg.EventsHeartbeat = lookupDuration("CART_EVENTS_HEARTBEAT", 15*time.Second)
+	cfg.CartVersions = lookupInt("CART_VERSIONS", 20)
+	cfg.CartHistory = lookupInt("CART_HISTORY", 100)
+	cfg.CartEventSourcing = lookupBool("CART_EVENT_SOURCING", false)
+	cfg.CartSnapshotEvery = lookupInt("CART_SNAPSHOT_EVERY", 50)
+	cfg.WriteBehindWindow = lookupDuration("WRITE_BEHIND_WINDOW", 0)
+	cfg.ClampQuantity = lookupBool("CLAMP_QUANTITY", true)
+	cfg.ItemMaxQuantities = lookupIntMap("ITEM_MAX_QUANTITIES")
+	cfg.DefaultQuantity = lookupInt("DEFAULT_QUANTITY", 1)
+	cfg.MaxDecompressedBody = int64(lookupInt("MAX_DECOMPRESSED_BODY", 1<<20))
+	cfg.TrustedProxies = lookupList("TRUSTED_PROXIES")
+	cfg.KafkaMaxBackoff = lookupDuration("KAFKA_MAX_BACKOFF", reciever.DefaultMaxBackoff)
+	cfg.KafkaWorkers = lookupInt("KAFKA_WORKERS", 1)
+	cfg.KafkaLagInterval = lookupDuration("KAFKA_LAG_INTERVAL", 30*time.Second)
+	cfg.SnapshotEndpoint = lookupString("SNAPSHOT_ENDPOINT", "https://s3.amazonaws.com")
+	cfg.SnapshotBucket = lookupString("SNAPSHOT_BUCKET", "")
+	cfg.SnapshotRegion = lookupString("SNAPSHOT_REGION", "us-east-1")
+	cfg.SnapshotAccessKey = lookupString("SNAPSHOT_ACCESS_KEY", "")
+	cfg.SnapshotSecretKey = lookupString("SNAPSHOT_SECRET_KEY", "")
+	cfg.ProblemJSON = lookupBool("PROBLEM_JSON", false)
+	cfg.EnvelopeResponses = lookupBool("ENVELOPE_RESPONSES", false)
+	cfg.RedisSlowThreshold = lookupDuration("REDIS_SLOW_THRESHOLD", 100*time.Millisecond)
+	cfg.ClampStock = lookupBool("CLAMP_STOCK", true)
+	cfg.Coupons = lookupCoupons("COUPONS")
+	cfg.Flags = lookupFlags()
+	cfg.MaxInFlight = lookupInt("MAX_IN_FLIGHT", 0)
+	cfg.CartRateLimit = lookupInt("CART_RATE_LIMIT", 0)
+	cfg.CartRateWindow = lookupDuration("CART_RATE_WINDOW", time.Second)
+	cfg.MaxPathSegment = lookupInt("MAX_PATH_SEGMENT", 128)
+	cfg.ShutdownTimeout = lookupDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
+	cfg.MaxRequestTimeout = lookupDuration("MAX_REQUEST_TIMEOUT", 30*time.Second)
+	cfg.RequestTimeout = lookupDuration("REQUEST_TIMEOUT", 0)
+	cfg.RouteTimeouts = lookupDurationMap("ROUTE_TIMEOUTS")
+	cfg.SeedFile = lookupString("SEED_FILE", "")
+	cfg.LoadTestEndpoints = lookupBool("LOAD_TEST_ENDPOINTS", false)
+	cfg.JSONEncoder = lookupString("JSON_ENCODER", "std")
+	cfg.JSONPretty = lookupBool("JSON_PRETTY", false)
+	cfg.JSONFieldCase = lookupString("JSON_FIELD_CASE", "")
+	cfg.TransferReplaceActive = lookupBool("TRANSFER_REPLACE_ACTIVE", false)
+
 	return &cfg
 }
+
+// Capabilities describes enabled features for clients
+func (c *Configuration) Capabilities() models.Capabilities {
+	return models.Capabilities{
+		PriceSource:  c.PriceSource,
+		Sharing:      !c.CartEventSourcing,
+		ShareTTL:     int64(c.ShareTTL.Seconds()),
+		MaxItemPrice: c.MaxItemPrice,
+		MaxCartTotal: c.MaxCartTotal,
+		ETA:          c.ETAEnabled(),
+	}
+}
+
+// ETAEnabled reports whether delivery estimates are served
+func (c *Configuration) ETAEnabled() bool {
+	return c.ETABase > 0 || c.ETAPerItem > 0
+}
+
+func lookupString(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func lookupInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid integer, using default")
+		return fallback
+	}
+	return n
+}
+
+func lookupFloat(key string, fallback float64) float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid number, using default")
+		return fallback
+	}
+	return f
+}
+
+func lookupBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid boolean, using default")
+		return fallback
+	}
+	return b
+}
+
+func lookupDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid duration, using default")
+		return fallback
+	}
+	return d
+}
+
+func lookupIntMap(key string) map[int]int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	var m map[int]int
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid json object, ignoring")
+		return nil
+	}
+	return m
+}
+
+func lookupFloatMap(key string) map[string]float64 {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	var m map[string]float64
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid json object, ignoring")
+		return nil
+	}
+	return m
+}
+
+func lookupDurationMap(key string) map[string]time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid json object, ignoring")
+		return nil
+	}
+	m := make(map[string]time.Duration, len(raw))
+	for name, v := range raw {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Warn().Err(err).Str("key", key).Str("name", name).Msg("invalid duration, ignoring")
+			continue
+		}
+		m[name] = d
+	}
+	return m
+}
+
+func lookupList(key string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func lookupCoupons(key string) []models.Coupon {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return nil
+	}
+	var coupons []models.Coupon
+	if err := json.Unmarshal([]byte(value), &coupons); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("invalid json array, ignoring")
+		return nil
+	}
+	return coupons
+}
+
+func lookupFlags() map[string]bool {
+	values := map[string]bool{}
+	for _, name := range flags.Names() {
+		key := "FLAG_" + strings.ToUpper(name)
+		if _, ok := os.LookupEnv(key); ok {
+			values[name] = lookupBool(key, flags.Defaults[name])
+		}
+	}
+	return values
+}
@@ -0,0 +1,62 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Client reads catalog items from catalog-api
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates catalog-api client, baseURL should point to the catalog
+// api root e.g. http://catalog-api:8000/catalog
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+type catalogItem struct {
+	ID       int          `json:"id"`
+	Price    models.Money `json:"price"`
+	Currency string       `json:"currency"`
+}
+
+// GetPrice implements handlers.PriceProvider.
+func (c *Client) GetPrice(ctx context.Context, productID int) (models.Money, error) {
+	url := fmt.Sprintf("%s/items/%d", c.baseURL, productID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return models.Money{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return models.Money{}, fmt.Errorf("error getting catalog item %d: %w", productID, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return models.Money{}, fmt.Errorf("catalog item %d: %w", productID, models.ErrPriceNotFound)
+	}
+	if res.StatusCode != http.StatusOK {
+		return models.Money{}, fmt.Errorf("error getting catalog item %d: unexpected status %d", productID, res.StatusCode)
+	}
+
+	var item catalogItem
+	if err := json.NewDecoder(res.Body).Decode(&item); err != nil {
+		return models.Money{}, fmt.Errorf("error decoding catalog item %d: %w", productID, err)
+	}
+	return item.Price, nil
+}
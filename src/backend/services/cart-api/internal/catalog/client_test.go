@@ -0,0 +1,34 @@
+package catalog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientGetPrice(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /catalog/items/1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":1,"name":"Plov","price":12.5,"currency":"USD"}`))
+	})
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	client := NewClient(svr.URL + "/catalog/")
+
+	t.Run("given existing item should return its price", func(t *testing.T) {
+		price, err := client.GetPrice(context.Background(), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 1250}, price)
+	})
+
+	t.Run("given missing item should return ErrPriceNotFound", func(t *testing.T) {
+		_, err := client.GetPrice(context.Background(), 2)
+		assert.ErrorIs(t, err, models.ErrPriceNotFound)
+	})
+}
@@ -0,0 +1,210 @@
+// Package coupons evaluates coupons against carts
+package coupons
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+var (
+	ErrCouponNotFound = errors.New("coupon not found")
+	ErrCannotStack    = errors.New("coupon can't be stacked with applied coupons")
+
+	// coupons which give the cart no discount violate business rules
+	ErrCouponExpired error = models.NewBusinessRule("coupon_expired", "coupon expired")
+	ErrBelowMinSpend error = models.NewBusinessRule("below_min_spend", "cart is below minimum spend of the coupon")
+	ErrNotApplicable error = models.NewBusinessRule("coupon_not_applicable", "coupon does not apply to any item of the cart")
+	ErrCurrency      error = models.NewBusinessRule("coupon_currency_mismatch", "coupon is in another currency than the cart")
+)
+
+// Static is a fixed set of coupons keyed by case insensitive code
+type Static map[string]models.Coupon
+
+// NewStatic indexes coupons by code
+func NewStatic(coupons []models.Coupon) Static {
+	s := make(Static, len(coupons))
+	for _, c := range coupons {
+		s[strings.ToUpper(c.Code)] = c
+	}
+	return s
+}
+
+// Coupon returns coupon by code, ErrCouponNotFound when it is unknown
+func (s Static) Coupon(ctx context.Context, code string) (models.Coupon, error) {
+	c, ok := s[strings.ToUpper(code)]
+	if !ok {
+		return models.Coupon{}, fmt.Errorf("%w: %s", ErrCouponNotFound, code)
+	}
+	return c, nil
+}
+
+// Discount returns discount the coupon gives to the cart at now, it is never
+// more than subtotal of the items the coupon applies to
+func Discount(c models.Coupon, cart *models.Cart, now time.Time) (models.Money, error) {
+	remaining, err := lineTotals(cart)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return discount(c, cart, remaining, now)
+}
+
+// Stack returns discounts of coupons applied together to the cart in order
+// of their precedence: higher Priority first, ties keep the order of applied.
+// Every coupon discounts what earlier ones left of the items it applies to,
+// so the order changes the total when percentage and fixed amounts mix
+func Stack(applied []models.Coupon, cart *models.Cart, now time.Time) []models.AppliedDiscount {
+	ordered := append([]models.Coupon(nil), applied...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority > ordered[j].Priority
+	})
+
+	remaining, totalsErr := lineTotals(cart)
+	total := cart.Total
+	discounts := make([]models.AppliedDiscount, 0, len(ordered))
+	for _, c := range ordered {
+		result := models.AppliedDiscount{Code: c.Code}
+		amount, err := models.Money{}, totalsErr
+		if err == nil {
+			amount, err = discount(c, cart, remaining, now)
+		}
+		if err != nil {
+			result.Reason = err.Error()
+		} else {
+			result.Amount = amount
+			total = total.Sub(amount)
+		}
+		result.Total = total
+		discounts = append(discounts, result)
+	}
+	return discounts
+}
+
+// CheckStack returns ErrCannotStack when the coupon can't be applied to a
+// cart having applied coupons, see models.Coupon for the rules
+func CheckStack(applied []models.Coupon, c models.Coupon) error {
+	for _, other := range applied {
+		switch {
+		case strings.EqualFold(other.Code, c.Code):
+			return fmt.Errorf("%w: %s is applied already", ErrCannotStack, c.Code)
+		case c.Exclusive || other.Exclusive:
+			return fmt.Errorf("%w: %s is exclusive of %s", ErrCannotStack, c.Code, other.Code)
+		case stackGroup(c) == stackGroup(other):
+			return fmt.Errorf("%w: %s and %s are both %s coupons", ErrCannotStack, c.Code, other.Code, stackGroup(c))
+		}
+	}
+	return nil
+}
+
+// stackGroup returns group of the coupon, by default percentage coupons
+// make one group and fixed amount coupons another
+func stackGroup(c models.Coupon) string {
+	switch {
+	case c.StackGroup != "":
+		return c.StackGroup
+	case c.PercentOff != 0:
+		return "percentage"
+	default:
+		return "fixed amount"
+	}
+}
+
+// lineTotals returns price of every line of the cart
+func lineTotals(cart *models.Cart) ([]models.Money, error) {
+	totals := make([]models.Money, len(cart.LineItems))
+	for i, item := range cart.LineItems {
+		total, err := item.UnitPrice.Mul(item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		totals[i] = total
+	}
+	return totals, nil
+}
+
+// discount returns discount the coupon gives to what remains of the lines
+// of the cart and takes it off remaining, line by line. The minimum spend is
+// checked against the subtotal before any discount
+func discount(c models.Coupon, cart *models.Cart, remaining []models.Money, now time.Time) (models.Money, error) {
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return models.Money{}, ErrCouponExpired
+	}
+	// percentages apply to carts in any currency, fixed amounts only to
+	// carts in their own
+	currency := cart.CurrencyOf()
+	for _, m := range []models.Money{c.AmountOff, c.MinSpend} {
+		if !m.IsZero() && m.Currency != currency {
+			return models.Money{}, fmt.Errorf("%w: coupon in %q, cart in %q", ErrCurrency, m.Currency, currency)
+		}
+	}
+
+	var prices, applicable, left []models.Money
+	for i, item := range cart.LineItems {
+		price, err := item.UnitPrice.Mul(item.Quantity)
+		if err != nil {
+			return models.Money{}, err
+		}
+		prices = append(prices, price)
+		if appliesTo(c, item.Product()) {
+			applicable = append(applicable, price)
+			left = append(left, remaining[i])
+		}
+	}
+	subtotal, err := models.Sum(prices...)
+	if err != nil {
+		return models.Money{}, err
+	}
+	if subtotal.Minor < c.MinSpend.Minor {
+		return models.Money{}, fmt.Errorf("%w %s", ErrBelowMinSpend, c.MinSpend)
+	}
+	applicableTotal, err := models.Sum(applicable...)
+	if err != nil {
+		return models.Money{}, err
+	}
+	if applicableTotal.IsZero() {
+		return models.Money{}, ErrNotApplicable
+	}
+
+	leftTotal, err := models.Sum(left...)
+	if err != nil {
+		return models.Money{}, err
+	}
+	percent, err := leftTotal.Percent(c.PercentOff)
+	if err != nil {
+		return models.Money{}, err
+	}
+	amount, err := models.Sum(percent, c.AmountOff)
+	if err != nil {
+		return models.Money{}, err
+	}
+	amount = amount.Min(leftTotal)
+	taken := amount
+	for i, item := range cart.LineItems {
+		if taken.IsZero() {
+			break
+		}
+		if appliesTo(c, item.Product()) {
+			part := taken.Min(remaining[i])
+			remaining[i] = remaining[i].Sub(part)
+			taken = taken.Sub(part)
+		}
+	}
+	return amount, nil
+}
+
+func appliesTo(c models.Coupon, productID int) bool {
+	if len(c.ProductIDs) == 0 {
+		return true
+	}
+	for _, id := range c.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
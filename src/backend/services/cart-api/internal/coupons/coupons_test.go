@@ -0,0 +1,161 @@
+package coupons
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscount(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	cart := &models.Cart{LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+		{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1},
+	}}
+
+	tests := []struct {
+		name   string
+		coupon models.Coupon
+		want   int64
+		err    error
+	}{
+		{"percent off", models.Coupon{PercentOff: 10}, 250, nil},
+		{"percent off rounds half up to cents", models.Coupon{PercentOff: 7.5}, 188, nil},
+		{"percent and amount off", models.Coupon{PercentOff: 10, AmountOff: models.Money{Minor: 10}}, 260, nil},
+		{"amount off", models.Coupon{AmountOff: models.Money{Minor: 300}}, 300, nil},
+		{"capped at subtotal", models.Coupon{AmountOff: models.Money{Minor: 10000}}, 2500, nil},
+		{"applicable products only", models.Coupon{PercentOff: 50, ProductIDs: []int{2}}, 250, nil},
+		{"no applicable product", models.Coupon{PercentOff: 50, ProductIDs: []int{3}}, 0, ErrNotApplicable},
+		{"expired", models.Coupon{PercentOff: 10, ExpiresAt: &yesterday}, 0, ErrCouponExpired},
+		{"below minimum spend", models.Coupon{PercentOff: 10, MinSpend: models.Money{Minor: 3000}}, 0, ErrBelowMinSpend},
+		{"amount off in another currency", models.Coupon{AmountOff: models.Money{Minor: 300, Currency: "JPY"}}, 0, ErrCurrency},
+		{"minimum spend in another currency", models.Coupon{PercentOff: 10, MinSpend: models.Money{Minor: 30, Currency: "JPY"}}, 0, ErrCurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Discount(tt.coupon, cart, now)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, models.Money{Minor: tt.want}, got)
+		})
+	}
+}
+
+func TestCouponJSON(t *testing.T) {
+	var c models.Coupon
+	assert.NoError(t, json.Unmarshal([]byte(`{"code":"YEN","amount_off":500,"min_spend":"3000","currency":"jpy"}`), &c))
+	assert.Equal(t, "JPY", c.Currency)
+	assert.Equal(t, models.Money{Minor: 500, Currency: "JPY"}, c.AmountOff)
+	assert.Equal(t, models.Money{Minor: 3000, Currency: "JPY"}, c.MinSpend)
+
+	jpy := "JPY"
+	cart := &models.Cart{Currency: &jpy, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 4000, Currency: jpy}, Quantity: 1}}}
+	got, err := Discount(c, cart, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, models.Money{Minor: 500, Currency: jpy}, got)
+
+	var named models.Coupon
+	assert.NoError(t, json.Unmarshal([]byte(`{"code":"EURO","amount_off":"5 EUR"}`), &named))
+	assert.Equal(t, "EUR", named.Currency)
+}
+
+func TestStatic(t *testing.T) {
+	s := NewStatic([]models.Coupon{{Code: "Spring10", PercentOff: 10}})
+
+	c, err := s.Coupon(context.Background(), "SPRING10")
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, c.PercentOff)
+
+	_, err = s.Coupon(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrCouponNotFound)
+}
+
+func TestStack(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	cart := &models.Cart{Total: models.Money{Minor: 2500}, LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+		{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1},
+	}}
+	percent := models.Coupon{Code: "TEN", PercentOff: 10}
+	fixed := models.Coupon{Code: "FIVE", AmountOff: models.Money{Minor: 500}}
+
+	type discount struct {
+		code          string
+		amount, total int64
+	}
+	tests := []struct {
+		name    string
+		applied []models.Coupon
+		want    []discount
+	}{
+		{"percentage then fixed", []models.Coupon{percent, fixed}, []discount{{"TEN", 250, 2250}, {"FIVE", 500, 1750}}},
+		{"applied order breaks ties", []models.Coupon{fixed, percent}, []discount{{"FIVE", 500, 2000}, {"TEN", 200, 1800}}},
+		{
+			"higher priority goes first",
+			[]models.Coupon{percent, {Code: "FIVE", AmountOff: models.Money{Minor: 500}, Priority: 1}},
+			[]discount{{"FIVE", 500, 2000}, {"TEN", 200, 1800}},
+		},
+		{
+			// the fixed amount takes all of the first line and some of the second
+			"later coupons discount what is left of their products",
+			[]models.Coupon{{Code: "BIG", AmountOff: models.Money{Minor: 2200}}, {Code: "HALF", PercentOff: 50, ProductIDs: []int{2}}},
+			[]discount{{"BIG", 2200, 300}, {"HALF", 150, 150}},
+		},
+		{
+			"nothing is left to discount",
+			[]models.Coupon{{Code: "ALL", AmountOff: models.Money{Minor: 5000}}, percent},
+			[]discount{{"ALL", 2500, 0}, {"TEN", 0, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []discount
+			for _, d := range Stack(tt.applied, cart, now) {
+				assert.Empty(t, d.Reason)
+				got = append(got, discount{d.Code, d.Amount.Minor, d.Total.Minor})
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("invalid coupons should give nothing", func(t *testing.T) {
+		expired := models.Coupon{Code: "OLD", PercentOff: 50, ExpiresAt: &yesterday}
+		got := Stack([]models.Coupon{expired, fixed}, cart, now)
+		assert.Equal(t, []models.AppliedDiscount{
+			{Code: "OLD", Total: models.Money{Minor: 2500}, Reason: ErrCouponExpired.Error()},
+			{Code: "FIVE", Amount: models.Money{Minor: 500}, Total: models.Money{Minor: 2000}},
+		}, got)
+	})
+}
+
+func TestCheckStack(t *testing.T) {
+	percent := models.Coupon{Code: "TEN", PercentOff: 10}
+	fixed := models.Coupon{Code: "FIVE", AmountOff: models.Money{Minor: 500}}
+
+	tests := []struct {
+		name    string
+		applied []models.Coupon
+		coupon  models.Coupon
+		err     error
+	}{
+		{"first coupon", nil, percent, nil},
+		{"percentage and fixed amount", []models.Coupon{percent}, fixed, nil},
+		{"different groups", []models.Coupon{percent}, models.Coupon{Code: "VIP", PercentOff: 5, StackGroup: "loyalty"}, nil},
+		{"two percentages", []models.Coupon{percent}, models.Coupon{Code: "TWENTY", PercentOff: 20}, ErrCannotStack},
+		{"two fixed amounts", []models.Coupon{fixed}, models.Coupon{Code: "ONE", AmountOff: models.Money{Minor: 100}}, ErrCannotStack},
+		{"same group", []models.Coupon{{Code: "SPRING", PercentOff: 10, StackGroup: "seasonal"}}, models.Coupon{Code: "EASTER", AmountOff: models.Money{Minor: 100}, StackGroup: "seasonal"}, ErrCannotStack},
+		{"exclusive coupon", []models.Coupon{percent}, models.Coupon{Code: "ONLY", AmountOff: models.Money{Minor: 100}, Exclusive: true}, ErrCannotStack},
+		{"applied exclusive coupon", []models.Coupon{{Code: "ONLY", PercentOff: 10, Exclusive: true}}, fixed, ErrCannotStack},
+		{"applied already", []models.Coupon{percent}, models.Coupon{Code: "ten", PercentOff: 10, StackGroup: "other"}, ErrCannotStack},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, CheckStack(tt.applied, tt.coupon), tt.err)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/jurabek/cart-api/pkg/breaker"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type circuitBreakerHook struct {
+	breaker *breaker.Breaker
+}
+
+// NewCircuitBreakerHook creates redis hook which short-circuits commands with
+// *breaker.OpenError while redis is considered unavailable
+func NewCircuitBreakerHook(b *breaker.Breaker) redis.Hook {
+	return &circuitBreakerHook{breaker: b}
+}
+
+func (h *circuitBreakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *circuitBreakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := h.breaker.Allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := next(ctx, cmd)
+		h.done(ctx, err)
+		return err
+	}
+}
+
+func (h *circuitBreakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := h.breaker.Allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err := next(ctx, cmds)
+		h.done(ctx, err)
+		return err
+	}
+}
+
+// done records the result of a command, errors caused by the caller's
+// context count neither way since clients choose their own deadlines, down
+// to a millisecond with X-Timeout-Ms, and could otherwise open the breaker
+func (h *circuitBreakerHook) done(ctx context.Context, err error) {
+	if err != nil && (ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		h.breaker.Cancel()
+		return
+	}
+	h.breaker.Done(isHealthy(err))
+}
+
+// isHealthy reports whether err still proves redis is reachable, replies
+// like redis.Nil or WRONGTYPE come from a working server
+func isHealthy(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return true
+	}
+	var redisErr redis.Error
+	return errors.As(err, &redisErr)
+}
+
+// ObserveCircuitBreaker exports breaker state as a gauge,
+// 0 - closed, 1 - half-open, 2 - open
+func ObserveCircuitBreaker(b *breaker.Breaker) error {
+	meter := otel.Meter("cart-api")
+	_, err := meter.Int64ObservableGauge("redis.circuit_breaker.state",
+		metric.WithDescription("State of redis circuit breaker: 0 closed, 1 half-open, 2 open"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(b.State()))
+			return nil
+		}),
+	)
+	return err
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jurabek/cart-api/pkg/breaker"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerHook(t *testing.T) {
+	// nothing listens on port 1, every dial fails immediately
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	b := breaker.New(2, time.Minute)
+	client.AddHook(NewCircuitBreakerHook(b))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		err := client.Get(ctx, "cart").Err()
+		var openErr *breaker.OpenError
+		assert.Error(t, err)
+		assert.False(t, errors.As(err, &openErr), "dial errors must reach redis before breaker opens")
+	}
+	assert.Equal(t, breaker.StateOpen, b.State())
+
+	err := client.Get(ctx, "cart").Err()
+	var openErr *breaker.OpenError
+	assert.ErrorAs(t, err, &openErr)
+}
+
+func TestIsHealthy(t *testing.T) {
+	assert.True(t, isHealthy(nil))
+	assert.True(t, isHealthy(redis.Nil))
+	assert.False(t, isHealthy(errors.New("dial tcp: connection refused")))
+}
+
+func TestCircuitBreakerHookCallerContext(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	b := breaker.New(2, time.Minute)
+	client.AddHook(NewCircuitBreakerHook(b))
+
+	// deadlines and cancellations of callers say nothing about redis
+	expired, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-expired.Done()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 5; i++ {
+		assert.Error(t, client.Get(expired, "cart").Err())
+		assert.Error(t, client.Get(cancelled, "cart").Err())
+	}
+	assert.Equal(t, breaker.StateClosed, b.State())
+}
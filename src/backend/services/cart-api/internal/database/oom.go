@@ -0,0 +1,18 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IsOutOfMemory reports whether err is the OOM reply redis sends to writes
+// once maxmemory is reached and nothing can be evicted
+func IsOutOfMemory(err error) bool {
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimPrefix(redisErr.Error(), "ERR "), "OOM ")
+}
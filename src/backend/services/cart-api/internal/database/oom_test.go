@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsOutOfMemory(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	mr.SetError("OOM command not allowed when used memory > 'maxmemory'.")
+	err := client.Set(ctx, "cart", "{}", 0).Err()
+	assert.True(t, IsOutOfMemory(err))
+	assert.True(t, IsOutOfMemory(fmt.Errorf("error setting key cart: %w", err)))
+
+	mr.SetError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	assert.False(t, IsOutOfMemory(client.Set(ctx, "cart", "{}", 0).Err()))
+	assert.False(t, IsOutOfMemory(errors.New("OOM but not from redis")))
+	assert.False(t, IsOutOfMemory(nil))
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// cartIDPattern finds the cart id within keys such as cart:tenant:<id> or
+// reservations:cart:<id>
+var cartIDPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+type slowLogHook struct {
+	threshold time.Duration
+	now       func() time.Time
+}
+
+// NewSlowLogHook creates redis hook which logs a warning for every command
+// or pipeline taking longer than threshold
+func NewSlowLogHook(threshold time.Duration) redis.Hook {
+	return &slowLogHook{threshold: threshold, now: time.Now}
+}
+
+func (h *slowLogHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *slowLogHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := h.now()
+		err := next(ctx, cmd)
+		if elapsed := h.now().Sub(start); elapsed > h.threshold {
+			event := slowLogEvent(ctx, elapsed).Str("command", cmd.Name())
+			if key := commandKey(cmd); key != "" {
+				event = event.Str("key", key).Str("cart_id", cartIDPattern.FindString(key))
+			}
+			event.Msg("slow redis command")
+		}
+		return err
+	}
+}
+
+func (h *slowLogHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := h.now()
+		err := next(ctx, cmds)
+		if elapsed := h.now().Sub(start); elapsed > h.threshold {
+			names := make([]string, 0, len(cmds))
+			var cartID string
+			for _, cmd := range cmds {
+				names = append(names, cmd.Name())
+				if cartID == "" {
+					cartID = cartIDPattern.FindString(commandKey(cmd))
+				}
+			}
+			slowLogEvent(ctx, elapsed).Strs("commands", names).Str("cart_id", cartID).Msg("slow redis pipeline")
+		}
+		return err
+	}
+}
+
+func slowLogEvent(ctx context.Context, elapsed time.Duration) *zerolog.Event {
+	event := log.Warn().Dur("elapsed", elapsed)
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		event = event.Str("trace_id", spanCtx.TraceID().String())
+	}
+	return event
+}
+
+// commandKey returns the first key argument of cmd, empty for commands
+// without one
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	key, _ := args[1].(string)
+	return key
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowLogHook(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = previous })
+
+	now := time.Now()
+	hook := &slowLogHook{threshold: 100 * time.Millisecond, now: func() time.Time { return now }}
+	ctx := context.Background()
+	key := "cart:5b1e4a5e-8f0c-4a7b-9a53-3c4a0d2b7f11"
+
+	// fakeClient spends latency inside redis for every command
+	fakeClient := func(latency time.Duration) redis.ProcessHook {
+		return func(ctx context.Context, cmd redis.Cmder) error {
+			now = now.Add(latency)
+			return nil
+		}
+	}
+
+	t.Run("fast command should not be logged", func(t *testing.T) {
+		buf.Reset()
+		assert.NoError(t, hook.ProcessHook(fakeClient(50*time.Millisecond))(ctx, redis.NewStringCmd(ctx, "get", key)))
+		assert.Zero(t, buf.Len())
+	})
+
+	t.Run("slow command should be logged", func(t *testing.T) {
+		buf.Reset()
+		assert.NoError(t, hook.ProcessHook(fakeClient(250*time.Millisecond))(ctx, redis.NewStringCmd(ctx, "get", key)))
+
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+		assert.Equal(t, "warn", line["level"])
+		assert.Equal(t, "get", line["command"])
+		assert.Equal(t, key, line["key"])
+		assert.Equal(t, "5b1e4a5e-8f0c-4a7b-9a53-3c4a0d2b7f11", line["cart_id"])
+		assert.Equal(t, float64(250), line["elapsed"])
+	})
+
+	t.Run("slow pipeline should be logged", func(t *testing.T) {
+		buf.Reset()
+		pipeline := func(ctx context.Context, cmds []redis.Cmder) error {
+			now = now.Add(time.Second)
+			return nil
+		}
+		cmds := []redis.Cmder{redis.NewStringCmd(ctx, "get", key), redis.NewStatusCmd(ctx, "set", key, "{}")}
+		assert.NoError(t, hook.ProcessPipelineHook(pipeline)(ctx, cmds))
+		assert.Contains(t, buf.String(), `"commands":["get","set"]`)
+	})
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// Event types emitted for cart changes
+const (
+	CartUpdatedEventType    = "CartUpdated"
+	CartCheckedOutEventType = "CartCheckedOut"
+)
+
+type Publisher interface {
+	Publish(ctx context.Context, topic string, key string, value []byte) error
+}
+
+// CartEvent is emitted on cart changes and carries the cart snapshot
+type CartEvent struct {
+	Type       string       `json:"type"`
+	CartID     string       `json:"cartId"`
+	OccurredAt time.Time    `json:"occurredAt"`
+	Cart       *models.Cart `json:"cart"`
+}
+
+// CartEventPublisher emits cart events keyed by cart id. Kafka maps equal
+// keys to the same partition with the default hash partitioner, so all
+// events of a cart are consumed in the order they were published. The
+// guarantee holds as long as the partition count of the topic is not
+// changed and the producer does not reorder retries (idempotent producer
+// or a single in-flight request).
+type CartEventPublisher struct {
+	publisher Publisher
+	topic     string
+	codec     Codec
+}
+
+// NewCartEventPublisher creates publisher encoding events with the codec
+// set by UseCodec
+func NewCartEventPublisher(publisher Publisher, topic string) *CartEventPublisher {
+	return &CartEventPublisher{publisher: publisher, topic: topic, codec: defaultCodec}
+}
+
+// CartUpdated publishes CartUpdated event
+func (p *CartEventPublisher) CartUpdated(ctx context.Context, cart *models.Cart) error {
+	return p.publish(ctx, CartUpdatedEventType, cart)
+}
+
+// CartCheckedOut publishes CartCheckedOut event
+func (p *CartEventPublisher) CartCheckedOut(ctx context.Context, cart *models.Cart) error {
+	return p.publish(ctx, CartCheckedOutEventType, cart)
+}
+
+func (p *CartEventPublisher) publish(ctx context.Context, eventType string, cart *models.Cart) error {
+	cartID := cart.ID.String()
+	value, err := p.codec.Marshal(CartEventSchema, CartEvent{
+		Type:       eventType,
+		CartID:     cartID,
+		OccurredAt: time.Now().UTC(),
+		Cart:       cart,
+	})
+	if err != nil {
+		return err
+	}
+	return p.publisher.Publish(ctx, p.topic, cartID, value)
+}
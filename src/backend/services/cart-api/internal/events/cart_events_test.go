@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	producer "github.com/jurabek/cart-api/pkg/publisher"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCartEventPublisher(t *testing.T) {
+	cart := &models.Cart{ID: uuid.New()}
+
+	var sent []*sarama.ProducerMessage
+	config := mocks.NewTestConfig()
+	mockProducer := mocks.NewSyncProducer(t, config)
+	checker := func(msg *sarama.ProducerMessage) error {
+		sent = append(sent, msg)
+		return nil
+	}
+	mockProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(checker)
+	mockProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(checker)
+
+	p := NewCartEventPublisher(producer.NewProducer(mockProducer, config), "carts")
+	assert.NoError(t, p.CartUpdated(context.Background(), cart))
+	assert.NoError(t, p.CartCheckedOut(context.Background(), cart))
+
+	assert.Len(t, sent, 2)
+	for i, eventType := range []string{CartUpdatedEventType, CartCheckedOutEventType} {
+		key, _ := sent[i].Key.Encode()
+		assert.Equal(t, cart.ID.String(), string(key), "events must be keyed by cart id")
+
+		value, _ := sent[i].Value.Encode()
+		var event CartEvent
+		assert.NoError(t, json.Unmarshal(value, &event))
+		assert.Equal(t, eventType, event.Type)
+		assert.Equal(t, cart.ID.String(), event.CartID)
+	}
+}
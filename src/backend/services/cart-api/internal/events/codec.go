@@ -0,0 +1,79 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Codec serializes events exchanged over kafka. schema describes the event
+// for binary formats, self describing formats like JSON ignore it
+type Codec interface {
+	Marshal(schema protoreflect.MessageDescriptor, v interface{}) ([]byte, error)
+	Unmarshal(schema protoreflect.MessageDescriptor, data []byte, v interface{}) error
+}
+
+// JSONCodec writes events as JSON following the json tags, it is the default
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(_ protoreflect.MessageDescriptor, v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(_ protoreflect.MessageDescriptor, data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtoCodec writes events in protobuf wire format of schema so consumers
+// using a schema registry can read them. Events are mapped to the schema
+// through their JSON form, json names of schema fields match the json tags.
+// Fields missing from the schema are dropped
+type ProtoCodec struct{}
+
+func (ProtoCodec) Marshal(schema protoreflect.MessageDescriptor, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	msg := dynamicpb.NewMessage(schema)
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("mapping event to %s: %w", schema.FullName(), err)
+	}
+	return proto.Marshal(msg)
+}
+
+func (ProtoCodec) Unmarshal(schema protoreflect.MessageDescriptor, data []byte, v interface{}) error {
+	msg := dynamicpb.NewMessage(schema)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("decoding %s: %w", schema.FullName(), err)
+	}
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// NewCodec returns codec by name, either "json" or "protobuf"
+func NewCodec(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "protobuf", "proto":
+		return ProtoCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown event codec %q", name)
+}
+
+// defaultCodec is used by publishers and handlers created afterwards
+var defaultCodec Codec = JSONCodec{}
+
+// UseCodec sets the codec of events published and handled from now on, it
+// is meant to be called once at startup
+func UseCodec(c Codec) {
+	defaultCodec = c
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	userID := "user-1"
+	discount := models.Money{Minor: 150}
+	cartEvent := &CartEvent{
+		Type:       CartUpdatedEventType,
+		CartID:     "7f9c2c4e-36a1-4b8e-9a3e-2f4f4f0a1b2c",
+		OccurredAt: time.Date(2026, 10, 14, 12, 30, 0, 500, time.UTC),
+		Cart: &models.Cart{
+			ID: uuid.MustParse("7f9c2c4e-36a1-4b8e-9a3e-2f4f4f0a1b2c"),
+			LineItems: []models.LineItem{{
+				ItemID:      1,
+				UnitPrice:   models.Money{Minor: 1250},
+				Quantity:    2,
+				ProductName: "burger",
+				Attributes:  map[string]interface{}{"size": "large"},
+			}},
+			Total:    models.Money{Minor: 2500},
+			UserID:   &userID,
+			Discount: &discount,
+			Status:   models.CartStatusProcessing,
+		},
+	}
+	orderCompleted := &OrderCompletedEvent{OrderID: "o-1", CartID: "c-1", UserID: "u-1", TransactionID: "t-1", OrderDate: "2026-10-14"}
+	priceChanged := &PriceChangedEvent{ProductID: 7, Price: models.Money{Minor: 999}}
+	orderCancelled := &OrderCancelledEvent{OrderID: "o-1", CartID: "c-1", UserID: "u-1"}
+	itemDiscontinued := &ItemDiscontinuedEvent{ProductID: 7}
+
+	events := []struct {
+		name   string
+		schema protoreflect.MessageDescriptor
+		event  interface{}
+		decode func() interface{}
+	}{
+		{"cart event", CartEventSchema, cartEvent, func() interface{} { return &CartEvent{} }},
+		{"order completed", OrderCompletedEventSchema, orderCompleted, func() interface{} { return &OrderCompletedEvent{} }},
+		{"price changed", PriceChangedEventSchema, priceChanged, func() interface{} { return &PriceChangedEvent{} }},
+		{"order cancelled", OrderCancelledEventSchema, orderCancelled, func() interface{} { return &OrderCancelledEvent{} }},
+		{"item discontinued", ItemDiscontinuedEventSchema, itemDiscontinued, func() interface{} { return &ItemDiscontinuedEvent{} }},
+	}
+
+	for _, name := range []string{"json", "protobuf"} {
+		codec, err := NewCodec(name)
+		require.NoError(t, err)
+		for _, e := range events {
+			t.Run(name+"/"+e.name, func(t *testing.T) {
+				data, err := codec.Marshal(e.schema, e.event)
+				require.NoError(t, err)
+
+				decoded := e.decode()
+				require.NoError(t, codec.Unmarshal(e.schema, data, decoded))
+				assert.Equal(t, e.event, decoded)
+			})
+		}
+	}
+}
+
+func TestProtoCodecIsBinary(t *testing.T) {
+	data, err := ProtoCodec{}.Marshal(PriceChangedEventSchema, &PriceChangedEvent{ProductID: 7, Price: models.Money{Minor: 999}})
+	require.NoError(t, err)
+	// field 1 varint 7, field 2 fixed64 double 9.99 in any order
+	fields := map[protowire.Number]uint64{}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		require.Positive(t, n)
+		data = data[n:]
+		switch typ {
+		case protowire.VarintType:
+			fields[num], n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			fields[num], n = protowire.ConsumeFixed64(data)
+		default:
+			t.Fatalf("unexpected wire type %d of field %d", typ, num)
+		}
+		require.Positive(t, n)
+		data = data[n:]
+	}
+	assert.Equal(t, map[protowire.Number]uint64{1: 7, 2: math.Float64bits(9.99)}, fields)
+
+	assert.Error(t, ProtoCodec{}.Unmarshal(PriceChangedEventSchema, []byte(`{"productId": 7}`), &PriceChangedEvent{}))
+}
+
+func TestNewCodec(t *testing.T) {
+	codec, err := NewCodec("")
+	require.NoError(t, err)
+	assert.Equal(t, JSONCodec{}, codec)
+
+	_, err = NewCodec("avro")
+	assert.Error(t, err)
+}
+
+func TestHandlersUseCodec(t *testing.T) {
+	UseCodec(ProtoCodec{})
+	defer UseCodec(JSONCodec{})
+
+	ctx := context.Background()
+	repo := repositoriestest.NewMemoryRepository()
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1},
+	}}
+	require.NoError(t, repo.Update(ctx, cart))
+
+	value, err := ProtoCodec{}.Marshal(PriceChangedEventSchema, &PriceChangedEvent{ProductID: 1, Price: models.Money{Minor: 1250}})
+	require.NoError(t, err)
+	require.NoError(t, NewPriceChangedEventHandler(repo).Handle(ctx, &reciever.Message{Value: value}))
+
+	result, err := repo.Get(ctx, cart.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, models.Money{Minor: 1250}, result.LineItems[0].UnitPrice)
+}
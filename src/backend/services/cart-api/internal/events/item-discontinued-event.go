@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/rs/zerolog/log"
+)
+
+// Event types of the prices topic, see reciever.EventTypeAttribute
+const (
+	PriceChangedEventType     = "PriceChanged"
+	ItemDiscontinuedEventType = "ItemDiscontinued"
+)
+
+type ItemDiscontinuer interface {
+	DiscontinueItems(ctx context.Context, productID int, cursor uint64, count int64) ([]*models.Cart, uint64, error)
+}
+
+// CartNotifier tells other services a cart changed
+type CartNotifier interface {
+	CartUpdated(ctx context.Context, cart *models.Cart) error
+}
+
+// ItemDiscontinuedEventHandler removes a product taken off the menu from
+// every cart having it, so it can't be checked out. Updated carts are
+// announced by CartUpdated events when a notifier is set
+type ItemDiscontinuedEventHandler struct {
+	discontinuer ItemDiscontinuer
+	notifier     CartNotifier
+	codec        Codec
+}
+
+// NewItemDiscontinuedEventHandler creates the handler, notifier may be nil
+func NewItemDiscontinuedEventHandler(discontinuer ItemDiscontinuer, notifier CartNotifier) *ItemDiscontinuedEventHandler {
+	return &ItemDiscontinuedEventHandler{discontinuer: discontinuer, notifier: notifier, codec: defaultCodec}
+}
+
+type ItemDiscontinuedEvent struct {
+	ProductID int `json:"productId"`
+}
+
+var _ reciever.MessageHandler = (*ItemDiscontinuedEventHandler)(nil)
+
+// Handle implements reciever.MessageHandler.
+func (h *ItemDiscontinuedEventHandler) Handle(ctx context.Context, message *reciever.Message) error {
+	event := &ItemDiscontinuedEvent{}
+	if err := h.codec.Unmarshal(ItemDiscontinuedEventSchema, message.Value, event); err != nil {
+		return err
+	}
+	log.Info().Int("product_id", event.ProductID).Msg("ItemDiscontinuedEvent received")
+
+	var cursor uint64
+	var updated int
+	for {
+		carts, next, err := h.discontinuer.DiscontinueItems(ctx, event.ProductID, cursor, menuScanCount)
+		if err != nil {
+			log.Error().Err(err).Int("product_id", event.ProductID).Msg("failed to discontinue items of carts")
+			return err
+		}
+		updated += len(carts)
+		h.notify(ctx, carts)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	log.Info().Int("product_id", event.ProductID).Int("carts", updated).Msg("discontinued items removed from carts")
+	return nil
+}
+
+// notify announces updated carts, a failure is logged and does not fail the
+// event since the carts were changed already and a redelivery finds nothing
+// to change
+func (h *ItemDiscontinuedEventHandler) notify(ctx context.Context, carts []*models.Cart) {
+	if h.notifier == nil {
+		return
+	}
+	for _, cart := range carts {
+		if err := h.notifier.CartUpdated(ctx, cart); err != nil {
+			log.Warn().Err(err).Str("cart_id", cart.ID.String()).Msg("failed to publish CartUpdated")
+		}
+	}
+}
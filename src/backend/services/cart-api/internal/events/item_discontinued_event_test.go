@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	updated []*models.Cart
+	err     error
+}
+
+func (n *recordingNotifier) CartUpdated(ctx context.Context, cart *models.Cart) error {
+	n.updated = append(n.updated, cart)
+	return n.err
+}
+
+func TestItemDiscontinuedEventHandler(t *testing.T) {
+	ctx := context.Background()
+	newCarts := func(t *testing.T) (*repositoriestest.MemoryRepository, *models.Cart, *models.Cart) {
+		repo := repositoriestest.NewMemoryRepository()
+		affected := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+			{ItemID: 1, ProductName: "burger", UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+			{ItemID: 2, ProductName: "fries", UnitPrice: models.Money{Minor: 300}, Quantity: 1},
+		}}
+		other := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+			{ItemID: 2, ProductName: "fries", UnitPrice: models.Money{Minor: 300}, Quantity: 2},
+		}}
+		require.NoError(t, repo.Update(ctx, affected))
+		require.NoError(t, repo.Update(ctx, other))
+		return repo, affected, other
+	}
+
+	t.Run("discontinued item should be removed from affected carts", func(t *testing.T) {
+		repo, affected, other := newCarts(t)
+		notifier := &recordingNotifier{}
+		handler := NewItemDiscontinuedEventHandler(repo, notifier)
+		require.NoError(t, handler.Handle(ctx, &reciever.Message{Value: []byte(`{"productId": 1}`)}))
+
+		result, err := repo.Get(ctx, affected.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, []models.LineItem{affected.LineItems[1]}, result.LineItems)
+		assert.Equal(t, models.Money{Minor: 300}, result.Total)
+
+		untouched, err := repo.Get(ctx, other.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, other.LineItems, untouched.LineItems)
+
+		require.Len(t, notifier.updated, 1, "only affected carts should be announced")
+		assert.Equal(t, affected.ID, notifier.updated[0].ID)
+		assert.Equal(t, result.LineItems, notifier.updated[0].LineItems)
+	})
+
+	t.Run("failing notifier should not fail the event", func(t *testing.T) {
+		repo, affected, _ := newCarts(t)
+		handler := NewItemDiscontinuedEventHandler(repo, &recordingNotifier{err: errors.New("broker down")})
+		require.NoError(t, handler.Handle(ctx, &reciever.Message{Value: []byte(`{"productId": 2}`)}))
+
+		result, err := repo.Get(ctx, affected.ID.String())
+		require.NoError(t, err)
+		assert.Len(t, result.LineItems, 1)
+	})
+
+	t.Run("without notifier and with malformed events", func(t *testing.T) {
+		repo, _, other := newCarts(t)
+		handler := NewItemDiscontinuedEventHandler(repo, nil)
+		require.NoError(t, handler.Handle(ctx, &reciever.Message{Value: []byte(`{"productId": 2}`)}))
+		result, err := repo.Get(ctx, other.ID.String())
+		require.NoError(t, err)
+		assert.Empty(t, result.LineItems)
+
+		assert.Error(t, handler.Handle(ctx, &reciever.Message{Value: []byte(`not json`)}))
+	})
+}
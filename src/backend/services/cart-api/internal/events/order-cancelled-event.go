@@ -0,0 +1,98 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/jurabek/cart-api/pkg/snapshot"
+	"github.com/rs/zerolog/log"
+)
+
+// Event types of the orders topic, see reciever.EventTypeAttribute
+const (
+	OrderCompletedEventType = "OrderCompleted"
+	OrderCancelledEventType = "OrderCancelled"
+)
+
+// SnapshotReader reads back snapshots of checked out carts, Latest returns
+// the snapshot with the greatest key under prefix or snapshot.ErrNotFound
+type SnapshotReader interface {
+	Latest(ctx context.Context, prefix string) ([]byte, error)
+}
+
+// OrderCancelledEventHandler reopens carts checked out by cancelled orders,
+// the cart is restored from the snapshot taken by OrderCompletedEventHandler
+type OrderCancelledEventHandler struct {
+	cartGetterUpdater CartGetterUpdater
+	snapshots         SnapshotReader
+	codec             Codec
+}
+
+// NewOrderCancelledEventHandler creates handler restoring carts from
+// snapshots, without snapshots cancelled orders are acknowledged and ignored
+func NewOrderCancelledEventHandler(cartGetterUpdater CartGetterUpdater, snapshots SnapshotReader) *OrderCancelledEventHandler {
+	return &OrderCancelledEventHandler{cartGetterUpdater: cartGetterUpdater, snapshots: snapshots, codec: defaultCodec}
+}
+
+type OrderCancelledEvent struct {
+	OrderID string `json:"orderId"`
+	CartID  string `json:"cartId"`
+	UserID  string `json:"userId"`
+}
+
+var _ reciever.MessageHandler = (*OrderCancelledEventHandler)(nil)
+
+// Handle implements reciever.MessageHandler.
+func (h *OrderCancelledEventHandler) Handle(ctx context.Context, message *reciever.Message) error {
+	event := &OrderCancelledEvent{}
+	if err := h.codec.Unmarshal(OrderCancelledEventSchema, message.Value, event); err != nil {
+		return err
+	}
+	logger := log.With().Str("order_id", event.OrderID).Str("cart_id", event.CartID).Logger()
+	logger.Info().Msg("OrderCancelledEvent received")
+
+	// an active cart was never checked out or is already restored
+	_, err := h.cartGetterUpdater.Get(ctx, event.CartID)
+	if err == nil {
+		logger.Info().Msg("cart is active, nothing to restore")
+		return nil
+	}
+	if !errors.Is(err, repositories.ErrCartNotFound) {
+		return err
+	}
+
+	if h.snapshots == nil {
+		logger.Info().Msg("snapshots are disabled, cart is not restored")
+		return nil
+	}
+	body, err := h.snapshots.Latest(ctx, event.CartID+"/")
+	if errors.Is(err, snapshot.ErrNotFound) {
+		logger.Info().Msg("no snapshot of the cart, cart is not restored")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var cart models.Cart
+	if err := json.Unmarshal(body, &cart); err != nil {
+		return fmt.Errorf("decoding snapshot of cart %s: %w", event.CartID, err)
+	}
+	if cart.OrderID != nil && event.OrderID != "" && *cart.OrderID != event.OrderID {
+		logger.Warn().Str("snapshot_order_id", *cart.OrderID).Msg("latest snapshot belongs to another order, cart is not restored")
+		return nil
+	}
+	cart.Status = models.CartStatusNew
+	cart.OrderID = nil
+	cart.TransactionID = nil
+	if err := h.cartGetterUpdater.Update(ctx, &cart); err != nil {
+		return err
+	}
+	logger.Info().Int("items", len(cart.LineItems)).Msg("cart restored")
+	return nil
+}
@@ -3,6 +3,9 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/jurabek/cart-api/internal/models"
 	"github.com/jurabek/cart-api/pkg/reciever"
@@ -14,12 +17,38 @@ type CartGetterUpdater interface {
 	Update(ctx context.Context, cart *models.Cart) error
 }
 
+// SnapshotStore keeps immutable copies of carts, e.g. in an object storage
+type SnapshotStore interface {
+	Put(ctx context.Context, key string, body []byte) error
+}
+
 type OrderCompletedEventHandler struct {
 	cartGetterUpdater CartGetterUpdater
+	snapshots         SnapshotStore
+	codec             Codec
+	now               func() time.Time
+
+	// lastProcessed is unix nano time of the last successfully handled event
+	lastProcessed atomic.Int64
+}
+
+// Option configures OrderCompletedEventHandler
+type Option func(*OrderCompletedEventHandler)
+
+// WithSnapshotStore writes the checked out cart to store, by default no
+// snapshots are taken
+func WithSnapshotStore(store SnapshotStore) Option {
+	return func(h *OrderCompletedEventHandler) {
+		h.snapshots = store
+	}
 }
 
-func NewOrderCompletedEventHandler(cartGetterUpdater CartGetterUpdater) *OrderCompletedEventHandler {
-	return &OrderCompletedEventHandler{cartGetterUpdater: cartGetterUpdater}
+func NewOrderCompletedEventHandler(cartGetterUpdater CartGetterUpdater, opts ...Option) *OrderCompletedEventHandler {
+	h := &OrderCompletedEventHandler{cartGetterUpdater: cartGetterUpdater, codec: defaultCodec, now: time.Now}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 type OrderCompletedEvent struct {
@@ -34,12 +63,11 @@ var _ reciever.MessageHandler = (*OrderCompletedEventHandler)(nil)
 
 // Handle implements consumer.ConsumerMessageHandler.
 func (h *OrderCompletedEventHandler) Handle(ctx context.Context, message *reciever.Message) error {
-	log.Info().Msgf("OrderCompletedEvent received: %s", string(message.Value))
-
 	orderCompletedEvent := &OrderCompletedEvent{}
-	if err := json.Unmarshal(message.Value, orderCompletedEvent); err != nil {
+	if err := h.codec.Unmarshal(OrderCompletedEventSchema, message.Value, orderCompletedEvent); err != nil {
 		return err
 	}
+	log.Info().Str("order_id", orderCompletedEvent.OrderID).Str("cart_id", orderCompletedEvent.CartID).Msg("OrderCompletedEvent received")
 
 	cart, err := h.cartGetterUpdater.Get(ctx, orderCompletedEvent.CartID)
 	if err != nil {
@@ -55,5 +83,40 @@ func (h *OrderCompletedEventHandler) Handle(ctx context.Context, message *reciev
 		log.Error().Err(err)
 		return err
 	}
+	if err := h.snapshot(ctx, cart); err != nil {
+		return err
+	}
+	h.lastProcessed.Store(time.Now().UnixNano())
 	return nil
 }
+
+// LastProcessed returns time the last event was handled successfully, zero
+// time when none was handled yet
+func (h *OrderCompletedEventHandler) LastProcessed() time.Time {
+	n := h.lastProcessed.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// snapshot stores the checked out cart keyed by cart id and checkout time
+func (h *OrderCompletedEventHandler) snapshot(ctx context.Context, cart *models.Cart) error {
+	if h.snapshots == nil {
+		return nil
+	}
+	body, err := json.Marshal(cart)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot of cart %s: %w", cart.ID, err)
+	}
+	key := SnapshotKey(cart.ID.String(), h.now())
+	if err := h.snapshots.Put(ctx, key, body); err != nil {
+		return fmt.Errorf("storing snapshot of cart %s: %w", cart.ID, err)
+	}
+	return nil
+}
+
+// SnapshotKey is an object key of the cart snapshot taken at t
+func SnapshotKey(cartID string, t time.Time) string {
+	return cartID + "/" + t.UTC().Format("20060102T150405.000000000Z") + ".json"
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/jurabek/cart-api/pkg/snapshot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Latest implements SnapshotReader.
+func (m memorySnapshotStore) Latest(ctx context.Context, prefix string) ([]byte, error) {
+	var keys []string
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, snapshot.ErrNotFound
+	}
+	sort.Strings(keys)
+	return m[keys[len(keys)-1]], nil
+}
+
+func TestOrderCancelledEventHandler(t *testing.T) {
+	ctx := context.Background()
+	items := []models.LineItem{{ItemID: 1, ProductName: "burger", UnitPrice: models.Money{Minor: 1000}, Quantity: 2}}
+
+	// checkout runs the completed handler taking the snapshot
+	checkout := func(t *testing.T, repo *repositoriestest.MemoryRepository, store memorySnapshotStore, orderID string) *models.Cart {
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, LineItems: items, Total: models.Money{Minor: 2000}}
+		require.NoError(t, repo.Update(ctx, cart))
+		completed := NewOrderCompletedEventHandler(repo, WithSnapshotStore(store))
+		completed.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+		require.NoError(t, completed.Handle(ctx, &reciever.Message{Value: []byte(`{"cartId": "` + cart.ID.String() + `", "orderId": "` + orderID + `", "userId": "u-1", "transactionId": "t-1"}`)}))
+		_, err := repo.Get(ctx, cart.ID.String())
+		require.Error(t, err, "checked out cart should be gone")
+		return cart
+	}
+	cancelled := func(cart *models.Cart, orderID string) *reciever.Message {
+		return &reciever.Message{Value: []byte(`{"cartId": "` + cart.ID.String() + `", "orderId": "` + orderID + `"}`)}
+	}
+
+	t.Run("should restore cart from snapshot", func(t *testing.T) {
+		repo := repositoriestest.NewMemoryRepository()
+		store := memorySnapshotStore{}
+		cart := checkout(t, repo, store, "o-1")
+
+		handler := NewOrderCancelledEventHandler(repo, store)
+		require.NoError(t, handler.Handle(ctx, cancelled(cart, "o-1")))
+
+		restored, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, models.CartStatusNew, restored.Status)
+		assert.Equal(t, items, restored.LineItems)
+		assert.Equal(t, models.Money{Minor: 2000}, restored.Total)
+		assert.Nil(t, restored.OrderID)
+		assert.Nil(t, restored.TransactionID)
+		assert.Equal(t, "u-1", *restored.UserID)
+
+		// redelivery finds the cart active
+		require.NoError(t, handler.Handle(ctx, cancelled(cart, "o-1")))
+	})
+
+	t.Run("should be a no-op without snapshot", func(t *testing.T) {
+		repo := repositoriestest.NewMemoryRepository()
+		cart := checkout(t, repo, memorySnapshotStore{}, "o-1")
+
+		require.NoError(t, NewOrderCancelledEventHandler(repo, memorySnapshotStore{}).Handle(ctx, cancelled(cart, "o-1")))
+		require.NoError(t, NewOrderCancelledEventHandler(repo, nil).Handle(ctx, cancelled(cart, "o-1")))
+
+		_, err := repo.Get(ctx, cart.ID.String())
+		assert.Error(t, err, "cart should stay checked out")
+	})
+
+	t.Run("should ignore snapshot of another order", func(t *testing.T) {
+		repo := repositoriestest.NewMemoryRepository()
+		store := memorySnapshotStore{}
+		cart := checkout(t, repo, store, "o-2")
+
+		require.NoError(t, NewOrderCancelledEventHandler(repo, store).Handle(ctx, cancelled(cart, "o-1")))
+		_, err := repo.Get(ctx, cart.ID.String())
+		assert.Error(t, err)
+	})
+
+	t.Run("should not touch an active cart", func(t *testing.T) {
+		repo := repositoriestest.NewMemoryRepository()
+		store := memorySnapshotStore{}
+		cart := checkout(t, repo, store, "o-1")
+		active := &models.Cart{ID: cart.ID, Status: models.CartStatusNew}
+		require.NoError(t, repo.Update(ctx, active))
+
+		require.NoError(t, NewOrderCancelledEventHandler(repo, store).Handle(ctx, cancelled(cart, "o-1")))
+		current, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Empty(t, current.LineItems)
+	})
+
+	t.Run("should fail on malformed event or snapshot", func(t *testing.T) {
+		repo := repositoriestest.NewMemoryRepository()
+		cart := &models.Cart{ID: uuid.New()}
+		store := memorySnapshotStore{cart.ID.String() + "/20240301T120000.000000000Z.json": []byte(`not json`)}
+		handler := NewOrderCancelledEventHandler(repo, store)
+
+		assert.Error(t, handler.Handle(ctx, &reciever.Message{Value: []byte(`not json`)}))
+		assert.Error(t, handler.Handle(ctx, cancelled(cart, "o-1")))
+	})
+}
+
+func TestOrdersRouting(t *testing.T) {
+	ctx := context.Background()
+	repo := repositoriestest.NewMemoryRepository()
+	store := memorySnapshotStore{}
+	cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+	require.NoError(t, repo.Update(ctx, cart))
+
+	completed := NewOrderCompletedEventHandler(repo, WithSnapshotStore(store))
+	router := reciever.NewRouter().
+		Register("", completed).
+		Register(OrderCancelledEventType, NewOrderCancelledEventHandler(repo, store))
+
+	value, _ := json.Marshal(OrderCompletedEvent{CartID: cart.ID.String(), OrderID: "o-1"})
+	require.NoError(t, router.Handle(ctx, &reciever.Message{Value: value}))
+	_, err := repo.Get(ctx, cart.ID.String())
+	require.Error(t, err)
+
+	value, _ = json.Marshal(OrderCancelledEvent{CartID: cart.ID.String(), OrderID: "o-1"})
+	require.NoError(t, router.Handle(ctx, &reciever.Message{Value: value, Attributes: map[string]string{reciever.EventTypeAttribute: OrderCancelledEventType}}))
+	_, err = repo.Get(ctx, cart.ID.String())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderCompletedLastProcessed(t *testing.T) {
+	ctx := context.Background()
+	repo := repositoriestest.NewMemoryRepository()
+	cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+	assert.NoError(t, repo.Update(ctx, cart))
+
+	handler := NewOrderCompletedEventHandler(repo)
+	assert.True(t, handler.LastProcessed().IsZero())
+
+	err := handler.Handle(ctx, &reciever.Message{Value: []byte(`{"cartId": "missing"}`)})
+	assert.Error(t, err)
+	assert.True(t, handler.LastProcessed().IsZero())
+
+	err = handler.Handle(ctx, &reciever.Message{Value: []byte(`{"cartId": "` + cart.ID.String() + `", "orderId": "o-1"}`)})
+	assert.NoError(t, err)
+	assert.False(t, handler.LastProcessed().IsZero())
+}
+
+// memorySnapshotStore keeps snapshots by key
+type memorySnapshotStore map[string][]byte
+
+func (m memorySnapshotStore) Put(ctx context.Context, key string, body []byte) error {
+	m[key] = body
+	return nil
+}
+
+func TestOrderCompletedSnapshot(t *testing.T) {
+	ctx := context.Background()
+	repo := repositoriestest.NewMemoryRepository()
+	cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, LineItems: []models.LineItem{
+		{ItemID: 1, ProductName: "burger", UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+	}}
+	assert.NoError(t, repo.Update(ctx, cart))
+
+	store := memorySnapshotStore{}
+	checkout := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	handler := NewOrderCompletedEventHandler(repo, WithSnapshotStore(store))
+	handler.now = func() time.Time { return checkout }
+
+	err := handler.Handle(ctx, &reciever.Message{Value: []byte(`{"cartId": "` + cart.ID.String() + `", "orderId": "o-1", "userId": "u-1"}`)})
+	assert.NoError(t, err)
+
+	body, ok := store[SnapshotKey(cart.ID.String(), checkout)]
+	assert.True(t, ok)
+	assert.Len(t, store, 1)
+
+	var snapshot models.Cart
+	assert.NoError(t, json.Unmarshal(body, &snapshot))
+	assert.Equal(t, cart.ID, snapshot.ID)
+	assert.Equal(t, models.CartStatusCompleted, snapshot.Status)
+	assert.Equal(t, "o-1", *snapshot.OrderID)
+	assert.Equal(t, cart.LineItems, snapshot.LineItems)
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/rs/zerolog/log"
+)
+
+// menuScanCount is a number of keys scanned per page when menu events
+// update carts
+const menuScanCount = 100
+
+type ItemRepricer interface {
+	RepriceItems(ctx context.Context, productID int, price models.Money, cursor uint64, count int64) ([]string, uint64, error)
+}
+
+// PriceChangedEventHandler updates unit price of the product in every cart
+// having it, so carts stay accurate when menu prices change
+type PriceChangedEventHandler struct {
+	repricer ItemRepricer
+	codec    Codec
+}
+
+func NewPriceChangedEventHandler(repricer ItemRepricer) *PriceChangedEventHandler {
+	return &PriceChangedEventHandler{repricer: repricer, codec: defaultCodec}
+}
+
+type PriceChangedEvent struct {
+	ProductID int `json:"productId"`
+	// Price is read from a number or a decimal string
+	Price models.Money `json:"price"`
+}
+
+var _ reciever.MessageHandler = (*PriceChangedEventHandler)(nil)
+
+// Handle implements reciever.MessageHandler.
+func (h *PriceChangedEventHandler) Handle(ctx context.Context, message *reciever.Message) error {
+	event := &PriceChangedEvent{}
+	if err := h.codec.Unmarshal(PriceChangedEventSchema, message.Value, event); err != nil {
+		return err
+	}
+	log.Info().Int("product_id", event.ProductID).Str("price", event.Price.String()).Msg("PriceChangedEvent received")
+
+	var cursor uint64
+	var updated int
+	for {
+		ids, next, err := h.repricer.RepriceItems(ctx, event.ProductID, event.Price, cursor, menuScanCount)
+		if err != nil {
+			log.Error().Err(err).Int("product_id", event.ProductID).Msg("failed to reprice carts")
+			return err
+		}
+		updated += len(ids)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	log.Info().Int("product_id", event.ProductID).Int("carts", updated).Msg("carts repriced")
+	return nil
+}
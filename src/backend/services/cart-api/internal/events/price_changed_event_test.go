@@ -0,0 +1,44 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/jurabek/cart-api/pkg/reciever"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriceChangedEventHandler(t *testing.T) {
+	ctx := context.Background()
+	repo := repositoriestest.NewMemoryRepository()
+	burger := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+		{ItemID: 1, ProductName: "burger", UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+		{ItemID: 2, ProductName: "fries", UnitPrice: models.Money{Minor: 300}, Quantity: 1},
+	}}
+	fries := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+		{ItemID: 2, ProductName: "fries", UnitPrice: models.Money{Minor: 300}, Quantity: 2},
+	}}
+	require.NoError(t, repo.Update(ctx, burger))
+	require.NoError(t, repo.Update(ctx, fries))
+
+	handler := NewPriceChangedEventHandler(repo)
+	err := handler.Handle(ctx, &reciever.Message{Value: []byte(`{"productId": 1, "price": 12.5}`)})
+	require.NoError(t, err)
+
+	result, err := repo.Get(ctx, burger.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, models.Money{Minor: 1250}, result.LineItems[0].UnitPrice)
+	assert.Equal(t, models.Money{Minor: 300}, result.LineItems[1].UnitPrice)
+	assert.Equal(t, models.Money{Minor: 2800}, result.Total)
+
+	untouched, err := repo.Get(ctx, fries.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, fries.LineItems, untouched.LineItems)
+
+	err = handler.Handle(ctx, &reciever.Message{Value: []byte(`not json`)})
+	assert.Error(t, err)
+}
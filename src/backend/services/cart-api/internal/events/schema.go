@@ -0,0 +1,148 @@
+package events
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// register well known types the schemas depend on
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Protobuf schemas of events, they mirror the JSON form of event structs.
+// Integers are int32 since protojson writes int64 as strings, money is a
+// double of major units like the JSON form of models.Money
+var (
+	CartEventSchema             protoreflect.MessageDescriptor
+	OrderCompletedEventSchema   protoreflect.MessageDescriptor
+	PriceChangedEventSchema     protoreflect.MessageDescriptor
+	OrderCancelledEventSchema   protoreflect.MessageDescriptor
+	ItemDiscontinuedEventSchema protoreflect.MessageDescriptor
+)
+
+func init() {
+	file, err := protodesc.NewFile(eventsFile(), protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	messages := file.Messages()
+	CartEventSchema = messages.ByName("CartEvent")
+	OrderCompletedEventSchema = messages.ByName("OrderCompletedEvent")
+	PriceChangedEventSchema = messages.ByName("PriceChangedEvent")
+	OrderCancelledEventSchema = messages.ByName("OrderCancelledEvent")
+	ItemDiscontinuedEventSchema = messages.ByName("ItemDiscontinuedEvent")
+}
+
+const (
+	typeString    = descriptorpb.FieldDescriptorProto_TYPE_STRING
+	typeInt32     = descriptorpb.FieldDescriptorProto_TYPE_INT32
+	typeDouble    = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+	typeMessage   = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	labelOptional = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	labelRepeated = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+)
+
+// field describes one field of a schema message, optional fields track
+// presence so pointers of the event survive a round trip
+type field struct {
+	name     string
+	jsonName string
+	typ      descriptorpb.FieldDescriptorProto_Type
+	typeName string
+	repeated bool
+	optional bool
+}
+
+func message(name string, fields ...field) *descriptorpb.DescriptorProto {
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	for i, f := range fields {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(f.name),
+			JsonName: proto.String(f.jsonName),
+			Number:   proto.Int32(int32(i + 1)),
+			Type:     f.typ.Enum(),
+			Label:    labelOptional.Enum(),
+		}
+		if f.typeName != "" {
+			fd.TypeName = proto.String(f.typeName)
+		}
+		if f.repeated {
+			fd.Label = labelRepeated.Enum()
+		}
+		if f.optional {
+			fd.Proto3Optional = proto.Bool(true)
+			fd.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+			msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f.name)})
+		}
+		msg.Field = append(msg.Field, fd)
+	}
+	return msg
+}
+
+// eventsFile describes cart/events/v1/events.proto, field numbers follow
+// declaration order and must not be reused once published
+func eventsFile() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("cart/events/v1/events.proto"),
+		Package:    proto.String("cart.events.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/struct.proto", "google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("LineItem",
+				field{name: "item_id", jsonName: "item_id", typ: typeInt32},
+				field{name: "unit_price", jsonName: "unit_price", typ: typeDouble},
+				field{name: "quantity", jsonName: "quantity", typ: typeInt32},
+				field{name: "img", jsonName: "img", typ: typeString},
+				field{name: "product_name", jsonName: "product_name", typ: typeString},
+				field{name: "product_description", jsonName: "product_description", typ: typeString},
+				field{name: "attributes", jsonName: "attributes", typ: typeMessage, typeName: ".google.protobuf.Struct"},
+				field{name: "image_url", jsonName: "image_url", typ: typeString},
+				field{name: "display_name", jsonName: "display_name", typ: typeString},
+				field{name: "product_id", jsonName: "product_id", typ: typeInt32},
+			),
+			message("Cart",
+				field{name: "id", jsonName: "id", typ: typeString},
+				field{name: "items", jsonName: "items", typ: typeMessage, typeName: ".cart.events.v1.LineItem", repeated: true},
+				field{name: "total", jsonName: "total", typ: typeDouble},
+				field{name: "user_id", jsonName: "user_id", typ: typeString, optional: true},
+				field{name: "discount", jsonName: "discount", typ: typeDouble, optional: true},
+				field{name: "tax", jsonName: "tax", typ: typeDouble, optional: true},
+				field{name: "shipping", jsonName: "shipping", typ: typeDouble, optional: true},
+				field{name: "shipping_method", jsonName: "shipping_method", typ: typeString, optional: true},
+				field{name: "currency", jsonName: "currency", typ: typeString, optional: true},
+				field{name: "status", jsonName: "status", typ: typeInt32},
+				field{name: "order_id", jsonName: "order_id", typ: typeString, optional: true},
+				field{name: "transaction_id", jsonName: "transaction_id", typ: typeString, optional: true},
+				field{name: "name", jsonName: "name", typ: typeString},
+			),
+			message("CartEvent",
+				field{name: "type", jsonName: "type", typ: typeString},
+				field{name: "cart_id", jsonName: "cartId", typ: typeString},
+				field{name: "occurred_at", jsonName: "occurredAt", typ: typeMessage, typeName: ".google.protobuf.Timestamp"},
+				field{name: "cart", jsonName: "cart", typ: typeMessage, typeName: ".cart.events.v1.Cart"},
+			),
+			message("OrderCompletedEvent",
+				field{name: "order_id", jsonName: "orderId", typ: typeString},
+				field{name: "cart_id", jsonName: "cartId", typ: typeString},
+				field{name: "user_id", jsonName: "userId", typ: typeString},
+				field{name: "transaction_id", jsonName: "transactionId", typ: typeString},
+				field{name: "order_date", jsonName: "orderDate", typ: typeString},
+			),
+			message("PriceChangedEvent",
+				field{name: "product_id", jsonName: "productId", typ: typeInt32},
+				field{name: "price", jsonName: "price", typ: typeDouble},
+			),
+			message("OrderCancelledEvent",
+				field{name: "order_id", jsonName: "orderId", typ: typeString},
+				field{name: "cart_id", jsonName: "cartId", typ: typeString},
+				field{name: "user_id", jsonName: "userId", typ: typeString},
+			),
+			message("ItemDiscontinuedEvent",
+				field{name: "product_id", jsonName: "productId", typ: typeInt32},
+			),
+		},
+	}
+}
@@ -0,0 +1,52 @@
+// Package flags toggles behaviours of the api per deployment
+package flags
+
+import (
+	"context"
+	"sort"
+)
+
+// Names of known flags
+const (
+	Coupons = "coupons"
+	ETags   = "etags"
+	Tax     = "tax"
+)
+
+// Defaults are values of known flags when a provider doesn't set them
+var Defaults = map[string]bool{
+	Coupons: true,
+	ETags:   true,
+	Tax:     true,
+}
+
+// Names returns sorted names of known flags
+func Names() []string {
+	names := make([]string, 0, len(Defaults))
+	for name := range Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Static is a fixed set of flag values, e.g. read from the environment,
+// flags it doesn't set fall back to Defaults
+type Static map[string]bool
+
+// Enabled reports whether the flag is on, unknown flags are off
+func (s Static) Enabled(ctx context.Context, name string) bool {
+	if v, ok := s[name]; ok {
+		return v
+	}
+	return Defaults[name]
+}
+
+// Flags returns values of all known flags
+func (s Static) Flags(ctx context.Context) map[string]bool {
+	values := make(map[string]bool, len(Defaults))
+	for name := range Defaults {
+		values[name] = s.Enabled(ctx, name)
+	}
+	return values
+}
@@ -0,0 +1,19 @@
+package flags
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatic(t *testing.T) {
+	ctx := context.Background()
+	s := Static{Coupons: false}
+
+	assert.False(t, s.Enabled(ctx, Coupons))
+	assert.True(t, s.Enabled(ctx, ETags))
+	assert.False(t, s.Enabled(ctx, "unknown"))
+	assert.Equal(t, map[string]bool{Coupons: false, ETags: true, Tax: true}, s.Flags(ctx))
+	assert.Equal(t, []string{Coupons, ETags, Tax}, Names())
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/jurabek/cart-api/internal/models"
 	pbv1 "github.com/jurabek/cart-api/pb/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var _ pbv1.CartServiceServer = (*cartGrpcService)(nil)
@@ -27,8 +29,8 @@ func mapBasketToCartResponse(cart *models.Cart) *pbv1.GetCartResponse {
 	var cartItems []*pbv1.CartItem
 	for _, basketItem := range cart.LineItems {
 		cartItems = append(cartItems, &pbv1.CartItem{
-			ItemId:   int64(basketItem.ItemID),
-			Price:    basketItem.UnitPrice,
+			ItemId:   int64(basketItem.Product()),
+			Price:    float32(basketItem.UnitPrice.Major()),
 			Quantity: int64(basketItem.Quantity),
 		})
 	}
@@ -39,7 +41,8 @@ func mapBasketToCartResponse(cart *models.Cart) *pbv1.GetCartResponse {
 	}
 }
 
-// GetCustomerCart implements v1.CartServiceServer
+// GetCustomerCart implements v1.CartServiceServer, the response is projected
+// to the read mask of the request
 func (s *cartGrpcService) GetCart(
 	ctx context.Context,
 	req *pbv1.GetCartRequest,
@@ -48,5 +51,9 @@ func (s *cartGrpcService) GetCart(
 	if err != nil {
 		return nil, err
 	}
-	return mapBasketToCartResponse(customerBasket), nil
+	resp := mapBasketToCartResponse(customerBasket)
+	if err := project(resp, req.GetReadMask()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return resp, nil
 }
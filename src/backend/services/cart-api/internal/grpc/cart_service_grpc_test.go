@@ -0,0 +1,73 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	pbv1 "github.com/jurabek/cart-api/pb/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestGetCart(t *testing.T) {
+	ctx := context.Background()
+	repo := repositoriestest.NewMemoryRepository()
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 250}, Quantity: 2},
+		{ItemID: 2, ProductID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 1},
+	}}
+	require.NoError(t, repo.Update(ctx, cart))
+	service := NewCartGrpcService(repo)
+
+	get := func(t *testing.T, paths ...string) *pbv1.GetCartResponse {
+		t.Helper()
+		req := &pbv1.GetCartRequest{CartId: cart.ID.String()}
+		if paths != nil {
+			req.ReadMask = &fieldmaskpb.FieldMask{Paths: paths}
+		}
+		resp, err := service.GetCart(ctx, req)
+		require.NoError(t, err)
+		return resp
+	}
+	full := &pbv1.GetCartResponse{CartId: cart.ID.String(), Items: []*pbv1.CartItem{
+		{ItemId: 1, Price: 2.5, Quantity: 2},
+		{ItemId: 7, Price: 1, Quantity: 1},
+	}}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  *pbv1.GetCartResponse
+	}{
+		{"without mask", nil, full},
+		{"empty mask", []string{}, full},
+		{"cart id", []string{"cart_id"}, &pbv1.GetCartResponse{CartId: cart.ID.String()}},
+		{"whole items", []string{"items"}, &pbv1.GetCartResponse{Items: full.Items}},
+		{
+			"fields of items",
+			[]string{"items.item_id", "items.quantity"},
+			&pbv1.GetCartResponse{Items: []*pbv1.CartItem{{ItemId: 1, Quantity: 2}, {ItemId: 7, Quantity: 1}}},
+		},
+		{"items and a field of them", []string{"items.price", "items", "cart_id"}, full},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := get(t, tt.paths...)
+			assert.True(t, proto.Equal(tt.want, got), "got %v", got)
+		})
+	}
+
+	t.Run("unknown fields should be invalid", func(t *testing.T) {
+		for _, path := range []string{"total", "items.name", "cart_id.value", ""} {
+			_, err := service.GetCart(ctx, &pbv1.GetCartRequest{CartId: cart.ID.String(), ReadMask: &fieldmaskpb.FieldMask{Paths: []string{path}}})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err), path)
+		}
+	})
+}
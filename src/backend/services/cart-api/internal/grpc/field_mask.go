@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// maskTree is a field mask by field name, an empty subtree selects the
+// whole field
+type maskTree map[string]maskTree
+
+// project clears fields of msg the mask doesn't select, an empty mask selects
+// every field. Paths traverse messages and elements of repeated messages,
+// unknown fields fail the projection
+func project(msg proto.Message, mask *fieldmaskpb.FieldMask) error {
+	if len(mask.GetPaths()) == 0 {
+		return nil
+	}
+	m := msg.ProtoReflect()
+	tree := maskTree{}
+	for _, path := range mask.GetPaths() {
+		if err := tree.add(m.Descriptor(), strings.Split(path, ".")); err != nil {
+			return fmt.Errorf("invalid path %q: %w", path, err)
+		}
+	}
+	tree.prune(m)
+	return nil
+}
+
+func (t maskTree) add(desc protoreflect.MessageDescriptor, names []string) error {
+	fd := desc.Fields().ByName(protoreflect.Name(names[0]))
+	if fd == nil {
+		return fmt.Errorf("%s has no field %s", desc.FullName(), names[0])
+	}
+	sub, ok := t[names[0]]
+	if ok && len(sub) == 0 {
+		// the whole field is selected already
+		return nil
+	}
+	if len(names) == 1 {
+		t[names[0]] = maskTree{}
+		return nil
+	}
+	if fd.Message() == nil || fd.IsMap() {
+		return fmt.Errorf("field %s of %s has no fields", names[0], desc.FullName())
+	}
+	if !ok {
+		sub = maskTree{}
+	}
+	if err := sub.add(fd.Message(), names[1:]); err != nil {
+		return err
+	}
+	t[names[0]] = sub
+	return nil
+}
+
+func (t maskTree) prune(m protoreflect.Message) {
+	var cleared []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		sub, ok := t[string(fd.Name())]
+		switch {
+		case !ok:
+			cleared = append(cleared, fd)
+		case len(sub) == 0:
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				sub.prune(list.Get(i).Message())
+			}
+		default:
+			sub.prune(v.Message())
+		}
+		return true
+	})
+	for _, fd := range cleared {
+		m.Clear(fd)
+	}
+}
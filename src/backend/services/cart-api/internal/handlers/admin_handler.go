@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// defaultRecomputeCount is a SCAN count hint of a batch recompute page
+const defaultRecomputeCount = 100
+
+type TotalRecomputer interface {
+	RecomputeTotal(ctx context.Context, cartID string) (*models.Cart, error)
+	RecomputeTotals(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error)
+}
+
+// AdminHandler serves maintenance endpoints
+type AdminHandler struct {
+	recomputer TotalRecomputer
+}
+
+// NewAdminHandler creates new instance of AdminHandler
+func NewAdminHandler(r TotalRecomputer) *AdminHandler {
+	return &AdminHandler{recomputer: r}
+}
+
+// RecomputeTotal go doc
+//
+//	@Summary		Recomputes cart total
+//	@Description	Recalculates total from line items and persists it when drifted
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			id	path		string	true	"Cart ID"
+//	@Success		200	{object}	models.Cart
+//	@Failure		401	{object}	models.HTTPError
+//	@Failure		403	{object}	models.HTTPError
+//	@Failure		404	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/admin/carts/{id}/recompute	[post]
+func (h *AdminHandler) RecomputeTotal(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	cartID := r.PathValue("id")
+	cart, err := h.recomputer.RecomputeTotal(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+cartID))
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return writeJSON(w, r, cart)
+}
+
+// RecomputeTotals go doc
+//
+//	@Summary		Recomputes totals of a page of carts
+//	@Description	Recalculates totals of carts in one SCAN page, call again with returned cursor until it is zero
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			cursor	query		int	false	"Scan cursor"
+//	@Param			count	query		int	false	"Scan count hint"
+//	@Success		200		{object}	models.RecomputeTotalsResp
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		401		{object}	models.HTTPError
+//	@Failure		403		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/admin/carts/recompute	[post]
+func (h *AdminHandler) RecomputeTotals(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	cursor, count, err := scanPage(r)
+	if err != nil {
+		return err
+	}
+
+	corrected, next, err := h.recomputer.RecomputeTotals(r.Context(), cursor, count)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	resp := models.RecomputeTotalsResp{Corrected: corrected, Cursor: next}
+	return writeJSON(w, r, resp)
+}
+
+// scanPage reads the cursor and count query parameters of a batch page
+func scanPage(r *http.Request) (uint64, int64, error) {
+	var cursor uint64
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		c, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, 0, models.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "cursor"))
+		}
+		cursor = c
+	}
+	count := int64(defaultRecomputeCount)
+	if v := r.URL.Query().Get("count"); v != "" {
+		c, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || c <= 0 {
+			return 0, 0, models.NewHTTPError(http.StatusBadRequest, errors.New("count must be a positive integer"))
+		}
+		count = c
+	}
+	return cursor, count, nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type TotalRecomputerMock struct {
+	mock.Mock
+}
+
+func (m *TotalRecomputerMock) RecomputeTotal(ctx context.Context, cartID string) (*models.Cart, error) {
+	args := m.Called(ctx, cartID)
+	cart, _ := args.Get(0).(*models.Cart)
+	return cart, args.Error(1)
+}
+
+func (m *TotalRecomputerMock) RecomputeTotals(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	args := m.Called(ctx, cursor, count)
+	ids, _ := args.Get(0).([]string)
+	return ids, args.Get(1).(uint64), args.Error(2)
+}
+
+var _ TotalRecomputer = (*TotalRecomputerMock)(nil)
+
+// adminRequest creates a request of a caller with the admin role
+func adminRequest(method, target string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set(UserRoleHeader, adminRole)
+	return r
+}
+
+func TestAdminHandler(t *testing.T) {
+	cart := &models.Cart{ID: uuid.New(), LineItems: items, Total: models.Money{Minor: 2000}}
+
+	recomputer := &TotalRecomputerMock{}
+	recomputer.On("RecomputeTotal", mock.Anything, "abcd").Return(cart, nil)
+	recomputer.On("RecomputeTotal", mock.Anything, "missing").Return(nil, repositories.ErrCartNotFound)
+	recomputer.On("RecomputeTotals", mock.Anything, uint64(0), int64(defaultRecomputeCount)).Return([]string{"abcd"}, uint64(17), nil)
+	recomputer.On("RecomputeTotals", mock.Anything, uint64(17), int64(10)).Return([]string{}, uint64(0), nil)
+	handler := NewAdminHandler(recomputer)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/carts/{id}/recompute", ErrorHandler(handler.RecomputeTotal))
+	mux.HandleFunc("POST /admin/carts/recompute", ErrorHandler(handler.RecomputeTotals))
+
+	t.Run("recompute should return corrected cart", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/carts/abcd/recompute"))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var got models.Cart
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, models.Money{Minor: 2000}, got.Total)
+	})
+
+	t.Run("recompute of missing cart should return 404", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/carts/missing/recompute"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("batch should return corrected ids and next cursor", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/carts/recompute"))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var got models.RecomputeTotalsResp
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, models.RecomputeTotalsResp{Corrected: []string{"abcd"}, Cursor: 17}, got)
+	})
+
+	t.Run("batch should pass cursor and count", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/carts/recompute?cursor=17&count=10"))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("invalid count should return 400", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/carts/recompute?count=-1"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("callers without admin role should be rejected", func(t *testing.T) {
+		for _, path := range []string{"/admin/carts/abcd/recompute", "/admin/carts/recompute"} {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			r := httptest.NewRequest(http.MethodPost, path, nil)
+			r.Header.Set(UserRoleHeader, "customer")
+			w = httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			assert.Equal(t, http.StatusForbidden, w.Code)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStockChecker records lookups, products missing from stock fail
+type countingStockChecker struct {
+	stock   map[int]int
+	lookups map[int]int
+}
+
+func (c *countingStockChecker) Available(ctx context.Context, productID int) (int, error) {
+	c.lookups[productID]++
+	available, ok := c.stock[productID]
+	if !ok {
+		return 0, errors.New("inventory unavailable")
+	}
+	return available, nil
+}
+
+func getCartWithAvailability(t *testing.T, checker StockChecker, target string) (*httptest.ResponseRecorder, *CartRepositoryMock) {
+	t.Helper()
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+		{ItemID: 1, Quantity: 2},
+		{ItemID: 2, Quantity: 5},
+		{ItemID: 2, Quantity: 1},
+		{ItemID: 3, Quantity: 1},
+		{ItemID: 4, Quantity: 1},
+	}}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+	handler := NewCartHandler(repo, WithStockChecker(checker))
+
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.SetPathValue("id", "abcd")
+	w := httptest.NewRecorder()
+	ErrorHandler(handler.Get)(w, r)
+	return w, repo
+}
+
+func TestGetWithAvailability(t *testing.T) {
+	checker := &countingStockChecker{
+		stock:   map[int]int{1: 10, 2: 3, 3: math.MaxInt},
+		lookups: map[int]int{},
+	}
+	w, _ := getCartWithAvailability(t, checker, "/cart/abcd?withAvailability=true")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var result struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Len(t, result.Items, 5)
+
+	assert.Equal(t, true, result.Items[0]["available"])
+	assert.Equal(t, float64(10), result.Items[0]["available_quantity"])
+	assert.Equal(t, false, result.Items[1]["available"])
+	assert.Equal(t, float64(3), result.Items[1]["available_quantity"])
+	assert.Equal(t, true, result.Items[2]["available"])
+
+	// unlimited stock has no quantity
+	assert.Equal(t, true, result.Items[3]["available"])
+	assert.NotContains(t, result.Items[3], "available_quantity")
+
+	// failed lookups leave the item unannotated
+	assert.NotContains(t, result.Items[4], "available")
+	assert.NotContains(t, result.Items[4], "available_quantity")
+
+	assert.Equal(t, map[int]int{1: 1, 2: 1, 3: 1, 4: 1}, checker.lookups)
+}
+
+func TestGetWithoutAvailability(t *testing.T) {
+	for _, target := range []string{"/cart/abcd", "/cart/abcd?withAvailability=false"} {
+		t.Run(target, func(t *testing.T) {
+			checker := &countingStockChecker{stock: map[int]int{1: 10}, lookups: map[int]int{}}
+			w, _ := getCartWithAvailability(t, checker, target)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			assert.NotContains(t, w.Body.String(), "available")
+			assert.Empty(t, checker.lookups)
+		})
+	}
+}
+
+func TestGetWithInvalidAvailability(t *testing.T) {
+	checker := &countingStockChecker{lookups: map[int]int{}}
+	w, repo := getCartWithAvailability(t, checker, "/cart/abcd?withAvailability=maybe")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	repo.AssertNotCalled(t, "Get", mock.Anything, "abcd")
+	assert.Empty(t, checker.lookups)
+}
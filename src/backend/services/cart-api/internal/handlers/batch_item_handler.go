@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// maxBatchCarts bounds cart ids of a single batch request, larger
+// promotions page through all carts instead
+const maxBatchCarts = 1000
+
+type CartsItemAdder interface {
+	ScanCartIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error)
+	AddItemToCarts(ctx context.Context, cartIDs []string, item models.LineItem, once bool) []error
+}
+
+// BatchItemHandler applies one item change to many carts, e.g. a free
+// sample of a promotion
+type BatchItemHandler struct {
+	carts CartsItemAdder
+}
+
+// NewBatchItemHandler creates new instance of BatchItemHandler
+func NewBatchItemHandler(carts CartsItemAdder) *BatchItemHandler {
+	return &BatchItemHandler{carts: carts}
+}
+
+// AddItem go doc
+//
+//	@Summary		Adds a line item to many carts
+//	@Description	Adds the item to every listed cart, or with all to carts of one SCAN page, call again with returned cursor until it is zero. Best effort: outcome of every cart is returned and a failing cart does not stop others, missing carts of a scan and carts having the product already are skipped
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			cursor	query		int						false	"Scan cursor"
+//	@Param			count	query		int						false	"Scan count hint"
+//	@Param			request	body		models.BatchAddItemReq	true	"Item and carts"
+//	@Success		200		{object}	models.BatchCartsResp
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		401		{object}	models.HTTPError
+//	@Failure		403		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/admin/carts:addItem	[post]
+func (h *BatchItemHandler) AddItem(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	var req models.BatchAddItemReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Item.Quantity <= 0 {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("item quantity must be positive"))
+	}
+	if err := validateItem(req.Item); err != nil {
+		return err
+	}
+	if req.All == (len(req.CartIDs) > 0) {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("either cart_ids or all is required"))
+	}
+	if len(req.CartIDs) > maxBatchCarts {
+		return models.NewHTTPError(http.StatusBadRequest, fmt.Errorf("at most %d cart ids are allowed", maxBatchCarts))
+	}
+	for _, id := range req.CartIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return models.NewHTTPError(http.StatusBadRequest, errors.Wrap(err, "cart id "+id))
+		}
+	}
+
+	cartIDs, next := req.CartIDs, uint64(0)
+	if req.All {
+		cursor, count, err := scanPage(r)
+		if err != nil {
+			return err
+		}
+		if cartIDs, next, err = h.carts.ScanCartIDs(r.Context(), cursor, count); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, err)
+		}
+	}
+
+	// SCAN may return a cart twice, with all carts get the item once
+	errs := h.carts.AddItemToCarts(r.Context(), cartIDs, req.Item, req.All)
+	resp := models.BatchCartsResp{Results: make([]models.BatchCartResult, 0, len(cartIDs)), Cursor: next}
+	for i, id := range cartIDs {
+		// scanned carts may be completed or expired meanwhile, or have been
+		// visited already
+		if req.All && (errors.Is(errs[i], repositories.ErrCartNotFound) || errors.Is(errs[i], repositories.ErrItemInCart)) {
+			continue
+		}
+		result := models.BatchCartResult{CartID: id, Status: cartStatus(errs[i])}
+		if errs[i] != nil {
+			result.Error = errs[i].Error()
+			result.ErrorCode = models.BusinessRuleCode(errs[i])
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return writeJSON(w, r, resp)
+}
+
+// cartStatus maps an error of a single cart to the status it would get
+func cartStatus(err error) int {
+	if errors.Is(err, repositories.ErrCartNotFound) {
+		return http.StatusNotFound
+	}
+	return itemStatus(err)
+}
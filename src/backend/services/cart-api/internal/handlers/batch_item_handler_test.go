@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchItemHandlerAddItem(t *testing.T) {
+	ctx := context.Background()
+	sample := models.LineItem{ItemID: 9, Quantity: 1, ProductName: "sample"}
+
+	setup := func(t *testing.T) (*repositoriestest.MemoryRepository, []string) {
+		repo := repositoriestest.NewMemoryRepository()
+		var ids []string
+		for i := 0; i < 2; i++ {
+			cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+			require.NoError(t, repo.Update(ctx, cart))
+			ids = append(ids, cart.ID.String())
+		}
+		return repo, ids
+	}
+	post := func(repo *repositoriestest.MemoryRepository, role string, req models.BatchAddItemReq) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/admin/carts:addItem", bytes.NewReader(body))
+		r.Header.Set(UserRoleHeader, role)
+		w := httptest.NewRecorder()
+		ErrorHandler(NewBatchItemHandler(repo).AddItem)(w, r)
+		return w
+	}
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) models.BatchCartsResp {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp models.BatchCartsResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp
+	}
+
+	t.Run("listed carts should get the item and a failing cart its error", func(t *testing.T) {
+		repo, ids := setup(t)
+		missing := uuid.NewString()
+		resp := decode(t, post(repo, adminRole, models.BatchAddItemReq{CartIDs: []string{ids[0], missing, ids[1]}, Item: sample}))
+		require.Len(t, resp.Results, 3)
+		assert.Equal(t, models.BatchCartResult{CartID: ids[0], Status: http.StatusOK}, resp.Results[0])
+		assert.Equal(t, missing, resp.Results[1].CartID)
+		assert.Equal(t, http.StatusNotFound, resp.Results[1].Status)
+		assert.NotEmpty(t, resp.Results[1].Error)
+		assert.Equal(t, models.BatchCartResult{CartID: ids[1], Status: http.StatusOK}, resp.Results[2])
+
+		for _, id := range ids {
+			cart, err := repo.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, []models.LineItem{sample}, cart.LineItems)
+		}
+	})
+
+	t.Run("all should add the item to scanned carts", func(t *testing.T) {
+		repo, ids := setup(t)
+		resp := decode(t, post(repo, adminRole, models.BatchAddItemReq{All: true, Item: sample}))
+		assert.Len(t, resp.Results, len(ids))
+		assert.Zero(t, resp.Cursor)
+
+		// SCAN may return carts again, they keep a single sample
+		resp = decode(t, post(repo, adminRole, models.BatchAddItemReq{All: true, Item: sample}))
+		assert.Empty(t, resp.Results)
+		for _, id := range ids {
+			cart, err := repo.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, []models.LineItem{sample}, cart.LineItems)
+		}
+	})
+
+	t.Run("invalid requests should be rejected", func(t *testing.T) {
+		repo, ids := setup(t)
+		assert.Equal(t, http.StatusForbidden, post(repo, "customer", models.BatchAddItemReq{CartIDs: ids, Item: sample}).Code)
+		assert.Equal(t, http.StatusBadRequest, post(repo, adminRole, models.BatchAddItemReq{Item: sample}).Code)
+		assert.Equal(t, http.StatusBadRequest, post(repo, adminRole, models.BatchAddItemReq{CartIDs: ids, All: true, Item: sample}).Code)
+		assert.Equal(t, http.StatusBadRequest, post(repo, adminRole, models.BatchAddItemReq{CartIDs: ids, Item: models.LineItem{ItemID: 9}}).Code)
+		assert.Equal(t, http.StatusBadRequest, post(repo, adminRole, models.BatchAddItemReq{CartIDs: []string{"not-a-cart"}, Item: sample}).Code)
+	})
+}
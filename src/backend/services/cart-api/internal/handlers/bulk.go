@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// Modes of bulk operations set by the mode query parameter
+const (
+	bulkModeAtomic  = "atomic"
+	bulkModePartial = "partial"
+)
+
+// partialMode reads the mode query parameter, atomic is the default
+func partialMode(r *http.Request) (bool, error) {
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", bulkModeAtomic:
+		return false, nil
+	case bulkModePartial:
+		return true, nil
+	default:
+		return false, models.NewHTTPError(http.StatusBadRequest, fmt.Errorf("unknown mode %q", mode))
+	}
+}
+
+// itemStatus maps an error of a single item to the status it would get
+func itemStatus(err error) int {
+	var httpErr *models.HTTPError
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, models.ErrBusinessRule):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &httpErr):
+		return httpErr.Code
+	case errors.Is(err, repositories.ErrItemNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// bulkError maps an error failing the whole batch
+func bulkError(err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrCartNotFound), errors.Is(err, repositories.ErrItemNotFound):
+		return models.NewHTTPError(http.StatusNotFound, err)
+	case errors.Is(err, models.ErrBusinessRule):
+		return err
+	}
+	return models.NewHTTPError(http.StatusInternalServerError, err)
+}
+
+func setResult(result *models.BulkItemResult, err error) {
+	result.Status = itemStatus(err)
+	if err != nil {
+		result.Error = err.Error()
+		result.ErrorCode = models.BusinessRuleCode(err)
+	}
+}
+
+// setResults sets the outcome of entries merged into one item
+func setResults(results []models.BulkItemResult, indexes []int, err error) {
+	for _, i := range indexes {
+		setResult(&results[i], err)
+	}
+}
+
+// mergeDuplicates folds entries of the same item into the first one with
+// quantities summed, so the item is checked and added once as if it was
+// sent with the total quantity. Entries without quantity count as the
+// default quantity, without a default such an entry makes the merged item
+// rejected as a whole. Indexes of the entries are returned for every merged
+// item
+func (h *CartHandler) mergeDuplicates(items []models.LineItem) ([]models.LineItem, [][]int) {
+	merged := make([]models.LineItem, 0, len(items))
+	indexes := make([][]int, 0, len(items))
+	positions := make(map[int]int, len(items))
+	for i, item := range items {
+		pos, ok := positions[item.Product()]
+		if !ok {
+			positions[item.Product()] = len(merged)
+			merged = append(merged, item)
+			indexes = append(indexes, []int{i})
+			continue
+		}
+		indexes[pos] = append(indexes[pos], i)
+		first, next := h.entryQuantity(merged[pos]), h.entryQuantity(item)
+		if first == 0 || next == 0 {
+			merged[pos].Quantity = 0
+			continue
+		}
+		merged[pos].Quantity = first + next
+	}
+	return merged, indexes
+}
+
+// entryQuantity is the quantity prepareItem would add for a single entry
+func (h *CartHandler) entryQuantity(item models.LineItem) int {
+	if item.Quantity == 0 {
+		return h.defaultQuantity
+	}
+	return item.Quantity
+}
+
+// AddItems go doc
+//
+//	@Summary		Adds line items
+//	@Description	Adds items or increments their quantity in one step, all or none by default. With mode=partial valid items are added and outcome of every item is returned with 207. Entries of the same item_id are merged into the first one by summing quantities and share its outcome
+//	@Tags			Cart
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Cart ID"
+//	@Param			mode	query		string					false	"atomic or partial"
+//	@Param			items	body		models.BulkAddItemsReq	true	"Line items"
+//	@Success		200		""
+//	@Success		207		{object}	models.BulkItemsResp
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		422		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/{id}/items	[post]
+func (h *CartHandler) AddItems(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	h.traceCart(r.Context(), cartID, -1)
+	partial, err := partialMode(r)
+	if err != nil {
+		return err
+	}
+	var req models.BulkAddItemsReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if len(req.Items) == 0 {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("items are required"))
+	}
+
+	results := make([]models.BulkItemResult, len(req.Items))
+	for i, item := range req.Items {
+		results[i] = models.BulkItemResult{Index: i, ItemID: item.ItemID}
+	}
+	items, indexes := h.mergeDuplicates(req.Items)
+	inCart, err := h.cartQuantities(r.Context(), cartID)
+	if err != nil {
+		return err
+	}
+	var accepted []models.LineItem
+	var acceptedIndexes [][]int
+	for i := range items {
+		item := items[i]
+		if err := h.prepareItem(w, r, cartID, &item, inCart); err != nil {
+			if !partial {
+				return errors.Wrapf(err, "item %d", indexes[i][0])
+			}
+			setResults(results, indexes[i], err)
+			continue
+		}
+		accepted = append(accepted, item)
+		acceptedIndexes = append(acceptedIndexes, indexes[i])
+	}
+
+	if len(accepted) > 0 {
+		itemErrs, err := h.repository.AddItems(r.Context(), cartID, accepted, partial)
+		if err != nil {
+			return bulkError(err)
+		}
+		for j, err := range itemErrs {
+			setResults(results, acceptedIndexes[j], err)
+		}
+	}
+	if !partial {
+		return nil
+	}
+	return writeJSONStatus(w, r, http.StatusMultiStatus, models.BulkItemsResp{Results: results})
+}
+
+// DeleteItems go doc
+//
+//	@Summary		Deletes line items
+//	@Description	Deletes items in one step, all or none by default. With mode=partial existing items are deleted and outcome of every item is returned with 207
+//	@Tags			Cart
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string						true	"Cart ID"
+//	@Param			mode	query		string						false	"atomic or partial"
+//	@Param			items	body		models.BulkDeleteItemsReq	true	"Item IDs"
+//	@Success		200		""
+//	@Success		207		{object}	models.BulkItemsResp
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/{id}/items	[delete]
+func (h *CartHandler) DeleteItems(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	h.traceCart(r.Context(), cartID, -1)
+	partial, err := partialMode(r)
+	if err != nil {
+		return err
+	}
+	var req models.BulkDeleteItemsReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if len(req.ItemIDs) == 0 {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("item_ids are required"))
+	}
+
+	itemErrs, err := h.repository.DeleteItems(r.Context(), cartID, req.ItemIDs, partial)
+	if err != nil {
+		return bulkError(err)
+	}
+	if !partial {
+		return nil
+	}
+	results := make([]models.BulkItemResult, len(req.ItemIDs))
+	for i, itemID := range req.ItemIDs {
+		results[i] = models.BulkItemResult{Index: i, ItemID: itemID}
+		setResult(&results[i], itemErrs[i])
+	}
+	return writeJSONStatus(w, r, http.StatusMultiStatus, models.BulkItemsResp{Results: results})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/pkg/errors"
+)
+
+// Bounds of a bulk create request, they keep a single request from
+// occupying redis for long
+const (
+	maxBulkCreateCarts     = 1000
+	maxBulkCreateItems     = 20
+	defaultBulkCreateItems = 3
+)
+
+// requireAdmin allows only callers with the admin role set by the gateway
+func requireAdmin(r *http.Request) error {
+	role := r.Header.Get(UserRoleHeader)
+	if role == "" {
+		return models.NewHTTPError(http.StatusUnauthorized, errors.New(UserRoleHeader+" is required"))
+	}
+	if role != adminRole {
+		return models.NewHTTPError(http.StatusForbidden, errors.New("admin role is required"))
+	}
+	return nil
+}
+
+type CartsCreator interface {
+	CreateCarts(ctx context.Context, carts []*models.Cart) error
+}
+
+// BulkCreateHandler fills redis with random carts for load tests
+type BulkCreateHandler struct {
+	creator     CartsCreator
+	idGenerator IDGenerator
+}
+
+// NewBulkCreateHandler creates new instance of BulkCreateHandler
+func NewBulkCreateHandler(creator CartsCreator, idGenerator IDGenerator) *BulkCreateHandler {
+	return &BulkCreateHandler{creator: creator, idGenerator: idGenerator}
+}
+
+// BulkCreate go doc
+//
+//	@Summary		Creates random carts
+//	@Description	Creates count carts with random line items in one transaction for load tests, disabled unless LOAD_TEST_ENDPOINTS is set
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			carts	body		models.BulkCreateCartsReq	true	"Number of carts"
+//	@Success		201		{object}	models.BulkCreateCartsResp
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		401		{object}	models.HTTPError
+//	@Failure		403		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/admin/carts:bulkCreate	[post]
+func (h *BulkCreateHandler) BulkCreate(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+
+	var req models.BulkCreateCartsReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Count <= 0 || req.Count > maxBulkCreateCarts {
+		return models.NewHTTPError(http.StatusBadRequest, fmt.Errorf("count must be between 1 and %d", maxBulkCreateCarts))
+	}
+	if req.MaxItems == 0 {
+		req.MaxItems = defaultBulkCreateItems
+	}
+	if req.MaxItems < 0 || req.MaxItems > maxBulkCreateItems {
+		return models.NewHTTPError(http.StatusBadRequest, fmt.Errorf("max_items must be between 1 and %d", maxBulkCreateItems))
+	}
+
+	carts := make([]*models.Cart, req.Count)
+	ids := make([]string, req.Count)
+	for i := range carts {
+		id, err := h.idGenerator.NewID()
+		if err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		carts[i] = randomCart(id, req.MaxItems)
+		ids[i] = id.String()
+	}
+	if err := h.creator.CreateCarts(r.Context(), carts); err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return writeJSONStatus(w, r, http.StatusCreated, models.BulkCreateCartsResp{IDs: ids})
+}
+
+// randomCart returns an anonymous cart having 1 to maxItems distinct items
+func randomCart(id uuid.UUID, maxItems int) *models.Cart {
+	userID := "anonymous"
+	cart := &models.Cart{ID: id, UserID: &userID, Status: models.CartStatusNew}
+	n := 1 + rand.Intn(maxItems)
+	for _, itemID := range rand.Perm(1000)[:n] {
+		item := models.LineItem{
+			ItemID:      itemID + 1,
+			UnitPrice:   models.Money{Minor: int64(100 + rand.Intn(5000))},
+			Quantity:    1 + rand.Intn(5),
+			ProductName: fmt.Sprintf("load test product %d", itemID+1),
+		}
+		cart.LineItems = append(cart.LineItems, item)
+		// prices and quantities are small, the line total can't overflow
+		line, _ := item.UnitPrice.Mul(item.Quantity)
+		cart.Total = cart.Total.Add(line)
+	}
+	return cart
+}
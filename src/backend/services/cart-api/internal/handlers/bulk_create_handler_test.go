@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubCartsCreator struct {
+	carts []*models.Cart
+	err   error
+}
+
+func (s *stubCartsCreator) CreateCarts(ctx context.Context, carts []*models.Cart) error {
+	s.carts = carts
+	return s.err
+}
+
+func TestBulkCreateHandler(t *testing.T) {
+	serve := func(creator CartsCreator, role, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/carts:bulkCreate", strings.NewReader(body))
+		if role != "" {
+			req.Header.Set(UserRoleHeader, role)
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(NewBulkCreateHandler(creator, UUIDv4{}).BulkCreate)(w, req)
+		return w
+	}
+
+	t.Run("should create requested count of carts with unique ids", func(t *testing.T) {
+		creator := &stubCartsCreator{}
+		w := serve(creator, adminRole, `{"count": 50, "max_items": 4}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var resp models.BulkCreateCartsResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Len(t, resp.IDs, 50)
+		unique := map[string]bool{}
+		for _, id := range resp.IDs {
+			unique[id] = true
+		}
+		assert.Len(t, unique, 50, "ids must be unique")
+
+		require.Len(t, creator.carts, 50)
+		for i, cart := range creator.carts {
+			assert.Equal(t, resp.IDs[i], cart.ID.String())
+			assert.NotEmpty(t, cart.LineItems)
+			assert.LessOrEqual(t, len(cart.LineItems), 4)
+			assert.Equal(t, calculateTotal(cart.LineItems), cart.Total)
+		}
+	})
+
+	t.Run("should require admin role", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(&stubCartsCreator{}, "", `{"count": 1}`).Code)
+		assert.Equal(t, http.StatusForbidden, serve(&stubCartsCreator{}, "user", `{"count": 1}`).Code)
+	})
+
+	t.Run("should reject invalid counts", func(t *testing.T) {
+		for _, body := range []string{`{"count": 0}`, `{"count": 1001}`, `{"count": 1, "max_items": 21}`, `{"count": 1, "max_items": -1}`, `not json`} {
+			assert.Equal(t, http.StatusBadRequest, serve(&stubCartsCreator{}, adminRole, body).Code, body)
+		}
+	})
+
+	t.Run("failed create should return 500", func(t *testing.T) {
+		w := serve(&stubCartsCreator{err: errors.New("redis down")}, adminRole, `{"count": 1}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func calculateTotal(items []models.LineItem) models.Money {
+	var total models.Money
+	for _, item := range items {
+		line, _ := item.UnitPrice.Mul(item.Quantity)
+		total = total.Add(line)
+	}
+	return total
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartHandlerAddItems(t *testing.T) {
+	burger := models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}
+	fries := models.LineItem{ItemID: 3, UnitPrice: models.Money{Minor: 300}, Quantity: 2}
+	pricey := models.LineItem{ItemID: 4, UnitPrice: models.Money{Minor: 100000}, Quantity: 1}
+
+	serve := func(repo *CartRepositoryMock, query, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/items"+query, strings.NewReader(body))
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo).AddItems)(w, r)
+		return w
+	}
+	mixed := `{"items": [
+		{"item_id": 1, "unit_price": 10, "quantity": 1},
+		{"item_id": 2, "quantity": 1, "image_url": "not-a-url"},
+		{"item_id": 3, "unit_price": 3, "quantity": 2},
+		{"item_id": 4, "unit_price": 1000, "quantity": 1}
+	]}`
+
+	t.Run("partial mode should report every item", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AddItems", mock.Anything, "abcd", []models.LineItem{burger, fries, pricey}, true).
+			Return([]error{nil, nil, repositories.ErrItemPriceExceeded}, nil)
+
+		w := serve(repo, "?mode=partial", mixed)
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+
+		var resp models.BulkItemsResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		statuses := []int{}
+		for i, result := range resp.Results {
+			assert.Equal(t, i, result.Index)
+			statuses = append(statuses, result.Status)
+		}
+		assert.Equal(t, []int{http.StatusOK, http.StatusBadRequest, http.StatusOK, http.StatusUnprocessableEntity}, statuses)
+		assert.Equal(t, 2, resp.Results[1].ItemID)
+		assert.NotEmpty(t, resp.Results[1].Error)
+		assert.Empty(t, resp.Results[0].Error)
+	})
+
+	t.Run("atomic mode should reject the batch with an invalid item", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		w := serve(repo, "", mixed)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		repo.AssertNotCalled(t, "AddItems", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("atomic mode should map repository errors", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AddItems", mock.Anything, "abcd", []models.LineItem{burger}, false).Return(nil, repositories.ErrCartNotFound)
+		w := serve(repo, "?mode=atomic", `{"items": [{"item_id": 1, "unit_price": 10, "quantity": 1}]}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("quantity in the cart should count against stock", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("Get", mock.Anything, "abcd").Return(&models.Cart{LineItems: []models.LineItem{{ItemID: 1, Quantity: 2}}}, nil)
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/items", strings.NewReader(`{"items": [{"item_id": 1, "unit_price": 10, "quantity": 2}]}`))
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo, WithStockChecker(stubStockChecker{1: 3}), WithStockClamp(false)).AddItems)(w, r)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		repo.AssertNotCalled(t, "AddItems", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown mode should return 400", func(t *testing.T) {
+		w := serve(&CartRepositoryMock{}, "?mode=best", mixed)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("duplicate items should be merged by summing quantities", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		merged := burger
+		merged.Quantity = 4
+		repo.On("AddItems", mock.Anything, "abcd", []models.LineItem{merged, fries}, false).Return([]error{nil, nil}, nil)
+		w := serve(repo, "", `{"items": [
+			{"item_id": 1, "unit_price": 10, "quantity": 1},
+			{"item_id": 3, "unit_price": 3, "quantity": 2},
+			{"item_id": 1, "unit_price": 10, "quantity": 3}
+		]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("merged entries should share the outcome", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		merged := pricey
+		merged.Quantity = 2
+		repo.On("AddItems", mock.Anything, "abcd", []models.LineItem{merged, burger}, true).
+			Return([]error{repositories.ErrItemPriceExceeded, nil}, nil)
+		w := serve(repo, "?mode=partial", `{"items": [
+			{"item_id": 4, "unit_price": 1000, "quantity": 1},
+			{"item_id": 1, "unit_price": 10, "quantity": 1},
+			{"item_id": 4, "unit_price": 1000, "quantity": 1}
+		]}`)
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+
+		var resp models.BulkItemsResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		require.Len(t, resp.Results, 3)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Results[0].Status)
+		assert.Equal(t, http.StatusOK, resp.Results[1].Status)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Results[2].Status)
+		assert.Equal(t, 2, resp.Results[2].Index)
+		assert.Equal(t, 4, resp.Results[2].ItemID)
+	})
+
+	t.Run("duplicate without quantity should use default quantity", func(t *testing.T) {
+		h := NewCartHandler(&CartRepositoryMock{})
+		items, indexes := h.mergeDuplicates([]models.LineItem{{ItemID: 1, Quantity: 2}, {ItemID: 1}})
+		assert.Equal(t, []models.LineItem{{ItemID: 1, Quantity: 3}}, items)
+		assert.Equal(t, [][]int{{0, 1}}, indexes)
+
+		h = NewCartHandler(&CartRepositoryMock{}, WithDefaultQuantity(0))
+		items, _ = h.mergeDuplicates([]models.LineItem{{ItemID: 1, Quantity: 2}, {ItemID: 1}})
+		assert.Zero(t, items[0].Quantity, "without default the item should be rejected")
+	})
+}
+
+func TestCartHandlerDeleteItems(t *testing.T) {
+	serve := func(repo *CartRepositoryMock, query string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodDelete, "/cart/abcd/items"+query, strings.NewReader(`{"item_ids": [1, 42]}`))
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo).DeleteItems)(w, r)
+		return w
+	}
+
+	t.Run("partial mode should report missing items", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("DeleteItems", mock.Anything, "abcd", []int{1, 42}, true).Return([]error{nil, repositories.ErrItemNotFound}, nil)
+
+		w := serve(repo, "?mode=partial")
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		var resp models.BulkItemsResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, []models.BulkItemResult{
+			{Index: 0, ItemID: 1, Status: http.StatusOK},
+			{Index: 1, ItemID: 42, Status: http.StatusNotFound, Error: repositories.ErrItemNotFound.Error()},
+		}, resp.Results)
+	})
+
+	t.Run("atomic mode should return 404 for missing items", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("DeleteItems", mock.Anything, "abcd", []int{1, 42}, false).Return(nil, repositories.ErrItemNotFound)
+		assert.Equal(t, http.StatusNotFound, serve(repo, "").Code)
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBusinessRuleViolations(t *testing.T) {
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) models.HTTPError {
+		t.Helper()
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		var body models.HTTPError
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		return body
+	}
+	addItem := func(handler *CartHandler, item models.LineItem) *httptest.ResponseRecorder {
+		r := newItemRequest(t, http.MethodPost, "/cart/cart-1/item", item)
+		r.SetPathValue("id", "cart-1")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.AddItem)(w, r)
+		return w
+	}
+
+	for _, tt := range []struct {
+		err  error
+		code string
+	}{
+		{repositories.ErrItemPriceExceeded, "item_price_exceeded"},
+		{repositories.ErrCartTotalExceeded, "cart_total_exceeded"},
+		{repositories.ErrItemQuantityExceeded, "item_quantity_exceeded"},
+	} {
+		t.Run("repository "+tt.code, func(t *testing.T) {
+			repo := &CartRepositoryMock{}
+			repo.On("AddItem", mock.Anything, "cart-1", mock.Anything).Return(fmt.Errorf("%w: item 1", tt.err))
+
+			body := decode(t, addItem(NewCartHandler(repo), models.LineItem{ItemID: 1, Quantity: 1}))
+			assert.Equal(t, tt.code, body.ErrorCode)
+			assert.Equal(t, tt.err.Error()+": item 1", body.Message)
+		})
+	}
+
+	t.Run("amount overflow", func(t *testing.T) {
+		repo := repositoriestest.NewMemoryRepository()
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(context.Background(), cart))
+		r := newItemRequest(t, http.MethodPost, "/cart/"+cart.ID.String()+"/item", models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: math.MaxInt64 / 2}, Quantity: 3})
+		r.SetPathValue("id", cart.ID.String())
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo).AddItem)(w, r)
+
+		assert.Equal(t, "amount_overflow", decode(t, w).ErrorCode)
+	})
+
+	t.Run("quantity above the maximum is invalid", func(t *testing.T) {
+		w := addItem(NewCartHandler(&CartRepositoryMock{}), models.LineItem{ItemID: 1, Quantity: models.MaxItemQuantity + 1})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), models.ErrQuantityTooLarge.Error())
+	})
+
+	t.Run("negative quantity", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AdjustItemQuantity", mock.Anything, "cart-1", 1, -5, false).Return(repositories.ErrNegativeQuantity)
+		r := httptest.NewRequest(http.MethodPost, "/cart/cart-1/item/1/quantity", strings.NewReader(`{"delta": -5}`))
+		r.SetPathValue("id", "cart-1")
+		r.SetPathValue("itemID", "1")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo, WithClampQuantity(false)).AdjustItemQuantity)(w, r)
+
+		assert.Equal(t, "negative_quantity", decode(t, w).ErrorCode)
+	})
+
+	t.Run("adjusted quantity above the maximum", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AdjustItemQuantity", mock.Anything, "cart-1", 1, math.MaxInt, false).Return(repositories.ErrQuantityTooLarge)
+		r := httptest.NewRequest(http.MethodPost, "/cart/cart-1/item/1/quantity", strings.NewReader(fmt.Sprintf(`{"delta": %d}`, math.MaxInt)))
+		r.SetPathValue("id", "cart-1")
+		r.SetPathValue("itemID", "1")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo, WithClampQuantity(false)).AdjustItemQuantity)(w, r)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "quantity_too_large", decode(t, w).ErrorCode)
+	})
+
+	t.Run("stock", func(t *testing.T) {
+		stock := stubStockChecker{1: 0, 2: 3}
+		repo := &CartRepositoryMock{}
+		repo.On("Get", mock.Anything, mock.Anything).Return((*models.Cart)(nil), repositories.ErrCartNotFound)
+		handler := NewCartHandler(repo, WithStockChecker(stock), WithStockClamp(false))
+
+		assert.Equal(t, "out_of_stock", decode(t, addItem(handler, models.LineItem{ItemID: 1, Quantity: 1})).ErrorCode)
+		assert.Equal(t, "insufficient_stock", decode(t, addItem(handler, models.LineItem{ItemID: 2, Quantity: 5})).ErrorCode)
+	})
+
+	t.Run("status chosen by handler is overridden", func(t *testing.T) {
+		handler := func(w http.ResponseWriter, r *http.Request) error {
+			return models.NewHTTPError(http.StatusInternalServerError, fmt.Errorf("%w: item 1", repositories.ErrItemQuantityExceeded))
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(handler)(w, httptest.NewRequest(http.MethodPost, "/cart/cart-1/item", nil))
+
+		body := decode(t, w)
+		assert.Equal(t, "item_quantity_exceeded", body.ErrorCode)
+		assert.Equal(t, "item quantity exceeds the product limit: item 1", body.Message)
+	})
+
+	t.Run("problem details carry the code", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AddItem", mock.Anything, "cart-1", mock.Anything).Return(repositories.ErrCartTotalExceeded)
+		r := newItemRequest(t, http.MethodPost, "/cart/cart-1/item", models.LineItem{ItemID: 1, Quantity: 1})
+		r.SetPathValue("id", "cart-1")
+		r.Header.Set("Accept", ProblemContentType)
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo).AddItem)(w, r)
+
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		var problem models.ProblemDetails
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, "cart_total_exceeded", problem.ErrorCode)
+	})
+
+	t.Run("partial bulk results carry the code", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AddItems", mock.Anything, "cart-1", mock.Anything, true).Return([]error{nil, fmt.Errorf("%w: item 2", repositories.ErrItemQuantityExceeded)}, nil)
+		r := httptest.NewRequest(http.MethodPost, "/cart/cart-1/items?mode=partial", strings.NewReader(`{"items": [{"item_id": 1, "quantity": 1}, {"item_id": 2, "quantity": 99}]}`))
+		r.SetPathValue("id", "cart-1")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo).AddItems)(w, r)
+
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		var resp models.BulkItemsResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, http.StatusOK, resp.Results[0].Status)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Results[1].Status)
+		assert.Equal(t, "item_quantity_exceeded", resp.Results[1].ErrorCode)
+	})
+}
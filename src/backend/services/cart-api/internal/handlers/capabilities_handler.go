@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// CapabilitiesHandler serves features enabled by configuration
+type CapabilitiesHandler struct {
+	capabilities models.Capabilities
+}
+
+// NewCapabilitiesHandler creates new instance of CapabilitiesHandler
+func NewCapabilitiesHandler(c models.Capabilities) *CapabilitiesHandler {
+	return &CapabilitiesHandler{capabilities: c}
+}
+
+// Get go doc
+//
+//	@Summary		Lists capabilities
+//	@Description	Returns features and limits enabled in this deployment
+//	@Tags			Capabilities
+//	@Produce		json
+//	@Success		200	{object}	models.Capabilities
+//	@Router			/capabilities	[get]
+func (h *CapabilitiesHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, r, h.capabilities)
+}
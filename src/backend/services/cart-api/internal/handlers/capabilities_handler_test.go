@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/cmd/config"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilitiesHandler(t *testing.T) {
+	t.Setenv("PRICE_SOURCE", config.PriceSourceCatalog)
+	t.Setenv("MAX_ITEM_PRICE", "150")
+	t.Setenv("MAX_CART_TOTAL", "1000")
+	t.Setenv("SHARE_TTL", "1h")
+
+	handler := NewCapabilitiesHandler(config.Init().Capabilities())
+
+	w := httptest.NewRecorder()
+	ErrorHandler(handler.Get)(w, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var result models.Capabilities
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(t, models.Capabilities{
+		PriceSource:  config.PriceSourceCatalog,
+		Sharing:      true,
+		ShareTTL:     3600,
+		MaxItemPrice: 150,
+		MaxCartTotal: 1000,
+	}, result)
+}
@@ -3,49 +3,186 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/jurabek/cart-api/internal/database"
+	"github.com/jurabek/cart-api/internal/flags"
 	"github.com/jurabek/cart-api/internal/models"
 	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/jurabek/cart-api/pkg/breaker"
 	"github.com/pkg/errors"
-	"github.com/rs/zerolog/log"
 )
 
 type GetCreateDeleter interface {
 	Get(ctx context.Context, cartID string) (*models.Cart, error)
 	Update(ctx context.Context, cart *models.Cart) error
+	CheckCart(cart *models.Cart) error
 	Delete(ctx context.Context, id string) error
+	DeleteIfMatch(ctx context.Context, id string, etags []string) error
 	AddItem(ctx context.Context, cartID string, item models.LineItem) error
 	UpdateItem(ctx context.Context, cartID string, itemID int, item models.LineItem) error
 	DeleteItem(ctx context.Context, cartID string, itemID int) error
+	MoveItem(ctx context.Context, sourceID, targetID string, itemID int) error
+	DecrementItem(ctx context.Context, cartID string, itemID int) error
+	AdjustItemQuantity(ctx context.Context, cartID string, itemID int, delta int, clamp bool) error
+	Touch(ctx context.Context, cartID string) error
+	AddItems(ctx context.Context, cartID string, items []models.LineItem, partial bool) ([]error, error)
+	DeleteItems(ctx context.Context, cartID string, itemIDs []int, partial bool) ([]error, error)
 }
 
 // CartHandler is router initializer for http
 type CartHandler struct {
-	repository GetCreateDeleter
+	repository    GetCreateDeleter
+	priceProvider PriceProvider
+	traceCartID   bool
+	clampQuantity bool
+
+	// defaultQuantity replaces missing quantity of added items, zero rejects them
+	defaultQuantity int
+
+	stockChecker StockChecker
+	clampStock   bool
+	ids          IDGenerator
+	flags        FlagProvider
+
+	// fees adds breakdown of the total to returned carts, nil leaves it out
+	fees FeeCalculator
+	// coupons adds discounts of applied coupons to returned carts
+	coupons CouponFinder
+}
+
+// Option configures optional behaviour of CartHandler
+type Option func(*CartHandler)
+
+// WithClampQuantity decides whether a quantity delta going below zero removes
+// the item (clamp) or is rejected with 400
+func WithClampQuantity(clamp bool) Option {
+	return func(h *CartHandler) {
+		h.clampQuantity = clamp
+	}
+}
+
+// WithDefaultQuantity sets quantity of items added without one, zero makes
+// AddItem reject them with 400 instead
+func WithDefaultQuantity(quantity int) Option {
+	return func(h *CartHandler) {
+		h.defaultQuantity = quantity
+	}
 }
 
 // NewCartHandler creates new instance of CartHandler with CartRepository
-func NewCartHandler(r GetCreateDeleter) *CartHandler {
-	return &CartHandler{repository: r}
+func NewCartHandler(r GetCreateDeleter, opts ...Option) *CartHandler {
+	h := &CartHandler{repository: r, traceCartID: true, clampQuantity: true, defaultQuantity: 1, stockChecker: InStock{}, clampStock: true, ids: UUIDv4{}, flags: flags.Static{}}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 type HandlerFunc func(http.ResponseWriter,*http.Request)
 
+// outOfMemoryRetryAfter is suggested to clients while redis rejects writes
+// with OOM, eviction or a scale up usually frees memory within seconds
+const outOfMemoryRetryAfter = 5 * time.Second
+
 func ErrorHandler(f func(w http.ResponseWriter, r *http.Request) error) HandlerFunc  {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := f(w, r)
+		// deadline of X-Timeout-Ms passed before the handler finished
+		if err != nil && errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			writeError(w, r, models.NewHTTPError(http.StatusGatewayTimeout, errors.Wrap(err, "request deadline exceeded")))
+			return
+		}
+		// the client went away, there is nobody to write the response to
+		if r.Context().Err() != nil {
+			if err != nil {
+				logFromCtx(r.Context()).Debug().Err(err).Str("path", r.URL.Path).Msg("request cancelled")
+			}
+			return
+		}
 		if err != nil {
+			var openErr *breaker.OpenError
+			if errors.As(err, &openErr) {
+				w.Header().Set("Retry-After", retryAfterSeconds(openErr.RetryAfter))
+				writeError(w, r, models.NewHTTPError(http.StatusServiceUnavailable, openErr))
+				return
+			}
+			if database.IsOutOfMemory(err) {
+				logFromCtx(r.Context()).Error().Err(err).Str("severity", "critical").Str("path", r.URL.Path).
+					Msg("redis out of memory, rejecting writes")
+				w.Header().Set("Retry-After", retryAfterSeconds(outOfMemoryRetryAfter))
+				writeError(w, r, models.NewHTTPError(http.StatusServiceUnavailable, errors.New("storage is temporarily full")))
+				return
+			}
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, r, models.NewHTTPError(http.StatusRequestEntityTooLarge, tooLarge))
+				return
+			}
+			// business rules are 422 whatever status the handler mapped them to
+			if ruleErr := models.NewBusinessRuleError(err); ruleErr != nil {
+				writeError(w, r, ruleErr)
+				return
+			}
 			var httpErr *models.HTTPError
 			if errors.As(err, &httpErr) {
-				http.Error(w, httpErr.Error(), httpErr.Code)
+				writeError(w, r, httpErr)
+				return
 			}
+			// errors which are not mapped by handlers are internal
+			writeError(w, r, models.NewHTTPError(http.StatusInternalServerError, err))
+			return
 		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// writeError writes err as json body with its status code, as problem
+// details when the request asks for them
+func writeError(w http.ResponseWriter, r *http.Request, err *models.HTTPError) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if wantsProblem(r) {
+		w.Header().Set("Content-Type", ProblemContentType)
+		w.WriteHeader(err.Code)
+		_ = newResponseEncoder(w).Encode(err.Problem())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Code)
+	_ = newResponseEncoder(w).Encode(err)
+}
+
+// retryAfterSeconds formats d as Retry-After header value, rounded up
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
+
+// validateItem rejects the item with malformed fields with 400, every
+// invalid field is reported together with errs found before
+func validateItem(item models.LineItem, errs ...models.FieldError) error {
+	var itemErrs models.ValidationErrors
+	if errors.As(item.Validate(), &itemErrs) {
+		errs = append(errs, itemErrs...)
+	}
+	return validationError(errs)
+}
+
+// validationError is the 400 of failed validations, nil when there are none
+func validationError(errs models.ValidationErrors) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return models.NewValidationError(errs)
+}
+
 // Create go doc
 //
 //	@Summary		Creates new cart
@@ -57,30 +194,48 @@ func ErrorHandler(f func(w http.ResponseWriter, r *http.Request) error) HandlerF
 //	@Success		200				{object}	models.Cart
 //	@Failure		400				{object}	models.HTTPError
 //	@Failure		404				{object}	models.HTTPError
+//	@Failure		422				{object}	models.HTTPError
 //	@Failure		500 			{object}	models.HTTPError
 //	@Router			/cart 			[post]
 func (h *CartHandler) Create(w http.ResponseWriter, r *http.Request) error {
-	log.Info().Str("path", r.URL.Path).Msg("Create cart")
+	logFromCtx(r.Context()).Info().Str("path", r.URL.Path).Msg("Create cart")
 	var req models.CreateCartReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return models.NewHTTPError(http.StatusBadRequest, err)
-	} 
+	}
+	if req.LineItems != nil {
+		if err := validationError(models.ValidateLineItems("items", *req.LineItems)); err != nil {
+			return err
+		}
+		if err := h.resolvePrices(r.Context(), *req.LineItems); err != nil {
+			return err
+		}
+	}
 	cart := models.MapCreateCartReqToCart(req)
-	err := h.repository.Update(r.Context(), cart)
+	id, err := h.ids.NewID()
 	if err != nil {
-		return models.NewHTTPError(http.StatusBadRequest, err)
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	cart.ID = id
+	if err := h.checkLines(w, r, cart); err != nil {
+		return err
+	}
+	h.checkTax(r.Context(), cart)
+	err = h.repository.Update(r.Context(), cart)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
 
 	result, err := h.repository.Get(r.Context(), cart.ID.String())
 	if err != nil {
-		return models.NewHTTPError(http.StatusBadRequest, err)
+		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
+	h.traceCart(r.Context(), result.ID.String(), len(result.LineItems))
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(result); err != nil {
-		return models.NewHTTPError(http.StatusInternalServerError, err)
+	if err := h.addTotals(r.Context(), result); err != nil {
+		return err
 	}
-	return nil
+	return writeJSON(w, r, result)
 }
 
 // Update cart doc
@@ -95,6 +250,7 @@ func (h *CartHandler) Create(w http.ResponseWriter, r *http.Request) error {
 //	@Success		200					{object}	models.Cart
 //	@Failure		400					{object}	models.HTTPError
 //	@Failure		404					{object}	models.HTTPError
+//	@Failure		422					{object}	models.HTTPError
 //	@Failure		500 				{object}	models.HTTPError
 //	@Router			/cart/{id}			[put]
 func (h *CartHandler) Update(w http.ResponseWriter, r *http.Request) error {
@@ -104,6 +260,14 @@ func (h *CartHandler) Update(w http.ResponseWriter, r *http.Request) error {
 	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
 		return models.NewHTTPError(http.StatusBadRequest, err)
 	}
+	if updateReq.LineItems != nil {
+		if err := validationError(models.ValidateLineItems("items", *updateReq.LineItems)); err != nil {
+			return err
+		}
+		if err := h.resolvePrices(r.Context(), *updateReq.LineItems); err != nil {
+			return err
+		}
+	}
 
 	cart, err := h.repository.Get(r.Context(), cartID)
 	if err != nil {
@@ -114,6 +278,13 @@ func (h *CartHandler) Update(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	cartForUpdate := models.MapUpdateCartReqToCart(cart, updateReq)
+	if updateReq.LineItems != nil {
+		if err := h.checkLines(w, r, cartForUpdate); err != nil {
+			return err
+		}
+	}
+	h.checkTax(r.Context(), cartForUpdate)
+	h.traceCart(r.Context(), cartID, len(cartForUpdate.LineItems))
 	if err := h.repository.Update(r.Context(), cartForUpdate); err != nil {
 		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
@@ -123,17 +294,27 @@ func (h *CartHandler) Update(w http.ResponseWriter, r *http.Request) error {
 // Get go doc
 //
 //	@Summary		Gets a Cart
-//	@Description	Get Cart by ID
+//	@Description	Get Cart by ID, fields limits the response to the listed fields, e.g. id,items.quantity. withAvailability annotates items with their stock
 //	@Tags			Cart
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		string	true	"Cart ID"
+//	@Param			id					path		string	true	"Cart ID"
+//	@Param			fields				query		string	false	"Comma separated fields to return, nested fields are joined with dots"
+//	@Param			withAvailability	query		bool	false	"Annotate line items with available and available_quantity"
 //	@Success		200	{object}	models.Cart
 //	@Failure		400	{object}	models.HTTPError
 //	@Failure		404 {object}	models.HTTPError
 //	@Router			/cart/{id} 		[get]
 func (h *CartHandler) Get(w http.ResponseWriter, r *http.Request) error {
 	id := r.PathValue("id")
+	fields, err := requestedFields(r, models.Cart{})
+	if err != nil {
+		return err
+	}
+	availability, err := withAvailability(r)
+	if err != nil {
+		return err
+	}
 	result, err := h.repository.Get(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, repositories.ErrCartNotFound) {
@@ -141,12 +322,22 @@ func (h *CartHandler) Get(w http.ResponseWriter, r *http.Request) error {
 		}
 		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
+	h.traceCart(r.Context(), id, len(result.LineItems))
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(result); err != nil {
-		return models.NewHTTPError(http.StatusInternalServerError, err)
+	// the etag identifies this version for diffs
+	if h.flags.Enabled(r.Context(), flags.ETags) {
+		w.Header().Set("ETag", models.ETag(result))
 	}
-	return nil
+	if err := h.addTotals(r.Context(), result); err != nil {
+		return err
+	}
+	if availability {
+		h.addAvailability(r.Context(), result)
+	}
+	if fields != nil {
+		return writeJSON(w, r, projection{value: result, fields: fields})
+	}
+	return writeJSON(w, r, result)
 }
 
 // Delete go doc
@@ -156,26 +347,112 @@ func (h *CartHandler) Get(w http.ResponseWriter, r *http.Request) error {
 //	@Tags			Cart
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path	string	true	"Cart ID"
+//	@Param			id			path	string	true	"Cart ID"
+//	@Param			If-Match	header	string	false	"ETag of the cart as read, deletes only this version"
 //	@Success		200	""
 //	@Failure		400	{object}	models.HTTPError
 //	@Failure		404	{object}	models.HTTPError
+//	@Failure		412	{object}	models.HTTPError
 //	@Failure		500	{object}	models.HTTPError
 //	@Router			/cart/{id} 		[delete]
 func (h *CartHandler) Delete(w http.ResponseWriter, r *http.Request) error {
 	id := r.PathValue("id")
+	h.traceCart(r.Context(), id, -1)
 
-	err := h.repository.Delete(r.Context(), id)
-	if err != nil {
-		return models.NewHTTPError(http.StatusBadRequest, err)
+	etags := ifMatch(r)
+	if etags == nil {
+		if err := h.repository.Delete(r.Context(), id); err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		return nil
+	}
+	err := h.repository.DeleteIfMatch(r.Context(), id, etags)
+	switch {
+	case errors.Is(err, repositories.ErrETagMismatch):
+		return models.NewHTTPError(http.StatusPreconditionFailed, errors.Wrap(err, "cartID: "+id))
+	case errors.Is(err, repositories.ErrCartNotFound):
+		return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+id))
+	case err != nil:
+		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
 	return nil
 }
 
+// ifMatch returns etags of If-Match headers, nil when the request has none.
+// Weak etags never match as If-Match uses strong comparison
+func ifMatch(r *http.Request) []string {
+	var etags []string
+	for _, value := range r.Header.Values("If-Match") {
+		for _, etag := range strings.Split(value, ",") {
+			if etag = strings.TrimSpace(etag); etag != "" {
+				etags = append(etags, etag)
+			}
+		}
+	}
+	return etags
+}
+
+// Touch go doc
+//
+//	@Summary		Keeps a Cart alive
+//	@Description	Refreshes TTL of the Cart without changing it
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			id	path	string	true	"Cart ID"
+//	@Success		204	""
+//	@Failure		404	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/cart/{id}/touch 	[post]
+func (h *CartHandler) Touch(w http.ResponseWriter, r *http.Request) error {
+	id := r.PathValue("id")
+	h.traceCart(r.Context(), id, -1)
+
+	if err := h.repository.Touch(r.Context(), id); err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// checkLines checks stock of every line of a cart written as a whole and
+// the limits of the repository, like prepareItem and item mutations do for
+// a single line
+func (h *CartHandler) checkLines(w http.ResponseWriter, r *http.Request, cart *models.Cart) error {
+	for i := range cart.LineItems {
+		if err := h.checkStock(w, r, &cart.LineItems[i], 0); err != nil {
+			return err
+		}
+	}
+	return h.repository.CheckCart(cart)
+}
+
+// prepareItem fills default quantity, validates the item to be added and
+// resolves its stock and price, inCart are quantities of cartQuantities
+func (h *CartHandler) prepareItem(w http.ResponseWriter, r *http.Request, cartID string, item *models.LineItem, inCart map[int]int) error {
+	var errs []models.FieldError
+	if item.Quantity == 0 && h.defaultQuantity == 0 {
+		errs = append(errs, models.NewFieldError("quantity", errors.New("is required")))
+	} else if item.Quantity == 0 {
+		logFromCtx(r.Context()).Warn().Str("cart_id", cartID).Int("item_id", item.ItemID).
+			Int("quantity", h.defaultQuantity).Msg("item added without quantity, using default")
+		item.Quantity = h.defaultQuantity
+	}
+	if err := validateItem(*item, errs...); err != nil {
+		return err
+	}
+	if err := h.checkStock(w, r, item, inCart[item.Product()]); err != nil {
+		return err
+	}
+	return h.resolvePrice(r.Context(), item)
+}
+
 // Update line item doc
 //
 //	@Summary		Add a line item
-//	@Description	Adds item into cart, if item exists sums the quantity
+//	@Description	Adds item into cart, if the cart has a line of the product sums the quantity. The item id of the line is the product unless ITEM_ID_STRATEGY is sequence, then lines are numbered per cart and product_id names the product
 //	@Tags			Cart
 //	@Accept			json
 //	@Produce		json
@@ -184,15 +461,27 @@ func (h *CartHandler) Delete(w http.ResponseWriter, r *http.Request) error {
 //	@Success		200					{object}	models.Cart
 //	@Failure		400					{object}	models.HTTPError
 //	@Failure		404					{object}	models.HTTPError
+//	@Failure		422					{object}	models.HTTPError
 //	@Failure		500 				{object}	models.HTTPError
 //	@Router			/cart/{id}/item		[post]
 func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) error {
 	cartID := r.PathValue("id")
+	h.traceCart(r.Context(), cartID, -1)
 	var entity models.LineItem
 	if err := json.NewDecoder(r.Body).Decode(&entity); err != nil {
 		return models.NewHTTPError(http.StatusBadRequest, err)
 	}
+	inCart, err := h.cartQuantities(r.Context(), cartID)
+	if err != nil {
+		return err
+	}
+	if err := h.prepareItem(w, r, cartID, &entity, inCart); err != nil {
+		return err
+	}
 	if err := h.repository.AddItem(r.Context(), cartID, entity); err != nil {
+		if errors.Is(err, models.ErrBusinessRule) {
+			return err
+		}
 		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
 	return nil
@@ -211,11 +500,13 @@ func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) error {
 //	@Success		200								{object}	models.Cart
 //	@Failure		400								{object}	models.HTTPError
 //	@Failure		404								{object}	models.HTTPError
+//	@Failure		422								{object}	models.HTTPError
 //	@Failure		500 							{object}	models.HTTPError
 //	@Router			/cart/{id}/item/{itemID}		[put]
 func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) error {
 	cartID := r.PathValue("id")
 	itemID := r.PathValue("itemID")
+	h.traceCart(r.Context(), cartID, -1)
 
 	itemIDInt, err := strconv.Atoi(itemID)
 	if err != nil {
@@ -226,7 +517,19 @@ func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) error {
 	if err := json.NewDecoder(r.Body).Decode(&entity); err != nil {
 		return models.NewHTTPError(http.StatusBadRequest, err)
 	}
+	if err := validateItem(entity); err != nil {
+		return err
+	}
+	if err := h.resolveLinePrice(r.Context(), cartID, itemIDInt, &entity); err != nil {
+		return err
+	}
 	if err := h.repository.UpdateItem(r.Context(), cartID, itemIDInt, entity); err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) || errors.Is(err, repositories.ErrItemNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		if errors.Is(err, models.ErrBusinessRule) {
+			return err
+		}
 		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
 	return nil
@@ -249,6 +552,7 @@ func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) error {
 func (h *CartHandler) DeleteItem(w http.ResponseWriter, r *http.Request) error {
 	cartID := r.PathValue("id")
 	itemID := r.PathValue("itemID")
+	h.traceCart(r.Context(), cartID, -1)
 
 	itemIDInt, err := strconv.Atoi(itemID)
 	if err != nil {
@@ -256,6 +560,137 @@ func (h *CartHandler) DeleteItem(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	if err := h.repository.DeleteItem(r.Context(), cartID, itemIDInt); err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) || errors.Is(err, repositories.ErrItemNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// Move line item doc
+//
+//	@Summary		Move line item
+//	@Description	Moves line item into another cart atomically, sums the quantity if target has the item
+//	@Tags			Cart
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path				string		true	"Cart ID"
+//	@Param			itemID	path				string		true	"Item ID"
+//	@Param			move	body				models.MoveItemReq	true	"Target cart"
+//	@Success		200	""
+//	@Failure		400								{object}	models.HTTPError
+//	@Failure		404								{object}	models.HTTPError
+//	@Failure		422								{object}	models.HTTPError
+//	@Failure		500 							{object}	models.HTTPError
+//	@Router			/cart/{id}/item/{itemID}/move	[post]
+func (h *CartHandler) MoveItem(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	itemID := r.PathValue("itemID")
+	h.traceCart(r.Context(), cartID, -1)
+
+	itemIDInt, err := strconv.Atoi(itemID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	var req models.MoveItemReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.TargetCartID == "" {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("target_cart_id is required"))
+	}
+
+	if err := h.repository.MoveItem(r.Context(), cartID, req.TargetCartID, itemIDInt); err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrSameCart):
+			return models.NewHTTPError(http.StatusBadRequest, err)
+		case errors.Is(err, repositories.ErrCartNotFound), errors.Is(err, repositories.ErrItemNotFound):
+			return models.NewHTTPError(http.StatusNotFound, err)
+		case errors.Is(err, models.ErrBusinessRule):
+			return err
+		case errors.Is(err, repositories.ErrCrossShard):
+			return models.NewHTTPError(http.StatusUnprocessableEntity, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// Decrement line item doc
+//
+//	@Summary		Decrement line item
+//	@Description	Atomically decrements item quantity by one, removes the item when quantity reaches zero
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			id		path				string		true	"Cart ID"
+//	@Param			itemID	path				string		true	"Item ID"
+//	@Success		200	""
+//	@Failure		400									{object}	models.HTTPError
+//	@Failure		404									{object}	models.HTTPError
+//	@Failure		500 								{object}	models.HTTPError
+//	@Router			/cart/{id}/item/{itemID}/decrement	[post]
+func (h *CartHandler) DecrementItem(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	itemID := r.PathValue("itemID")
+	h.traceCart(r.Context(), cartID, -1)
+
+	itemIDInt, err := strconv.Atoi(itemID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if err := h.repository.DecrementItem(r.Context(), cartID, itemIDInt); err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) || errors.Is(err, repositories.ErrItemNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// Adjust line item quantity doc
+//
+//	@Summary		Adjust line item quantity
+//	@Description	Atomically adds delta to item quantity, removes the item when quantity reaches zero
+//	@Tags			Cart
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path				string		true	"Cart ID"
+//	@Param			itemID	path				string		true	"Item ID"
+//	@Param			delta	body				models.QuantityDeltaReq	true	"Quantity delta"
+//	@Success		200	""
+//	@Failure		400									{object}	models.HTTPError
+//	@Failure		404									{object}	models.HTTPError
+//	@Failure		422									{object}	models.HTTPError
+//	@Failure		500 								{object}	models.HTTPError
+//	@Router			/cart/{id}/item/{itemID}/quantity	[post]
+func (h *CartHandler) AdjustItemQuantity(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	itemID := r.PathValue("itemID")
+	h.traceCart(r.Context(), cartID, -1)
+
+	itemIDInt, err := strconv.Atoi(itemID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	var req models.QuantityDeltaReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Delta == 0 {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("delta must not be zero"))
+	}
+
+	if err := h.repository.AdjustItemQuantity(r.Context(), cartID, itemIDInt, req.Delta, h.clampQuantity); err != nil {
+		switch {
+		case errors.Is(err, models.ErrBusinessRule):
+			return err
+		case errors.Is(err, repositories.ErrCartNotFound), errors.Is(err, repositories.ErrItemNotFound):
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
 		return models.NewHTTPError(http.StatusInternalServerError, err)
 	}
 	return nil
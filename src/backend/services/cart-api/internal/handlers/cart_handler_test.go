@@ -8,18 +8,23 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 
+	"github.com/jurabek/cart-api/internal/flags"
 	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 var items = []models.LineItem{{
 	ItemID:      1,
-	UnitPrice:   20,
+	UnitPrice:   models.Money{Minor: 2000},
 	Quantity:    1,
 	Image:       "picture",
 	ProductName: "foodName",
@@ -49,6 +54,50 @@ func (r *CartRepositoryMock) AddItem(ctx context.Context, cartID string, item mo
 	return args.Error(0)
 }
 
+// MoveItem implements GetCreateDeleter.
+func (r *CartRepositoryMock) MoveItem(ctx context.Context, sourceID, targetID string, itemID int) error {
+	args := r.Called(ctx, sourceID, targetID, itemID)
+	return args.Error(0)
+}
+
+// DecrementItem implements GetCreateDeleter.
+func (r *CartRepositoryMock) DecrementItem(ctx context.Context, cartID string, itemID int) error {
+	args := r.Called(ctx, cartID, itemID)
+	return args.Error(0)
+}
+
+// AdjustItemQuantity implements GetCreateDeleter.
+func (r *CartRepositoryMock) AdjustItemQuantity(ctx context.Context, cartID string, itemID int, delta int, clamp bool) error {
+	args := r.Called(ctx, cartID, itemID, delta, clamp)
+	return args.Error(0)
+}
+
+// UpdateCoupons implements CouponUpdater.
+func (r *CartRepositoryMock) UpdateCoupons(ctx context.Context, cartID string, fn func(cart *models.Cart) error) (*models.Cart, error) {
+	args := r.Called(ctx, cartID, fn)
+	return args.Get(0).(*models.Cart), args.Error(1)
+}
+
+// Touch implements GetCreateDeleter.
+func (r *CartRepositoryMock) Touch(ctx context.Context, cartID string) error {
+	args := r.Called(ctx, cartID)
+	return args.Error(0)
+}
+
+// AddItems implements GetCreateDeleter.
+func (r *CartRepositoryMock) AddItems(ctx context.Context, cartID string, items []models.LineItem, partial bool) ([]error, error) {
+	args := r.Called(ctx, cartID, items, partial)
+	itemErrs, _ := args.Get(0).([]error)
+	return itemErrs, args.Error(1)
+}
+
+// DeleteItems implements GetCreateDeleter.
+func (r *CartRepositoryMock) DeleteItems(ctx context.Context, cartID string, itemIDs []int, partial bool) ([]error, error) {
+	args := r.Called(ctx, cartID, itemIDs, partial)
+	itemErrs, _ := args.Get(0).([]error)
+	return itemErrs, args.Error(1)
+}
+
 var _ GetCreateDeleter = (*CartRepositoryMock)(nil)
 
 // Get mock
@@ -63,12 +112,23 @@ func (r *CartRepositoryMock) Update(ctx context.Context, item *models.Cart) erro
 	return args.Error(0)
 }
 
+// CheckCart accepts every cart, limits are covered by repository tests
+func (r *CartRepositoryMock) CheckCart(cart *models.Cart) error {
+	return nil
+}
+
 // Delete mock
 func (r *CartRepositoryMock) Delete(ctx context.Context, id string) error {
 	args := r.Called(ctx, id)
 	return args.Error(0)
 }
 
+// DeleteIfMatch implements GetCreateDeleter.
+func (r *CartRepositoryMock) DeleteIfMatch(ctx context.Context, id string, etags []string) error {
+	args := r.Called(ctx, id, etags)
+	return args.Error(0)
+}
+
 func TestCartHandler(t *testing.T) {
 	t.Skip()
 	ctx := context.TODO()
@@ -168,3 +228,402 @@ func TestCartHandler(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
 	})
 }
+
+func TestCartHandlerLimits(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("AddItem", mock.Anything, "overpriced", mock.Anything).
+		Return(fmt.Errorf("%w: price 500 is above 100", repositories.ErrItemPriceExceeded))
+	repo.On("AddItem", mock.Anything, "overtotal", mock.Anything).
+		Return(fmt.Errorf("%w: total 300 is above 250", repositories.ErrCartTotalExceeded))
+	repo.On("AddItem", mock.Anything, "overquantity", mock.Anything).
+		Return(fmt.Errorf("%w: quantity 3 of item 1 is above 2", repositories.ErrItemQuantityExceeded))
+	handler := NewCartHandler(repo)
+
+	for _, cartID := range []string{"overpriced", "overtotal", "overquantity"} {
+		t.Run("AddItem should return 422 for "+cartID, func(t *testing.T) {
+			r := newItemRequest(t, http.MethodPost, "/cart/"+cartID+"/item", models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 50000}, Quantity: 1})
+			r.SetPathValue("id", cartID)
+			w := httptest.NewRecorder()
+			ErrorHandler(handler.AddItem)(w, r)
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		})
+	}
+}
+
+// limitedRepository rejects whole carts above max total like
+// repositories.Limits does
+type limitedRepository struct {
+	*repositoriestest.MemoryRepository
+	max models.Money
+}
+
+func (r limitedRepository) CheckCart(cart *models.Cart) error {
+	var total models.Money
+	for _, item := range cart.LineItems {
+		line, _ := item.UnitPrice.Mul(item.Quantity)
+		total = total.Add(line)
+	}
+	if total.Minor > r.max.Minor {
+		return fmt.Errorf("%w: total %s is above %s", repositories.ErrCartTotalExceeded, total, r.max)
+	}
+	return nil
+}
+
+func TestCartHandlerWholeCartLimits(t *testing.T) {
+	ctx := context.Background()
+	userID := "user-1"
+	repo := limitedRepository{MemoryRepository: repositoriestest.NewMemoryRepository(), max: models.Money{Minor: 1000}}
+	stored := &models.Cart{ID: uuid.New(), UserID: &userID, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 500}, Quantity: 1}}}
+	require.NoError(t, repo.Update(ctx, stored))
+
+	serve := func(handler *CartHandler, method string, body any) *httptest.ResponseRecorder {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		target, f := "/cart", handler.Create
+		if method == http.MethodPut {
+			target, f = "/cart/"+stored.ID.String(), handler.Update
+		}
+		r := httptest.NewRequest(method, target, bytes.NewReader(data))
+		r.SetPathValue("id", stored.ID.String())
+		w := httptest.NewRecorder()
+		ErrorHandler(f)(w, r)
+		return w
+	}
+	over := []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 500}, Quantity: 3}}
+
+	t.Run("PUT above the limits should return 422 and keep the cart", func(t *testing.T) {
+		w := serve(NewCartHandler(repo), http.MethodPut, models.UpdateCartReq{LineItems: &over, UserID: &userID})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		cart, err := repo.Get(ctx, stored.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 1, cart.LineItems[0].Quantity)
+	})
+
+	t.Run("PUT without items should keep the stored items", func(t *testing.T) {
+		status := "new"
+		w := serve(NewCartHandler(repo), http.MethodPut, models.UpdateCartReq{UserID: &userID, Status: &status})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("POST above the limits should return 422", func(t *testing.T) {
+		w := serve(NewCartHandler(repo), http.MethodPost, models.CreateCartReq{LineItems: &over, UserID: &userID})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("PUT of an item out of stock should return 422", func(t *testing.T) {
+		within := []models.LineItem{{ItemID: 2, UnitPrice: models.Money{Minor: 100}, Quantity: 1}}
+		checker := &countingStockChecker{stock: map[int]int{2: 0}, lookups: map[int]int{}}
+		w := serve(NewCartHandler(repo, WithStockChecker(checker)), http.MethodPut, models.UpdateCartReq{LineItems: &within, UserID: &userID})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, 1, checker.lookups[2])
+	})
+}
+
+func TestCartHandlerMoveItem(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("MoveItem", mock.Anything, "source", "target", 1).Return(nil)
+	repo.On("MoveItem", mock.Anything, "source", "source", 1).Return(repositories.ErrSameCart)
+	repo.On("MoveItem", mock.Anything, "source", "missing", 1).Return(repositories.ErrCartNotFound)
+	repo.On("MoveItem", mock.Anything, "source", "target", 2).Return(repositories.ErrItemNotFound)
+	handler := NewCartHandler(repo)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cart/{id}/item/{itemID}/move", ErrorHandler(handler.MoveItem))
+
+	tests := []struct {
+		name   string
+		itemID string
+		target string
+		want   int
+	}{
+		{"move should return ok", "1", "target", http.StatusOK},
+		{"same cart should return 400", "1", "source", http.StatusBadRequest},
+		{"missing target should return 400", "1", "", http.StatusBadRequest},
+		{"missing cart should return 404", "1", "missing", http.StatusNotFound},
+		{"missing item should return 404", "2", "target", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.MoveItemReq{TargetCartID: tt.target})
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cart/source/item/"+tt.itemID+"/move", bytes.NewBuffer(body)))
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestCartHandlerDecrementItem(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("DecrementItem", mock.Anything, "abcd", 1).Return(nil)
+	repo.On("DecrementItem", mock.Anything, "abcd", 2).Return(repositories.ErrItemNotFound)
+	handler := NewCartHandler(repo)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cart/{id}/item/{itemID}/decrement", ErrorHandler(handler.DecrementItem))
+
+	t.Run("decrement should return ok", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cart/abcd/item/1/decrement", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("decrement on missing item should return 404", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cart/abcd/item/2/decrement", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestCartHandlerAdjustItemQuantity(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("AdjustItemQuantity", mock.Anything, "abcd", 1, 2, true).Return(nil)
+	repo.On("AdjustItemQuantity", mock.Anything, "abcd", 1, -1, true).Return(nil)
+	repo.On("AdjustItemQuantity", mock.Anything, "abcd", 1, -5, false).Return(repositories.ErrNegativeQuantity)
+	repo.On("AdjustItemQuantity", mock.Anything, "abcd", 2, 1, true).Return(repositories.ErrItemNotFound)
+
+	serve := func(handler *CartHandler, itemID string, body string) int {
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /cart/{id}/item/{itemID}/quantity", ErrorHandler(handler.AdjustItemQuantity))
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item/"+itemID+"/quantity", strings.NewReader(body))
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("positive delta should return ok", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(NewCartHandler(repo), "1", `{"delta": 2}`))
+	})
+
+	t.Run("negative delta should return ok", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(NewCartHandler(repo), "1", `{"delta": -1}`))
+	})
+
+	t.Run("zero crossing without clamp should return 422", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(NewCartHandler(repo, WithClampQuantity(false)), "1", `{"delta": -5}`))
+	})
+
+	t.Run("zero delta should return 400", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(NewCartHandler(repo), "1", `{"delta": 0}`))
+	})
+
+	t.Run("missing item should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(NewCartHandler(repo), "2", `{"delta": 1}`))
+	})
+}
+
+func TestCartHandlerMissingItem(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("UpdateItem", mock.Anything, "abcd", 404, mock.Anything).Return(fmt.Errorf("%w: item 404 in cart abcd", repositories.ErrItemNotFound))
+	repo.On("DeleteItem", mock.Anything, "abcd", 404).Return(fmt.Errorf("%w: item 404 in cart abcd", repositories.ErrItemNotFound))
+	repo.On("UpdateItem", mock.Anything, "missing", 1, mock.Anything).Return(repositories.ErrCartNotFound)
+	handler := NewCartHandler(repo)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /cart/{id}/item/{itemID}", ErrorHandler(handler.UpdateItem))
+	mux.HandleFunc("DELETE /cart/{id}/item/{itemID}", ErrorHandler(handler.DeleteItem))
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	t.Run("updating missing item should return 404", func(t *testing.T) {
+		w := serve(http.MethodPut, "/cart/abcd/item/404", `{"quantity": 1}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "item not found")
+	})
+
+	t.Run("deleting missing item should return 404", func(t *testing.T) {
+		w := serve(http.MethodDelete, "/cart/abcd/item/404", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "item not found")
+	})
+
+	t.Run("updating item of missing cart should return 404", func(t *testing.T) {
+		w := serve(http.MethodPut, "/cart/missing/item/1", `{"quantity": 1}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "cart not found")
+	})
+}
+
+func TestCartHandlerTouch(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("Touch", mock.Anything, "abcd").Return(nil)
+	repo.On("Touch", mock.Anything, "missing").Return(repositories.ErrCartNotFound)
+	handler := NewCartHandler(repo)
+
+	serve := func(cartID string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /cart/{id}/touch", ErrorHandler(handler.Touch))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cart/"+cartID+"/touch", nil))
+		return w
+	}
+
+	t.Run("existing cart should return 204", func(t *testing.T) {
+		w := serve("abcd")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Zero(t, w.Body.Len())
+	})
+
+	t.Run("missing cart should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("missing").Code)
+	})
+}
+
+func TestCartHandlerItemImageURL(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("AddItem", mock.Anything, "abcd", mock.MatchedBy(func(item models.LineItem) bool {
+		return item.ImageURL == "https://cdn.example.com/burger.png" && item.DisplayName == "Burger"
+	})).Return(nil)
+	handler := NewCartHandler(repo)
+
+	serve := func(body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", strings.NewReader(body))
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.AddItem)(w, r)
+		return w.Code
+	}
+
+	t.Run("display metadata should be passed through", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(`{"item_id": 1, "quantity": 1, "image_url": "https://cdn.example.com/burger.png", "display_name": "Burger"}`))
+	})
+
+	for _, imageURL := range []string{"burger.png", "ftp://cdn.example.com/burger.png", "https://", "http://[::1"} {
+		t.Run("malformed image url "+imageURL+" should return 400", func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, serve(`{"item_id": 1, "quantity": 1, "image_url": "`+imageURL+`"}`))
+		})
+	}
+	repo.AssertNumberOfCalls(t, "AddItem", 1)
+}
+
+func TestCartHandlerValidationErrors(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	handler := NewCartHandler(repo)
+
+	t.Run("every invalid field of a cart should be reported", func(t *testing.T) {
+		body := `{"items": [
+			{"item_id": 1, "quantity": 1},
+			{"item_id": 2, "quantity": -1, "image_url": "burger.png"},
+			{"item_id": 3, "quantity": 1, "unit_price": "-2.50"}
+		]}`
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.Create)(w, httptest.NewRequest(http.MethodPost, "/cart", strings.NewReader(body)))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		var resp models.HTTPError
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		fields := []string{}
+		for _, fieldErr := range resp.Errors {
+			fields = append(fields, fieldErr.Field)
+			assert.NotEmpty(t, fieldErr.Message)
+		}
+		assert.Equal(t, []string{"items[1].quantity", "items[1].image_url", "items[2].unit_price"}, fields)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing quantity should be reported with invalid fields of the item", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", strings.NewReader(`{"item_id": 1, "image_url": "burger.png"}`))
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo, WithDefaultQuantity(0)).AddItem)(w, r)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"quantity"`)
+		assert.Contains(t, w.Body.String(), `"field":"image_url"`)
+	})
+}
+
+func TestCartHandlerAddItemDefaultQuantity(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("AddItem", mock.Anything, "abcd", mock.Anything).Return(nil)
+
+	addItem := func(handler *CartHandler, body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", strings.NewReader(body))
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.AddItem)(w, r)
+		return w.Code
+	}
+	lastQuantity := func() int {
+		calls := repo.Calls
+		return calls[len(calls)-1].Arguments.Get(2).(models.LineItem).Quantity
+	}
+
+	t.Run("omitted quantity should default to one", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, addItem(NewCartHandler(repo), `{"item_id": 1}`))
+		assert.Equal(t, 1, lastQuantity())
+	})
+
+	t.Run("explicit quantity should be kept", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, addItem(NewCartHandler(repo), `{"item_id": 1, "quantity": 3}`))
+		assert.Equal(t, 3, lastQuantity())
+	})
+
+	t.Run("omitted quantity should be rejected without default", func(t *testing.T) {
+		calls := len(repo.Calls)
+		assert.Equal(t, http.StatusBadRequest, addItem(NewCartHandler(repo, WithDefaultQuantity(0)), `{"item_id": 1}`))
+		assert.Len(t, repo.Calls, calls)
+	})
+}
+
+func TestCartHandlerDeleteIfMatch(t *testing.T) {
+	repo := repositoriestest.NewMemoryRepository()
+	handler := NewCartHandler(repo, WithFlags(flags.Static{flags.ETags: true}))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cart/{id}", ErrorHandler(handler.Get))
+	mux.HandleFunc("DELETE /cart/{id}", ErrorHandler(handler.Delete))
+
+	serve := func(method, cartID, ifMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/cart/"+cartID, nil)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	created := func(t *testing.T) (string, string) {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, Quantity: 1}}}
+		require.NoError(t, repo.Update(context.Background(), cart))
+		w := serve(http.MethodGet, cart.ID.String(), "")
+		require.Equal(t, http.StatusOK, w.Code)
+		return cart.ID.String(), w.Header().Get("ETag")
+	}
+
+	t.Run("matching etag should delete", func(t *testing.T) {
+		id, etag := created(t)
+		assert.Equal(t, http.StatusOK, serve(http.MethodDelete, id, etag).Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, id, "").Code)
+	})
+
+	t.Run("one of listed etags should delete", func(t *testing.T) {
+		id, etag := created(t)
+		assert.Equal(t, http.StatusOK, serve(http.MethodDelete, id, `"stale", `+etag).Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, id, "").Code)
+	})
+
+	t.Run("modified cart should return 412 and be kept", func(t *testing.T) {
+		id, etag := created(t)
+		require.NoError(t, repo.AddItem(context.Background(), id, models.LineItem{ItemID: 2, Quantity: 1}))
+
+		assert.Equal(t, http.StatusPreconditionFailed, serve(http.MethodDelete, id, etag).Code)
+		assert.Equal(t, http.StatusPreconditionFailed, serve(http.MethodDelete, id, "W/"+etag).Code, "weak etags should not match")
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, id, "").Code)
+	})
+
+	t.Run("absent header should delete unconditionally", func(t *testing.T) {
+		id, _ := created(t)
+		require.NoError(t, repo.AddItem(context.Background(), id, models.LineItem{ItemID: 2, Quantity: 1}))
+
+		assert.Equal(t, http.StatusOK, serve(http.MethodDelete, id, "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, id, "").Code)
+	})
+
+	t.Run("wildcard should delete existing cart only", func(t *testing.T) {
+		id, _ := created(t)
+		assert.Equal(t, http.StatusOK, serve(http.MethodDelete, id, "*").Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, id, "*").Code)
+	})
+}
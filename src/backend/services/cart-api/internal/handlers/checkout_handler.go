@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+)
+
+var (
+	ErrEmptyCart         error = models.NewBusinessRule("empty_cart", "cart has no items")
+	ErrBelowMinimumOrder error = models.NewBusinessRule("below_minimum_order", "cart total is below the minimum order value")
+)
+
+// MinimumOrder is the least total of items a cart is checked out with, e.g. a
+// delivery minimum. PerCurrency overrides Default for carts in the currency,
+// Default is in major units so it fits currencies with any minor unit. A zero
+// minimum disables the check
+type MinimumOrder struct {
+	Default     float64
+	PerCurrency map[string]models.Money
+}
+
+// For returns the minimum of carts in currency
+func (m MinimumOrder) For(currency string) models.Money {
+	if minimum, ok := m.PerCurrency[strings.ToUpper(currency)]; ok {
+		return minimum
+	}
+	return models.FromMajor(m.Default, currency)
+}
+
+// CheckoutHandler hands carts over to ordering
+type CheckoutHandler struct {
+	carts   CartGetUpdater
+	minimum MinimumOrder
+}
+
+// NewCheckoutHandler creates new instance of CheckoutHandler
+func NewCheckoutHandler(carts CartGetUpdater, minimum MinimumOrder) *CheckoutHandler {
+	return &CheckoutHandler{carts: carts, minimum: minimum}
+}
+
+// Checkout go doc
+//
+//	@Summary		Checks out a Cart
+//	@Description	Moves the cart to processing. Carts without items or with a total of items below the minimum order value of their currency get 422, details of the error have the shortfall
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			id	path		string	true	"Cart ID"
+//	@Success		200	{object}	models.Cart
+//	@Failure		404	{object}	models.HTTPError
+//	@Failure		409	{object}	models.HTTPError
+//	@Failure		422	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/cart/{id}/checkout	[post]
+func (h *CheckoutHandler) Checkout(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	cart, err := h.carts.Get(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if cart.Status == models.CartStatusProcessing {
+		return models.NewHTTPError(http.StatusConflict, fmt.Errorf("cart %s is checked out already", cartID))
+	}
+	if len(cart.LineItems) == 0 {
+		return fmt.Errorf("%w: cart %s", ErrEmptyCart, cartID)
+	}
+	if err := h.checkMinimum(cart); err != nil {
+		return err
+	}
+
+	cart.Status = models.CartStatusProcessing
+	if err := h.carts.Update(r.Context(), cart); err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, cart)
+}
+
+// checkMinimum rejects carts below the minimum order value of their currency
+// with the minimum and the shortfall as details
+func (h *CheckoutHandler) checkMinimum(cart *models.Cart) error {
+	currency := cart.Total.Currency
+	if currency == "" && cart.Currency != nil {
+		currency = *cart.Currency
+	}
+	minimum := h.minimum.For(currency)
+	if cart.Total.Minor >= minimum.Minor {
+		return nil
+	}
+
+	shortfall := models.Money{Minor: minimum.Minor - cart.Total.Minor, Currency: minimum.Currency}
+	ruleErr := models.NewHTTPError(http.StatusUnprocessableEntity, fmt.Errorf("%w: add %s to reach %s", ErrBelowMinimumOrder, shortfall, minimum))
+	ruleErr.Details = map[string]string{"minimum": minimum.Decimal(), "shortfall": shortfall.Decimal()}
+	return ruleErr
+}
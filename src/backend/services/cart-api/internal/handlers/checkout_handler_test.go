@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckout(t *testing.T) {
+	ctx := context.Background()
+	minimum := MinimumOrder{
+		Default:     15,
+		PerCurrency: map[string]models.Money{"JPY": {Minor: 2000, Currency: "JPY"}},
+	}
+
+	checkout := func(t *testing.T, cart *models.Cart) (*httptest.ResponseRecorder, *repositoriestest.MemoryRepository) {
+		t.Helper()
+		repo := repositoriestest.NewMemoryRepository()
+		if cart != nil {
+			require.NoError(t, repo.Update(ctx, cart))
+		}
+		handler := NewCheckoutHandler(repo, minimum)
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /cart/{id}/checkout", ErrorHandler(handler.Checkout))
+		cartID := uuid.NewString()
+		if cart != nil {
+			cartID = cart.ID.String()
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cart/"+cartID+"/checkout", nil))
+		return w, repo
+	}
+	cartOf := func(total models.Money) *models.Cart {
+		return &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, Total: total, LineItems: []models.LineItem{
+			{ItemID: 1, UnitPrice: total, Quantity: 1},
+		}}
+	}
+
+	t.Run("below the minimum should return 422 with the shortfall", func(t *testing.T) {
+		cart := cartOf(models.Money{Minor: 1050})
+		w, repo := checkout(t, cart)
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		var resp models.HTTPError
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, "below_minimum_order", resp.ErrorCode)
+		assert.Equal(t, map[string]string{"minimum": "15.00", "shortfall": "4.50"}, resp.Details)
+
+		stored, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, models.CartStatusNew, stored.Status)
+	})
+
+	for name, total := range map[string]int64{"exactly at": 1500, "above": 1501} {
+		t.Run(name+" the minimum should check out", func(t *testing.T) {
+			cart := cartOf(models.Money{Minor: total})
+			w, repo := checkout(t, cart)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			stored, err := repo.Get(ctx, cart.ID.String())
+			require.NoError(t, err)
+			assert.Equal(t, models.CartStatusProcessing, stored.Status)
+		})
+	}
+
+	t.Run("currency should override the minimum", func(t *testing.T) {
+		w, _ := checkout(t, cartOf(models.Money{Minor: 1800, Currency: "JPY"}))
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		var resp models.HTTPError
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, map[string]string{"minimum": "2000", "shortfall": "200"}, resp.Details)
+
+		w, _ = checkout(t, cartOf(models.Money{Minor: 2000, Currency: "JPY"}))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("other currencies should get the default", func(t *testing.T) {
+		assert.Equal(t, models.Money{Minor: 1500, Currency: "EUR"}, minimum.For("EUR"))
+		assert.Equal(t, models.Money{Minor: 15, Currency: "KRW"}, minimum.For("KRW"))
+		assert.True(t, MinimumOrder{}.For("EUR").IsZero(), "zero minimum disables the check")
+	})
+
+	t.Run("empty, checked out and missing carts should be rejected", func(t *testing.T) {
+		w, _ := checkout(t, &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, LineItems: []models.LineItem{}})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "empty_cart")
+
+		cart := cartOf(models.Money{Minor: 2000})
+		cart.Status = models.CartStatusProcessing
+		w, _ = checkout(t, cart)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w, _ = checkout(t, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
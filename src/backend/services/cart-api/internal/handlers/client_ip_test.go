@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct connection", "203.0.113.7:5123", nil, "203.0.113.7"},
+		{"spoofed header from untrusted source", "203.0.113.7:5123", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"header from trusted proxy", "10.0.0.5:443", []string{"198.51.100.9"}, "198.51.100.9"},
+		{"chain of trusted proxies", "10.0.0.5:443", []string{"198.51.100.9, 192.168.1.1, 10.1.2.3"}, "198.51.100.9"},
+		{"spoofed hop before real client", "10.0.0.5:443", []string{"1.2.3.4, 198.51.100.9"}, "198.51.100.9"},
+		{"multiple headers", "10.0.0.5:443", []string{"1.2.3.4", "198.51.100.9"}, "198.51.100.9"},
+		{"garbage hop stops the walk", "10.0.0.5:443", []string{"198.51.100.9, nonsense"}, "10.0.0.5"},
+		{"trusted proxy without header", "10.0.0.5:443", nil, "10.0.0.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+			r.RemoteAddr = tt.remote
+			for _, xff := range tt.xff {
+				r.Header.Add("X-Forwarded-For", xff)
+			}
+			assert.Equal(t, tt.want, resolver.ClientIP(r))
+		})
+	}
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+
+	var got string
+	handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = ClientIPFromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+	r.RemoteAddr = "10.0.0.5:443"
+	r.Header.Set("X-Forwarded-For", "198.51.100.9")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "198.51.100.9", got)
+}
+
+func TestNewClientIPResolverInvalid(t *testing.T) {
+	_, err := NewClientIPResolver([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
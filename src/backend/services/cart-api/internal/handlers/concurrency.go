@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// saturatedRetryAfter is suggested to clients rejected by ConcurrencyLimiter
+const saturatedRetryAfter = time.Second
+
+// ConcurrencyLimiter caps simultaneous executions of handlers to protect
+// redis under traffic spikes, requests above the cap get 503 immediately
+type ConcurrencyLimiter struct {
+	slots    chan struct{}
+	inFlight atomic.Int64
+	router   Router
+	exempt   map[string]bool
+}
+
+// NewConcurrencyLimiter creates limiter allowing max requests in flight
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// Exempt leaves requests of the patterns router registered out of the cap,
+// e.g. long lived streams which would hold their slot until they end
+func (l *ConcurrencyLimiter) Exempt(router Router, patterns ...string) *ConcurrencyLimiter {
+	l.router = router
+	if l.exempt == nil {
+		l.exempt = make(map[string]bool, len(patterns))
+	}
+	for _, pattern := range patterns {
+		l.exempt[pattern] = true
+	}
+	return l
+}
+
+func (l *ConcurrencyLimiter) exempted(r *http.Request) bool {
+	if l.router == nil || len(l.exempt) == 0 {
+		return false
+	}
+	_, pattern := l.router.Handler(r)
+	return l.exempt[pattern]
+}
+
+// Middleware rejects requests with 503 while max requests are in flight
+func (l *ConcurrencyLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.exempted(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", retryAfterSeconds(saturatedRetryAfter))
+			writeError(w, r, models.NewHTTPError(http.StatusServiceUnavailable, errors.New("too many requests in flight")))
+			return
+		}
+		l.inFlight.Add(1)
+		defer func() {
+			l.inFlight.Add(-1)
+			<-l.slots
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns number of requests being handled
+func (l *ConcurrencyLimiter) InFlight() int64 {
+	return l.inFlight.Load()
+}
+
+// Observe exports InFlight as a gauge
+func (l *ConcurrencyLimiter) Observe() error {
+	meter := otel.Meter("cart-api")
+	_, err := meter.Int64ObservableGauge("http.server.in_flight_requests",
+		metric.WithDescription("Requests being handled, capped by the concurrency limit"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(l.InFlight())
+			return nil
+		}),
+	)
+	return err
+}
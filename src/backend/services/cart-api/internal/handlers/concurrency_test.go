@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/abcd", nil))
+			codes[i] = w.Code
+		}(i)
+		<-started
+	}
+	assert.Equal(t, int64(2), limiter.InFlight())
+
+	t.Run("request beyond the cap should return 503", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/abcd", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.Equal(t, int64(2), limiter.InFlight())
+	})
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK}, codes)
+
+	t.Run("count should decrement after completion", func(t *testing.T) {
+		assert.Zero(t, limiter.InFlight())
+
+		// release is closed so the handler returns right away
+		go func() { <-started }()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/abcd", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Zero(t, limiter.InFlight())
+	})
+}
+
+func TestConcurrencyLimiterExempt(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cart/{id}/events", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /cart/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	limiter := NewConcurrencyLimiter(1).Exempt(mux, "GET /cart/{id}/events")
+	release := make(chan struct{})
+	h := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("hold") {
+			<-release
+		}
+	}))
+	defer close(release)
+
+	// the exempt request holds no slot while it runs
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cart/abcd/events?hold", nil))
+	go h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/cart/abcd?hold", nil))
+	assert.Eventually(t, func() bool { return limiter.InFlight() == 1 }, time.Second, time.Millisecond)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/efgh", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/efgh/events", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+type ConsumerController interface {
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// ConsumerHandler pauses and resumes consumption of order events for
+// maintenance while the http api keeps serving
+type ConsumerHandler struct {
+	consumer ConsumerController
+}
+
+// NewConsumerHandler creates new instance of ConsumerHandler
+func NewConsumerHandler(c ConsumerController) *ConsumerHandler {
+	return &ConsumerHandler{consumer: c}
+}
+
+// Pause go doc
+//
+//	@Summary		Pauses the orders consumer
+//	@Description	Stops fetching and handling order events until resumed, pausing a paused consumer is a no-op
+//	@Tags			Admin
+//	@Produce		json
+//	@Success		200	{object}	models.ConsumerState
+//	@Failure		401	{object}	models.HTTPError
+//	@Failure		403	{object}	models.HTTPError
+//	@Router			/admin/consumer/pause	[post]
+func (h *ConsumerHandler) Pause(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	h.consumer.Pause()
+	return writeJSON(w, r, models.ConsumerState{Paused: h.consumer.Paused()})
+}
+
+// Resume go doc
+//
+//	@Summary		Resumes the orders consumer
+//	@Description	Continues consumption of order events stopped by pause
+//	@Tags			Admin
+//	@Produce		json
+//	@Success		200	{object}	models.ConsumerState
+//	@Failure		401	{object}	models.HTTPError
+//	@Failure		403	{object}	models.HTTPError
+//	@Router			/admin/consumer/resume	[post]
+func (h *ConsumerHandler) Resume(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	h.consumer.Resume()
+	return writeJSON(w, r, models.ConsumerState{Paused: h.consumer.Paused()})
+}
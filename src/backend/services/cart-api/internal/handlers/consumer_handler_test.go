@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubConsumer struct{ paused bool }
+
+func (s *stubConsumer) Pause()       { s.paused = true }
+func (s *stubConsumer) Resume()      { s.paused = false }
+func (s *stubConsumer) Paused() bool { return s.paused }
+
+func TestConsumerHandler(t *testing.T) {
+	consumer := &stubConsumer{}
+	handler := NewConsumerHandler(consumer)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/consumer/pause", ErrorHandler(handler.Pause))
+	mux.HandleFunc("POST /admin/consumer/resume", ErrorHandler(handler.Resume))
+
+	serve := func(path, role string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		if role != "" {
+			r.Header.Set(UserRoleHeader, role)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+	post := func(t *testing.T, path string) models.ConsumerState {
+		w := serve(path, adminRole)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var state models.ConsumerState
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&state))
+		return state
+	}
+
+	assert.True(t, post(t, "/admin/consumer/pause").Paused)
+	assert.True(t, consumer.paused)
+	assert.True(t, post(t, "/admin/consumer/pause").Paused, "pausing twice is a no-op")
+
+	assert.False(t, post(t, "/admin/consumer/resume").Paused)
+	assert.False(t, consumer.paused)
+
+	for _, path := range []string{"/admin/consumer/pause", "/admin/consumer/resume"} {
+		assert.Equal(t, http.StatusUnauthorized, serve(path, "").Code)
+		assert.Equal(t, http.StatusForbidden, serve(path, "customer").Code)
+	}
+	assert.False(t, consumer.paused, "rejected requests must not pause")
+}
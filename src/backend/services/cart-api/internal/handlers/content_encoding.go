@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// DecompressBody transparently decodes gzip request bodies, reading more than
+// maxBytes of decompressed data fails with 413 to guard against decompression
+// bombs. Other encodings are rejected with 415, brotli is not supported yet
+func DecompressBody(maxBytes int64, f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		switch encoding {
+		case "", "identity":
+			return f(w, r)
+		case "gzip", "x-gzip":
+		default:
+			return models.NewHTTPError(http.StatusUnsupportedMediaType, fmt.Errorf("unsupported Content-Encoding %q", encoding))
+		}
+
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Errorf("invalid gzip body: %w", err))
+		}
+		defer gz.Close()
+
+		r.Body = http.MaxBytesReader(w, gz, maxBytes)
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		return f(w, r)
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBody(t *testing.T, data []byte) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	return &buf
+}
+
+func TestDecompressBody(t *testing.T) {
+	var got models.LineItem
+	decode := func(w http.ResponseWriter, r *http.Request) error {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			return models.NewHTTPError(http.StatusBadRequest, err)
+		}
+		return nil
+	}
+	handler := ErrorHandler(DecompressBody(1024, decode))
+
+	t.Run("gzipped body should be decoded", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", gzipBody(t, []byte(`{"item_id": 7, "quantity": 2}`)))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 7, got.ItemID)
+		assert.Equal(t, 2, got.Quantity)
+	})
+
+	t.Run("plain body should pass through", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", bytes.NewBufferString(`{"item_id": 8}`))
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 8, got.ItemID)
+	})
+
+	t.Run("decompression bomb should return 413", func(t *testing.T) {
+		// a megabyte of spaces compresses to about a kilobyte
+		bomb := append(bytes.Repeat([]byte(" "), 1<<20), []byte(`{"item_id": 9}`)...)
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", gzipBody(t, bomb))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("invalid gzip should return 400", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", bytes.NewBufferString(`{}`))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("unsupported encoding should return 415", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", bytes.NewBufferString(`{}`))
+		r.Header.Set("Content-Encoding", "br")
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
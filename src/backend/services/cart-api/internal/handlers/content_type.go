@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// RequireJSON rejects requests whose Content-Type is not application/json
+// with 415, an optional utf-8 charset parameter is allowed
+func RequireJSON(f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if err := checkJSONContentType(r.Header.Get("Content-Type")); err != nil {
+			return models.NewHTTPError(http.StatusUnsupportedMediaType, err)
+		}
+		return f(w, r)
+	}
+}
+
+func checkJSONContentType(contentType string) error {
+	if contentType == "" {
+		return fmt.Errorf("missing Content-Type, expected application/json")
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type %q: %w", contentType, err)
+	}
+	if mediaType != "application/json" {
+		return fmt.Errorf("unsupported Content-Type %q, expected application/json", mediaType)
+	}
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return fmt.Errorf("unsupported charset %q, expected utf-8", charset)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireJSON(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		want        int
+	}{
+		{"application/json is accepted", "application/json", http.StatusOK},
+		{"charset suffix is accepted", "application/json; charset=UTF-8", http.StatusOK},
+		{"missing content type is rejected", "", http.StatusUnsupportedMediaType},
+		{"text/plain is rejected", "text/plain", http.StatusUnsupportedMediaType},
+		{"non utf-8 charset is rejected", "application/json; charset=latin1", http.StatusUnsupportedMediaType},
+		{"malformed content type is rejected", "application/json;;", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/cart", strings.NewReader(`{}`))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			ErrorHandler(RequireJSON(ok))(w, r)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
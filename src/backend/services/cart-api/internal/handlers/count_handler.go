@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+type CartCounter interface {
+	Count(ctx context.Context) (int64, error)
+}
+
+// CountHandler serves the number of carts for dashboards
+type CountHandler struct {
+	counter CartCounter
+}
+
+// NewCountHandler creates new instance of CountHandler
+func NewCountHandler(counter CartCounter) *CountHandler {
+	return &CountHandler{counter: counter}
+}
+
+// Count go doc
+//
+//	@Summary		Counts carts
+//	@Description	Returns the number of carts which are not expired, completed or cancelled
+//	@Tags			Admin
+//	@Produce		json
+//	@Success		200	{object}	models.CartCountResp
+//	@Failure		401	{object}	models.HTTPError
+//	@Failure		403	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/admin/carts/count	[get]
+func (h *CountHandler) Count(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	n, err := h.counter.Count(r.Context())
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, models.CartCountResp{Count: n})
+}
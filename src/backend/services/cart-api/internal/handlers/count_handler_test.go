@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubCounter struct {
+	n   int64
+	err error
+}
+
+func (s stubCounter) Count(ctx context.Context) (int64, error) {
+	return s.n, s.err
+}
+
+func TestCountHandler(t *testing.T) {
+	serveAs := func(counter CartCounter, role string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/admin/carts/count", nil)
+		if role != "" {
+			r.Header.Set(UserRoleHeader, role)
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCountHandler(counter).Count)(w, r)
+		return w
+	}
+	serve := func(counter CartCounter) *httptest.ResponseRecorder {
+		return serveAs(counter, adminRole)
+	}
+
+	t.Run("should return count", func(t *testing.T) {
+		w := serve(stubCounter{n: 42})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.CartCountResp
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, int64(42), resp.Count)
+	})
+
+	t.Run("failed count should return 500", func(t *testing.T) {
+		w := serve(stubCounter{err: errors.New("redis down")})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	t.Run("callers without admin role should be rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serveAs(stubCounter{n: 42}, "").Code)
+		assert.Equal(t, http.StatusForbidden, serveAs(stubCounter{n: 42}, "customer").Code)
+	})
+}
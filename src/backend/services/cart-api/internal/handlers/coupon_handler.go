@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/coupons"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+)
+
+type CouponFinder interface {
+	Coupon(ctx context.Context, code string) (models.Coupon, error)
+}
+
+type CartGetter interface {
+	Get(ctx context.Context, cartID string) (*models.Cart, error)
+}
+
+// CartGetUpdater reads and writes whole carts
+type CartGetUpdater interface {
+	CartGetter
+	Update(ctx context.Context, cart *models.Cart) error
+}
+
+// CouponUpdater reads carts and changes their coupons atomically, fn can be
+// retried when the cart is modified concurrently
+type CouponUpdater interface {
+	CartGetter
+	UpdateCoupons(ctx context.Context, cartID string, fn func(cart *models.Cart) error) (*models.Cart, error)
+}
+
+// CouponHandler evaluates coupons against carts and applies them
+type CouponHandler struct {
+	carts   CouponUpdater
+	coupons CouponFinder
+	now     func() time.Time
+}
+
+// NewCouponHandler creates new instance of CouponHandler
+func NewCouponHandler(carts CouponUpdater, coupons CouponFinder) *CouponHandler {
+	return &CouponHandler{carts: carts, coupons: coupons, now: time.Now}
+}
+
+// Validate go doc
+//
+//	@Summary		Validates a coupon
+//	@Description	Returns discount the coupon would give to the cart, nothing is persisted
+//	@Tags			Coupons
+//	@Produce		json
+//	@Param			code	path		string	true	"Coupon code"
+//	@Param			cartId	query		string	true	"Cart ID"
+//	@Success		200		{object}	models.CouponValidationResp
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/coupons/{code}/validate	[get]
+func (h *CouponHandler) Validate(w http.ResponseWriter, r *http.Request) error {
+	code := r.PathValue("code")
+	cartID := r.URL.Query().Get("cartId")
+	if cartID == "" {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("cartId is required"))
+	}
+
+	cart, err := h.carts.Get(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	coupon, err := h.coupons.Coupon(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, coupons.ErrCouponNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	result := models.CouponValidationResp{Code: coupon.Code, CartID: cartID, Total: cart.Total}
+	discount, err := coupons.Discount(coupon, cart, h.now())
+	if err != nil {
+		result.Reason = err.Error()
+		return writeJSON(w, r, result)
+	}
+	result.Valid = true
+	result.Discount = discount
+	result.Total = cart.Total.Sub(discount)
+	return writeJSON(w, r, result)
+}
+
+// Apply go doc
+//
+//	@Summary		Applies a coupon to a cart
+//	@Description	Stacks the coupon with coupons the cart has, a coupon can't join an exclusive one or one of the same stack group. Returns the cart with the discount of every coupon
+//	@Tags			Coupons
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Cart ID"
+//	@Param			coupon	body		models.ApplyCouponReq	true	"Coupon"
+//	@Success		200		{object}	models.Cart
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		409		{object}	models.HTTPError
+//	@Failure		422		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/{id}/coupons	[post]
+func (h *CouponHandler) Apply(w http.ResponseWriter, r *http.Request) error {
+	var req models.ApplyCouponReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.Code == "" {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("code is required"))
+	}
+	coupon, err := h.coupons.Coupon(r.Context(), req.Code)
+	if err != nil {
+		if errors.Is(err, coupons.ErrCouponNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	cart, err := h.carts.UpdateCoupons(r.Context(), r.PathValue("id"), func(cart *models.Cart) error {
+		applied, _, err := findCoupons(r.Context(), h.coupons, cart.Coupons)
+		if err != nil {
+			return models.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		if err := coupons.CheckStack(applied, coupon); err != nil {
+			return models.NewHTTPError(http.StatusConflict, err)
+		}
+		// a coupon giving nothing now is rejected rather than kept for later
+		if _, err := coupons.Discount(coupon, cart, h.now()); err != nil {
+			return err
+		}
+		cart.Coupons = append(cart.Coupons, coupon.Code)
+		return nil
+	})
+	return h.write(w, r, cart, err)
+}
+
+// Remove go doc
+//
+//	@Summary		Removes a coupon from a cart
+//	@Tags			Coupons
+//	@Produce		json
+//	@Param			id		path		string	true	"Cart ID"
+//	@Param			code	path		string	true	"Coupon code"
+//	@Success		200		{object}	models.Cart
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/{id}/coupons/{code}	[delete]
+func (h *CouponHandler) Remove(w http.ResponseWriter, r *http.Request) error {
+	code := r.PathValue("code")
+	cart, err := h.carts.UpdateCoupons(r.Context(), r.PathValue("id"), func(cart *models.Cart) error {
+		kept := make([]string, 0, len(cart.Coupons))
+		for _, applied := range cart.Coupons {
+			if !strings.EqualFold(applied, code) {
+				kept = append(kept, applied)
+			}
+		}
+		if len(kept) == len(cart.Coupons) {
+			return models.NewHTTPError(http.StatusNotFound, fmt.Errorf("coupon %s is not applied to cart %s", code, cart.ID))
+		}
+		cart.Coupons = kept
+		return nil
+	})
+	return h.write(w, r, cart, err)
+}
+
+// write writes the cart whose coupons were updated with its discounts, or
+// the error of the update
+func (h *CouponHandler) write(w http.ResponseWriter, r *http.Request, cart *models.Cart, err error) error {
+	if err != nil {
+		var httpErr *models.HTTPError
+		switch {
+		case errors.As(err, &httpErr):
+			return err
+		case errors.Is(err, repositories.ErrCartNotFound):
+			return models.NewHTTPError(http.StatusNotFound, err)
+		default:
+			return models.NewHTTPError(http.StatusInternalServerError, err)
+		}
+	}
+	if err := addDiscounts(r.Context(), h.coupons, cart, h.now()); err != nil {
+		return err
+	}
+	return writeJSON(w, r, cart)
+}
+
+// WithCoupons adds discounts of coupons applied to returned carts, coupons
+// are looked up by finder
+func WithCoupons(finder CouponFinder) Option {
+	return func(h *CartHandler) {
+		h.coupons = finder
+	}
+}
+
+// addDiscounts sets discounts of coupons applied to the cart at now, codes
+// which are no longer known give no discount
+func addDiscounts(ctx context.Context, finder CouponFinder, cart *models.Cart, now time.Time) error {
+	if finder == nil || len(cart.Coupons) == 0 {
+		return nil
+	}
+	applied, unknown, err := findCoupons(ctx, finder, cart.Coupons)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	cart.Discounts = coupons.Stack(applied, cart, now)
+	total := cart.Total
+	if len(cart.Discounts) > 0 {
+		total = cart.Discounts[len(cart.Discounts)-1].Total
+	}
+	for _, code := range unknown {
+		cart.Discounts = append(cart.Discounts, models.AppliedDiscount{Code: code, Total: total, Reason: coupons.ErrCouponNotFound.Error()})
+	}
+	return nil
+}
+
+// findCoupons looks up coupons by codes, unknown codes are returned apart
+func findCoupons(ctx context.Context, finder CouponFinder, codes []string) ([]models.Coupon, []string, error) {
+	var found []models.Coupon
+	var unknown []string
+	for _, code := range codes {
+		coupon, err := finder.Coupon(ctx, code)
+		if errors.Is(err, coupons.ErrCouponNotFound) {
+			unknown = append(unknown, code)
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("looking up coupon %s: %w", code, err)
+		}
+		found = append(found, coupon)
+	}
+	return found, unknown, nil
+}
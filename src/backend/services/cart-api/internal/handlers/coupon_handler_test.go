@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/coupons"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCouponHandlerValidate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour)
+	cart := &models.Cart{Total: models.Money{Minor: 2500}, LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+		{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1},
+	}}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+	repo.On("Get", mock.Anything, "missing").Return((*models.Cart)(nil), repositories.ErrCartNotFound)
+
+	handler := NewCouponHandler(repo, coupons.NewStatic([]models.Coupon{
+		{Code: "SPRING10", PercentOff: 10},
+		{Code: "WINTER", PercentOff: 10, ExpiresAt: &expired},
+		{Code: "BIG", AmountOff: models.Money{Minor: 1000}, MinSpend: models.Money{Minor: 5000}},
+	}))
+	handler.now = func() time.Time { return now }
+
+	serve := func(code, cartID string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /coupons/{code}/validate", ErrorHandler(handler.Validate))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/coupons/"+code+"/validate?cartId="+cartID, nil))
+		return w
+	}
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) models.CouponValidationResp {
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp models.CouponValidationResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp
+	}
+
+	t.Run("valid coupon should return would-be discount", func(t *testing.T) {
+		resp := decode(t, serve("spring10", "abcd"))
+		assert.True(t, resp.Valid)
+		assert.Equal(t, models.Money{Minor: 250}, resp.Discount)
+		assert.Equal(t, models.Money{Minor: 2250}, resp.Total)
+		assert.Empty(t, resp.Reason)
+	})
+
+	t.Run("expired coupon should be invalid", func(t *testing.T) {
+		resp := decode(t, serve("WINTER", "abcd"))
+		assert.False(t, resp.Valid)
+		assert.Equal(t, coupons.ErrCouponExpired.Error(), resp.Reason)
+		assert.Zero(t, resp.Discount)
+		assert.Equal(t, models.Money{Minor: 2500}, resp.Total)
+	})
+
+	t.Run("below minimum spend should be invalid", func(t *testing.T) {
+		resp := decode(t, serve("BIG", "abcd"))
+		assert.False(t, resp.Valid)
+		assert.Contains(t, resp.Reason, coupons.ErrBelowMinSpend.Error())
+	})
+
+	t.Run("unknown coupon should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("NOPE", "abcd").Code)
+	})
+
+	t.Run("missing cart should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("SPRING10", "missing").Code)
+	})
+
+	t.Run("missing cartId should return 400", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve("SPRING10", "").Code)
+	})
+
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestCouponHandlerApply(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	finder := coupons.NewStatic([]models.Coupon{
+		{Code: "TEN", PercentOff: 10},
+		{Code: "TWENTY", PercentOff: 20},
+		{Code: "FIVE", AmountOff: models.Money{Minor: 500}, Priority: 1},
+		{Code: "ONLY", AmountOff: models.Money{Minor: 100}, Exclusive: true},
+		{Code: "BIG", AmountOff: models.Money{Minor: 1000}, MinSpend: models.Money{Minor: 5000}},
+	})
+
+	newCart := func(t *testing.T) (*repositoriestest.MemoryRepository, string) {
+		repo := repositoriestest.NewMemoryRepository()
+		cart := &models.Cart{ID: uuid.New(), Total: models.Money{Minor: 2500}, LineItems: []models.LineItem{
+			{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+			{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1},
+		}}
+		require.NoError(t, repo.Update(ctx, cart))
+		return repo, cart.ID.String()
+	}
+	serve := func(repo *repositoriestest.MemoryRepository, method, target, body string) *httptest.ResponseRecorder {
+		handler := NewCouponHandler(repo, finder)
+		handler.now = func() time.Time { return now }
+		mux := http.NewServeMux()
+		mux.HandleFunc("POST /cart/{id}/coupons", ErrorHandler(handler.Apply))
+		mux.HandleFunc("DELETE /cart/{id}/coupons/{code}", ErrorHandler(handler.Remove))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+	apply := func(repo *repositoriestest.MemoryRepository, cartID, code string) *httptest.ResponseRecorder {
+		return serve(repo, http.MethodPost, "/cart/"+cartID+"/coupons", `{"code":"`+code+`"}`)
+	}
+
+	t.Run("stacked coupons should be applied by precedence", func(t *testing.T) {
+		repo, cartID := newCart(t)
+		require.Equal(t, http.StatusOK, apply(repo, cartID, "ten").Code)
+		w := apply(repo, cartID, "FIVE")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp models.Cart
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, []string{"TEN", "FIVE"}, resp.Coupons)
+		assert.Equal(t, []models.AppliedDiscount{
+			{Code: "FIVE", Amount: models.Money{Minor: 500}, Total: models.Money{Minor: 2000}},
+			{Code: "TEN", Amount: models.Money{Minor: 200}, Total: models.Money{Minor: 1800}},
+		}, resp.Discounts)
+
+		stored, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TEN", "FIVE"}, stored.Coupons)
+
+		cartHandler := NewCartHandler(repo, WithCoupons(finder))
+		r := httptest.NewRequest(http.MethodGet, "/cart/"+cartID, nil)
+		r.SetPathValue("id", cartID)
+		got := httptest.NewRecorder()
+		ErrorHandler(cartHandler.Get)(got, r)
+		require.NoError(t, json.NewDecoder(got.Body).Decode(&resp))
+		assert.Equal(t, models.Money{Minor: 1800}, resp.Discounts[1].Total)
+	})
+
+	t.Run("conflicting coupons should return 409", func(t *testing.T) {
+		repo, cartID := newCart(t)
+		require.Equal(t, http.StatusOK, apply(repo, cartID, "TEN").Code)
+
+		assert.Equal(t, http.StatusConflict, apply(repo, cartID, "TWENTY").Code)
+		assert.Equal(t, http.StatusConflict, apply(repo, cartID, "ONLY").Code)
+		assert.Equal(t, http.StatusConflict, apply(repo, cartID, "TEN").Code)
+
+		stored, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TEN"}, stored.Coupons)
+	})
+
+	t.Run("coupons giving no discount should return 422", func(t *testing.T) {
+		repo, cartID := newCart(t)
+		w := apply(repo, cartID, "BIG")
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "below_min_spend")
+	})
+
+	t.Run("unknown coupon and cart should return 404", func(t *testing.T) {
+		repo, cartID := newCart(t)
+		assert.Equal(t, http.StatusNotFound, apply(repo, cartID, "NOPE").Code)
+		assert.Equal(t, http.StatusNotFound, apply(repo, uuid.NewString(), "TEN").Code)
+		assert.Equal(t, http.StatusBadRequest, apply(repo, cartID, "").Code)
+	})
+
+	t.Run("removed coupon should free its group", func(t *testing.T) {
+		repo, cartID := newCart(t)
+		require.Equal(t, http.StatusOK, apply(repo, cartID, "TEN").Code)
+
+		require.Equal(t, http.StatusOK, serve(repo, http.MethodDelete, "/cart/"+cartID+"/coupons/ten", "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(repo, http.MethodDelete, "/cart/"+cartID+"/coupons/TEN", "").Code)
+		assert.Equal(t, http.StatusOK, apply(repo, cartID, "TWENTY").Code)
+	})
+}
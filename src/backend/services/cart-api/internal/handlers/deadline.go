@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TimeoutHeader lets callers bound time the server spends on their request
+const TimeoutHeader = "X-Timeout-Ms"
+
+// DeadlineMiddleware sets deadline of the request context from X-Timeout-Ms,
+// capped at max so callers can't hold resources longer. Missing, invalid and
+// non positive values leave the request without deadline
+func DeadlineMiddleware(max time.Duration, next http.Handler) http.Handler {
+	return RouteDeadlineMiddleware(RouteTimeouts{}, max, next)
+}
+
+// Router resolves the route pattern of requests, *http.ServeMux implements it
+type Router interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// RouteTimeouts are deadlines of requests not setting X-Timeout-Ms. Routes
+// is keyed by patterns Router registered, e.g. "POST /api/v1/cart/{id}/items",
+// other routes get Default, zero Default leaves them without deadline
+type RouteTimeouts struct {
+	Router  Router
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// timeout returns deadline of the route of r
+func (t RouteTimeouts) timeout(r *http.Request) time.Duration {
+	if t.Router != nil && len(t.Routes) > 0 {
+		if _, pattern := t.Router.Handler(r); pattern != "" {
+			if timeout, ok := t.Routes[pattern]; ok {
+				return timeout
+			}
+		}
+	}
+	return t.Default
+}
+
+// RouteDeadlineMiddleware is DeadlineMiddleware falling back to timeouts of
+// the route when the request doesn't set X-Timeout-Ms, e.g. to give bulk
+// operations longer than reads. Zero max ignores the header
+func RouteDeadlineMiddleware(timeouts RouteTimeouts, max time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout, ok := time.Duration(0), false
+		if max > 0 {
+			timeout, ok = requestTimeout(r, max)
+		}
+		if !ok {
+			timeout = timeouts.timeout(r)
+			ok = timeout > 0
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func requestTimeout(r *http.Request, max time.Duration) (time.Duration, bool) {
+	value := r.Header.Get(TimeoutHeader)
+	if value == "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		logFromCtx(r.Context()).Debug().Str("value", value).Msg("ignoring invalid " + TimeoutHeader)
+		return 0, false
+	}
+	timeout := time.Duration(ms) * time.Millisecond
+	if timeout > max || timeout/time.Millisecond != time.Duration(ms) {
+		timeout = max
+	}
+	return timeout, true
+}
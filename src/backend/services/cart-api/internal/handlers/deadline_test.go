@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlineMiddleware(t *testing.T) {
+	max := time.Second
+
+	deadline := func(header string) (time.Duration, bool) {
+		var remaining time.Duration
+		var ok bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var d time.Time
+			if d, ok = r.Context().Deadline(); ok {
+				remaining = time.Until(d)
+			}
+		})
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+		if header != "" {
+			r.Header.Set(TimeoutHeader, header)
+		}
+		DeadlineMiddleware(max, next).ServeHTTP(httptest.NewRecorder(), r)
+		return remaining, ok
+	}
+
+	t.Run("header should set deadline", func(t *testing.T) {
+		remaining, ok := deadline("200")
+		assert.True(t, ok)
+		assert.InDelta(t, 200*time.Millisecond, remaining, float64(50*time.Millisecond))
+	})
+
+	t.Run("header above max should be clamped", func(t *testing.T) {
+		for _, value := range []string{"60000", "9223372036854775807"} {
+			remaining, ok := deadline(value)
+			assert.True(t, ok)
+			assert.InDelta(t, max, remaining, float64(50*time.Millisecond))
+		}
+	})
+
+	for _, value := range []string{"", "abc", "-5", "0", "1.5"} {
+		t.Run("value "+value+" should be ignored", func(t *testing.T) {
+			_, ok := deadline(value)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	}
+	handler := DeadlineMiddleware(time.Second, http.HandlerFunc(ErrorHandler(slow)))
+
+	r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+	r.Header.Set(TimeoutHeader, "10")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "deadline exceeded")
+}
+
+func TestRouteDeadlineMiddleware(t *testing.T) {
+	router := http.NewServeMux()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	router.HandleFunc("GET /cart/{id}", noop)
+	router.HandleFunc("POST /cart/{id}/items", noop)
+	router.HandleFunc("GET /capabilities", noop)
+	timeouts := RouteTimeouts{
+		Router:  router,
+		Default: 2 * time.Second,
+		Routes: map[string]time.Duration{
+			"GET /cart/{id}":        500 * time.Millisecond,
+			"POST /cart/{id}/items": 20 * time.Second,
+		},
+	}
+
+	deadline := func(method, path, header string) (time.Duration, bool) {
+		var remaining time.Duration
+		var ok bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var d time.Time
+			if d, ok = r.Context().Deadline(); ok {
+				remaining = time.Until(d)
+			}
+		})
+		r := httptest.NewRequest(method, path, nil)
+		if header != "" {
+			r.Header.Set(TimeoutHeader, header)
+		}
+		RouteDeadlineMiddleware(timeouts, 30*time.Second, next).ServeHTTP(httptest.NewRecorder(), r)
+		return remaining, ok
+	}
+	delta := float64(50 * time.Millisecond)
+
+	t.Run("bulk route should get longer deadline than get", func(t *testing.T) {
+		get, ok := deadline(http.MethodGet, "/cart/abcd", "")
+		assert.True(t, ok)
+		bulk, ok := deadline(http.MethodPost, "/cart/abcd/items", "")
+		assert.True(t, ok)
+		assert.InDelta(t, 500*time.Millisecond, get, delta)
+		assert.InDelta(t, 20*time.Second, bulk, delta)
+		assert.Greater(t, bulk, get)
+	})
+
+	t.Run("other routes should get default", func(t *testing.T) {
+		remaining, ok := deadline(http.MethodGet, "/capabilities", "")
+		assert.True(t, ok)
+		assert.InDelta(t, 2*time.Second, remaining, delta)
+
+		remaining, ok = deadline(http.MethodGet, "/unknown", "")
+		assert.True(t, ok)
+		assert.InDelta(t, 2*time.Second, remaining, delta)
+	})
+
+	t.Run("header should override route timeout up to max", func(t *testing.T) {
+		remaining, ok := deadline(http.MethodPost, "/cart/abcd/items", "100")
+		assert.True(t, ok)
+		assert.InDelta(t, 100*time.Millisecond, remaining, delta)
+
+		remaining, ok = deadline(http.MethodGet, "/cart/abcd", "60000")
+		assert.True(t, ok)
+		assert.InDelta(t, 30*time.Second, remaining, delta)
+	})
+
+	t.Run("zero default should leave other routes without deadline", func(t *testing.T) {
+		timeouts.Default = 0
+		_, ok := deadline(http.MethodGet, "/capabilities", "")
+		assert.False(t, ok)
+	})
+}
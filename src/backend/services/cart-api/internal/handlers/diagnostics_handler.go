@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type LagChecker interface {
+	Lag() (map[int32]int64, error)
+}
+
+type ProcessedTracker interface {
+	LastProcessed() time.Time
+}
+
+type PauseChecker interface {
+	Paused() bool
+}
+
+type ArchiveCounter interface {
+	ArchivedCount(ctx context.Context) (int64, error)
+}
+
+// DiagnosticsHandler summarizes health of dependencies for operators
+type DiagnosticsHandler struct {
+	redis     Pinger
+	lag       LagChecker
+	processed ProcessedTracker
+	consumer  PauseChecker
+	archive   ArchiveCounter
+}
+
+// NewDiagnosticsHandler creates new instance of DiagnosticsHandler
+func NewDiagnosticsHandler(redis Pinger, lag LagChecker, processed ProcessedTracker, consumer PauseChecker, archive ArchiveCounter) *DiagnosticsHandler {
+	return &DiagnosticsHandler{redis: redis, lag: lag, processed: processed, consumer: consumer, archive: archive}
+}
+
+// Get go doc
+//
+//	@Summary		Diagnostics
+//	@Description	Returns redis latency, orders consumer lag and pause state, time of the last processed OrderCompleted event and number of archived carts, 503 when any dependency is unhealthy
+//	@Tags			Admin
+//	@Produce		json
+//	@Success		200	{object}	models.Diagnostics
+//	@Failure		401	{object}	models.HTTPError
+//	@Failure		403	{object}	models.HTTPError
+//	@Failure		503	{object}	models.Diagnostics
+//	@Router			/admin/diagnostics	[get]
+func (h *DiagnosticsHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	var d models.Diagnostics
+
+	start := time.Now()
+	err := h.redis.Ping(r.Context())
+	d.Redis.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	d.Redis.Healthy = err == nil
+	if err != nil {
+		d.Redis.Error = err.Error()
+	}
+
+	lag, err := h.lag.Lag()
+	d.Kafka.Healthy = err == nil
+	d.Kafka.Paused = h.consumer.Paused()
+	if err != nil {
+		d.Kafka.Error = err.Error()
+	} else {
+		d.Kafka.Details = lag
+		for _, n := range lag {
+			d.Kafka.Lag += n
+		}
+	}
+
+	// the count is informational, redis health is checked by the ping
+	if archived, err := h.archive.ArchivedCount(r.Context()); err == nil {
+		d.ArchivedCarts = archived
+	} else {
+		logFromCtx(r.Context()).Warn().Err(err).Msg("failed to count archived carts")
+	}
+
+	if last := h.processed.LastProcessed(); !last.IsZero() {
+		last = last.UTC()
+		d.LastOrderCompletedAt = &last
+	}
+
+	d.Healthy = d.Redis.Healthy && d.Kafka.Healthy
+	if !d.Healthy {
+		return writeJSONStatus(w, r, http.StatusServiceUnavailable, d)
+	}
+	return writeJSON(w, r, d)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubPinger struct{ err error }
+
+func (s stubPinger) Ping(ctx context.Context) error { return s.err }
+
+type stubLagChecker struct {
+	lag map[int32]int64
+	err error
+}
+
+func (s stubLagChecker) Lag() (map[int32]int64, error) { return s.lag, s.err }
+
+type stubTracker struct{ last time.Time }
+
+func (s stubTracker) LastProcessed() time.Time { return s.last }
+
+type stubPauseChecker struct{ paused bool }
+
+func (s stubPauseChecker) Paused() bool { return s.paused }
+
+type stubArchiveCounter struct{ n int64 }
+
+func (s stubArchiveCounter) ArchivedCount(ctx context.Context) (int64, error) { return s.n, nil }
+
+func TestDiagnosticsHandler(t *testing.T) {
+	last := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	get := func(t *testing.T, handler *DiagnosticsHandler) (int, models.Diagnostics) {
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.Get)(w, adminRequest(http.MethodGet, "/admin/diagnostics"))
+		var d models.Diagnostics
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&d))
+		return w.Code, d
+	}
+
+	t.Run("healthy dependencies should return 200", func(t *testing.T) {
+		handler := NewDiagnosticsHandler(stubPinger{}, stubLagChecker{lag: map[int32]int64{0: 3, 1: 4}}, stubTracker{last: last}, stubPauseChecker{}, stubArchiveCounter{n: 5})
+		code, d := get(t, handler)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, d.Healthy)
+		assert.True(t, d.Redis.Healthy)
+		assert.Equal(t, int64(7), d.Kafka.Lag)
+		assert.False(t, d.Kafka.Paused)
+		assert.Equal(t, last, *d.LastOrderCompletedAt)
+		assert.Equal(t, int64(5), d.ArchivedCarts)
+	})
+
+	t.Run("paused consumer should be reported and stay healthy", func(t *testing.T) {
+		handler := NewDiagnosticsHandler(stubPinger{}, stubLagChecker{lag: map[int32]int64{0: 3}}, stubTracker{}, stubPauseChecker{paused: true}, stubArchiveCounter{})
+		code, d := get(t, handler)
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, d.Kafka.Paused)
+		assert.True(t, d.Kafka.Healthy)
+	})
+
+	t.Run("redis down should return 503", func(t *testing.T) {
+		handler := NewDiagnosticsHandler(stubPinger{err: errors.New("connection refused")}, stubLagChecker{}, stubTracker{}, stubPauseChecker{}, stubArchiveCounter{})
+		code, d := get(t, handler)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.False(t, d.Healthy)
+		assert.Equal(t, "connection refused", d.Redis.Error)
+		assert.True(t, d.Kafka.Healthy)
+		assert.Nil(t, d.LastOrderCompletedAt)
+	})
+
+	t.Run("lag check failure should return 503", func(t *testing.T) {
+		handler := NewDiagnosticsHandler(stubPinger{}, stubLagChecker{err: errors.New("coordinator not available")}, stubTracker{}, stubPauseChecker{}, stubArchiveCounter{})
+		code, d := get(t, handler)
+
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.False(t, d.Kafka.Healthy)
+		assert.Equal(t, "coordinator not available", d.Kafka.Error)
+	})
+
+	t.Run("callers without admin role should be rejected", func(t *testing.T) {
+		handler := NewDiagnosticsHandler(stubPinger{}, stubLagChecker{}, stubTracker{}, stubPauseChecker{}, stubArchiveCounter{})
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.Get)(w, httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		r := httptest.NewRequest(http.MethodGet, "/admin/diagnostics", nil)
+		r.Header.Set(UserRoleHeader, "customer")
+		w = httptest.NewRecorder()
+		ErrorHandler(handler.Get)(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
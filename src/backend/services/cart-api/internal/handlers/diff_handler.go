@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+type CartVersioner interface {
+	Get(ctx context.Context, cartID string) (*models.Cart, error)
+	VersionByETag(ctx context.Context, cartID, etag string) (*models.Cart, error)
+	VersionAt(ctx context.Context, cartID string, t time.Time) (*models.Cart, error)
+}
+
+// DiffHandler serves changes of a cart relative to its prior versions
+type DiffHandler struct {
+	versioner CartVersioner
+}
+
+// NewDiffHandler creates new instance of DiffHandler
+func NewDiffHandler(v CartVersioner) *DiffHandler {
+	return &DiffHandler{versioner: v}
+}
+
+// Diff go doc
+//
+//	@Summary		Diffs a Cart
+//	@Description	Returns items added, removed and changed since a prior version given by ETag, RFC 3339 time or unix seconds
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			id		path		string	true	"Cart ID"
+//	@Param			since	query		string	true	"ETag or timestamp of the prior version"
+//	@Success		200		{object}	models.CartDiff
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/{id}/diff	[get]
+func (h *DiffHandler) Diff(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("since is required"))
+	}
+
+	current, err := h.versioner.Get(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	var previous *models.Cart
+	if t, ok := parseSince(since); ok {
+		previous, err = h.versioner.VersionAt(r.Context(), cartID, t)
+	} else {
+		previous, err = h.versioner.VersionByETag(r.Context(), cartID, quoteETag(since))
+	}
+	if err != nil {
+		if errors.Is(err, repositories.ErrVersionNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, models.DiffCarts(previous, current))
+}
+
+// parseSince reads since as RFC 3339 time or unix seconds
+func parseSince(since string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+		return t, true
+	}
+	if seconds, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	return time.Time{}, false
+}
+
+// quoteETag accepts etags with or without quotes, query parameters are often
+// passed without them
+func quoteETag(etag string) string {
+	etag = strings.TrimPrefix(etag, "W/")
+	if strings.HasPrefix(etag, `"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubVersioner serves a current cart and a single prior version
+type stubVersioner struct {
+	current, previous *models.Cart
+	at                time.Time
+}
+
+func (s *stubVersioner) Get(ctx context.Context, cartID string) (*models.Cart, error) {
+	if cartID != s.current.ID.String() {
+		return nil, repositories.ErrCartNotFound
+	}
+	return s.current, nil
+}
+
+func (s *stubVersioner) VersionByETag(ctx context.Context, cartID, etag string) (*models.Cart, error) {
+	if etag != models.ETag(s.previous) {
+		return nil, repositories.ErrVersionNotFound
+	}
+	return s.previous, nil
+}
+
+func (s *stubVersioner) VersionAt(ctx context.Context, cartID string, t time.Time) (*models.Cart, error) {
+	if t.Before(s.at) {
+		return nil, repositories.ErrVersionNotFound
+	}
+	return s.previous, nil
+}
+
+func TestDiffHandler(t *testing.T) {
+	id := uuid.New()
+	previous := &models.Cart{ID: id, LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1},
+		{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 2},
+	}}
+	current := &models.Cart{ID: id, LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 3},
+		{ItemID: 3, UnitPrice: models.Money{Minor: 700}, Quantity: 1},
+	}}
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	handler := NewDiffHandler(&stubVersioner{current: current, previous: previous, at: at})
+
+	serve := func(cartID, since string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/cart/"+cartID+"/diff?since="+since, nil)
+		r.SetPathValue("id", cartID)
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.Diff)(w, r)
+		return w
+	}
+
+	etag := models.ETag(previous)
+	for name, since := range map[string]string{
+		"etag":          etag,
+		"unquoted etag": etag[1 : len(etag)-1],
+		"timestamp":     at.Add(time.Second).Format(time.RFC3339),
+		"unix seconds":  "1709294401",
+	} {
+		t.Run("diff since "+name, func(t *testing.T) {
+			w := serve(id.String(), since)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var diff models.CartDiff
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&diff))
+			assert.Equal(t, []models.LineItem{current.LineItems[1]}, diff.Added)
+			assert.Equal(t, []models.LineItem{previous.LineItems[1]}, diff.Removed)
+			assert.Equal(t, []models.LineItemChange{{ItemID: 1, Before: previous.LineItems[0], After: current.LineItems[0]}}, diff.Changed)
+			assert.Equal(t, etag, diff.SinceETag)
+			assert.Equal(t, models.ETag(current), diff.ETag)
+		})
+	}
+
+	t.Run("unknown version should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(id.String(), `"deadbeef"`).Code)
+		assert.Equal(t, http.StatusNotFound, serve(id.String(), at.Add(-time.Hour).Format(time.RFC3339)).Code)
+	})
+
+	t.Run("missing cart should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(uuid.NewString(), etag).Code)
+	})
+
+	t.Run("missing since should return 400", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(id.String(), "").Code)
+	})
+}
+
+func TestSubresourceRouter(t *testing.T) {
+	var served string
+	router := NewSubresourceRouter(func(w http.ResponseWriter, r *http.Request) error {
+		served = "shared " + r.PathValue("token")
+		return nil
+	}).Register("diff", func(w http.ResponseWriter, r *http.Request) error {
+		served = "diff " + r.PathValue("id")
+		return nil
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cart/{id}/{resource}", ErrorHandler(router.Handle))
+
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/cart/share/token-1"))
+	assert.Equal(t, "shared token-1", served)
+	assert.Equal(t, http.StatusOK, serve("/cart/abcd/diff"))
+	assert.Equal(t, "diff abcd", served)
+	assert.Equal(t, http.StatusNotFound, serve("/cart/abcd/unknown"))
+
+	mux = http.NewServeMux()
+	mux.HandleFunc("GET /cart/{id}/{resource}", ErrorHandler(NewSubresourceRouter(nil).Handle))
+	assert.Equal(t, http.StatusNotFound, serve("/cart/share/token-1"))
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries request id chosen by the client or a proxy
+const RequestIDHeader = "X-Request-ID"
+
+type envelopeKey struct{}
+
+// EnvelopeMiddleware wraps successful responses of every request into
+// models.Envelope regardless of the Accept header
+func EnvelopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), envelopeKey{}, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// wantsEnvelope reports whether the response of r is wrapped, either enabled
+// by EnvelopeMiddleware or asked by the client with the envelope parameter,
+// e.g. Accept: application/json; envelope=true
+func wantsEnvelope(r *http.Request) bool {
+	if enabled, _ := r.Context().Value(envelopeKey{}).(bool); enabled {
+		return true
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil || mediaType != "application/json" {
+				continue
+			}
+			if enabled, _ := strconv.ParseBool(params["envelope"]); enabled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// envelope wraps v when the request asks for it
+func envelope(r *http.Request, v interface{}) interface{} {
+	if !wantsEnvelope(r) {
+		return v
+	}
+	return models.Envelope{
+		Data: v,
+		Meta: models.EnvelopeMeta{
+			RequestID:  requestID(r),
+			ServerTime: time.Now().UTC(),
+		},
+	}
+}
+
+// requestID is X-Request-ID of r, the trace id is used when it is missing
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	if spanCtx := trace.SpanContextFromContext(r.Context()); spanCtx.IsValid() {
+		return spanCtx.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	cart := &models.Cart{LineItems: items}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+	handler := NewCartHandler(repo)
+
+	serve := func(accept string, middleware bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+		r.SetPathValue("id", "abcd")
+		r.Header.Set(RequestIDHeader, "req-1")
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		var h http.Handler = http.HandlerFunc(ErrorHandler(handler.Get))
+		if middleware {
+			h = EnvelopeMiddleware(h)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("raw response by default", func(t *testing.T) {
+		w := serve("application/json", false)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		assert.NotContains(t, body, "data")
+		assert.Contains(t, body, "items")
+	})
+
+	for name, tt := range map[string]struct {
+		accept     string
+		middleware bool
+	}{
+		"envelope by accept param": {"application/json; envelope=true", false},
+		"envelope by config":       {"", true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := serve(tt.accept, tt.middleware)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var body struct {
+				Data models.Cart         `json:"data"`
+				Meta models.EnvelopeMeta `json:"meta"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+			assert.Equal(t, cart.LineItems, body.Data.LineItems)
+			assert.Equal(t, "req-1", body.Meta.RequestID)
+			assert.WithinDuration(t, time.Now(), body.Meta.ServerTime, time.Minute)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/jurabek/cart-api/pkg/breaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestErrorHandler(t *testing.T) {
+	t.Run("open circuit breaker should return 503 with Retry-After", func(t *testing.T) {
+		f := func(w http.ResponseWriter, r *http.Request) error {
+			err := fmt.Errorf("error getting key abcd: %w", &breaker.OpenError{RetryAfter: 2500 * time.Millisecond})
+			return models.NewHTTPError(http.StatusInternalServerError, err)
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(f)(w, httptest.NewRequest(http.MethodGet, "/cart/abcd", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "3", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("http error should be written with its code", func(t *testing.T) {
+		f := func(w http.ResponseWriter, r *http.Request) error {
+			return models.NewHTTPError(http.StatusNotFound, fmt.Errorf("cart not found"))
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(f)(w, httptest.NewRequest(http.MethodGet, "/cart/abcd", nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	})
+}
+
+// redisError is a reply error as returned by go-redis
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+func (e redisError) RedisError()   {}
+
+func TestOutOfMemory(t *testing.T) {
+	oom := redisError("OOM command not allowed when used memory > 'maxmemory'.")
+	repo := &CartRepositoryMock{}
+	repo.On("AddItem", mock.Anything, "abcd", mock.Anything).Return(fmt.Errorf("error setting key abcd: %w", oom))
+	handler := NewCartHandler(repo)
+
+	r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", strings.NewReader(`{"item_id": 1, "quantity": 1}`))
+	r.SetPathValue("id", "abcd")
+	w := httptest.NewRecorder()
+	ErrorHandler(handler.AddItem)(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.NotContains(t, w.Body.String(), "maxmemory")
+}
+
+func TestErrorBodyShape(t *testing.T) {
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "missing").Return((*models.Cart)(nil), repositories.ErrCartNotFound)
+	repo.On("Delete", mock.Anything, "broken").Return(fmt.Errorf("connection reset"))
+	handler := NewCartHandler(repo)
+
+	newRequest := func(method, target, cartID, body string) *http.Request {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.SetPathValue("id", cartID)
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request) error
+		request *http.Request
+		want    int
+	}{
+		{"missing cart", handler.Get, newRequest(http.MethodGet, "/cart/missing", "missing", ""), http.StatusNotFound},
+		{"repository failure", handler.Delete, newRequest(http.MethodDelete, "/cart/broken", "broken", ""), http.StatusInternalServerError},
+		{"malformed body", handler.AddItem, newRequest(http.MethodPost, "/cart/abcd/item", "abcd", "{"), http.StatusBadRequest},
+		{"wrong content type", RequireJSON(handler.AddItem), newRequest(http.MethodPost, "/cart/abcd/item", "abcd", "{}"), http.StatusUnsupportedMediaType},
+		{"open breaker", func(w http.ResponseWriter, r *http.Request) error {
+			return &breaker.OpenError{RetryAfter: time.Second}
+		}, newRequest(http.MethodGet, "/cart/abcd", "abcd", ""), http.StatusServiceUnavailable},
+		{"unmapped error", func(w http.ResponseWriter, r *http.Request) error {
+			return fmt.Errorf("boom")
+		}, newRequest(http.MethodGet, "/cart/abcd", "abcd", ""), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ErrorHandler(tt.handler)(w, tt.request)
+
+			assert.Equal(t, tt.want, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var body map[string]interface{}
+			assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+			assert.Len(t, body, 2)
+			assert.Equal(t, float64(tt.want), body["code"])
+			assert.NotEmpty(t, body["message"])
+		})
+	}
+}
+
+func TestCancelledRequest(t *testing.T) {
+	cart := &models.Cart{LineItems: items}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+	repo.On("Get", mock.Anything, "slow").Return((*models.Cart)(nil), context.Canceled)
+	handler := NewCartHandler(repo)
+
+	for _, cartID := range []string{"abcd", "slow"} {
+		t.Run("Get should not write a body for "+cartID, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			r := httptest.NewRequest(http.MethodGet, "/cart/"+cartID, nil).WithContext(ctx)
+			r.SetPathValue("id", cartID)
+			w := httptest.NewRecorder()
+
+			done := make(chan struct{})
+			go func() {
+				ErrorHandler(handler.Get)(w, r)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("handler did not return")
+			}
+
+			assert.Zero(t, w.Body.Len())
+			assert.Empty(t, w.Header().Get("Content-Type"))
+		})
+	}
+}
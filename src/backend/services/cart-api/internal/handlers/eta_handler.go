@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// ETA is how long an order of a cart takes to prepare and then to deliver
+type ETA struct {
+	Preparation time.Duration
+	Delivery    time.Duration
+}
+
+// ETAEstimator estimates preparation and delivery of an order of the cart,
+// e.g. from kitchen load or the distance to the customer
+type ETAEstimator interface {
+	Estimate(ctx context.Context, cart *models.Cart) (ETA, error)
+}
+
+// PerItemETA prepares an order in Base plus PerItem for every unit of the
+// cart, capped at MaxPreparation when set, and delivers it in Delivery
+type PerItemETA struct {
+	Base           time.Duration
+	PerItem        time.Duration
+	MaxPreparation time.Duration
+	Delivery       time.Duration
+}
+
+// Estimate implements ETAEstimator.
+func (e PerItemETA) Estimate(ctx context.Context, cart *models.Cart) (ETA, error) {
+	units := 0
+	for _, item := range cart.LineItems {
+		units += item.Quantity
+	}
+	preparation := e.Base + time.Duration(units)*e.PerItem
+	if e.MaxPreparation > 0 {
+		preparation = min(preparation, e.MaxPreparation)
+	}
+	return ETA{Preparation: preparation, Delivery: e.Delivery}, nil
+}
+
+// ETAHandler serves delivery estimates of carts
+type ETAHandler struct {
+	carts     CartGetter
+	estimator ETAEstimator
+	now       func() time.Time
+}
+
+// NewETAHandler creates new instance of ETAHandler
+func NewETAHandler(carts CartGetter, estimator ETAEstimator) *ETAHandler {
+	return &ETAHandler{carts: carts, estimator: estimator, now: time.Now}
+}
+
+// ETA go doc
+//
+//	@Summary		Estimates delivery of a Cart
+//	@Description	Returns minutes an order of the cart takes to prepare and to deliver and when it arrives if ordered now, carts without items get 422
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			id	path		string	true	"Cart ID"
+//	@Success		200	{object}	models.CartETA
+//	@Failure		400	{object}	models.HTTPError
+//	@Failure		404	{object}	models.HTTPError
+//	@Failure		422	{object}	models.HTTPError
+//	@Failure		502	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/cart/{id}/eta	[get]
+func (h *ETAHandler) ETA(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	cart, err := h.carts.Get(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+cartID))
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if len(cart.LineItems) == 0 {
+		return fmt.Errorf("%w: cart %s", ErrEmptyCart, cartID)
+	}
+
+	eta, err := h.estimator.Estimate(r.Context(), cart)
+	if err != nil {
+		return models.NewHTTPError(http.StatusBadGateway, fmt.Errorf("estimating delivery of cart %s: %w", cartID, err))
+	}
+	total := eta.Preparation + eta.Delivery
+	return writeJSON(w, r, models.CartETA{
+		PreparationMinutes: minutes(eta.Preparation),
+		DeliveryMinutes:    minutes(eta.Delivery),
+		TotalMinutes:       minutes(total),
+		ArrivesAt:          h.now().Add(total).UTC().Truncate(time.Second),
+	})
+}
+
+// minutes rounds d up so an estimate is never early
+func minutes(d time.Duration) int {
+	return int(math.Ceil(d.Minutes()))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubEstimator struct {
+	eta  ETA
+	err  error
+	cart *models.Cart
+}
+
+func (s *stubEstimator) Estimate(ctx context.Context, cart *models.Cart) (ETA, error) {
+	s.cart = cart
+	return s.eta, s.err
+}
+
+func TestETAHandler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := repositoriestest.NewMemoryRepository()
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 2}}}
+	empty := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+	require.NoError(t, repo.Update(ctx, cart))
+	require.NoError(t, repo.Update(ctx, empty))
+
+	get := func(estimator ETAEstimator, cartID string) *httptest.ResponseRecorder {
+		handler := NewETAHandler(repo, estimator)
+		handler.now = func() time.Time { return now }
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /cart/{id}/eta", ErrorHandler(handler.ETA))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/"+cartID+"/eta", nil))
+		return w
+	}
+
+	t.Run("estimate should be returned in minutes with arrival time", func(t *testing.T) {
+		estimator := &stubEstimator{eta: ETA{Preparation: 14*time.Minute + 10*time.Second, Delivery: 20 * time.Minute}}
+		w := get(estimator, cart.ID.String())
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var eta models.CartETA
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&eta))
+		assert.Equal(t, models.CartETA{
+			PreparationMinutes: 15,
+			DeliveryMinutes:    20,
+			TotalMinutes:       35,
+			ArrivesAt:          now.Add(34*time.Minute + 10*time.Second),
+		}, eta)
+		assert.Equal(t, cart.LineItems, estimator.cart.LineItems, "estimator should get the cart")
+	})
+
+	t.Run("empty and missing carts should not be estimated", func(t *testing.T) {
+		w := get(&stubEstimator{}, empty.ID.String())
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "empty_cart")
+
+		assert.Equal(t, http.StatusNotFound, get(&stubEstimator{}, uuid.NewString()).Code)
+	})
+
+	t.Run("failing estimator should return 502", func(t *testing.T) {
+		w := get(&stubEstimator{err: errors.New("routing unavailable")}, cart.ID.String())
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+	})
+}
+
+func TestPerItemETA(t *testing.T) {
+	estimator := PerItemETA{Base: 5 * time.Minute, PerItem: 2 * time.Minute, Delivery: 20 * time.Minute}
+	cart := &models.Cart{LineItems: []models.LineItem{{ItemID: 1, Quantity: 2}, {ItemID: 2, Quantity: 3}}}
+
+	eta, err := estimator.Estimate(context.Background(), cart)
+	require.NoError(t, err)
+	assert.Equal(t, ETA{Preparation: 15 * time.Minute, Delivery: 20 * time.Minute}, eta)
+
+	estimator.MaxPreparation = 12 * time.Minute
+	eta, err = estimator.Estimate(context.Background(), cart)
+	require.NoError(t, err)
+	assert.Equal(t, 12*time.Minute, eta.Preparation, "preparation should be capped")
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// CartWatcher streams snapshots of a cart written after Watch, the channel is
+// closed when ctx is done or the cart is gone
+type CartWatcher interface {
+	Watch(ctx context.Context, cartID string) (<-chan *models.Cart, error)
+}
+
+// EventsHandler pushes carts to web clients with Server-Sent Events
+type EventsHandler struct {
+	carts     CartGetter
+	watcher   CartWatcher
+	heartbeat time.Duration
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewEventsHandler creates new instance of EventsHandler, a comment is sent
+// every heartbeat so proxies keep idle streams open, zero sends none
+func NewEventsHandler(carts CartGetter, watcher CartWatcher, heartbeat time.Duration) *EventsHandler {
+	return &EventsHandler{carts: carts, watcher: watcher, heartbeat: heartbeat, closing: make(chan struct{})}
+}
+
+// Close ends open streams and every stream started after, streams never go
+// idle so http.Server.Shutdown would wait for them until it times out
+func (h *EventsHandler) Close() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// Events go doc
+//
+//	@Summary		Streams changes of a Cart
+//	@Description	Server-Sent Events stream sending the cart as a cart event right away and again after each change. The stream ends when the cart is deleted, completed or cancelled and when the server shuts down, EventSource reconnects to the latter.
+//	@Tags			Cart
+//	@Produce		text/event-stream
+//	@Param			id	path		string	true	"Cart ID"
+//	@Success		200	{object}	models.Cart
+//	@Failure		400	{object}	models.HTTPError
+//	@Failure		404	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/cart/{id}/events	[get]
+func (h *EventsHandler) Events(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// watching before reading the cart misses no change in between
+	changes, err := h.watcher.Watch(ctx, cartID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	cart, err := h.carts.Get(ctx, cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+cartID))
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	stream := http.NewResponseController(w)
+	// the response is committed, failed writes mean the client went away
+	if err := h.send(w, r, stream, cart); err != nil {
+		logFromCtx(ctx).Debug().Err(err).Str("cart_id", cartID).Msg("cart events stream closed")
+		return nil
+	}
+
+	var heartbeat <-chan time.Time
+	if h.heartbeat > 0 {
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-h.closing:
+			return nil
+		case cart, ok := <-changes:
+			if !ok {
+				return nil
+			}
+			err = h.send(w, r, stream, cart)
+		case <-heartbeat:
+			if _, err = fmt.Fprint(w, ": heartbeat\n\n"); err == nil {
+				err = stream.Flush()
+			}
+		}
+		if err != nil {
+			logFromCtx(ctx).Debug().Err(err).Str("cart_id", cartID).Msg("cart events stream closed")
+			return nil
+		}
+	}
+}
+
+// send writes cart as a cart event in the requested field casing
+func (h *EventsHandler) send(w http.ResponseWriter, r *http.Request, stream *http.ResponseController, cart *models.Cart) error {
+	data, err := marshalerFor(r).Marshal(cart)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: cart\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+	return stream.Flush()
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories/repositoriestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsHandler(t *testing.T) {
+	ctx := context.Background()
+	repo := repositoriestest.NewMemoryRepository()
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+	require.NoError(t, repo.Update(ctx, cart))
+
+	serve := func(heartbeat time.Duration) *httptest.Server {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /cart/{id}/events", ErrorHandler(NewEventsHandler(repo, repo, heartbeat).Events))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return server
+	}
+	// connect returns lines of the stream until it ends
+	connect := func(t *testing.T, server *httptest.Server, cartID string) (*http.Response, <-chan string) {
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/cart/"+cartID+"/events", nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(r)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		lines := make(chan string)
+		go func() {
+			defer close(lines)
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+		}()
+		return resp, lines
+	}
+	// next returns the cart of the next cart event
+	next := func(t *testing.T, lines <-chan string) *models.Cart {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case line, ok := <-lines:
+				require.True(t, ok, "stream should go on")
+				data, found := strings.CutPrefix(line, "data: ")
+				if !found {
+					continue
+				}
+				var got models.Cart
+				require.NoError(t, json.Unmarshal([]byte(data), &got))
+				return &got
+			case <-timeout:
+				require.FailNow(t, "no cart event")
+			}
+		}
+	}
+
+	t.Run("mutation should push the cart to a connected client", func(t *testing.T) {
+		resp, lines := connect(t, serve(0), cart.ID.String())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		assert.Empty(t, next(t, lines).LineItems, "current cart should be sent first")
+
+		require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, Quantity: 2}))
+		got := next(t, lines)
+		assert.Equal(t, cart.ID, got.ID)
+		require.Len(t, got.LineItems, 1)
+		assert.Equal(t, 2, got.LineItems[0].Quantity)
+	})
+
+	t.Run("idle stream should get heartbeats", func(t *testing.T) {
+		_, lines := connect(t, serve(10*time.Millisecond), cart.ID.String())
+		next(t, lines)
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case line := <-lines:
+				if line == ": heartbeat" {
+					return
+				}
+			case <-timeout:
+				require.FailNow(t, "no heartbeat")
+			}
+		}
+	})
+
+	t.Run("deleted cart should end the stream", func(t *testing.T) {
+		deleted := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, deleted))
+		_, lines := connect(t, serve(0), deleted.ID.String())
+		next(t, lines)
+
+		require.NoError(t, repo.Delete(ctx, deleted.ID.String()))
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case _, ok := <-lines:
+				if !ok {
+					return
+				}
+			case <-timeout:
+				require.FailNow(t, "stream should end")
+			}
+		}
+	})
+
+	t.Run("closed handler should end open streams", func(t *testing.T) {
+		h := NewEventsHandler(repo, repo, 0)
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /cart/{id}/events", ErrorHandler(h.Events))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		_, lines := connect(t, server, cart.ID.String())
+		next(t, lines)
+
+		h.Close()
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case _, ok := <-lines:
+				if !ok {
+					return
+				}
+			case <-timeout:
+				require.FailNow(t, "stream should end")
+			}
+		}
+	})
+
+	t.Run("missing cart should return 404", func(t *testing.T) {
+		resp, _ := connect(t, serve(0), uuid.NewString())
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("disconnected client should stop watching", func(t *testing.T) {
+		watched := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, watched))
+		server := serve(0)
+		_, lines := connect(t, server, watched.ID.String())
+		next(t, lines)
+		server.CloseClientConnections()
+
+		assert.Eventually(t, func() bool {
+			return repo.Watchers(watched.ID.String()) == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// csvHeader names columns of exported line items
+var csvHeader = []string{"product_id", "name", "quantity", "unit_price", "line_total"}
+
+// ExportHandler serves carts in formats for spreadsheets
+type ExportHandler struct {
+	getter CartGetter
+}
+
+// NewExportHandler creates new instance of ExportHandler
+func NewExportHandler(g CartGetter) *ExportHandler {
+	return &ExportHandler{getter: g}
+}
+
+// Export go doc
+//
+//	@Summary		Exports a Cart
+//	@Description	Returns the cart as JSON or its line items as CSV attachment with product id, name, quantity, unit price and line total
+//	@Tags			Cart
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			id		path		string	true	"Cart ID"
+//	@Param			format	query		string	false	"json (default) or csv"
+//	@Success		200		{object}	models.Cart
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		422		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/{id}/export	[get]
+func (h *ExportHandler) Export(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		return models.NewHTTPError(http.StatusBadRequest, errors.Errorf("unknown export format %q", format))
+	}
+
+	cart, err := h.getter.Get(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+cartID))
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	if format != "csv" {
+		return writeJSON(w, r, cart)
+	}
+
+	// line totals are computed first so overflows are answered before the body
+	lineTotals := make([]models.Money, len(cart.LineItems))
+	for i, item := range cart.LineItems {
+		if lineTotals[i], err = item.UnitPrice.Mul(item.Quantity); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="cart-`+cart.ID.String()+`.csv"`)
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for i, item := range cart.LineItems {
+		err := cw.Write([]string{
+			strconv.Itoa(item.Product()),
+			csvText(item.ProductName),
+			strconv.Itoa(item.Quantity),
+			item.UnitPrice.Decimal(),
+			lineTotals[i].Decimal(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText keeps spreadsheets from evaluating text starting like a formula
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportHandler(t *testing.T) {
+	cart := &models.Cart{ID: uuid.New(), Total: models.Money{Minor: 3250}, LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2, ProductName: "Plov, Tashkent style"},
+		{ItemID: 2, UnitPrice: models.Money{Minor: 1250}, Quantity: 1, ProductName: `Lagman "hand pulled"`},
+		{ItemID: 3, ProductID: 9, UnitPrice: models.Money{Minor: 0}, Quantity: 1, ProductName: "=HYPERLINK(\"x\")"},
+	}}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+	repo.On("Get", mock.Anything, "missing").Return((*models.Cart)(nil), repositories.ErrCartNotFound)
+	handler := NewExportHandler(repo)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cart/{id}/export", ErrorHandler(handler.Export))
+	export := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	t.Run("csv should list line items as attachment", func(t *testing.T) {
+		w := export("/cart/abcd/export?format=csv")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="cart-`+cart.ID.String()+`.csv"`, w.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"product_id", "name", "quantity", "unit_price", "line_total"},
+			{"1", "Plov, Tashkent style", "2", "10.00", "20.00"},
+			{"2", `Lagman "hand pulled"`, "1", "12.50", "12.50"},
+			{"9", `'=HYPERLINK("x")`, "1", "0.00", "0.00"},
+		}, records)
+	})
+
+	t.Run("fields with commas and quotes should be quoted", func(t *testing.T) {
+		body := export("/cart/abcd/export?format=csv").Body.String()
+		assert.Contains(t, body, `1,"Plov, Tashkent style",2,10.00,20.00`+"\n")
+		assert.Contains(t, body, `2,"Lagman ""hand pulled""",1,12.50,12.50`+"\n")
+	})
+
+	t.Run("json should be the default", func(t *testing.T) {
+		w := export("/cart/abcd/export")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var got models.Cart
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, cart, &got)
+	})
+
+	t.Run("unknown format should return 400", func(t *testing.T) {
+		w := export("/cart/abcd/export?format=xlsx")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "xlsx")
+	})
+
+	t.Run("missing cart should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, export("/cart/missing/export?format=csv").Code)
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// FeeCalculator itemizes fees charged on top of line items of the cart,
+// e.g. shipping, service fee and packaging
+type FeeCalculator interface {
+	Fees(ctx context.Context, cart *models.Cart) ([]models.Fee, error)
+}
+
+// FlatFees charges the same fees to every cart having items, zero fees are
+// left out. Fees without currency are taken in minor units of the currency
+// of the cart, e.g. 4.99 is 5 in JPY
+type FlatFees struct {
+	Shipping  models.Money
+	Service   models.Money
+	Packaging models.Money
+}
+
+// Fees implements FeeCalculator.
+func (f FlatFees) Fees(ctx context.Context, cart *models.Cart) ([]models.Fee, error) {
+	fees := []models.Fee{}
+	if len(cart.LineItems) == 0 {
+		return fees, nil
+	}
+	currency := cart.CurrencyOf()
+	for _, fee := range []models.Fee{
+		{Code: models.FeeShipping, Amount: f.Shipping.In(currency)},
+		{Code: models.FeeService, Amount: f.Service.In(currency)},
+		{Code: models.FeePackaging, Amount: f.Packaging.In(currency)},
+	} {
+		if !fee.Amount.IsZero() {
+			fees = append(fees, fee)
+		}
+	}
+	return fees, nil
+}
+
+// WithFeeCalculator adds breakdown of the total with fees of calculator to
+// returned carts
+func WithFeeCalculator(calculator FeeCalculator) Option {
+	return func(h *CartHandler) {
+		h.fees = calculator
+	}
+}
+
+// addBreakdown sets breakdown of the total when a fee calculator is set,
+// discounts of the cart are taken off the total and their running totals
+// include the fees, see addTotals
+func (h *CartHandler) addBreakdown(ctx context.Context, cart *models.Cart) error {
+	if h.fees == nil {
+		return nil
+	}
+	fees, err := h.fees.Fees(ctx, cart)
+	if err != nil {
+		return models.NewHTTPError(http.StatusBadGateway, fmt.Errorf("calculating fees of cart %s: %w", cart.ID, err))
+	}
+	amounts := make([]models.Money, len(fees))
+	for i, fee := range fees {
+		amounts[i] = fee.Amount
+	}
+	feeTotal, err := models.Sum(amounts...)
+	if err != nil {
+		return err
+	}
+	var discounts []models.Money
+	for i := range cart.Discounts {
+		discounts = append(discounts, cart.Discounts[i].Amount)
+		cart.Discounts[i].Total = cart.Discounts[i].Total.Add(feeTotal)
+	}
+	discount, err := models.Sum(discounts...)
+	if err != nil {
+		return err
+	}
+	total, err := models.Sum(cart.Total.Sub(discount), feeTotal)
+	if err != nil {
+		return err
+	}
+	cart.Breakdown = &models.TotalBreakdown{Subtotal: cart.Total, Discount: discount, Fees: fees, Total: total}
+	return nil
+}
+
+// addTotals sets discounts of coupons applied to the cart and breakdown of
+// its total, the breakdown and the last discount end at the same total. Tax
+// is left out while the tax flag is off
+func (h *CartHandler) addTotals(ctx context.Context, cart *models.Cart) error {
+	h.checkTax(ctx, cart)
+	if err := addDiscounts(ctx, h.coupons, cart, time.Now()); err != nil {
+		return err
+	}
+	return h.addBreakdown(ctx, cart)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/coupons"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type stubFees struct {
+	fees []models.Fee
+	err  error
+}
+
+func (s stubFees) Fees(ctx context.Context, cart *models.Cart) ([]models.Fee, error) {
+	return s.fees, s.err
+}
+
+var _ FeeCalculator = stubFees{}
+
+func TestCartHandlerFees(t *testing.T) {
+	newCart := func() *models.Cart {
+		return &models.Cart{ID: uuid.New(), Total: models.Money{Minor: 2000}, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}}}
+	}
+	get := func(t *testing.T, calculator FeeCalculator, opts ...Option) *httptest.ResponseRecorder {
+		repo := &CartRepositoryMock{}
+		cart := newCart()
+		cart.Coupons = []string{"FIVE"}
+		repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+		if calculator != nil {
+			opts = append(opts, WithFeeCalculator(calculator))
+		}
+		handler := NewCartHandler(repo, opts...)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /cart/{id}", ErrorHandler(handler.Get))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/abcd", nil))
+		return w
+	}
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) models.Cart {
+		require.Equal(t, http.StatusOK, w.Code)
+		var cart models.Cart
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&cart))
+		return cart
+	}
+
+	t.Run("fees of the provider should appear in the breakdown", func(t *testing.T) {
+		cart := decode(t, get(t, stubFees{fees: []models.Fee{
+			{Code: models.FeeShipping, Amount: models.Money{Minor: 450}},
+			{Code: models.FeeService, Amount: models.Money{Minor: 150}},
+		}}))
+
+		assert.Equal(t, models.Money{Minor: 2000}, cart.Total)
+		require.NotNil(t, cart.Breakdown)
+		assert.Equal(t, models.Money{Minor: 2000}, cart.Breakdown.Subtotal)
+		assert.Equal(t, []models.Fee{{Code: models.FeeShipping, Amount: models.Money{Minor: 450}}, {Code: models.FeeService, Amount: models.Money{Minor: 150}}}, cart.Breakdown.Fees)
+		assert.Equal(t, models.Money{Minor: 2600}, cart.Breakdown.Total)
+	})
+
+	t.Run("breakdown and discounts should end at the same total", func(t *testing.T) {
+		finder := coupons.NewStatic([]models.Coupon{{Code: "FIVE", AmountOff: models.Money{Minor: 500}}})
+		cart := decode(t, get(t, stubFees{fees: []models.Fee{{Code: models.FeeShipping, Amount: models.Money{Minor: 450}}}}, WithCoupons(finder)))
+
+		require.NotNil(t, cart.Breakdown)
+		assert.Equal(t, models.Money{Minor: 500}, cart.Breakdown.Discount)
+		assert.Equal(t, models.Money{Minor: 1950}, cart.Breakdown.Total)
+		require.Len(t, cart.Discounts, 1)
+		assert.Equal(t, cart.Breakdown.Total, cart.Discounts[0].Total)
+	})
+
+	t.Run("without calculator breakdown should be left out", func(t *testing.T) {
+		assert.Nil(t, decode(t, get(t, nil)).Breakdown)
+	})
+
+	t.Run("failing provider should return 502", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadGateway, get(t, stubFees{err: errors.New("fees service down")}).Code)
+	})
+}
+
+func TestFlatFees(t *testing.T) {
+	ctx := context.Background()
+	fees := FlatFees{Shipping: models.Money{Minor: 499}, Packaging: models.Money{Minor: 50}}
+
+	t.Run("configured fees should be charged", func(t *testing.T) {
+		got, err := fees.Fees(ctx, &models.Cart{LineItems: []models.LineItem{{ItemID: 1, Quantity: 1}}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Fee{{Code: models.FeeShipping, Amount: models.Money{Minor: 499}}, {Code: models.FeePackaging, Amount: models.Money{Minor: 50}}}, got)
+	})
+
+	t.Run("empty cart should have no fees", func(t *testing.T) {
+		got, err := fees.Fees(ctx, &models.Cart{})
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("fees should be scaled to the currency of the cart", func(t *testing.T) {
+		jpy := "JPY"
+		got, err := fees.Fees(ctx, &models.Cart{Currency: &jpy, LineItems: []models.LineItem{{ItemID: 1, Quantity: 1}}})
+		require.NoError(t, err)
+		assert.Equal(t, []models.Fee{{Code: models.FeeShipping, Amount: models.Money{Minor: 5, Currency: "JPY"}}, {Code: models.FeePackaging, Amount: models.Money{Minor: 1, Currency: "JPY"}}}, got)
+	})
+}
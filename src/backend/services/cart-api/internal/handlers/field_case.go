@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Casings of field names in response bodies, empty keeps the json tags of
+// the models which are snake_case
+const (
+	FieldCaseSnake = "snake"
+	FieldCaseCamel = "camel"
+)
+
+// verbatimFields hold client data, e.g. item attributes, their keys are
+// returned the way clients sent them
+var verbatimFields = map[string]bool{"attributes": true}
+
+// ParseFieldCase validates field casing by name
+func ParseFieldCase(name string) (string, error) {
+	switch name {
+	case "", FieldCaseSnake, FieldCaseCamel:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown field case %q", name)
+}
+
+// responseFieldCase is the casing of responses not asking for one
+var responseFieldCase string
+
+// UseFieldCase sets casing of response bodies, it must be called before
+// serving requests
+func UseFieldCase(fieldCase string) {
+	responseFieldCase = fieldCase
+}
+
+// caseMarshaler renames object keys encoded by Marshaler
+type caseMarshaler struct {
+	Marshaler
+	fieldCase string
+}
+
+// Marshal implements Marshaler.
+func (m caseMarshaler) Marshal(v interface{}) ([]byte, error) {
+	body, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return renameKeys(body, m.fieldCase)
+}
+
+// marshalerFor returns marshaler of the response of r, the case parameter of
+// Accept overrides the configured casing, e.g.
+// Accept: application/json; case=camel. Unknown casings are ignored
+func marshalerFor(r *http.Request) Marshaler {
+	fieldCase := responseFieldCase
+	if requested := acceptedFieldCase(r); requested != "" {
+		fieldCase = requested
+	}
+	if fieldCase == "" {
+		return responseMarshaler
+	}
+	return caseMarshaler{Marshaler: responseMarshaler, fieldCase: fieldCase}
+}
+
+func acceptedFieldCase(r *http.Request) string {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil || mediaType != "application/json" {
+				continue
+			}
+			if fieldCase, err := ParseFieldCase(params["case"]); err == nil && fieldCase != "" {
+				return fieldCase
+			}
+		}
+	}
+	return ""
+}
+
+// jsonFrame is an object or array being renamed
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	verbatim  bool
+	count     int
+	key       string
+}
+
+// renameKeys rewrites keys of every object in data to fieldCase keeping the
+// order of fields and values as they are
+func renameKeys(data []byte, fieldCase string) ([]byte, error) {
+	rename := toCamel
+	if fieldCase == FieldCaseSnake {
+		rename = toSnake
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	var stack []*jsonFrame
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			out.WriteRune(rune(delim))
+			continue
+		}
+		if top != nil && top.object && top.expectKey {
+			key := token.(string)
+			if top.count > 0 {
+				out.WriteByte(',')
+			}
+			top.count++
+			top.expectKey = false
+			top.key = key
+			if !top.verbatim {
+				key = rename(key)
+			}
+			if err := writeJSONValue(&out, key); err != nil {
+				return nil, err
+			}
+			out.WriteByte(':')
+			continue
+		}
+
+		verbatim := false
+		if top != nil {
+			if top.object {
+				top.expectKey = true
+				verbatim = top.verbatim || verbatimFields[top.key]
+			} else {
+				if top.count > 0 {
+					out.WriteByte(',')
+				}
+				top.count++
+				verbatim = top.verbatim
+			}
+		}
+		switch value := token.(type) {
+		case json.Delim:
+			out.WriteRune(rune(value))
+			stack = append(stack, &jsonFrame{object: value == '{', expectKey: value == '{', verbatim: verbatim})
+		case json.Number:
+			out.WriteString(value.String())
+		default:
+			if err := writeJSONValue(&out, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// writeJSONValue writes scalar v escaped the same way as encoding/json
+func writeJSONValue(out *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	out.Write(data)
+	return nil
+}
+
+// toCamel converts snake_case to camelCase, e.g. image_url to imageUrl
+func toCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+	parts := strings.Split(s, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// toSnake converts camelCase to snake_case, runs of capitals are one word,
+// e.g. cartID to cart_id
+func toSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, c := range runes {
+		if unicode.IsUpper(c) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
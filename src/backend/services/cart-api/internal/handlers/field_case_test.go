@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldCase(t *testing.T) {
+	userID := "user-1"
+	cart := &models.Cart{
+		ID:     uuid.New(),
+		UserID: &userID,
+		LineItems: []models.LineItem{{
+			ItemID:      7,
+			ProductName: "Margherita <large>",
+			UnitPrice:   models.Money{Minor: 1250},
+			Quantity:    2,
+			ImageURL:    "https://cdn.example.com/7.png",
+			Attributes:  map[string]interface{}{"gluten_free": true, "extra_toppings": []interface{}{"olive_oil"}},
+		}},
+		Total: models.Money{Minor: 2500},
+	}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+	handler := NewCartHandler(repo)
+
+	serve := func(accept string) string {
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+		r.SetPathValue("id", "abcd")
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.Get)(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	item := func(body string) map[string]interface{} {
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &decoded))
+		items := decoded["items"].([]interface{})
+		require.Len(t, items, 1)
+		return items[0].(map[string]interface{})
+	}
+
+	snake := serve("")
+	t.Run("snake_case by default", func(t *testing.T) {
+		got := item(snake)
+		assert.Equal(t, float64(7), got["item_id"])
+		assert.Equal(t, 12.5, got["unit_price"])
+		assert.Equal(t, "https://cdn.example.com/7.png", got["image_url"])
+		assert.Contains(t, snake, `"user_id":"user-1"`)
+	})
+
+	t.Run("camelCase by accept param", func(t *testing.T) {
+		body := serve("application/json; case=camel")
+		got := item(body)
+		assert.Equal(t, float64(7), got["itemId"])
+		assert.Equal(t, 12.5, got["unitPrice"])
+		assert.Equal(t, "https://cdn.example.com/7.png", got["imageUrl"])
+		assert.NotContains(t, got, "item_id")
+		assert.Contains(t, body, `"userId":"user-1"`)
+
+		assert.Equal(t, map[string]interface{}{"gluten_free": true, "extra_toppings": []interface{}{"olive_oil"}}, got["attributes"],
+			"attributes are client data and must keep their keys")
+		assert.Contains(t, body, `Margherita \u003clarge\u003e`, "strings should be escaped like encoding/json")
+	})
+
+	t.Run("camelCase by config", func(t *testing.T) {
+		UseFieldCase(FieldCaseCamel)
+		t.Cleanup(func() { UseFieldCase("") })
+
+		body := serve("")
+		assert.Contains(t, item(body), "itemId")
+		assert.Equal(t, snake, serve("application/json; case=snake"), "accept param should override config")
+		assert.Equal(t, body, serve("application/json; case=kebab"), "unknown casing should be ignored")
+	})
+
+	t.Run("field order is kept", func(t *testing.T) {
+		camel := serve("application/json; case=camel")
+		renamed, err := renameKeys([]byte(camel), FieldCaseSnake)
+		require.NoError(t, err)
+		assert.Equal(t, snake, string(renamed)+"\n")
+	})
+}
+
+func TestFieldCaseConversion(t *testing.T) {
+	for snake, camel := range map[string]string{
+		"id": "id", "item_id": "itemId", "image_url": "imageUrl", "server_time": "serverTime",
+	} {
+		assert.Equal(t, camel, toCamel(snake))
+		assert.Equal(t, snake, toSnake(camel))
+	}
+	assert.Equal(t, "cart_id", toSnake("cartID"))
+	assert.Equal(t, "http_status", toSnake("HTTPStatus"))
+}
+
+func TestParseFieldCase(t *testing.T) {
+	for _, name := range []string{"", FieldCaseSnake, FieldCaseCamel} {
+		got, err := ParseFieldCase(name)
+		assert.NoError(t, err)
+		assert.Equal(t, name, got)
+	}
+	_, err := ParseFieldCase("kebab")
+	assert.Error(t, err)
+}
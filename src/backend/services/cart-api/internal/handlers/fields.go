@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// fieldTree is a projection of json fields, a nil subtree keeps the whole
+// value of the field
+type fieldTree map[string]fieldTree
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// requestedFields parses ?fields=id,items.quantity into a projection of
+// responses of type schema. Nil is returned when the request has no fields,
+// unknown fields are rejected with 400
+func requestedFields(r *http.Request, schema interface{}) (fieldTree, error) {
+	value := r.URL.Query().Get("fields")
+	if value == "" {
+		return nil, nil
+	}
+	tree := fieldTree{}
+	for _, path := range strings.Split(value, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if err := checkField(reflect.TypeOf(schema), path); err != nil {
+			return nil, models.NewHTTPError(http.StatusBadRequest, err)
+		}
+		tree.add(strings.Split(path, "."))
+	}
+	if len(tree) == 0 {
+		return nil, nil
+	}
+	return tree, nil
+}
+
+func (t fieldTree) add(path []string) {
+	sub, ok := t[path[0]]
+	if ok && sub == nil {
+		// the whole field is kept already
+		return
+	}
+	if len(path) == 1 {
+		t[path[0]] = nil
+		return
+	}
+	if sub == nil {
+		sub = fieldTree{}
+		t[path[0]] = sub
+	}
+	sub.add(path[1:])
+}
+
+// checkField validates dotted path against json fields of t, fields of
+// lists are addressed through the list, e.g. items.quantity. Values encoded
+// by their own marshalers, e.g. amounts, and maps have no addressable fields
+func checkField(t reflect.Type, path string) error {
+	for _, name := range strings.Split(path, ".") {
+		t = elemType(t)
+		if t.Kind() != reflect.Struct || t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		field, ok := jsonField(t, name)
+		if !ok {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		t = field.Type
+	}
+	return nil
+}
+
+// elemType strips pointers and lists from t
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+			return t
+		}
+		t = t.Elem()
+	}
+	return t
+}
+
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		if tag == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// projection marshals only the fields of value in the tree, the order of
+// fields is kept
+type projection struct {
+	value  interface{}
+	fields fieldTree
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p projection) MarshalJSON() ([]byte, error) {
+	data, err := responseMarshaler.Marshal(p.value)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := project(&out, data, p.fields); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// project writes data keeping fields of objects in the tree, fields of
+// objects in lists are projected element by element
+func project(out *bytes.Buffer, data json.RawMessage, fields fieldTree) error {
+	data = bytes.TrimSpace(data)
+	if fields == nil || len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		out.Write(data)
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	comma := false
+	if data[0] == '[' {
+		out.WriteByte('[')
+		for dec.More() {
+			var element json.RawMessage
+			if err := dec.Decode(&element); err != nil {
+				return err
+			}
+			if comma {
+				out.WriteByte(',')
+			}
+			comma = true
+			if err := project(out, element, fields); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+		return nil
+	}
+	out.WriteByte('{')
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+		sub, ok := fields[key]
+		if !ok {
+			continue
+		}
+		if comma {
+			out.WriteByte(',')
+		}
+		comma = true
+		name, _ := json.Marshal(key)
+		out.Write(name)
+		out.WriteByte(':')
+		if err := project(out, value, sub); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
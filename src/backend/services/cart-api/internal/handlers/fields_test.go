@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSparseFields(t *testing.T) {
+	userID := "user-1"
+	id := uuid.MustParse("5f0d8f4e-3c1b-4d8e-9a6f-1b2c3d4e5f60")
+	cart := &models.Cart{
+		ID:     id,
+		UserID: &userID,
+		LineItems: []models.LineItem{
+			{ItemID: 1, ProductName: "Margherita", UnitPrice: models.Money{Minor: 1250}, Quantity: 2, Attributes: map[string]interface{}{"size": "large"}},
+			{ItemID: 2, ProductName: "Cola", UnitPrice: models.Money{Minor: 250}, Quantity: 1},
+		},
+		Total: models.Money{Minor: 2750},
+	}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+	handler := NewCartHandler(repo)
+
+	serve := func(fields, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd?fields="+url.QueryEscape(fields), nil)
+		r.SetPathValue("id", "abcd")
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.Get)(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{"top level fields", "id,total", `{"id":"` + id.String() + `","total":27.50}`},
+		{"nested fields of items", "items.item_id,items.quantity", `{"items":[{"item_id":1,"quantity":2},{"item_id":2,"quantity":1}]}`},
+		{"top level and nested fields", "id, items.quantity", `{"id":"` + id.String() + `","items":[{"quantity":2},{"quantity":1}]}`},
+		{"whole field wins over its subfields", "items.quantity,items", `{"items":[` +
+			`{"item_id":1,"unit_price":12.50,"quantity":2,"img":"","product_name":"Margherita","product_description":"","attributes":{"size":"large"}},` +
+			`{"item_id":2,"unit_price":2.50,"quantity":1,"img":"","product_name":"Cola","product_description":"","attributes":null}]}`},
+		{"maps are kept whole", "items.attributes", `{"items":[{"attributes":{"size":"large"}},{"attributes":null}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.fields, "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.want+"\n", w.Body.String())
+		})
+	}
+
+	t.Run("order of fields should follow the cart", func(t *testing.T) {
+		assert.Equal(t, `{"id":"`+id.String()+`","total":27.50}`+"\n", serve("total,id", "").Body.String())
+	})
+
+	t.Run("projection should be enveloped and cased", func(t *testing.T) {
+		w := serve("items.item_id", "application/json; envelope=true; case=camel")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"data":{"items":[{"itemId":1},{"itemId":2}]}`)
+	})
+
+	for _, fields := range []string{"unknown", "items.unknown", "total.minor", "id.version", "items.attributes.size", "items..quantity"} {
+		t.Run("unknown field "+fields+" should return 400", func(t *testing.T) {
+			repo.Calls = nil
+			w := serve(fields, "")
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "unknown field")
+			repo.AssertNotCalled(t, "Get", mock.Anything, "abcd")
+		})
+	}
+}
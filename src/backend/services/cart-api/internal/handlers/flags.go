@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/flags"
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// FlagProvider tells whether a feature flag is on, flags.Static reads them
+// from config and a remote provider can replace it
+type FlagProvider interface {
+	Enabled(ctx context.Context, name string) bool
+}
+
+type FlagLister interface {
+	Flags(ctx context.Context) map[string]bool
+}
+
+// WithFlags consults p for flagged behaviour of CartHandler, by default
+// flags have their default values
+func WithFlags(p FlagProvider) Option {
+	return func(h *CartHandler) {
+		h.flags = p
+	}
+}
+
+// checkTax leaves tax out of the cart while the tax flag is off, so tax is
+// neither stored nor returned
+func (h *CartHandler) checkTax(ctx context.Context, cart *models.Cart) {
+	if !h.flags.Enabled(ctx, flags.Tax) {
+		cart.Tax = nil
+	}
+}
+
+// RequireFlag serves f while the flag is on, otherwise the route answers 404
+// as if it didn't exist
+func RequireFlag(p FlagProvider, name string, f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if !p.Enabled(r.Context(), name) {
+			return models.NewHTTPError(http.StatusNotFound, fmt.Errorf("%s are disabled", name))
+		}
+		return f(w, r)
+	}
+}
+
+// FlagsHandler shows current values of feature flags
+type FlagsHandler struct {
+	lister FlagLister
+}
+
+// NewFlagsHandler creates new instance of FlagsHandler
+func NewFlagsHandler(l FlagLister) *FlagsHandler {
+	return &FlagsHandler{lister: l}
+}
+
+// Get go doc
+//
+//	@Summary		Feature flags
+//	@Description	Returns current values of feature flags
+//	@Tags			Admin
+//	@Produce		json
+//	@Success		200	{object}	map[string]bool
+//	@Failure		401	{object}	models.HTTPError
+//	@Failure		403	{object}	models.HTTPError
+//	@Router			/admin/flags	[get]
+func (h *FlagsHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	return writeJSON(w, r, h.lister.Flags(r.Context()))
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/flags"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestETagFlag(t *testing.T) {
+	cart := &models.Cart{ID: uuid.New(), LineItems: items}
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, "abcd").Return(cart, nil)
+
+	get := func(p FlagProvider) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo, WithFlags(p)).Get)(w, r)
+		return w
+	}
+
+	t.Run("flag on should set etag", func(t *testing.T) {
+		w := get(flags.Static{flags.ETags: true})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.ETag(cart), w.Header().Get("ETag"))
+	})
+
+	t.Run("flag off should omit etag", func(t *testing.T) {
+		w := get(flags.Static{flags.ETags: false})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("ETag"))
+	})
+}
+
+func TestTaxFlag(t *testing.T) {
+	tax := models.Money{Minor: 120}
+	get := func(p FlagProvider) *models.Cart {
+		repo := &CartRepositoryMock{}
+		repo.On("Get", mock.Anything, "abcd").Return(&models.Cart{ID: uuid.New(), LineItems: items, Tax: &tax}, nil)
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+		r.SetPathValue("id", "abcd")
+		w := httptest.NewRecorder()
+		ErrorHandler(NewCartHandler(repo, WithFlags(p)).Get)(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var cart models.Cart
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&cart))
+		return &cart
+	}
+
+	t.Run("flag on should return tax", func(t *testing.T) {
+		cart := get(flags.Static{flags.Tax: true})
+		if assert.NotNil(t, cart.Tax) {
+			assert.Equal(t, tax.Minor, cart.Tax.Minor)
+		}
+	})
+
+	t.Run("flag off should omit tax", func(t *testing.T) {
+		assert.Nil(t, get(flags.Static{flags.Tax: false}).Tax)
+	})
+}
+
+func TestRequireFlag(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) error {
+		return writeJSON(w, r, "ok")
+	}
+	serve := func(p FlagProvider) int {
+		w := httptest.NewRecorder()
+		ErrorHandler(RequireFlag(p, flags.Coupons, ok))(w, httptest.NewRequest(http.MethodGet, "/coupons/SAVE10/validate", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(flags.Static{flags.Coupons: true}))
+	assert.Equal(t, http.StatusNotFound, serve(flags.Static{flags.Coupons: false}))
+}
+
+func TestFlagsHandler(t *testing.T) {
+	handler := ErrorHandler(NewFlagsHandler(flags.Static{flags.Coupons: false}).Get)
+	w := httptest.NewRecorder()
+	handler(w, adminRequest(http.MethodGet, "/admin/flags"))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var values map[string]bool
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&values))
+	assert.Equal(t, map[string]bool{flags.Coupons: false, flags.ETags: true, flags.Tax: true}, values)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/admin/flags", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/flags", nil)
+	r.Header.Set(UserRoleHeader, "customer")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
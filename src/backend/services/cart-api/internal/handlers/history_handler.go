@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+type CartHistorian interface {
+	History(ctx context.Context, cartID string) ([]models.AuditEntry, error)
+}
+
+// HistoryHandler serves audit logs of carts for support investigations
+type HistoryHandler struct {
+	historian CartHistorian
+}
+
+// NewHistoryHandler creates new instance of HistoryHandler
+func NewHistoryHandler(historian CartHistorian) *HistoryHandler {
+	return &HistoryHandler{historian: historian}
+}
+
+// History go doc
+//
+//	@Summary		Audit log of a Cart
+//	@Description	Returns items added, updated and removed with time and actor, oldest first. The log is capped by CART_HISTORY and kept after the cart is deleted until it expires
+//	@Tags			Admin
+//	@Produce		json
+//	@Param			id	path		string	true	"Cart ID"
+//	@Success		200	{object}	models.CartHistory
+//	@Failure		400	{object}	models.HTTPError
+//	@Failure		401	{object}	models.HTTPError
+//	@Failure		403	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/cart/{id}/history	[get]
+func (h *HistoryHandler) History(w http.ResponseWriter, r *http.Request) error {
+	if err := requireAdmin(r); err != nil {
+		return err
+	}
+	entries, err := h.historian.History(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, models.CartHistory{Entries: entries})
+}
+
+// ActorMiddleware makes the X-User-ID header the actor of changes recorded
+// in audit logs
+func ActorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := r.Header.Get(UserIDHeader); actor != "" {
+			r = r.WithContext(models.ContextWithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubHistorian map[string][]models.AuditEntry
+
+func (s stubHistorian) History(ctx context.Context, cartID string) ([]models.AuditEntry, error) {
+	return s[cartID], nil
+}
+
+func TestHistoryHandler(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	historian := stubHistorian{"abcd": {
+		{At: at, Action: models.AuditItemAdded, Actor: "user-1", ItemID: 1, Quantity: 2},
+		{At: at.Add(time.Second), Action: models.AuditItemRemoved, Actor: "user-1", ItemID: 1},
+	}}
+	serve := func(role string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd/history", nil)
+		r.SetPathValue("id", "abcd")
+		if role != "" {
+			r.Header.Set(UserRoleHeader, role)
+		}
+		w := httptest.NewRecorder()
+		ErrorHandler(NewHistoryHandler(historian).History)(w, r)
+		return w
+	}
+
+	w := serve(adminRole)
+	require.Equal(t, http.StatusOK, w.Code)
+	var history models.CartHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Equal(t, historian["abcd"], history.Entries)
+
+	assert.Equal(t, http.StatusUnauthorized, serve("").Code)
+	assert.Equal(t, http.StatusForbidden, serve("customer").Code)
+}
+
+func TestActorMiddleware(t *testing.T) {
+	var actor string
+	handler := ActorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = models.ActorFromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/cart/abcd/item", nil)
+	r.Header.Set(UserIDHeader, "user-1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "user-1", actor)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/cart/abcd/item", nil))
+	assert.Empty(t, actor)
+}
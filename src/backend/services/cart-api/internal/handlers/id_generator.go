@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator generates ids of created carts
+type IDGenerator interface {
+	NewID() (uuid.UUID, error)
+}
+
+// UUIDv4 generates random ids, it is the default
+type UUIDv4 struct{}
+
+// NewID implements IDGenerator.
+func (UUIDv4) NewID() (uuid.UUID, error) {
+	return uuid.NewRandom()
+}
+
+// UUIDv7 generates ids prefixed with unix milliseconds as defined by RFC 9562,
+// they sort by creation time so redis scans return older carts first
+type UUIDv7 struct {
+	now func() time.Time
+}
+
+// NewID implements IDGenerator.
+func (g UUIDv7) NewID() (uuid.UUID, error) {
+	now := time.Now
+	if g.now != nil {
+		now = g.now
+	}
+	var id uuid.UUID
+	if _, err := rand.Read(id[6:]); err != nil {
+		return uuid.Nil, err
+	}
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(now().UnixMilli()))
+	copy(id[:6], ms[2:])
+	id[6] = id[6]&0x0f | 0x70 // version 7
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	return id, nil
+}
+
+// NewIDGenerator returns generator by name, either "uuidv4" or "uuidv7"
+func NewIDGenerator(name string) (IDGenerator, error) {
+	switch name {
+	case "", "uuidv4":
+		return UUIDv4{}, nil
+	case "uuidv7":
+		return UUIDv7{}, nil
+	}
+	return nil, fmt.Errorf("unknown cart id generator %q", name)
+}
+
+// WithIDGenerator sets generator of created cart ids
+func WithIDGenerator(g IDGenerator) Option {
+	return func(h *CartHandler) {
+		h.ids = g
+	}
+}
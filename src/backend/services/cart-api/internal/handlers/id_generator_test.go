@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedIDGenerator uuid.UUID
+
+func (g fixedIDGenerator) NewID() (uuid.UUID, error) {
+	return uuid.UUID(g), nil
+}
+
+func TestUUIDv7(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	g := UUIDv7{now: func() time.Time { return now }}
+
+	first, err := g.NewID()
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), first.Version())
+	assert.Equal(t, uuid.RFC4122, first.Variant())
+
+	now = now.Add(time.Millisecond)
+	second, err := g.NewID()
+	require.NoError(t, err)
+	assert.Less(t, first.String(), second.String(), "ids should sort by creation time")
+}
+
+func TestNewIDGenerator(t *testing.T) {
+	g, err := NewIDGenerator("uuidv7")
+	assert.NoError(t, err)
+	assert.IsType(t, UUIDv7{}, g)
+
+	g, err = NewIDGenerator("")
+	assert.NoError(t, err)
+	assert.IsType(t, UUIDv4{}, g)
+
+	_, err = NewIDGenerator("snowflake")
+	assert.Error(t, err)
+}
+
+func TestCreateUsesIDGenerator(t *testing.T) {
+	id := uuid.MustParse("018df5a2-8c00-7000-8000-000000000001")
+	repo := &CartRepositoryMock{}
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(cart *models.Cart) bool {
+		return cart.ID == id
+	})).Return(nil).Once()
+	repo.On("Get", mock.Anything, id.String()).Return(&models.Cart{ID: id}, nil).Once()
+	handler := NewCartHandler(repo, WithIDGenerator(fixedIDGenerator(id)))
+
+	r := httptest.NewRequest(http.MethodPost, "/cart", strings.NewReader(`{"user_id": "u-1"}`))
+	w := httptest.NewRecorder()
+	ErrorHandler(handler.Create)(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), id.String())
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// maxCartNameLength bounds names of named carts in characters
+const maxCartNameLength = 100
+
+type NamedCartStore interface {
+	CreateNamed(ctx context.Context, cart *models.Cart) error
+	NamedCarts(ctx context.Context, userID string) ([]*models.Cart, error)
+}
+
+// ListsHandler serves named carts users keep besides the active cart
+type ListsHandler struct {
+	store NamedCartStore
+	ids   IDGenerator
+}
+
+// NewListsHandler creates new instance of ListsHandler
+func NewListsHandler(s NamedCartStore, ids IDGenerator) *ListsHandler {
+	return &ListsHandler{store: s, ids: ids}
+}
+
+// Create go doc
+//
+//	@Summary		Creates a named Cart
+//	@Description	Creates empty cart of the user under a name unique among carts of the user, caller must be the user or an admin
+//	@Tags			Cart
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		string						true	"User ID"
+//	@Param			list	body		models.CreateNamedCartReq	true	"Name of the cart"
+//	@Success		201		{object}	models.Cart
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		401		{object}	models.HTTPError
+//	@Failure		403		{object}	models.HTTPError
+//	@Failure		409		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/user/{userID}/lists	[post]
+func (h *ListsHandler) Create(w http.ResponseWriter, r *http.Request) error {
+	userID := r.PathValue("userID")
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	var req models.CreateNamedCartReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxCartNameLength {
+		return models.NewHTTPError(http.StatusBadRequest, errors.Errorf("name must have 1 to %d characters", maxCartNameLength))
+	}
+
+	id, err := h.ids.NewID()
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	cart := &models.Cart{ID: id, LineItems: []models.LineItem{}, UserID: &userID, Name: name}
+	if err := h.store.CreateNamed(r.Context(), cart); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateName) {
+			return models.NewHTTPError(http.StatusConflict, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSONStatus(w, r, http.StatusCreated, cart)
+}
+
+// List go doc
+//
+//	@Summary		Lists named Carts
+//	@Description	Returns named carts of the user ordered by name, caller must be the user or an admin
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			userID	path		string	true	"User ID"
+//	@Success		200		{object}	models.UserCartsResp
+//	@Failure		401		{object}	models.HTTPError
+//	@Failure		403		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/user/{userID}/lists	[get]
+func (h *ListsHandler) List(w http.ResponseWriter, r *http.Request) error {
+	userID := r.PathValue("userID")
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	carts, err := h.store.NamedCarts(r.Context(), userID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, models.UserCartsResp{Carts: carts})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryNamedCarts keeps named carts of users in memory
+type memoryNamedCarts struct {
+	carts map[string][]*models.Cart
+}
+
+func (m *memoryNamedCarts) CreateNamed(ctx context.Context, cart *models.Cart) error {
+	for _, existing := range m.carts[*cart.UserID] {
+		if existing.Name == cart.Name {
+			return repositories.ErrDuplicateName
+		}
+	}
+	m.carts[*cart.UserID] = append(m.carts[*cart.UserID], cart)
+	return nil
+}
+
+func (m *memoryNamedCarts) NamedCarts(ctx context.Context, userID string) ([]*models.Cart, error) {
+	return m.carts[userID], nil
+}
+
+var _ NamedCartStore = (*memoryNamedCarts)(nil)
+
+func TestListsHandler(t *testing.T) {
+	handler := NewListsHandler(&memoryNamedCarts{carts: map[string][]*models.Cart{}}, UUIDv4{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cart/user/{userID}/lists", ErrorHandler(handler.Create))
+	mux.HandleFunc("GET /cart/user/{userID}/lists", ErrorHandler(handler.List))
+
+	serve := func(method, userID, body, caller string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/cart/user/"+userID+"/lists", strings.NewReader(body))
+		if caller != "" {
+			req.Header.Set(UserIDHeader, caller)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("user should create two named carts and list them", func(t *testing.T) {
+		for _, name := range []string{"Weekly groceries", " Party "} {
+			w := serve(http.MethodPost, "alice", `{"name":"`+name+`"}`, "alice")
+			require.Equal(t, http.StatusCreated, w.Code)
+			var cart models.Cart
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&cart))
+			assert.Equal(t, strings.TrimSpace(name), cart.Name)
+			assert.Equal(t, "alice", *cart.UserID)
+			assert.NotEqual(t, uuid.Nil, cart.ID)
+		}
+
+		w := serve(http.MethodGet, "alice", "", "alice")
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp models.UserCartsResp
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		require.Len(t, resp.Carts, 2)
+		assert.Equal(t, "Weekly groceries", resp.Carts[0].Name)
+		assert.Equal(t, "Party", resp.Carts[1].Name)
+	})
+
+	t.Run("duplicate name should return 409", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "alice", `{"name":"Party"}`, "alice").Code)
+	})
+
+	t.Run("invalid name should return 400", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "alice", `{"name":"  "}`, "alice").Code)
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "alice", `{"name":"`+strings.Repeat("a", maxCartNameLength+1)+`"}`, "alice").Code)
+	})
+
+	t.Run("another user should be forbidden", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "alice", `{"name":"Mine"}`, "mallory").Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "alice", "", "mallory").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "alice", "", "").Code)
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Marshaler encodes response bodies written by handlers
+type Marshaler interface {
+	Marshal(v interface{}) ([]byte, error)
+}
+
+// StdMarshaler uses encoding/json, it is the default
+type StdMarshaler struct{}
+
+// Marshal implements Marshaler.
+func (StdMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// JSONIterMarshaler uses jsoniter configured to produce the same output as
+// encoding/json, it encodes large carts considerably faster
+type JSONIterMarshaler struct{}
+
+var jsoniterStd = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// Marshal implements Marshaler.
+func (JSONIterMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return jsoniterStd.Marshal(v)
+}
+
+// NewMarshaler returns marshaler by name, either "std" or "jsoniter"
+func NewMarshaler(name string) (Marshaler, error) {
+	switch name {
+	case "", "std":
+		return StdMarshaler{}, nil
+	case "jsoniter":
+		return JSONIterMarshaler{}, nil
+	}
+	return nil, fmt.Errorf("unknown json encoder %q", name)
+}
+
+// responseMarshaler encodes bodies of writeJSON
+var responseMarshaler Marshaler = StdMarshaler{}
+
+// UseMarshaler sets marshaler of response bodies, it must be called before
+// serving requests
+func UseMarshaler(m Marshaler) {
+	responseMarshaler = m
+}
+
+// jsonIndent indents pretty response bodies
+const jsonIndent = "  "
+
+// prettyJSON indents response bodies, it is meant for local debugging
+var prettyJSON bool
+
+// UsePrettyJSON turns indenting of response bodies on, it must be called
+// before serving requests
+func UsePrettyJSON(enabled bool) {
+	prettyJSON = enabled
+}
+
+// indentJSON indents marshaled body when pretty output is on, the output is
+// the same as of json.Encoder with SetIndent
+func indentJSON(body []byte) ([]byte, error) {
+	if !prettyJSON {
+		return body, nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", jsonIndent); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// newResponseEncoder returns encoder of bodies not written by writeJSON,
+// indenting them when pretty output is on
+func newResponseEncoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	if prettyJSON {
+		enc.SetIndent("", jsonIndent)
+	}
+	return enc
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func largeCart(n int) *models.Cart {
+	userID := "user-1"
+	discount := models.Money{Minor: 250}
+	cart := &models.Cart{ID: uuid.New(), UserID: &userID, Discount: &discount, Status: models.CartStatusNew}
+	for i := 0; i < n; i++ {
+		cart.LineItems = append(cart.LineItems, models.LineItem{
+			ItemID:             i,
+			UnitPrice:          models.Money{Minor: int64(i*100 + 99)},
+			Quantity:           i%5 + 1,
+			ProductName:        fmt.Sprintf("item <%d> & co", i),
+			ProductDescription: "a rather long description of the item which is rendered in the cart",
+			ImageURL:           fmt.Sprintf("https://cdn.example.com/items/%d.png", i),
+			Attributes:         map[string]interface{}{"spicy": i%2 == 0, "size": "large", "grams": i * 10},
+		})
+	}
+	cart.Total = models.Money{Minor: 123456}
+	return cart
+}
+
+func TestMarshalersProduceIdenticalOutput(t *testing.T) {
+	values := map[string]interface{}{
+		"large cart": largeCart(200),
+		"empty cart": &models.Cart{ID: uuid.New()},
+		"envelope": models.Envelope{
+			Data: largeCart(3),
+			Meta: models.EnvelopeMeta{RequestID: "req-1", ServerTime: time.Date(2024, 3, 1, 12, 0, 0, 123, time.UTC)},
+		},
+		"diff":  models.DiffCarts(largeCart(3), largeCart(5)),
+		"error": models.NewHTTPError(400, fmt.Errorf("bad <input>")),
+	}
+	for name, v := range values {
+		t.Run(name, func(t *testing.T) {
+			want, err := json.Marshal(v)
+			require.NoError(t, err)
+			got, err := JSONIterMarshaler{}.Marshal(v)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+func TestNewMarshaler(t *testing.T) {
+	m, err := NewMarshaler("jsoniter")
+	assert.NoError(t, err)
+	assert.IsType(t, JSONIterMarshaler{}, m)
+
+	m, err = NewMarshaler("")
+	assert.NoError(t, err)
+	assert.IsType(t, StdMarshaler{}, m)
+
+	_, err = NewMarshaler("gob")
+	assert.Error(t, err)
+}
+
+func BenchmarkMarshalers(b *testing.B) {
+	cart := largeCart(500)
+	for name, m := range map[string]Marshaler{"std": StdMarshaler{}, "jsoniter": JSONIterMarshaler{}} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := m.Marshal(cart)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(len(data)))
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// allowProbeMethods are checked against the router when answering OPTIONS,
+// in the order they are listed in the Allow header
+var allowProbeMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// OptionsMiddleware answers OPTIONS requests with 204 and an Allow header of
+// methods mux serves for the path. Other requests, paths mux doesn't serve
+// and routes which register OPTIONS themselves are served by mux
+func OptionsMiddleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		allow := allowedMethods(mux, r)
+		if len(allow) == 0 {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allow []string
+	probe := r.Clone(r.Context())
+	for _, method := range allowProbeMethods {
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allow = append(allow, method)
+		}
+	}
+	return allow
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsMiddleware(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cart", ok)
+	mux.HandleFunc("GET /cart/{id}", ok)
+	mux.HandleFunc("DELETE /cart/{id}", ok)
+	mux.HandleFunc("PUT /cart/{id}", ok)
+	mux.HandleFunc("PUT /cart/{id}/item/{itemID}", ok)
+	mux.HandleFunc("DELETE /cart/{id}/item/{itemID}", ok)
+	mux.HandleFunc("OPTIONS /custom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	mux.HandleFunc("GET /custom", ok)
+	handler := OptionsMiddleware(mux)
+
+	tests := []struct {
+		name  string
+		path  string
+		code  int
+		allow string
+	}{
+		{"collection route", "/cart", http.StatusNoContent, "POST"},
+		{"item route", "/cart/abcd", http.StatusNoContent, "GET, PUT, DELETE"},
+		{"nested route", "/cart/abcd/item/1", http.StatusNoContent, "PUT, DELETE"},
+		{"unknown route", "/unknown", http.StatusNotFound, ""},
+		{"route serving options", "/custom", http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, tt.path, nil))
+
+			assert.Equal(t, tt.code, w.Code)
+			assert.Equal(t, tt.allow, w.Header().Get("Allow"))
+		})
+	}
+
+	t.Run("other methods should pass through", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/abcd", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Allow"))
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// PathLengthMiddleware rejects requests with 400 when a segment of the path,
+// i.e. any path parameter such as a cart or user id, is longer than max so
+// oversized values never reach redis keys
+func PathLengthMiddleware(max int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, segment := range strings.Split(r.URL.Path, "/") {
+			if len(segment) > max {
+				writeError(w, r, models.NewHTTPError(http.StatusBadRequest,
+					fmt.Errorf("path segment of %d bytes exceeds limit of %d", len(segment), max)))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AliasResolver returns the current id of a historical cart id
+type AliasResolver interface {
+	ResolveAlias(ctx context.Context, cartID string) (string, error)
+}
+
+// CartIDs checks {id} of cart routes, see Require
+type CartIDs struct {
+	aliases AliasResolver
+}
+
+// NewCartIDs creates CartIDs which resolve ids that aren't UUIDs by aliases,
+// e.g. ids of carts migrated from an older id scheme
+func NewCartIDs(aliases AliasResolver) CartIDs {
+	return CartIDs{aliases: aliases}
+}
+
+// Require rejects requests whose {id} is not a cart id with 400 before f
+// touches redis. Ids are generated as UUIDs by every IDGenerator, they are
+// normalized to lower case which is how carts are stored. Other ids having
+// an alias are replaced by the current id of the cart
+func (c CartIDs) Require(f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		raw := r.PathValue("id")
+		id, ok := normalizeCartID(raw)
+		if !ok && c.aliases != nil {
+			current, err := c.aliases.ResolveAlias(r.Context(), raw)
+			if err != nil && !errors.Is(err, repositories.ErrCartNotFound) {
+				return models.NewHTTPError(http.StatusInternalServerError, err)
+			}
+			if err == nil {
+				id, ok = normalizeCartID(current)
+			}
+		}
+		if !ok {
+			return models.NewHTTPError(http.StatusBadRequest, errors.New("invalid cart id, expected UUID"))
+		}
+		r.SetPathValue("id", id)
+		return f(w, r)
+	}
+}
+
+// RequireCartID is Require of CartIDs without aliases
+func RequireCartID(f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return CartIDs{}.Require(f)
+}
+
+// normalizeCartID returns canonical form of id. Only the 36 character form
+// is accepted, uuid.Parse also accepts braced and urn forms which must not
+// be mistaken for the same cart
+func normalizeCartID(id string) (string, bool) {
+	if len(id) != 36 {
+		return "", false
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return "", false
+	}
+	return parsed.String(), true
+}
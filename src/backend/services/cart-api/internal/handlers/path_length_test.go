@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPathLimits(t *testing.T) {
+	called := 0
+	ok := func(w http.ResponseWriter, r *http.Request) error {
+		called++
+		return nil
+	}
+	router := http.NewServeMux()
+	router.HandleFunc("GET /cart/{id}", ErrorHandler(RequireCartID(ok)))
+	router.HandleFunc("GET /cart/user/{userID}/recent", ErrorHandler(ok))
+	h := PathLengthMiddleware(128, router)
+
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	id := uuid.New()
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{"cart id", "/cart/" + id.String(), http.StatusOK},
+		{"user id", "/cart/user/user-1/recent", http.StatusOK},
+		{"overlong cart id", "/cart/" + strings.Repeat("a", 129), http.StatusBadRequest},
+		{"overlong user id", "/cart/user/" + strings.Repeat("u", 129) + "/recent", http.StatusBadRequest},
+		{"malformed cart id", "/cart/abcd", http.StatusBadRequest},
+		{"cart id without dashes", "/cart/" + strings.ReplaceAll(id.String(), "-", ""), http.StatusBadRequest},
+		{"urn cart id", "/cart/urn:uuid:" + id.String(), http.StatusBadRequest},
+		{"cart id with invalid characters", "/cart/" + strings.Replace(id.String(), id.String()[:1], "z", 1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = 0
+			assert.Equal(t, tt.code, serve(tt.path))
+			assert.Equal(t, tt.code == http.StatusOK, called == 1, "handler should only run for valid paths")
+		})
+	}
+}
+
+func TestRequireCartIDNormalizes(t *testing.T) {
+	id := uuid.New().String()
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, id).Return(&models.Cart{}, nil)
+	repo.On("Delete", mock.Anything, id).Return(nil)
+	handler := NewCartHandler(repo)
+
+	serve := func(f HandlerFunc, method, cartID string) int {
+		r := httptest.NewRequest(method, "/cart/"+cartID, nil)
+		r.SetPathValue("id", cartID)
+		w := httptest.NewRecorder()
+		f(w, r)
+		return w.Code
+	}
+	get := ErrorHandler(RequireCartID(handler.Get))
+	del := ErrorHandler(RequireCartID(handler.Delete))
+
+	t.Run("valid id", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(get, http.MethodGet, id))
+		assert.Equal(t, http.StatusOK, serve(del, http.MethodDelete, id))
+	})
+
+	t.Run("upper case id should be the same cart", func(t *testing.T) {
+		repo.Calls = nil
+		assert.Equal(t, http.StatusOK, serve(get, http.MethodGet, strings.ToUpper(id)))
+		assert.Equal(t, http.StatusOK, serve(del, http.MethodDelete, strings.ToUpper(id)))
+		repo.AssertCalled(t, "Get", mock.Anything, id)
+		repo.AssertCalled(t, "Delete", mock.Anything, id)
+	})
+
+	t.Run("malformed id should not reach redis", func(t *testing.T) {
+		repo.Calls = nil
+		for _, cartID := range []string{"abcd", "carts:*", "{" + id[:34] + "}", id[:35] + "g"} {
+			assert.Equal(t, http.StatusBadRequest, serve(get, http.MethodGet, cartID), cartID)
+			assert.Equal(t, http.StatusBadRequest, serve(del, http.MethodDelete, cartID), cartID)
+		}
+		assert.Empty(t, repo.Calls)
+	})
+}
+
+type stubAliases map[string]string
+
+func (s stubAliases) ResolveAlias(ctx context.Context, cartID string) (string, error) {
+	id, ok := s[cartID]
+	if !ok {
+		return "", repositories.ErrCartNotFound
+	}
+	return id, nil
+}
+
+func TestCartIDsAliases(t *testing.T) {
+	id := uuid.New().String()
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, id).Return(&models.Cart{}, nil)
+	get := ErrorHandler(NewCartIDs(stubAliases{"legacy-1": id}).Require(NewCartHandler(repo).Get))
+
+	serve := func(cartID string) int {
+		r := httptest.NewRequest(http.MethodGet, "/cart/"+cartID, nil)
+		r.SetPathValue("id", cartID)
+		w := httptest.NewRecorder()
+		get(w, r)
+		return w.Code
+	}
+
+	t.Run("legacy id should reach the current cart", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("legacy-1"))
+		repo.AssertCalled(t, "Get", mock.Anything, id)
+	})
+
+	t.Run("id without alias should be rejected", func(t *testing.T) {
+		repo.Calls = nil
+		assert.Equal(t, http.StatusBadRequest, serve("legacy-2"))
+		assert.Empty(t, repo.Calls)
+	})
+}
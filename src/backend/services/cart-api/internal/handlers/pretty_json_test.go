@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	serve := func(handler func(w http.ResponseWriter, r *http.Request) error) string {
+		w := httptest.NewRecorder()
+		ErrorHandler(handler)(w, httptest.NewRequest(http.MethodGet, "/admin/carts/count", nil))
+		return w.Body.String()
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) error {
+		return writeJSON(w, r, models.CartSummary{Total: models.Money{Minor: 250}, ItemCount: 2})
+	}
+	failed := func(w http.ResponseWriter, r *http.Request) error {
+		return models.NewHTTPError(http.StatusNotFound, errors.New("cart not found"))
+	}
+
+	t.Run("compact by default", func(t *testing.T) {
+		assert.Equal(t, "{\"total\":2.50,\"item_count\":2}\n", serve(ok))
+		assert.Equal(t, "{\"code\":404,\"message\":\"cart not found\"}\n", serve(failed))
+	})
+
+	t.Run("indented when enabled", func(t *testing.T) {
+		UsePrettyJSON(true)
+		defer UsePrettyJSON(false)
+
+		assert.Equal(t, "{\n  \"total\": 2.50,\n  \"item_count\": 2\n}\n", serve(ok))
+		assert.Equal(t, "{\n  \"code\": 404,\n  \"message\": \"cart not found\"\n}\n", serve(failed))
+	})
+}
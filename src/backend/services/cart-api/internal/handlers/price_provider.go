@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// PriceProvider looks up the trusted unit price of a product, it returns
+// models.ErrPriceNotFound when product is unknown
+type PriceProvider interface {
+	GetPrice(ctx context.Context, productID int) (models.Money, error)
+}
+
+// WithPriceProvider makes the handler ignore client supplied prices and
+// use the ones returned by provider instead
+func WithPriceProvider(p PriceProvider) Option {
+	return func(h *CartHandler) {
+		h.priceProvider = p
+	}
+}
+
+// resolvePrice overrides item.UnitPrice when a price provider is configured,
+// otherwise the client supplied price is trusted
+func (h *CartHandler) resolvePrice(ctx context.Context, item *models.LineItem) error {
+	if h.priceProvider == nil {
+		return nil
+	}
+
+	price, err := h.priceProvider.GetPrice(ctx, item.Product())
+	if err != nil {
+		if errors.Is(err, models.ErrPriceNotFound) {
+			return models.NewHTTPError(http.StatusBadRequest, fmt.Errorf("unknown product %d: %w", item.Product(), err))
+		}
+		return models.NewHTTPError(http.StatusBadGateway, err)
+	}
+	item.UnitPrice = price
+	return nil
+}
+
+// resolveLinePrice is resolvePrice for an update of the line itemID, the
+// price is of the product of the line whatever product the body names
+func (h *CartHandler) resolveLinePrice(ctx context.Context, cartID string, itemID int, item *models.LineItem) error {
+	if h.priceProvider == nil {
+		return nil
+	}
+	cart, err := h.repository.Get(ctx, cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	for _, line := range cart.LineItems {
+		if line.ItemID == itemID {
+			item.ItemID, item.ProductID = line.ItemID, line.ProductID
+			return h.resolvePrice(ctx, item)
+		}
+	}
+	return models.NewHTTPError(http.StatusNotFound, fmt.Errorf("%w: item %d in cart %s", repositories.ErrItemNotFound, itemID, cartID))
+}
+
+func (h *CartHandler) resolvePrices(ctx context.Context, items []models.LineItem) error {
+	for i := range items {
+		if err := h.resolvePrice(ctx, &items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type stubPriceProvider map[int]models.Money
+
+func (s stubPriceProvider) GetPrice(ctx context.Context, productID int) (models.Money, error) {
+	price, ok := s[productID]
+	if !ok {
+		return models.Money{}, models.ErrPriceNotFound
+	}
+	return price, nil
+}
+
+func newItemRequest(t *testing.T, method, target string, item models.LineItem) *http.Request {
+	t.Helper()
+	body, err := json.Marshal(item)
+	assert.NoError(t, err)
+	r := httptest.NewRequest(method, target, bytes.NewBuffer(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestPriceProvider(t *testing.T) {
+	prices := stubPriceProvider{1: {Minor: 950}}
+
+	t.Run("AddItem should override client price when provider is configured", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AddItem", mock.Anything, "cart-1", mock.MatchedBy(func(item models.LineItem) bool {
+			return item.ItemID == 1 && item.UnitPrice == models.Money{Minor: 950}
+		})).Return(nil).Once()
+		handler := NewCartHandler(repo, WithPriceProvider(prices))
+
+		r := newItemRequest(t, http.MethodPost, "/cart/cart-1/item", models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1}, Quantity: 1})
+		r.SetPathValue("id", "cart-1")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.AddItem)(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateItem should override client price when provider is configured", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("Get", mock.Anything, "cart-1").Return(&models.Cart{LineItems: []models.LineItem{{ItemID: 1, Quantity: 1}}}, nil)
+		repo.On("UpdateItem", mock.Anything, "cart-1", 1, mock.MatchedBy(func(item models.LineItem) bool {
+			return item.UnitPrice == models.Money{Minor: 950} && item.Quantity == 3
+		})).Return(nil).Once()
+		handler := NewCartHandler(repo, WithPriceProvider(prices))
+
+		r := newItemRequest(t, http.MethodPut, "/cart/cart-1/item/1", models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100000}, Quantity: 3})
+		r.SetPathValue("id", "cart-1")
+		r.SetPathValue("itemID", "1")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.UpdateItem)(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("UpdateItem should price the product of the line, not of the body", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("Get", mock.Anything, "cart-1").Return(&models.Cart{LineItems: []models.LineItem{{ItemID: 3, ProductID: 1, Quantity: 1}}}, nil)
+		repo.On("UpdateItem", mock.Anything, "cart-1", 3, mock.MatchedBy(func(item models.LineItem) bool {
+			return item.UnitPrice == models.Money{Minor: 950} && item.ProductID == 1
+		})).Return(nil).Once()
+		handler := NewCartHandler(repo, WithPriceProvider(stubPriceProvider{1: {Minor: 950}, 2: {Minor: 1}}))
+
+		r := newItemRequest(t, http.MethodPut, "/cart/cart-1/item/3", models.LineItem{ItemID: 2, ProductID: 2, Quantity: 1})
+		r.SetPathValue("id", "cart-1")
+		r.SetPathValue("itemID", "3")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.UpdateItem)(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("AddItem should keep client price without provider", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		repo.On("AddItem", mock.Anything, "cart-1", mock.MatchedBy(func(item models.LineItem) bool {
+			return item.UnitPrice == models.Money{Minor: 1}
+		})).Return(nil).Once()
+		handler := NewCartHandler(repo)
+
+		r := newItemRequest(t, http.MethodPost, "/cart/cart-1/item", models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1}, Quantity: 1})
+		r.SetPathValue("id", "cart-1")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.AddItem)(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("AddItem should return bad request for unknown product", func(t *testing.T) {
+		repo := &CartRepositoryMock{}
+		handler := NewCartHandler(repo, WithPriceProvider(prices))
+
+		r := newItemRequest(t, http.MethodPost, "/cart/cart-1/item", models.LineItem{ItemID: 42, UnitPrice: models.Money{Minor: 100}, Quantity: 1})
+		r.SetPathValue("id", "cart-1")
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.AddItem)(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		repo.AssertNotCalled(t, "AddItem", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
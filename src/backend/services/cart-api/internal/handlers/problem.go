@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ProblemContentType is a media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+type problemKey struct{}
+
+// ProblemMiddleware writes errors of every request as problem details
+// regardless of the Accept header
+func ProblemMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), problemKey{}, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// wantsProblem reports whether errors of r are written as problem details,
+// either enabled by ProblemMiddleware or accepted by the client
+func wantsProblem(r *http.Request) bool {
+	if enabled, _ := r.Context().Value(problemKey{}).(bool); enabled {
+		return true
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(part)
+			if err == nil && mediaType == ProblemContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetails(t *testing.T) {
+	notFound := ErrorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		return models.NewHTTPError(http.StatusNotFound, fmt.Errorf("cart not found"))
+	})
+
+	tests := []struct {
+		name       string
+		accept     string
+		middleware bool
+		problem    bool
+	}{
+		{"default", "", false, false},
+		{"accept json", "application/json", false, false},
+		{"accept problem", "application/json;q=0.5, application/problem+json", false, true},
+		{"enabled by config", "", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			var h http.Handler = http.HandlerFunc(notFound)
+			if tt.middleware {
+				h = ProblemMiddleware(h)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+			if !tt.problem {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				assert.Equal(t, float64(http.StatusNotFound), body["code"])
+				return
+			}
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, map[string]interface{}{
+				"type":   "about:blank",
+				"title":  "Not Found",
+				"status": float64(http.StatusNotFound),
+				"detail": "cart not found",
+			}, body)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+type CartMutationCounter interface {
+	CountCartMutation(ctx context.Context, cartID string, window time.Duration) (int64, time.Duration, error)
+}
+
+// CartRateLimiter caps mutations of a single cart per window across all
+// clients and instances, it guards hot carts against runaway clients
+type CartRateLimiter struct {
+	counter CartMutationCounter
+	limit   int
+	window  time.Duration
+}
+
+// NewCartRateLimiter creates limiter allowing limit mutations of a cart
+// per window
+func NewCartRateLimiter(counter CartMutationCounter, limit int, window time.Duration) *CartRateLimiter {
+	return &CartRateLimiter{counter: counter, limit: limit, window: window}
+}
+
+// Limit rejects requests mutating the cart of {id} with 429 once the cart
+// was mutated limit times in the current window. Counting failures let the
+// request through, the limit protects redis and must not add outages
+func (l *CartRateLimiter) Limit(f func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		id := r.PathValue("id")
+		count, reset, err := l.counter.CountCartMutation(r.Context(), id, l.window)
+		if err != nil {
+			logFromCtx(r.Context()).Warn().Err(err).Str("cart_id", id).Msg("failed to count cart mutation, rate limit skipped")
+			return f(w, r)
+		}
+		if count > int64(l.limit) {
+			w.Header().Set("Retry-After", retryAfterSeconds(reset))
+			return models.NewHTTPError(http.StatusTooManyRequests,
+				fmt.Errorf("cart is mutated more than %d times per %s", l.limit, l.window))
+		}
+		return f(w, r)
+	}
+}
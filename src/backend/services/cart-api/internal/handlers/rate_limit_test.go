@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubMutationCounter struct {
+	counts map[string]int64
+	err    error
+}
+
+func (s *stubMutationCounter) CountCartMutation(ctx context.Context, cartID string, window time.Duration) (int64, time.Duration, error) {
+	if s.err != nil {
+		return 0, 0, s.err
+	}
+	s.counts[cartID]++
+	return s.counts[cartID], 1500 * time.Millisecond, nil
+}
+
+func TestCartRateLimiter(t *testing.T) {
+	mutate := func(limiter *CartRateLimiter, cartID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/cart/"+cartID+"/touch", nil)
+		r.SetPathValue("id", cartID)
+		w := httptest.NewRecorder()
+		ErrorHandler(limiter.Limit(func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}))(w, r)
+		return w
+	}
+
+	t.Run("under the limit", func(t *testing.T) {
+		limiter := NewCartRateLimiter(&stubMutationCounter{counts: map[string]int64{}}, 3, time.Second)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusNoContent, mutate(limiter, "cart-1").Code)
+		}
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		limiter := NewCartRateLimiter(&stubMutationCounter{counts: map[string]int64{}}, 2, time.Second)
+		mutate(limiter, "cart-1")
+		mutate(limiter, "cart-1")
+
+		w := mutate(limiter, "cart-1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusNoContent, mutate(limiter, "cart-2").Code, "other carts should not be limited")
+	})
+
+	t.Run("counting failure lets requests through", func(t *testing.T) {
+		limiter := NewCartRateLimiter(&stubMutationCounter{err: errors.New("redis down")}, 1, time.Second)
+		assert.Equal(t, http.StatusNoContent, mutate(limiter, "cart-1").Code)
+		assert.Equal(t, http.StatusNoContent, mutate(limiter, "cart-1").Code)
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// defaultRecentLimit is the number of recent carts returned without ?limit
+const defaultRecentLimit = 10
+
+type RecentCartsLister interface {
+	RecentCarts(ctx context.Context, userID string, limit int) ([]*models.Cart, error)
+}
+
+// RecentHandler serves recently updated carts of users
+type RecentHandler struct {
+	lister RecentCartsLister
+}
+
+// NewRecentHandler creates new instance of RecentHandler
+func NewRecentHandler(l RecentCartsLister) *RecentHandler {
+	return &RecentHandler{lister: l}
+}
+
+// Recent go doc
+//
+//	@Summary		Gets recent carts of a user
+//	@Description	Returns carts of the user, most recently updated first, caller must be the user or an admin
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			userID	path		string	true	"User ID"
+//	@Param			limit	query		int		false	"Maximum number of carts, 10 by default and at most 50"
+//	@Success		200		{object}	models.UserCartsResp
+//	@Failure		400		{object}	models.HTTPError
+//	@Failure		401		{object}	models.HTTPError
+//	@Failure		403		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/user/{userID}/recent	[get]
+func (h *RecentHandler) Recent(w http.ResponseWriter, r *http.Request) error {
+	userID := r.PathValue("userID")
+
+	if err := authorizeUser(r, userID); err != nil {
+		return err
+	}
+
+	limit := defaultRecentLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 || l > repositories.MaxRecentCarts {
+			return models.NewHTTPError(http.StatusBadRequest, errors.Errorf("limit must be between 1 and %d", repositories.MaxRecentCarts))
+		}
+		limit = l
+	}
+
+	carts, err := h.lister.RecentCarts(r.Context(), userID, limit)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, models.UserCartsResp{Carts: carts})
+}
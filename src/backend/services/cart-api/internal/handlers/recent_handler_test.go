@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type RecentCartsListerMock struct {
+	mock.Mock
+}
+
+func (m *RecentCartsListerMock) RecentCarts(ctx context.Context, userID string, limit int) ([]*models.Cart, error) {
+	args := m.Called(ctx, userID, limit)
+	carts, _ := args.Get(0).([]*models.Cart)
+	return carts, args.Error(1)
+}
+
+var _ RecentCartsLister = (*RecentCartsListerMock)(nil)
+
+func TestRecentHandler(t *testing.T) {
+	carts := []*models.Cart{{ID: uuid.New()}, {ID: uuid.New()}}
+
+	lister := &RecentCartsListerMock{}
+	lister.On("RecentCarts", mock.Anything, "alice", defaultRecentLimit).Return(carts, nil)
+	lister.On("RecentCarts", mock.Anything, "alice", 1).Return(carts[:1], nil)
+	lister.On("RecentCarts", mock.Anything, "bob", defaultRecentLimit).Return(nil, errors.New("redis down"))
+	handler := NewRecentHandler(lister)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cart/user/{userID}/recent", ErrorHandler(handler.Recent))
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		code    int
+		carts   int
+	}{
+		{"owner gets default limit", "/cart/user/alice/recent", map[string]string{UserIDHeader: "alice"}, http.StatusOK, 2},
+		{"limit is passed", "/cart/user/alice/recent?limit=1", map[string]string{UserIDHeader: "alice"}, http.StatusOK, 1},
+		{"admin gets any user", "/cart/user/alice/recent", map[string]string{UserRoleHeader: adminRole}, http.StatusOK, 2},
+		{"anonymous caller", "/cart/user/alice/recent", nil, http.StatusUnauthorized, 0},
+		{"another user", "/cart/user/alice/recent", map[string]string{UserIDHeader: "mallory"}, http.StatusForbidden, 0},
+		{"zero limit", "/cart/user/alice/recent?limit=0", map[string]string{UserIDHeader: "alice"}, http.StatusBadRequest, 0},
+		{"limit above max", "/cart/user/alice/recent?limit=51", map[string]string{UserIDHeader: "alice"}, http.StatusBadRequest, 0},
+		{"invalid limit", "/cart/user/alice/recent?limit=ten", map[string]string{UserIDHeader: "alice"}, http.StatusBadRequest, 0},
+		{"repository error", "/cart/user/bob/recent", map[string]string{UserIDHeader: "bob"}, http.StatusInternalServerError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.code != http.StatusOK {
+				return
+			}
+			var resp models.UserCartsResp
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Len(t, resp.Carts, tt.carts)
+		})
+	}
+}
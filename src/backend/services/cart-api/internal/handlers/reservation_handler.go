@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+type ReservationCounter interface {
+	Reserved(ctx context.Context, productID int) (int, error)
+}
+
+// ReservationHandler serves aggregates of soft reserved quantities
+type ReservationHandler struct {
+	counter ReservationCounter
+}
+
+// NewReservationHandler creates new instance of ReservationHandler
+func NewReservationHandler(c ReservationCounter) *ReservationHandler {
+	return &ReservationHandler{counter: c}
+}
+
+// Get go doc
+//
+//	@Summary		Gets reserved quantity
+//	@Description	Returns quantity of the product reserved by carts which have not expired
+//	@Tags			Reservations
+//	@Produce		json
+//	@Param			productID	path		int	true	"Product ID"
+//	@Success		200			{object}	models.ReservationResp
+//	@Failure		400			{object}	models.HTTPError
+//	@Failure		500			{object}	models.HTTPError
+//	@Router			/reservations/{productID}	[get]
+func (h *ReservationHandler) Get(w http.ResponseWriter, r *http.Request) error {
+	productID, err := strconv.Atoi(r.PathValue("productID"))
+	if err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	quantity, err := h.counter.Reserved(r.Context(), productID)
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, models.ReservationResp{ProductID: productID, Quantity: quantity})
+}
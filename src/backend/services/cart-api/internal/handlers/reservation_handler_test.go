@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubReservationCounter map[int]int
+
+func (s stubReservationCounter) Reserved(ctx context.Context, productID int) (int, error) {
+	return s[productID], nil
+}
+
+func TestReservationHandler(t *testing.T) {
+	handler := NewReservationHandler(stubReservationCounter{42: 3})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /reservations/{productID}", ErrorHandler(handler.Get))
+
+	t.Run("Get should return reserved quantity", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reservations/42", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var got models.ReservationResp
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&got))
+		assert.Equal(t, models.ReservationResp{ProductID: 42, Quantity: 3}, got)
+	})
+
+	t.Run("invalid product id should return 400", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reservations/abc", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
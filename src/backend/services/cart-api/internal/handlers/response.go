@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// writeJSON encodes v as the response body in the requested field casing,
+// wrapped into an envelope when the request asks for it. Nothing is written once the client went away and the
+// context error is returned instead
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return writeJSONStatus(w, r, http.StatusOK, v)
+}
+
+// writeJSONStatus is writeJSON with a status code other than 200
+func writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	if err := r.Context().Err(); err != nil {
+		return err
+	}
+	body, err := marshalerFor(r).Marshal(envelope(r, v))
+	if err == nil {
+		body, err = indentJSON(body)
+	}
+	if err != nil {
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	// trailing newline keeps bodies identical to json.Encoder output
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+type CartSharer interface {
+	Share(ctx context.Context, cartID string, ttl time.Duration) (string, error)
+	GetShared(ctx context.Context, token string) (*models.Cart, error)
+}
+
+// ShareHandler creates and serves read-only cart snapshots
+type ShareHandler struct {
+	sharer CartSharer
+	ttl    time.Duration
+}
+
+// NewShareHandler creates new instance of ShareHandler, shared snapshots
+// expire after ttl
+func NewShareHandler(s CartSharer, ttl time.Duration) *ShareHandler {
+	return &ShareHandler{sharer: s, ttl: ttl}
+}
+
+// Share go doc
+//
+//	@Summary		Shares a Cart
+//	@Description	Creates read-only snapshot of the cart under a random token
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			id	path		string	true	"Cart ID"
+//	@Success		200	{object}	models.ShareCartResp
+//	@Failure		404	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/cart/{id}/share	[post]
+func (h *ShareHandler) Share(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	token, err := h.sharer.Share(r.Context(), cartID, h.ttl)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+cartID))
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	resp := models.ShareCartResp{
+		Token:     token,
+		ExpiresAt: time.Now().UTC().Add(h.ttl),
+	}
+	return writeJSON(w, r, resp)
+}
+
+// GetShared go doc
+//
+//	@Summary		Gets a shared Cart
+//	@Description	Gets read-only cart snapshot by share token
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			token	path		string	true	"Share token"
+//	@Success		200		{object}	models.Cart
+//	@Failure		404		{object}	models.HTTPError
+//	@Failure		500		{object}	models.HTTPError
+//	@Router			/cart/share/{token}	[get]
+func (h *ShareHandler) GetShared(w http.ResponseWriter, r *http.Request) error {
+	token := r.PathValue("token")
+	result, err := h.sharer.GetShared(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, repositories.ErrShareNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+
+	return writeJSON(w, r, result)
+}
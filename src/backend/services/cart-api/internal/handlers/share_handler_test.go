@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type CartSharerMock struct {
+	mock.Mock
+}
+
+func (m *CartSharerMock) Share(ctx context.Context, cartID string, ttl time.Duration) (string, error) {
+	args := m.Called(ctx, cartID, ttl)
+	return args.String(0), args.Error(1)
+}
+
+func (m *CartSharerMock) GetShared(ctx context.Context, token string) (*models.Cart, error) {
+	args := m.Called(ctx, token)
+	cart, _ := args.Get(0).(*models.Cart)
+	return cart, args.Error(1)
+}
+
+var _ CartSharer = (*CartSharerMock)(nil)
+
+func TestShareHandler(t *testing.T) {
+	cart := &models.Cart{ID: uuid.New(), LineItems: items}
+
+	sharer := &CartSharerMock{}
+	sharer.On("Share", mock.Anything, "abcd", time.Hour).Return("token", nil)
+	sharer.On("Share", mock.Anything, "missing", time.Hour).Return("", repositories.ErrCartNotFound)
+	sharer.On("GetShared", mock.Anything, "token").Return(cart, nil)
+	sharer.On("GetShared", mock.Anything, "expired").Return(nil, repositories.ErrShareNotFound)
+	handler := NewShareHandler(sharer, time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cart/{id}/share", ErrorHandler(handler.Share))
+	mux.HandleFunc("GET /cart/share/{token}", ErrorHandler(handler.GetShared))
+
+	t.Run("Share should return token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cart/abcd/share", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.ShareCartResp
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, "token", resp.Token)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), resp.ExpiresAt, time.Minute)
+	})
+
+	t.Run("Share should return 404 for missing cart", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cart/missing/share", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("GetShared should return snapshot", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/share/token", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var result models.Cart
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		assert.Equal(t, cart.ID, result.ID)
+	})
+
+	t.Run("GetShared should return 404 for expired token", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cart/share/expired", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("shared snapshot should not accept mutations", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/cart/share/token", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+)
+
+// Business rules of stock checked before adding items
+var (
+	ErrOutOfStock        error = models.NewBusinessRule("out_of_stock", "product is out of stock")
+	ErrInsufficientStock error = models.NewBusinessRule("insufficient_stock", "not enough stock of product")
+)
+
+// StockChecker reports how many units of a product can still be sold, it
+// decouples the cart from the inventory service
+type StockChecker interface {
+	Available(ctx context.Context, productID int) (int, error)
+}
+
+// InStock is the default StockChecker which treats every product as in stock
+type InStock struct{}
+
+// Available implements StockChecker.
+func (InStock) Available(ctx context.Context, productID int) (int, error) {
+	return math.MaxInt, nil
+}
+
+// WithStockChecker makes AddItem consult checker before adding items
+func WithStockChecker(checker StockChecker) Option {
+	return func(h *CartHandler) {
+		h.stockChecker = checker
+	}
+}
+
+// WithStockClamp decides whether an item added above available stock is
+// reduced to it with a Warning header (clamp) or rejected with 422
+func WithStockClamp(clamp bool) Option {
+	return func(h *CartHandler) {
+		h.clampStock = clamp
+	}
+}
+
+// checkStock rejects items which are out of stock and clamps or rejects the
+// quantity being added when it and inCart, the quantity of the product the
+// cart has already, exceed available stock
+func (h *CartHandler) checkStock(w http.ResponseWriter, r *http.Request, item *models.LineItem, inCart int) error {
+	available, err := h.stockChecker.Available(r.Context(), item.Product())
+	if err != nil {
+		return models.NewHTTPError(http.StatusBadGateway, fmt.Errorf("checking stock of product %d: %w", item.Product(), err))
+	}
+	left := available - min(inCart, available)
+	if item.Quantity <= left {
+		return nil
+	}
+	if available <= 0 {
+		return fmt.Errorf("%w: product %d", ErrOutOfStock, item.Product())
+	}
+	if !h.clampStock || left <= 0 {
+		return fmt.Errorf("%w: only %d of product %d are in stock, the cart has %d and requested %d", ErrInsufficientStock, available, item.Product(), inCart, item.Quantity)
+	}
+
+	logFromCtx(r.Context()).Warn().Int("item_id", item.ItemID).Int("requested", item.Quantity).
+		Int("quantity", left).Msg("item quantity clamped to available stock")
+	w.Header().Add("Warning", `299 cart-api "quantity of item `+strconv.Itoa(item.ItemID)+
+		` reduced to `+strconv.Itoa(left)+` available in stock"`)
+	item.Quantity = left
+	return nil
+}
+
+// cartQuantities returns quantity of every product in the cart, added items
+// are checked against stock together with them. The cart isn't read when
+// every product is in stock or it doesn't exist yet
+func (h *CartHandler) cartQuantities(ctx context.Context, cartID string) (map[int]int, error) {
+	if _, ok := h.stockChecker.(InStock); ok {
+		return nil, nil
+	}
+	cart, err := h.repository.Get(ctx, cartID)
+	if errors.Is(err, repositories.ErrCartNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	quantities := make(map[int]int, len(cart.LineItems))
+	for _, item := range cart.LineItems {
+		quantities[item.Product()] += item.Quantity
+	}
+	return quantities, nil
+}
+
+// withAvailability reports whether the request asks for availability of
+// line items, stock is looked up only then
+func withAvailability(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("withAvailability")
+	if v == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, models.NewHTTPError(http.StatusBadRequest, fmt.Errorf("withAvailability must be a boolean: %w", err))
+	}
+	return enabled, nil
+}
+
+// addAvailability annotates line items with the stock of their products,
+// each product is looked up once. Availability is an aid for rendering so
+// a failed lookup leaves the items of the product unannotated
+func (h *CartHandler) addAvailability(ctx context.Context, cart *models.Cart) {
+	stock := make(map[int]int)
+	for i := range cart.LineItems {
+		item := &cart.LineItems[i]
+		available, ok := stock[item.Product()]
+		if !ok {
+			var err error
+			if available, err = h.stockChecker.Available(ctx, item.Product()); err != nil {
+				logFromCtx(ctx).Warn().Err(err).Int("product_id", item.Product()).Msg("failed to check stock, availability left out")
+				continue
+			}
+			stock[item.Product()] = available
+		}
+		inStock := item.Quantity <= available
+		item.Available = &inStock
+		if available < math.MaxInt {
+			quantity := max(available, 0)
+			item.AvailableQuantity = &quantity
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type stubStockChecker map[int]int
+
+func (s stubStockChecker) Available(ctx context.Context, productID int) (int, error) {
+	return s[productID], nil
+}
+
+func TestStockChecker(t *testing.T) {
+	stock := stubStockChecker{1: 10, 2: 0, 3: 3}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		item     models.LineItem
+		inCart   int
+		want     int
+		quantity int
+		warning  bool
+	}{
+		{"default treats everything as in stock", nil, models.LineItem{ItemID: 2, Quantity: 5}, 0, http.StatusOK, 5, false},
+		{"in stock", []Option{WithStockChecker(stock)}, models.LineItem{ItemID: 1, Quantity: 5}, 0, http.StatusOK, 5, false},
+		{"out of stock", []Option{WithStockChecker(stock)}, models.LineItem{ItemID: 2, Quantity: 1}, 0, http.StatusUnprocessableEntity, 0, false},
+		{"clamped to stock", []Option{WithStockChecker(stock)}, models.LineItem{ItemID: 3, Quantity: 5}, 0, http.StatusOK, 3, true},
+		{"above stock without clamp", []Option{WithStockChecker(stock), WithStockClamp(false)}, models.LineItem{ItemID: 3, Quantity: 5}, 0, http.StatusUnprocessableEntity, 0, false},
+		{"quantity in the cart should count", []Option{WithStockChecker(stock)}, models.LineItem{ItemID: 3, Quantity: 2}, 2, http.StatusOK, 1, true},
+		{"quantity in the cart should count without clamp", []Option{WithStockChecker(stock), WithStockClamp(false)}, models.LineItem{ItemID: 3, Quantity: 2}, 2, http.StatusUnprocessableEntity, 0, false},
+		{"cart at stock should take no more", []Option{WithStockChecker(stock)}, models.LineItem{ItemID: 3, Quantity: 1}, 3, http.StatusUnprocessableEntity, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &CartRepositoryMock{}
+			cart := &models.Cart{LineItems: []models.LineItem{{ItemID: tt.item.ItemID, Quantity: tt.inCart}}}
+			repo.On("Get", mock.Anything, "cart-1").Return(cart, nil).Maybe()
+			if tt.quantity > 0 {
+				repo.On("AddItem", mock.Anything, "cart-1", mock.MatchedBy(func(item models.LineItem) bool {
+					return item.ItemID == tt.item.ItemID && item.Quantity == tt.quantity
+				})).Return(nil).Once()
+			}
+			handler := NewCartHandler(repo, tt.opts...)
+
+			r := newItemRequest(t, http.MethodPost, "/cart/cart-1/item", tt.item)
+			r.SetPathValue("id", "cart-1")
+			w := httptest.NewRecorder()
+			ErrorHandler(handler.AddItem)(w, r)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.warning {
+				assert.Contains(t, w.Header().Get("Warning"), "reduced to "+strconv.Itoa(tt.quantity))
+			} else {
+				assert.Empty(t, w.Header().Get("Warning"))
+			}
+			repo.AssertExpectations(t)
+		})
+	}
+}
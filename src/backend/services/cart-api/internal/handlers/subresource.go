@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// SubresourceRouter serves GET /cart/{id}/{resource}. GET /cart/share/{token}
+// overlaps every such pattern and ServeMux refuses to register overlapping
+// patterns, so shared carts are dispatched from the same route
+type SubresourceRouter struct {
+	shared    func(w http.ResponseWriter, r *http.Request) error
+	resources map[string]func(w http.ResponseWriter, r *http.Request) error
+}
+
+// NewSubresourceRouter creates router serving shared carts with shared, nil
+// shared answers them with 404
+func NewSubresourceRouter(shared func(w http.ResponseWriter, r *http.Request) error) *SubresourceRouter {
+	return &SubresourceRouter{shared: shared, resources: map[string]func(w http.ResponseWriter, r *http.Request) error{}}
+}
+
+// Register serves GET /cart/{id}/{name} with f
+func (s *SubresourceRouter) Register(name string, f func(w http.ResponseWriter, r *http.Request) error) *SubresourceRouter {
+	s.resources[name] = f
+	return s
+}
+
+// Handle dispatches the request by {id} and {resource} path values
+func (s *SubresourceRouter) Handle(w http.ResponseWriter, r *http.Request) error {
+	resource := r.PathValue("resource")
+	if r.PathValue("id") == "share" {
+		if s.shared == nil {
+			return models.NewHTTPError(http.StatusNotFound, errors.New("shared carts are not served"))
+		}
+		r.SetPathValue("token", resource)
+		return s.shared(w, r)
+	}
+	f, ok := s.resources[resource]
+	if !ok {
+		return models.NewHTTPError(http.StatusNotFound, fmt.Errorf("unknown cart resource %q", resource))
+	}
+	return f(w, r)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+type CartSummarizer interface {
+	Summary(ctx context.Context, cartID string) (models.CartSummary, error)
+}
+
+// SummaryHandler serves total and item count of carts, e.g. for badges
+// polled often, without returning the whole cart
+type SummaryHandler struct {
+	summarizer CartSummarizer
+}
+
+// NewSummaryHandler creates new instance of SummaryHandler
+func NewSummaryHandler(summarizer CartSummarizer) *SummaryHandler {
+	return &SummaryHandler{summarizer: summarizer}
+}
+
+// Summary go doc
+//
+//	@Summary		Summarizes a Cart
+//	@Description	Returns total and number of items of the cart, cached next to the cart
+//	@Tags			Cart
+//	@Produce		json
+//	@Param			id	path		string	true	"Cart ID"
+//	@Success		200	{object}	models.CartSummary
+//	@Failure		400	{object}	models.HTTPError
+//	@Failure		404	{object}	models.HTTPError
+//	@Failure		422	{object}	models.HTTPError
+//	@Failure		500	{object}	models.HTTPError
+//	@Router			/cart/{id}/summary	[get]
+func (h *SummaryHandler) Summary(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+	summary, err := h.summarizer.Summary(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrCartNotFound) {
+			return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+cartID))
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, summary)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubSummarizer map[string]models.CartSummary
+
+func (s stubSummarizer) Summary(ctx context.Context, cartID string) (models.CartSummary, error) {
+	if cartID == "broken" {
+		return models.CartSummary{}, errors.New("redis down")
+	}
+	summary, ok := s[cartID]
+	if !ok {
+		return models.CartSummary{}, repositories.ErrCartNotFound
+	}
+	return summary, nil
+}
+
+func TestSummaryHandler(t *testing.T) {
+	handler := NewSummaryHandler(stubSummarizer{"abcd": {Total: models.Money{Minor: 2500}, ItemCount: 3}})
+	serve := func(cartID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/cart/"+cartID+"/summary", nil)
+		r.SetPathValue("id", cartID)
+		w := httptest.NewRecorder()
+		ErrorHandler(handler.Summary)(w, r)
+		return w
+	}
+
+	t.Run("should return summary", func(t *testing.T) {
+		w := serve("abcd")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"total":25.00,"item_count":3}`, w.Body.String())
+	})
+
+	t.Run("missing cart should return 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("missing").Code)
+	})
+
+	t.Run("failure should return 500", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, serve("broken").Code)
+	})
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/tenant"
+)
+
+// TenantMiddleware puts the X-Tenant-ID header into baggage of the request
+// context, requests with an invalid tenant id are rejected with 400
+func TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(tenant.Header)
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, err := tenant.NewContext(r.Context(), id)
+		if err != nil {
+			writeError(w, r, models.NewHTTPError(http.StatusBadRequest, err))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
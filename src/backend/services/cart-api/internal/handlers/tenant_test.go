@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jurabek/cart-api/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	var got string
+	handler := TenantMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = tenant.FromContext(r.Context())
+	}))
+
+	t.Run("header should be put into baggage", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+		r.Header.Set(tenant.Header, "acme")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "acme", got)
+	})
+
+	t.Run("invalid tenant should return 400", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/cart/abcd", nil)
+		r.Header.Set(tenant.Header, "acme:*")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	cartIDKey        = attribute.Key("cart.id")
+	cartItemCountKey = attribute.Key("cart.item_count")
+)
+
+// WithCartIDAttribute toggles recording of the cart.id span attribute. Cart
+// ids are unbounded so it can be disabled for tracing backends that suffer
+// from high cardinality attributes.
+func WithCartIDAttribute(enabled bool) Option {
+	return func(h *CartHandler) {
+		h.traceCartID = enabled
+	}
+}
+
+// traceCart adds cart business context to the request span, negative
+// itemCount means the count is not known by the handler
+func (h *CartHandler) traceCart(ctx context.Context, cartID string, itemCount int) {
+	span := trace.SpanFromContext(ctx)
+	if h.traceCartID {
+		span.SetAttributes(cartIDKey.String(cartID))
+	}
+	if itemCount >= 0 {
+		span.SetAttributes(cartItemCountKey.Int(itemCount))
+	}
+}
+
+// logFromCtx returns the global logger with trace_id and span_id of the span
+// in ctx, so log lines can be joined with traces, and the client ip when known
+func logFromCtx(ctx context.Context) *zerolog.Logger {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	clientIP, hasClientIP := ClientIPFromContext(ctx)
+	if !spanCtx.IsValid() && !hasClientIP {
+		return &log.Logger
+	}
+	logCtx := log.With()
+	if spanCtx.IsValid() {
+		logCtx = logCtx.
+			Str("trace_id", spanCtx.TraceID().String()).
+			Str("span_id", spanCtx.SpanID().String())
+	}
+	if hasClientIP {
+		logCtx = logCtx.Str("client_ip", clientIP)
+	}
+	logger := logCtx.Logger()
+	return &logger
+}
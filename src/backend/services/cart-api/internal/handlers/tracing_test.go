@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func tracedGet(t *testing.T, handler *CartHandler, cartID string) map[attribute.Key]attribute.Value {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	r := httptest.NewRequest(http.MethodGet, "/cart/"+cartID, nil)
+	r.SetPathValue("id", cartID)
+	ctx, span := tp.Tracer("test").Start(r.Context(), "server")
+	w := httptest.NewRecorder()
+	ErrorHandler(handler.Get)(w, r.WithContext(ctx))
+	span.End()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTraceCart(t *testing.T) {
+	cart := &models.Cart{ID: uuid.New(), LineItems: items}
+	cartID := cart.ID.String()
+
+	repo := &CartRepositoryMock{}
+	repo.On("Get", mock.Anything, cartID).Return(cart, nil)
+
+	t.Run("Get should record cart id and item count", func(t *testing.T) {
+		attrs := tracedGet(t, NewCartHandler(repo), cartID)
+		assert.Equal(t, cartID, attrs[cartIDKey].AsString())
+		assert.Equal(t, int64(1), attrs[cartItemCountKey].AsInt64())
+	})
+
+	t.Run("cart id should not be recorded when disabled", func(t *testing.T) {
+		attrs := tracedGet(t, NewCartHandler(repo, WithCartIDAttribute(false)), cartID)
+		_, ok := attrs[cartIDKey]
+		assert.False(t, ok)
+		assert.Equal(t, int64(1), attrs[cartItemCountKey].AsInt64())
+	})
+}
+
+func TestLogFromCtx(t *testing.T) {
+	var buf bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = previous })
+
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "server")
+	defer span.End()
+
+	logFromCtx(ctx).Info().Msg("hello")
+
+	var line map[string]string
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, span.SpanContext().TraceID().String(), line["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), line["span_id"])
+
+	buf.Reset()
+	logFromCtx(context.Background()).Info().Msg("hello")
+	assert.NotContains(t, buf.String(), "trace_id")
+}
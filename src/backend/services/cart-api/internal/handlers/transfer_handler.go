@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/pkg/errors"
+)
+
+// Identity of the caller is set by the gateway after authentication
+const (
+	UserIDHeader   = "X-User-ID"
+	UserRoleHeader = "X-User-Role"
+	adminRole      = "admin"
+)
+
+// authorizeUser allows the user and admins to access carts of userID
+func authorizeUser(r *http.Request, userID string) error {
+	caller := r.Header.Get(UserIDHeader)
+	admin := r.Header.Get(UserRoleHeader) == adminRole
+	if caller == "" && !admin {
+		return models.NewHTTPError(http.StatusUnauthorized, errors.New(UserIDHeader+" is required"))
+	}
+	if !admin && caller != userID {
+		return models.NewHTTPError(http.StatusForbidden, errors.New("carts of another user"))
+	}
+	return nil
+}
+
+type CartTransferer interface {
+	Transfer(ctx context.Context, cartID, from, to string, replace bool) (*models.Cart, error)
+}
+
+// TransferHandler changes owners of carts
+type TransferHandler struct {
+	transferer CartTransferer
+
+	// replaceActive lets a transfer to a user having another active cart
+	// repoint the user to the transferred cart instead of failing with 409
+	replaceActive bool
+}
+
+// NewTransferHandler creates new instance of TransferHandler
+func NewTransferHandler(t CartTransferer, replaceActive bool) *TransferHandler {
+	return &TransferHandler{transferer: t, replaceActive: replaceActive}
+}
+
+// Transfer go doc
+//
+//	@Summary		Transfers a Cart
+//	@Description	Changes owner of the cart, caller must be the current owner or an admin
+//	@Tags			Cart
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string					true	"Cart ID"
+//	@Param			transfer	body		models.TransferCartReq	true	"New owner"
+//	@Success		200			{object}	models.Cart
+//	@Failure		400			{object}	models.HTTPError
+//	@Failure		401			{object}	models.HTTPError
+//	@Failure		403			{object}	models.HTTPError
+//	@Failure		404			{object}	models.HTTPError
+//	@Failure		409			{object}	models.HTTPError
+//	@Failure		500			{object}	models.HTTPError
+//	@Router			/cart/{id}/transfer	[post]
+func (h *TransferHandler) Transfer(w http.ResponseWriter, r *http.Request) error {
+	cartID := r.PathValue("id")
+
+	caller := r.Header.Get(UserIDHeader)
+	admin := r.Header.Get(UserRoleHeader) == adminRole
+	if caller == "" && !admin {
+		return models.NewHTTPError(http.StatusUnauthorized, errors.New(UserIDHeader+" is required"))
+	}
+	if admin {
+		caller = ""
+	}
+
+	var req models.TransferCartReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if req.UserID == "" {
+		return models.NewHTTPError(http.StatusBadRequest, errors.New("user_id is required"))
+	}
+
+	cart, err := h.transferer.Transfer(r.Context(), cartID, caller, req.UserID, h.replaceActive)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrCartNotFound):
+			return models.NewHTTPError(http.StatusNotFound, errors.Wrap(err, "cartID: "+cartID))
+		case errors.Is(err, repositories.ErrNotOwner):
+			return models.NewHTTPError(http.StatusForbidden, err)
+		case errors.Is(err, repositories.ErrActiveCart):
+			return models.NewHTTPError(http.StatusConflict, err)
+		}
+		return models.NewHTTPError(http.StatusInternalServerError, err)
+	}
+	return writeJSON(w, r, cart)
+}
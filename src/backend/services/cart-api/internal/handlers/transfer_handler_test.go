@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type CartTransfererMock struct {
+	mock.Mock
+}
+
+func (m *CartTransfererMock) Transfer(ctx context.Context, cartID, from, to string, replace bool) (*models.Cart, error) {
+	args := m.Called(ctx, cartID, from, to, replace)
+	cart, _ := args.Get(0).(*models.Cart)
+	return cart, args.Error(1)
+}
+
+var _ CartTransferer = (*CartTransfererMock)(nil)
+
+func TestTransferHandler(t *testing.T) {
+	owner := "bob"
+	cart := &models.Cart{ID: uuid.New(), UserID: &owner}
+
+	transferer := &CartTransfererMock{}
+	transferer.On("Transfer", mock.Anything, "abcd", "alice", "bob", true).Return(cart, nil)
+	transferer.On("Transfer", mock.Anything, "abcd", "", "bob", true).Return(cart, nil)
+	transferer.On("Transfer", mock.Anything, "abcd", "mallory", "bob", true).Return(nil, repositories.ErrNotOwner)
+	transferer.On("Transfer", mock.Anything, "abcd", "alice", "taken", true).Return(nil, repositories.ErrActiveCart)
+	transferer.On("Transfer", mock.Anything, "missing", "alice", "bob", true).Return(nil, repositories.ErrCartNotFound)
+	handler := NewTransferHandler(transferer, true)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /cart/{id}/transfer", ErrorHandler(handler.Transfer))
+
+	transfer := func(cartID, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/cart/"+cartID+"/transfer", strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name    string
+		cartID  string
+		body    string
+		headers map[string]string
+		code    int
+	}{
+		{"owner should transfer", "abcd", `{"user_id":"bob"}`, map[string]string{UserIDHeader: "alice"}, http.StatusOK},
+		{"admin should transfer", "abcd", `{"user_id":"bob"}`, map[string]string{UserIDHeader: "root", UserRoleHeader: "admin"}, http.StatusOK},
+		{"anonymous caller should be unauthorized", "abcd", `{"user_id":"bob"}`, nil, http.StatusUnauthorized},
+		{"other user should be forbidden", "abcd", `{"user_id":"bob"}`, map[string]string{UserIDHeader: "mallory"}, http.StatusForbidden},
+		{"target having cart should conflict", "abcd", `{"user_id":"taken"}`, map[string]string{UserIDHeader: "alice"}, http.StatusConflict},
+		{"missing cart should return 404", "missing", `{"user_id":"bob"}`, map[string]string{UserIDHeader: "alice"}, http.StatusNotFound},
+		{"missing user_id should return 400", "abcd", `{}`, map[string]string{UserIDHeader: "alice"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := transfer(tt.cartID, tt.body, tt.headers)
+			assert.Equal(t, tt.code, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("transferred cart should be returned", func(t *testing.T) {
+		w := transfer("abcd", `{"user_id":"bob"}`, map[string]string{UserIDHeader: "alice"})
+		assert.Contains(t, w.Body.String(), `"user_id":"bob"`)
+	})
+}
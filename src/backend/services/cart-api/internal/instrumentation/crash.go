@@ -0,0 +1,50 @@
+package instrumentation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// CrashFlusher flushes telemetry when the process dies of log.Fatal or a
+// panic, those skip deferred shutdown so the spans leading to the crash
+// would be lost otherwise
+type CrashFlusher struct {
+	flush   CloseFunc
+	timeout time.Duration
+	once    sync.Once
+}
+
+// NewCrashFlusher creates flusher calling flush at most once, bounded by
+// timeout so a crashing process doesn't hang on an unreachable collector
+func NewCrashFlusher(flush CloseFunc, timeout time.Duration) *CrashFlusher {
+	return &CrashFlusher{flush: flush, timeout: timeout}
+}
+
+// Run implements zerolog.Hook, fatal and panic events flush before zerolog
+// exits or panics
+func (f *CrashFlusher) Run(_ *zerolog.Event, level zerolog.Level, _ string) {
+	if level == zerolog.FatalLevel || level == zerolog.PanicLevel {
+		f.Flush()
+	}
+}
+
+// Recover flushes and panics again when the calling goroutine panics, it is
+// meant to be deferred first in main
+func (f *CrashFlusher) Recover() {
+	if r := recover(); r != nil {
+		f.Flush()
+		panic(r)
+	}
+}
+
+// Flush calls flush once, later calls return immediately
+func (f *CrashFlusher) Flush() {
+	f.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+		defer cancel()
+		_ = f.flush(ctx)
+	})
+}
@@ -0,0 +1,89 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// spanRecorder keeps exported spans after shutdown unlike
+// tracetest.InMemoryExporter
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (r *spanRecorder) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error { return nil }
+
+func (r *spanRecorder) GetSpans() []sdktrace.ReadOnlySpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spans
+}
+
+// batchedProvider exports spans only when flushed, like StartOTEL does
+func batchedProvider() (*sdktrace.TracerProvider, *spanRecorder) {
+	exporter := &spanRecorder{}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(time.Hour)))
+	return tp, exporter
+}
+
+func TestCrashFlusher(t *testing.T) {
+	t.Run("fatal log should flush spans", func(t *testing.T) {
+		tp, exporter := batchedProvider()
+		flusher := NewCrashFlusher(tp.Shutdown, time.Second)
+		logger := zerolog.New(&bytes.Buffer{}).Hook(flusher)
+
+		_, span := tp.Tracer("test").Start(context.Background(), "request")
+		span.End()
+		assert.Empty(t, exporter.GetSpans())
+
+		// WithLevel runs the hooks of Fatal without exiting the test
+		logger.WithLevel(zerolog.FatalLevel).Msg("crash")
+		assert.Len(t, exporter.GetSpans(), 1)
+	})
+
+	t.Run("other levels should not flush", func(t *testing.T) {
+		calls := 0
+		flusher := NewCrashFlusher(func(context.Context) error { calls++; return nil }, time.Second)
+		logger := zerolog.New(&bytes.Buffer{}).Hook(flusher)
+
+		logger.Error().Msg("failed")
+		logger.Warn().Msg("slow")
+		assert.Zero(t, calls)
+	})
+
+	t.Run("panic should flush and propagate", func(t *testing.T) {
+		tp, exporter := batchedProvider()
+		flusher := NewCrashFlusher(tp.Shutdown, time.Second)
+
+		_, span := tp.Tracer("test").Start(context.Background(), "request")
+		span.End()
+		assert.PanicsWithValue(t, "boom", func() {
+			defer flusher.Recover()
+			panic("boom")
+		})
+		assert.Len(t, exporter.GetSpans(), 1)
+	})
+
+	t.Run("flush should run once", func(t *testing.T) {
+		calls := 0
+		flusher := NewCrashFlusher(func(context.Context) error { calls++; return nil }, time.Second)
+		flusher.Flush()
+		flusher.Flush()
+		assert.Equal(t, 1, calls)
+	})
+}
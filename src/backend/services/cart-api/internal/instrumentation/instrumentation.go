@@ -2,11 +2,11 @@ package instrumentation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
-	"github.com/rs/zerolog/log"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
@@ -15,11 +15,15 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-type CloseFunc func()
+// CloseFunc flushes pending telemetry and closes the exporter connection,
+// it returns when done or ctx is done
+type CloseFunc func(ctx context.Context) error
 
 func StartOTEL(ctx context.Context) (CloseFunc, error) {
 	res, err := resource.New(ctx,
@@ -33,50 +37,90 @@ func StartOTEL(ctx context.Context) (CloseFunc, error) {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
-
 	otelExportEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
 	if otelExportEndpoint == "" {
 		otelExportEndpoint = "localhost:4317"
 	}
-	conn, err := grpc.DialContext(ctx, otelExportEndpoint,
+	// The connection is established lazily, startup never waits for the
+	// collector. Exporters drop telemetry and retry while it is unreachable
+	conn, err := grpc.NewClient(otelExportEndpoint,
 		// Note the use of insecure transport here. TLS is recommended in production.
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithBlock(),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create gRPC connection to collector: %w", err)
+		return noopFallback(fmt.Errorf("failed to create gRPC connection to collector: %w", err)), nil
 	}
+	go warnUnreachable(conn, otelExportEndpoint, collectorTimeout)
 
+	// globals are set once meters and traces can be exported, so a failing
+	// exporter leaves the no-op providers in place
 	meterProvider, err := setupMeterProvider(ctx, conn, res)
 	if err != nil {
-		return nil, err
+		return noopFallback(errors.Join(err, conn.Close())), nil
 	}
 
 	traceProvider, err := setupTraceProvider(ctx, conn, res)
 	if err != nil {
-		return nil, err
+		return noopFallback(errors.Join(err, meterProvider.Shutdown(ctx), conn.Close())), nil
 	}
 
-	closeFunc := func() {
-		// Handle shutdown properly so nothing leaks.
-		if meterErr := meterProvider.Shutdown(ctx ); meterErr != nil {
-			log.Error().Msg(meterErr.Error())
-		}
+	// Register as global providers so that they can be used via otel.Meter
+	// and otel.Tracer. Most instrumentation libraries use the global
+	// providers as default.
+	otel.SetMeterProvider(meterProvider)
+	otel.SetTracerProvider(traceProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 
-		if tpErr := traceProvider.Shutdown(ctx); tpErr != nil {
-			log.Error().Err(tpErr)
+	shutdowns := []func(context.Context) error{meterProvider.Shutdown, traceProvider.Shutdown}
+	if logsEnabled() {
+		loggerProvider, err := setupLoggerProvider(ctx, conn, res)
+		if err != nil {
+			// logs keep going to stderr only
+			log.Warn().Err(err).Msg("failed to export logs to otel collector")
+		} else {
+			shutdowns = append(shutdowns, loggerProvider.Shutdown)
 		}
+	}
 
-		if connErr := conn.Close(); connErr != nil {
-			log.Error().Err(connErr)
+	closeFunc := func(ctx context.Context) error {
+		// Handle shutdown properly so nothing leaks.
+		var errs []error
+		for _, shutdown := range shutdowns {
+			errs = append(errs, shutdown(ctx))
 		}
+		return errors.Join(append(errs, conn.Close())...)
 	}
 
 	return closeFunc, nil
 }
 
+// collectorTimeout is how long the collector may take to become reachable
+// before a warning is logged
+const collectorTimeout = 5 * time.Second
+
+// noopFallback warns that telemetry is disabled because of err and returns a
+// CloseFunc with nothing to flush, the global providers stay no-op
+func noopFallback(err error) CloseFunc {
+	log.Warn().Err(err).Msg("otel collector unavailable, telemetry is disabled")
+	return func(context.Context) error { return nil }
+}
+
+// warnUnreachable logs a warning when conn does not connect to the collector
+// within timeout
+func warnUnreachable(conn *grpc.ClientConn, endpoint string, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.Shutdown || !conn.WaitForStateChange(ctx, state) {
+			if state != connectivity.Shutdown {
+				log.Warn().Str("endpoint", endpoint).Msg("otel collector unreachable, telemetry is dropped until it connects")
+			}
+			return
+		}
+	}
+}
+
 func setupMeterProvider(ctx context.Context, conn *grpc.ClientConn, res *resource.Resource) (*metric.MeterProvider, error) {
 	// Create a meter provider.
 	// You can pass this instance directly to your instrumented code if it
@@ -93,12 +137,6 @@ func setupMeterProvider(ctx context.Context, conn *grpc.ClientConn, res *resourc
 			metric.WithInterval(10*time.Second))),
 	)
 
-	// Register as global meter provider so that it can be used via otel.Meter
-	// and accessed using otel.GetMeterProvider.
-	// Most instrumentation libraries use the global meter provider as default.
-	// If the global meter provider is not set then a no-op implementation
-	// is used, which fails to generate data.
-	otel.SetMeterProvider(meterProvider)
 	return meterProvider, nil
 }
 
@@ -114,7 +152,5 @@ func setupTraceProvider(ctx context.Context, conn *grpc.ClientConn, res *resourc
 		sdktrace.WithResource(res),
 		sdktrace.WithBatcher(traceExporter),
 	)
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 	return tp, nil
 }
@@ -0,0 +1,164 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// logsEnabled reports whether logs are exported besides stderr, export is
+// opted in by OTEL_LOGS_EXPORTER=otlp
+func logsEnabled() bool {
+	return os.Getenv("OTEL_LOGS_EXPORTER") == "otlp"
+}
+
+func setupLoggerProvider(ctx context.Context, conn *grpc.ClientConn, res *resource.Resource) (*sdklog.LoggerProvider, error) {
+	logExporter, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log exporter: %w", err)
+	}
+
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+	)
+	global.SetLoggerProvider(lp)
+	// zerolog keeps writing to stderr, records are exported besides
+	log.Logger = log.Output(zerolog.MultiLevelWriter(os.Stderr, NewLogWriter(lp.Logger("cart-api"))))
+	return lp, nil
+}
+
+// LogWriter bridges zerolog to OTEL logs, every json line written by zerolog
+// is emitted as a log record. trace_id and span_id fields added by loggers
+// of requests correlate the record with its span
+type LogWriter struct {
+	logger otellog.Logger
+}
+
+// NewLogWriter creates writer emitting records with logger
+func NewLogWriter(logger otellog.Logger) *LogWriter {
+	return &LogWriter{logger: logger}
+}
+
+var _ zerolog.LevelWriter = (*LogWriter)(nil)
+
+// Write implements io.Writer, lines written without level are emitted
+// without severity
+func (w *LogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter.
+func (w *LogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return 0, fmt.Errorf("decoding log line: %w", err)
+	}
+
+	now := time.Now()
+	var record otellog.Record
+	record.SetTimestamp(now)
+	record.SetObservedTimestamp(now)
+	if severity, ok := severities[level]; ok {
+		record.SetSeverity(severity)
+		record.SetSeverityText(level.String())
+	}
+
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	for key, value := range fields {
+		var err error
+		switch key {
+		case zerolog.LevelFieldName:
+		case zerolog.MessageFieldName:
+			record.SetBody(logValue(value))
+		case zerolog.TimestampFieldName:
+			if t, ok := logTime(value); ok {
+				record.SetTimestamp(t)
+			}
+		case "trace_id":
+			traceID, err = trace.TraceIDFromHex(fmt.Sprint(value))
+		case "span_id":
+			spanID, err = trace.SpanIDFromHex(fmt.Sprint(value))
+		default:
+			record.AddAttributes(otellog.KeyValue{Key: key, Value: logValue(value)})
+		}
+		if err != nil {
+			record.AddAttributes(otellog.String(key, fmt.Sprint(value)))
+		}
+	}
+
+	ctx := context.Background()
+	if spanCtx := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}); spanCtx.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, spanCtx)
+	}
+	w.logger.Emit(ctx, record)
+	return len(p), nil
+}
+
+var severities = map[zerolog.Level]otellog.Severity{
+	zerolog.TraceLevel: otellog.SeverityTrace,
+	zerolog.DebugLevel: otellog.SeverityDebug,
+	zerolog.InfoLevel:  otellog.SeverityInfo,
+	zerolog.WarnLevel:  otellog.SeverityWarn,
+	zerolog.ErrorLevel: otellog.SeverityError,
+	zerolog.FatalLevel: otellog.SeverityFatal,
+	zerolog.PanicLevel: otellog.SeverityFatal4,
+}
+
+// logTime parses the timestamp of zerolog in unix seconds or RFC3339
+func logTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		seconds, err := v.Int64()
+		return time.Unix(seconds, 0), err == nil
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// logValue converts a decoded json value, integers are kept as integers
+func logValue(value interface{}) otellog.Value {
+	switch v := value.(type) {
+	case string:
+		return otellog.StringValue(v)
+	case bool:
+		return otellog.BoolValue(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return otellog.Int64Value(n)
+		}
+		f, _ := v.Float64()
+		return otellog.Float64Value(f)
+	case []interface{}:
+		values := make([]otellog.Value, len(v))
+		for i, item := range v {
+			values[i] = logValue(item)
+		}
+		return otellog.SliceValue(values...)
+	case map[string]interface{}:
+		kvs := make([]otellog.KeyValue, 0, len(v))
+		for key, item := range v {
+			kvs = append(kvs, otellog.KeyValue{Key: key, Value: logValue(item)})
+		}
+		return otellog.MapValue(kvs...)
+	}
+	return otellog.Value{}
+}
@@ -0,0 +1,75 @@
+package instrumentation
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+type logRecorder struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (r *logRecorder) Export(_ context.Context, records []sdklog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, record := range records {
+		r.records = append(r.records, record.Clone())
+	}
+	return nil
+}
+
+func (r *logRecorder) Shutdown(context.Context) error   { return nil }
+func (r *logRecorder) ForceFlush(context.Context) error { return nil }
+
+func TestLogWriter(t *testing.T) {
+	recorder := &logRecorder{}
+	res := resource.NewSchemaless(semconv.ServiceName("cart-api"))
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewSimpleProcessor(recorder)),
+	)
+	logger := zerolog.New(NewLogWriter(provider.Logger("test"))).With().Timestamp().Logger()
+
+	_, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+	spanCtx := span.SpanContext()
+	logger.Warn().
+		Str("trace_id", spanCtx.TraceID().String()).
+		Str("span_id", spanCtx.SpanID().String()).
+		Str("cart_id", "cart-1").
+		Int("items", 3).
+		Msg("cart limit reached")
+	logger.Info().Msg("no span")
+
+	require.Len(t, recorder.records, 2)
+	record := recorder.records[0]
+	assert.Equal(t, spanCtx.TraceID(), record.TraceID())
+	assert.Equal(t, spanCtx.SpanID(), record.SpanID())
+	assert.Equal(t, otellog.SeverityWarn, record.Severity())
+	assert.Equal(t, "warn", record.SeverityText())
+	assert.Equal(t, "cart limit reached", record.Body().AsString())
+	recordRes := record.Resource()
+	assert.Equal(t, res.Attributes(), recordRes.Attributes())
+
+	attrs := map[string]otellog.Value{}
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	assert.Equal(t, "cart-1", attrs["cart_id"].AsString())
+	assert.Equal(t, int64(3), attrs["items"].AsInt64())
+	assert.NotContains(t, attrs, "trace_id")
+	assert.NotContains(t, attrs, "time")
+
+	assert.False(t, recorder.records[1].TraceID().IsValid())
+}
@@ -0,0 +1,39 @@
+package instrumentation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestStartOTELWithoutCollector(t *testing.T) {
+	for name, endpoint := range map[string]string{
+		"invalid endpoint":     "dns://a/b/c:%zz",
+		"unreachable endpoint": "127.0.0.1:1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", endpoint)
+			tracerProvider, meterProvider := otel.GetTracerProvider(), otel.GetMeterProvider()
+			t.Cleanup(func() {
+				otel.SetTracerProvider(tracerProvider)
+				otel.SetMeterProvider(meterProvider)
+			})
+
+			start := time.Now()
+			closeOTEL, err := StartOTEL(context.Background())
+			require.NoError(t, err)
+			assert.Less(t, time.Since(start), time.Second, "startup should not wait for the collector")
+
+			_, span := otel.Tracer("test").Start(context.Background(), "request")
+			span.End()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_ = closeOTEL(ctx)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Actions of audit entries
+const (
+	AuditItemAdded   = "item_added"
+	AuditItemUpdated = "item_updated"
+	AuditItemRemoved = "item_removed"
+	AuditCartDeleted = "cart_deleted"
+
+	// AuditCartReplaced is a write of the whole cart, recorded by event
+	// sourced carts only
+	AuditCartReplaced = "cart_replaced"
+)
+
+// AuditEntry is a change of a cart recorded for support investigations,
+// Quantity is the quantity of the item after the change
+type AuditEntry struct {
+	At       time.Time `json:"at"`
+	Action   string    `json:"action" example:"item_added"`
+	Actor    string    `json:"actor,omitempty" example:"user-1"`
+	ItemID   int       `json:"item_id,omitempty" example:"1"`
+	Quantity int       `json:"quantity,omitempty" example:"2"`
+}
+
+type actorKey struct{}
+
+// ContextWithActor returns ctx of a request made by actor, e.g. a user id
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns actor of ctx, empty for changes made by the
+// service itself such as consumed events
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// CartHistory is the audit log of a cart, oldest entry first
+type CartHistory struct {
+	Entries []AuditEntry `json:"entries"`
+}
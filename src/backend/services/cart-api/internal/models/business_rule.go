@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrBusinessRule matches every BusinessRule with errors.Is
+var ErrBusinessRule = errors.New("business rule violated")
+
+// BusinessRule is a rule of carts a request can violate, e.g. a quantity
+// cap. Violations are answered with 422 and Code, clients may rely on the
+// code while the message can change
+type BusinessRule struct {
+	Code    string
+	Message string
+}
+
+// NewBusinessRule creates rule identified by code, it is meant for sentinel
+// errors wrapped with details of the violation
+func NewBusinessRule(code, message string) *BusinessRule {
+	return &BusinessRule{Code: code, Message: message}
+}
+
+// Error implements error.
+func (e *BusinessRule) Error() string {
+	return e.Message
+}
+
+// Is makes errors.Is(err, ErrBusinessRule) true for every rule.
+func (e *BusinessRule) Is(target error) bool {
+	return target == ErrBusinessRule
+}
+
+// BusinessRuleCode returns code of the rule violated by err, empty when err
+// violates none
+func BusinessRuleCode(err error) string {
+	var rule *BusinessRule
+	if !errors.As(err, &rule) {
+		return ""
+	}
+	return rule.Code
+}
+
+// NewBusinessRuleError maps err violating a business rule to 422 with the
+// code of the rule, nil when err violates none. A status a handler already
+// chose for err is overridden so violations are answered consistently, its
+// details are kept
+func NewBusinessRuleError(err error) *HTTPError {
+	code := BusinessRuleCode(err)
+	if code == "" {
+		return nil
+	}
+	var details map[string]string
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		details = httpErr.Details
+		if httpErr.err != nil {
+			err = httpErr.err
+		}
+	}
+	ruleErr := NewHTTPError(http.StatusUnprocessableEntity, err)
+	ruleErr.ErrorCode = code
+	ruleErr.Details = details
+	return ruleErr
+}
+
+var _ error = (*BusinessRule)(nil)
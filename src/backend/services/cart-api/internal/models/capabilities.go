@@ -0,0 +1,12 @@
+package models
+
+// Capabilities lists features enabled in this deployment so clients can
+// adapt their UI, zero limits mean the limit is disabled
+type Capabilities struct {
+	PriceSource  string  `json:"price_source" example:"client"`
+	Sharing      bool    `json:"sharing"`
+	ShareTTL     int64   `json:"share_ttl_seconds" example:"86400"`
+	MaxItemPrice float64 `json:"max_item_price"`
+	MaxCartTotal float64 `json:"max_cart_total"`
+	ETA          bool    `json:"eta"`
+}
@@ -1,6 +1,12 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/google/uuid"
+)
 
 type CreateCartReq struct {
 	LineItems *[]LineItem `json:"items,omitempty"`
@@ -11,7 +17,7 @@ type UpdateCartReq struct {
 	LineItems *[]LineItem `json:"items,omitempty"`
 	UserID    *string     `json:"user_id,omitempty"`
 	Status    *string     `json:"status,omitempty"`
-	Discount  *float32    `json:"discount,omitempty"`
+	Discount  *Money      `json:"discount,omitempty" swaggertype:"number"`
 }
 
 func MapUpdateCartReqToCart(existingCart *Cart, req UpdateCartReq) *Cart {
@@ -28,6 +34,8 @@ func MapUpdateCartReqToCart(existingCart *Cart, req UpdateCartReq) *Cart {
 		ID:        existingCart.ID,
 		Status:    MapStatusStringToStatus(req.Status),
 		Discount:  req.Discount,
+		Name:      existingCart.Name,
+		Coupons:   existingCart.Coupons,
 	}
 	return cart
 }
@@ -47,15 +55,121 @@ func MapCreateCartReqToCart(req CreateCartReq) *Cart {
 	return cart
 }
 
-// LineItem
+// MoveItemReq moves line item into another cart
+type MoveItemReq struct {
+	TargetCartID string `json:"target_cart_id"`
+}
+
+// BulkAddItemsReq adds Items to the cart at once
+type BulkAddItemsReq struct {
+	Items []LineItem `json:"items"`
+}
+
+// BulkDeleteItemsReq removes items by ItemIDs from the cart at once
+type BulkDeleteItemsReq struct {
+	ItemIDs []int `json:"item_ids" example:"1,2"`
+}
+
+// BulkItemResult is an outcome of one item of a partial bulk operation,
+// Status is the http status the item would get on its own
+type BulkItemResult struct {
+	Index     int    `json:"index"`
+	ItemID    int    `json:"item_id"`
+	Status    int    `json:"status"`
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// BulkItemsResp is the multi-status body of a partial bulk operation
+type BulkItemsResp struct {
+	Results []BulkItemResult `json:"results"`
+}
+
+// CreateNamedCartReq creates empty cart kept by the user under Name
+type CreateNamedCartReq struct {
+	Name string `json:"name" example:"Weekly groceries"`
+}
+
+// TransferCartReq changes owner of the cart to UserID
+type TransferCartReq struct {
+	UserID string `json:"user_id" example:"user-42"`
+}
+
+// UserCartsResp lists carts of a user
+type UserCartsResp struct {
+	Carts []*Cart `json:"carts"`
+}
+
+// QuantityDeltaReq adds Delta to quantity of line item, negative decreases it
+type QuantityDeltaReq struct {
+	Delta int `json:"delta"`
+}
+
+// LineItem is a line of a cart. ItemID identifies the line for updates and
+// deletes, it is assigned when the item is added: by default it is the id of
+// the product, with sequential item ids lines are numbered per cart and the
+// product is kept in ProductID
 type LineItem struct {
 	ItemID             int                    `json:"item_id"`
-	UnitPrice          float32                `json:"unit_price"`
+	ProductID          int                    `json:"product_id,omitempty" example:"7"`
+	UnitPrice          Money                  `json:"unit_price" swaggertype:"number" example:"12.50"`
 	Quantity           int                    `json:"quantity"`
 	Image              string                 `json:"img"`
 	ProductName        string                 `json:"product_name"`
 	ProductDescription string                 `json:"product_description"`
 	Attributes         map[string]interface{} `json:"attributes"`
+
+	// ImageURL and DisplayName are opaque to the cart and passed through
+	// for rendering cart lines
+	ImageURL    string `json:"image_url,omitempty" example:"https://cdn.example.com/burger.png"`
+	DisplayName string `json:"display_name,omitempty" example:"Double Burger"`
+
+	// Available and AvailableQuantity are computed for responses asking for
+	// availability and never stored, AvailableQuantity is left out for
+	// products with unlimited stock
+	Available         *bool `json:"available,omitempty"`
+	AvailableQuantity *int  `json:"available_quantity,omitempty" example:"3"`
+}
+
+// Product returns id of the product of the line, lines without ProductID
+// were added with their product as ItemID
+func (i LineItem) Product() int {
+	if i.ProductID != 0 {
+		return i.ProductID
+	}
+	return i.ItemID
+}
+
+// MaxItemQuantity is the largest quantity of a line item, it keeps totals
+// of lines far from overflowing
+const MaxItemQuantity = 10000
+
+// ErrInvalidImageURL is returned for line items with malformed ImageURL
+var ErrInvalidImageURL = errors.New("must be an absolute http or https url")
+
+// Validate checks quantity, price and optional display metadata of the
+// item, every invalid field is reported in ValidationErrors
+func (i LineItem) Validate() error {
+	var errs ValidationErrors
+	if i.Quantity < 0 {
+		errs = append(errs, NewFieldError("quantity", ErrNegative))
+	}
+	if i.Quantity > MaxItemQuantity {
+		errs = append(errs, NewFieldError("quantity", ErrQuantityTooLarge))
+	}
+	if i.UnitPrice.Minor < 0 {
+		errs = append(errs, NewFieldError("unit_price", ErrNegative))
+	}
+	if i.ImageURL != "" {
+		u, err := url.Parse(i.ImageURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, NewFieldError("image_url", ErrInvalidImageURL))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
 // Status Enum
@@ -95,15 +209,165 @@ func MapStatusStringToStatus(status *string) Status {
 type Cart struct {
 	ID        uuid.UUID  `json:"id"`
 	LineItems []LineItem `json:"items"`
-	Total     float64    `json:"total"`
-
-	UserID         *string  `json:"user_id,omitempty"`
-	Discount       *float32 `json:"discount,omitempty"`
-	Tax            *float32 `json:"tax,omitempty"`
-	Shipping       *float32 `json:"shipping,omitempty"`
-	ShippingMethod *string  `json:"shipping_method,omitempty"`
-	Currency       *string  `json:"currency,omitempty"`
-	Status         Status   `json:"status,omitempty"`
-	OrderID        *string  `json:"order_id,omitempty"`
-	TransactionID  *string  `json:"transaction_id,omitempty"`
+	Total     Money      `json:"total" swaggertype:"number"`
+
+	UserID         *string `json:"user_id,omitempty"`
+	Discount       *Money  `json:"discount,omitempty" swaggertype:"number"`
+	Tax            *Money  `json:"tax,omitempty" swaggertype:"number"`
+	Shipping       *Money  `json:"shipping,omitempty" swaggertype:"number"`
+	ShippingMethod *string `json:"shipping_method,omitempty"`
+	Currency       *string `json:"currency,omitempty"`
+	Status         Status  `json:"status,omitempty"`
+	OrderID        *string `json:"order_id,omitempty"`
+	TransactionID  *string `json:"transaction_id,omitempty"`
+
+	// Name is set for named lists a user keeps besides the active cart
+	Name string `json:"name,omitempty"`
+
+	// Coupons are codes of coupons applied to the cart in the order they
+	// were applied
+	Coupons []string `json:"coupons,omitempty"`
+
+	// Breakdown is computed for responses and never stored
+	Breakdown *TotalBreakdown `json:"breakdown,omitempty"`
+	// Discounts of applied coupons in the order they discount the cart, they
+	// are computed for responses and never stored
+	Discounts []AppliedDiscount `json:"discounts,omitempty"`
+}
+
+// CurrencyOf returns the currency of amounts of the cart, Currency or else
+// the currency any of its amounts names
+func (c *Cart) CurrencyOf() string {
+	if c.Currency != nil {
+		return *c.Currency
+	}
+	for _, m := range c.amounts() {
+		if m.Currency != "" {
+			return m.Currency
+		}
+	}
+	return ""
+}
+
+// WithCurrency returns the cart with Currency set to CurrencyOf, amounts are
+// written to JSON as numbers not naming their currency so carts are stored
+// with it. The cart is copied when currency is set
+func (c *Cart) WithCurrency() *Cart {
+	currency := c.CurrencyOf()
+	if c.Currency != nil || currency == "" {
+		return c
+	}
+	copied := *c
+	copied.Currency = &currency
+	return &copied
+}
+
+// amounts returns every amount of the cart
+func (c *Cart) amounts() []*Money {
+	amounts := []*Money{&c.Total}
+	for _, m := range []*Money{c.Discount, c.Tax, c.Shipping} {
+		if m != nil {
+			amounts = append(amounts, m)
+		}
+	}
+	for i := range c.LineItems {
+		amounts = append(amounts, &c.LineItems[i].UnitPrice)
+	}
+	if c.Breakdown != nil {
+		amounts = append(amounts, &c.Breakdown.Subtotal, &c.Breakdown.Total)
+		for i := range c.Breakdown.Fees {
+			amounts = append(amounts, &c.Breakdown.Fees[i].Amount)
+		}
+	}
+	for i := range c.Discounts {
+		amounts = append(amounts, &c.Discounts[i].Amount, &c.Discounts[i].Total)
+	}
+	return amounts
+}
+
+// cartAmounts are the amounts of a cart as they were written
+type cartAmounts struct {
+	Total    json.RawMessage `json:"total"`
+	Discount json.RawMessage `json:"discount"`
+	Tax      json.RawMessage `json:"tax"`
+	Shipping json.RawMessage `json:"shipping"`
+	Items    []struct {
+		UnitPrice json.RawMessage `json:"unit_price"`
+	} `json:"items"`
+	Breakdown *struct {
+		Subtotal json.RawMessage `json:"subtotal"`
+		Discount json.RawMessage `json:"discount"`
+		Total    json.RawMessage `json:"total"`
+		Fees     []struct {
+			Amount json.RawMessage `json:"amount"`
+		} `json:"fees"`
+	} `json:"breakdown"`
+	Discounts []struct {
+		Amount json.RawMessage `json:"amount"`
+		Total  json.RawMessage `json:"total"`
+	} `json:"discounts"`
+}
+
+// UnmarshalJSON reads amounts not naming their currency in the currency of
+// the cart, e.g. 1200 of a JPY cart is 1200 yen rather than 12.00. Carts
+// of older versions named it only in amounts, e.g. "12.34 EUR", it is set
+// as currency of such carts
+func (c *Cart) UnmarshalJSON(data []byte) error {
+	type plain Cart
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	currency := c.CurrencyOf()
+	if currency == "" {
+		return nil
+	}
+	c.Currency = &currency
+
+	var raw cartAmounts
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	read := func(m *Money, data json.RawMessage) error {
+		if m == nil || len(data) == 0 {
+			return nil
+		}
+		return m.unmarshalIn(data, currency)
+	}
+	errs := []error{read(&c.Total, raw.Total), read(c.Discount, raw.Discount), read(c.Tax, raw.Tax), read(c.Shipping, raw.Shipping)}
+	for i := range raw.Items {
+		errs = append(errs, read(&c.LineItems[i].UnitPrice, raw.Items[i].UnitPrice))
+	}
+	if c.Breakdown != nil && raw.Breakdown != nil {
+		errs = append(errs, read(&c.Breakdown.Subtotal, raw.Breakdown.Subtotal), read(&c.Breakdown.Discount, raw.Breakdown.Discount), read(&c.Breakdown.Total, raw.Breakdown.Total))
+		for i := range raw.Breakdown.Fees {
+			errs = append(errs, read(&c.Breakdown.Fees[i].Amount, raw.Breakdown.Fees[i].Amount))
+		}
+	}
+	for i := range raw.Discounts {
+		errs = append(errs, read(&c.Discounts[i].Amount, raw.Discounts[i].Amount), read(&c.Discounts[i].Total, raw.Discounts[i].Total))
+	}
+	return errors.Join(errs...)
+}
+
+// Codes of fees charged on top of line items
+const (
+	FeeShipping  = "shipping"
+	FeeService   = "service"
+	FeePackaging = "packaging"
+)
+
+// Fee is an itemized charge on top of line items
+type Fee struct {
+	Code   string `json:"code" example:"shipping"`
+	Amount Money  `json:"amount" swaggertype:"number" example:"4.99"`
+}
+
+// TotalBreakdown itemizes what the cart costs, Total of the cart covers
+// Subtotal only. Total is Subtotal less Discount of applied coupons plus
+// Fees, the last of Discounts of the cart ends at the same total
+type TotalBreakdown struct {
+	Subtotal Money `json:"subtotal" swaggertype:"number"`
+	Discount Money `json:"discount" swaggertype:"number"`
+	Fees     []Fee `json:"fees"`
+	Total    Money `json:"total" swaggertype:"number"`
 }
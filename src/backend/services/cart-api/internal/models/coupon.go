@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Coupon describes a discount applicable to carts
+type Coupon struct {
+	Code string `json:"code" example:"SPRING10"`
+	// PercentOff and AmountOff are summed when both are set
+	PercentOff float64 `json:"percent_off,omitempty" example:"10"`
+	AmountOff  Money   `json:"amount_off,omitempty" swaggertype:"number" example:"5.00"`
+	// MinSpend is a minimal subtotal of the cart, zero disables the check
+	MinSpend Money `json:"min_spend,omitempty" swaggertype:"number" example:"30.00"`
+	// Currency is the currency of AmountOff and MinSpend, coupons having
+	// either apply only to carts in the currency
+	Currency string `json:"currency,omitempty" example:"EUR"`
+	// ProductIDs limits the coupon to items of the products, empty applies
+	// it to the whole cart
+	ProductIDs []int      `json:"product_ids,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+
+	// Exclusive coupons are never combined with other coupons of the cart.
+	// Coupons sharing StackGroup don't stack either, the group defaults to
+	// the kind of discount so a cart takes one percentage and one fixed
+	// amount coupon
+	Exclusive  bool   `json:"exclusive,omitempty"`
+	StackGroup string `json:"stack_group,omitempty" example:"seasonal"`
+	// Priority orders stacked coupons, higher priority discounts first and
+	// later coupons discount what is left
+	Priority int `json:"priority,omitempty" example:"10"`
+}
+
+// UnmarshalJSON reads amounts not naming their currency in the currency of
+// the coupon like Cart.UnmarshalJSON, a coupon without currency takes the
+// one its amounts name
+func (c *Coupon) UnmarshalJSON(data []byte) error {
+	type plain Coupon
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	c.Currency = strings.ToUpper(c.Currency)
+	for _, m := range []Money{c.AmountOff, c.MinSpend} {
+		if c.Currency == "" {
+			c.Currency = m.Currency
+		}
+	}
+	if c.Currency == "" {
+		return nil
+	}
+
+	var raw struct {
+		AmountOff json.RawMessage `json:"amount_off"`
+		MinSpend  json.RawMessage `json:"min_spend"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	read := func(m *Money, data json.RawMessage) error {
+		if len(data) == 0 {
+			m.Currency = c.Currency
+			return nil
+		}
+		return m.unmarshalIn(data, c.Currency)
+	}
+	return errors.Join(read(&c.AmountOff, raw.AmountOff), read(&c.MinSpend, raw.MinSpend))
+}
+
+// ApplyCouponReq applies coupon by Code to the cart
+type ApplyCouponReq struct {
+	Code string `json:"code" example:"SPRING10"`
+}
+
+// AppliedDiscount is what a coupon applied to the cart takes off, Total is
+// the total after the discount and those applied before it, fees of the
+// breakdown included. Coupons giving no discount, e.g. once expired, have
+// the Reason instead
+type AppliedDiscount struct {
+	Code   string `json:"code" example:"SPRING10"`
+	Amount Money  `json:"amount" swaggertype:"number" example:"4.50"`
+	Total  Money  `json:"total" swaggertype:"number" example:"40.50"`
+	Reason string `json:"reason,omitempty" example:"coupon expired"`
+}
+
+// CouponValidationResp is the discount a coupon would give to a cart
+type CouponValidationResp struct {
+	Code     string `json:"code" example:"SPRING10"`
+	CartID   string `json:"cart_id" example:"5b1e4a5e-8f0c-4a7b-9a53-3c4a0d2b7f11"`
+	Valid    bool   `json:"valid" example:"true"`
+	Reason   string `json:"reason,omitempty" example:"coupon expired"`
+	Discount Money  `json:"discount" swaggertype:"number" example:"4.50"`
+	Total    Money  `json:"total" swaggertype:"number" example:"40.50"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Diagnostics summarizes health of dependencies
+type Diagnostics struct {
+	Healthy bool              `json:"healthy"`
+	Redis   DependencyHealth  `json:"redis"`
+	Kafka   ConsumerLagHealth `json:"kafka"`
+
+	// LastOrderCompletedAt is time the last OrderCompleted event was handled
+	LastOrderCompletedAt *time.Time `json:"last_order_completed_at,omitempty"`
+
+	// ArchivedCarts is the number of completed and cancelled carts kept for
+	// the archive retention, swept ones are not counted
+	ArchivedCarts int64 `json:"archived_carts"`
+}
+
+// DependencyHealth is result of a single dependency check
+type DependencyHealth struct {
+	Healthy   bool    `json:"healthy"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ConsumerLagHealth is lag of the orders consumer group, lag grows while
+// the consumer is paused by an operator
+type ConsumerLagHealth struct {
+	Healthy bool            `json:"healthy"`
+	Paused  bool            `json:"paused"`
+	Lag     int64           `json:"lag"`
+	Error   string          `json:"error,omitempty"`
+	Details map[int32]int64 `json:"partitions,omitempty"`
+}
+
+// ConsumerState is state of the orders consumer
+type ConsumerState struct {
+	Paused bool `json:"paused"`
+}
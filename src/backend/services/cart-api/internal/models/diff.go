@@ -0,0 +1,64 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+)
+
+// ETag returns strong entity tag of the cart, equal carts have equal tags
+func ETag(cart *Cart) string {
+	data, _ := json.Marshal(cart)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// LineItemChange is an item present in both versions with different fields
+type LineItemChange struct {
+	ItemID int      `json:"item_id" example:"1"`
+	Before LineItem `json:"before"`
+	After  LineItem `json:"after"`
+}
+
+// CartDiff is a difference of line items between two versions of a cart
+type CartDiff struct {
+	SinceETag string           `json:"since_etag" example:"\"5d41402abc4b2a76b9719d911017c592\""`
+	ETag      string           `json:"etag" example:"\"7d793037a0760186574b0282f2f435e7\""`
+	Added     []LineItem       `json:"added"`
+	Removed   []LineItem       `json:"removed"`
+	Changed   []LineItemChange `json:"changed"`
+}
+
+// DiffCarts returns items added, removed and changed from before to after,
+// items are listed in order of the cart they come from
+func DiffCarts(before, after *Cart) CartDiff {
+	diff := CartDiff{
+		SinceETag: ETag(before),
+		ETag:      ETag(after),
+		Added:     []LineItem{},
+		Removed:   []LineItem{},
+		Changed:   []LineItemChange{},
+	}
+	previous := make(map[int]LineItem, len(before.LineItems))
+	for _, item := range before.LineItems {
+		previous[item.ItemID] = item
+	}
+	current := make(map[int]bool, len(after.LineItems))
+	for _, item := range after.LineItems {
+		current[item.ItemID] = true
+		old, ok := previous[item.ItemID]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, item)
+		case !reflect.DeepEqual(old, item):
+			diff.Changed = append(diff.Changed, LineItemChange{ItemID: item.ItemID, Before: old, After: item})
+		}
+	}
+	for _, item := range before.LineItems {
+		if !current[item.ItemID] {
+			diff.Removed = append(diff.Removed, item)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Envelope wraps successful responses for clients asking for it
+type Envelope struct {
+	Data interface{}  `json:"data"`
+	Meta EnvelopeMeta `json:"meta"`
+}
+
+// EnvelopeMeta describes the request the response was produced for
+type EnvelopeMeta struct {
+	RequestID  string    `json:"request_id" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+	ServerTime time.Time `json:"server_time"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// CartETA estimates an order of the cart placed now, minutes are rounded up
+type CartETA struct {
+	PreparationMinutes int       `json:"preparation_minutes" example:"15"`
+	DeliveryMinutes    int       `json:"delivery_minutes" example:"20"`
+	TotalMinutes       int       `json:"total_minutes" example:"35"`
+	ArrivesAt          time.Time `json:"arrives_at"`
+}
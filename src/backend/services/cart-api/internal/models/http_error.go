@@ -1,12 +1,17 @@
 package models
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
 
 // NewHTTPError creates new http error using Golang error
 func NewHTTPError(status int, err error) *HTTPError {
 	er := HTTPError{
 		Code:    status,
 		Message: err.Error(),
+		err:     err,
 	}
 	return &er
 }
@@ -15,6 +20,15 @@ func NewHTTPError(status int, err error) *HTTPError {
 type HTTPError struct {
 	Code    int    `json:"code" example:"400"`
 	Message string `json:"message" example:"status bad request"`
+	// ErrorCode identifies the violated business rule of 422 errors
+	ErrorCode string `json:"error_code,omitempty" example:"item_quantity_exceeded"`
+	// Details are facts about the error clients can act on, e.g. the amount
+	// missing to a minimum order value
+	Details map[string]string `json:"details,omitempty"`
+	// Errors are every invalid field of a 400 caused by validation
+	Errors []FieldError `json:"errors,omitempty"`
+
+	err error
 }
 
 // Error implements error.
@@ -22,4 +36,56 @@ func (e *HTTPError) Error() string {
 	return fmt.Sprintf("code: %v message:%v", e.Code, e.Message)
 }
 
+// Unwrap returns the error HTTPError was created from.
+func (e *HTTPError) Unwrap() error {
+	return e.err
+}
+
+// MarshalJSON writes the error as {"code": ..., "message": ...}, the wrapped
+// error is never exposed
+func (e *HTTPError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code      int               `json:"code"`
+		Message   string            `json:"message"`
+		ErrorCode string            `json:"error_code,omitempty"`
+		Details   map[string]string `json:"details,omitempty"`
+		Errors    []FieldError      `json:"errors,omitempty"`
+	}{e.Code, e.Message, e.ErrorCode, e.Details, e.Errors})
+}
+
+// ProblemDetails is an RFC 7807 representation of an error
+type ProblemDetails struct {
+	Type   string `json:"type" example:"about:blank"`
+	Title  string `json:"title" example:"Bad Request"`
+	Status int    `json:"status" example:"400"`
+	Detail string `json:"detail" example:"status bad request"`
+	// ErrorCode, Details and Errors are extension members, see HTTPError
+	ErrorCode string            `json:"error_code,omitempty" example:"item_quantity_exceeded"`
+	Details   map[string]string `json:"details,omitempty"`
+	Errors    []FieldError      `json:"errors,omitempty"`
+}
+
+// Problem converts the error to problem details, errors carry no specific
+// problem type so about:blank is used with the status text as title
+func (e *HTTPError) Problem() ProblemDetails {
+	return ProblemDetails{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Code),
+		Status:    e.Code,
+		Detail:    e.Message,
+		ErrorCode: e.ErrorCode,
+		Details:   e.Details,
+		Errors:    e.Errors,
+	}
+}
+
+// NewValidationError answers failed validations with 400 listing every
+// invalid field
+func NewValidationError(errs ValidationErrors) *HTTPError {
+	httpErr := NewHTTPError(http.StatusBadRequest, errs)
+	httpErr.Errors = errs
+	return httpErr
+}
+
 var _ error = (*HTTPError)(nil)
+var _ json.Marshaler = (*HTTPError)(nil)
@@ -0,0 +1,289 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidMoney is returned for amounts which can't be parsed as Money
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// ErrMoneyOverflow is returned when an amount doesn't fit in minor units,
+// e.g. a price multiplied by a huge quantity
+var ErrMoneyOverflow error = NewBusinessRule("amount_overflow", "amount is too large")
+
+// ErrPriceNotFound is returned by price lookups when product is unknown
+var ErrPriceNotFound = errors.New("price not found")
+
+// Money is an exact amount in minor units of Currency, e.g. cents, so sums
+// of prices, taxes and discounts don't drift like floats do. Empty Currency
+// is the currency of the cart.
+//
+// Money is written to JSON as a number of major units, e.g. 12.34, carts
+// name the currency in their own field. It reads numbers as well as decimal
+// strings optionally followed by the currency, e.g. "12.34 EUR"
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// minorDigits are digits of minor units of currencies not having two
+var minorDigits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "PYG": 0, "UGX": 0, "VND": 0,
+}
+
+// digits returns number of decimal digits of minor units of the currency
+func digits(currency string) int {
+	if d, ok := minorDigits[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// FromMajor converts amount in major units to Money, rounding half away
+// from zero to minor units. It is meant for boundaries still using floats
+func FromMajor(amount float64, currency string) Money {
+	return Money{Minor: int64(math.Round(amount * float64(pow10(digits(currency))))), Currency: currency}
+}
+
+// In returns m in minor units of currency, e.g. a limit or fee configured
+// without currency applied to a cart in JPY. Only the digits of minor units
+// change, the amount is not exchanged. Extra digits are rounded half away
+// from zero and scaling up saturates at the bounds of int64
+func (m Money) In(currency string) Money {
+	from, to := digits(m.Currency), digits(currency)
+	minor := m.Minor
+	switch {
+	case to > from:
+		scaled, ok := mulInt64(minor, pow10(to-from))
+		if !ok {
+			scaled = math.MaxInt64
+			if minor < 0 {
+				scaled = math.MinInt64
+			}
+		}
+		minor = scaled
+	case to < from:
+		p := pow10(from - to)
+		rest := minor % p
+		minor /= p
+		if rest >= (p+1)/2 {
+			minor++
+		} else if -rest >= (p+1)/2 {
+			minor--
+		}
+	}
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseMoney parses decimal amount of major units optionally followed by
+// the currency, e.g. "12.34" or "-0.5 EUR". Amounts more precise than minor
+// units of the currency are rejected instead of being rounded
+func ParseMoney(s string) (Money, error) {
+	amount, currency, _ := strings.Cut(strings.TrimSpace(s), " ")
+	m := Money{Currency: strings.ToUpper(strings.TrimSpace(currency))}
+
+	negative := strings.HasPrefix(amount, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(amount, "-"), ".")
+	d := digits(m.Currency)
+	if whole == "" || len(fraction) > d || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	fraction += strings.Repeat("0", d-len(fraction))
+
+	// len(whole) < 16 keeps minor units of any currency within int64
+	if len(whole) > 15 {
+		return Money{}, fmt.Errorf("%w: %q is too large", ErrInvalidMoney, s)
+	}
+	units, _ := strconv.ParseInt(whole+fraction, 10, 64)
+	if negative {
+		units = -units
+	}
+	m.Minor = units
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Decimal formats the amount in major units without the currency
+func (m Money) Decimal() string {
+	d := digits(m.Currency)
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	if d == 0 {
+		return sign + strconv.FormatInt(minor, 10)
+	}
+	p := pow10(d)
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/p, d, minor%p)
+}
+
+// String formats the amount in major units followed by the currency if any
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// Major returns the amount in major units for boundaries still using floats
+func (m Money) Major() float64 {
+	return float64(m.Minor) / float64(pow10(digits(m.Currency)))
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Minor == 0
+}
+
+// Add returns m + o, amounts are expected to be in the same currency and
+// the currency of m is kept unless it is empty
+func (m Money) Add(o Money) Money {
+	return Money{Minor: m.Minor + o.Minor, Currency: m.currencyWith(o)}
+}
+
+// Sub returns m - o in the currency of Add
+func (m Money) Sub(o Money) Money {
+	return Money{Minor: m.Minor - o.Minor, Currency: m.currencyWith(o)}
+}
+
+func (m Money) currencyWith(o Money) string {
+	if m.Currency == "" {
+		return o.Currency
+	}
+	return m.Currency
+}
+
+// Mul returns m multiplied by n, e.g. unit price by quantity, or
+// ErrMoneyOverflow when the product doesn't fit in minor units
+func (m Money) Mul(n int) (Money, error) {
+	product, ok := mulInt64(m.Minor, int64(n))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s times %d", ErrMoneyOverflow, m, n)
+	}
+	return Money{Minor: product, Currency: m.Currency}, nil
+}
+
+// Percent returns p percent of m, p is taken to hundredths of a percent and
+// the result is rounded half away from zero to minor units. It returns
+// ErrMoneyOverflow when the result doesn't fit in minor units
+func (m Money) Percent(p float64) (Money, error) {
+	bp := math.Round(p * 100)
+	if math.IsNaN(bp) || math.Abs(bp) >= math.MaxInt64 {
+		return Money{}, fmt.Errorf("%w: %v percent of %s", ErrMoneyOverflow, p, m)
+	}
+	product, ok := mulInt64(m.Minor, int64(bp))
+	half := int64(5000)
+	if product < 0 {
+		half = -half
+	}
+	if !ok || (half > 0 && product > math.MaxInt64-half) || (half < 0 && product < math.MinInt64-half) {
+		return Money{}, fmt.Errorf("%w: %v percent of %s", ErrMoneyOverflow, p, m)
+	}
+	return Money{Minor: (product + half) / 10000, Currency: m.Currency}, nil
+}
+
+// Sum returns the total of amounts like Add, or ErrMoneyOverflow when it
+// doesn't fit in minor units
+func Sum(amounts ...Money) (Money, error) {
+	var total Money
+	for _, m := range amounts {
+		sum := total.Add(m)
+		if (m.Minor > 0 && sum.Minor < total.Minor) || (m.Minor < 0 && sum.Minor > total.Minor) {
+			return Money{}, fmt.Errorf("%w: sum above %s", ErrMoneyOverflow, total)
+		}
+		total = sum
+	}
+	return total, nil
+}
+
+// mulInt64 returns a * b, ok is false when it overflows
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return product, true
+}
+
+// Min returns the smaller of m and o
+func (m Money) Min(o Money) Money {
+	if o.Minor < m.Minor {
+		return o
+	}
+	return m
+}
+
+// MarshalJSON writes m as a number of major units, e.g. 12.34. The number
+// is formatted from minor units so it is as exact as m
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON reads decimal strings and numbers of major units. Numbers
+// are parsed from their literal so no precision is lost, numbers more
+// precise than minor units, e.g. totals summed as floats by older versions,
+// are rounded
+func (m *Money) UnmarshalJSON(data []byte) error {
+	return m.unmarshalIn(data, "")
+}
+
+// unmarshalIn is UnmarshalJSON reading amounts which don't name their
+// currency in currency, e.g. amounts of a cart in the currency of the cart
+func (m *Money) unmarshalIn(data []byte, currency string) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	amount := string(data)
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &amount); err != nil {
+			return err
+		}
+	}
+	withCurrency := amount
+	if currency != "" && !strings.Contains(strings.TrimSpace(amount), " ") {
+		withCurrency += " " + currency
+	}
+	parsed, err := ParseMoney(withCurrency)
+	if err == nil {
+		*m = parsed
+		return nil
+	}
+	if data[0] == '"' {
+		return err
+	}
+	f, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMoney, data)
+	}
+	*m = FromMajor(f, currency)
+	return nil
+}
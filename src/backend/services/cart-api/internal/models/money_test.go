@@ -0,0 +1,277 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"12.34", Money{Minor: 1234}},
+		{"12.3", Money{Minor: 1230}},
+		{"12", Money{Minor: 1200}},
+		{"0.05", Money{Minor: 5}},
+		{"-0.5", Money{Minor: -50}},
+		{"12.34 eur", Money{Minor: 1234, Currency: "EUR"}},
+		{"1.234 KWD", Money{Minor: 1234, Currency: "KWD"}},
+		{"1500 JPY", Money{Minor: 1500, Currency: "JPY"}},
+		{"999999999999999.99", Money{Minor: 99999999999999999}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMoney(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, in := range []string{"", "abc", "1.2.3", "1.234", "1.5 JPY", ".5", "--1", "1e3", "+1", "1000000000000000"} {
+		t.Run("invalid "+in, func(t *testing.T) {
+			_, err := ParseMoney(in)
+			assert.ErrorIs(t, err, ErrInvalidMoney)
+		})
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	assert.Equal(t, "12.34", Money{Minor: 1234}.String())
+	assert.Equal(t, "0.05", Money{Minor: 5}.String())
+	assert.Equal(t, "-0.05", Money{Minor: -5}.String())
+	assert.Equal(t, "12.34 EUR", Money{Minor: 1234, Currency: "EUR"}.String())
+	assert.Equal(t, "1.234", Money{Minor: 1234, Currency: "KWD"}.Decimal())
+	assert.Equal(t, "1500", Money{Minor: 1500, Currency: "JPY"}.Decimal())
+	assert.Equal(t, 12.34, Money{Minor: 1234}.Major())
+}
+
+func TestMoneySummationDoesNotDrift(t *testing.T) {
+	t.Run("0.1 + 0.2 is 0.3", func(t *testing.T) {
+		a, b := FromMajor(0.1, ""), FromMajor(0.2, "")
+		assert.Equal(t, Money{Minor: 30}, a.Add(b))
+		assert.Equal(t, "0.30", a.Add(b).String())
+	})
+
+	t.Run("many small amounts", func(t *testing.T) {
+		var total Money
+		var floats float32
+		for i := 0; i < 10000; i++ {
+			total = total.Add(Money{Minor: 1})
+			floats += 0.01
+		}
+		assert.Equal(t, Money{Minor: 10000}, total)
+		assert.NotEqual(t, float32(100), floats, "floats drift which Money must not do")
+	})
+
+	t.Run("line totals", func(t *testing.T) {
+		price := Money{Minor: 1999}
+		assert.Equal(t, Money{Minor: 5997}, must(t)(price.Mul(3)))
+		assert.Equal(t, Money{Minor: 1999 * 1000000}, must(t)(price.Mul(1000000)))
+	})
+
+	t.Run("overflow should be rejected", func(t *testing.T) {
+		_, err := Money{Minor: math.MaxInt64 / 2}.Mul(3)
+		assert.ErrorIs(t, err, ErrMoneyOverflow)
+		assert.ErrorIs(t, err, ErrBusinessRule)
+		_, err = Money{Minor: math.MinInt64}.Mul(-1)
+		assert.ErrorIs(t, err, ErrMoneyOverflow)
+		_, err = Money{Minor: math.MaxInt64 / 100}.Percent(200)
+		assert.ErrorIs(t, err, ErrMoneyOverflow)
+		_, err = Money{Minor: 100}.Percent(math.Inf(1))
+		assert.ErrorIs(t, err, ErrMoneyOverflow)
+		_, err = Sum(Money{Minor: math.MaxInt64}, Money{Minor: 1})
+		assert.ErrorIs(t, err, ErrMoneyOverflow)
+
+		sum, err := Sum(Money{Minor: math.MaxInt64}, Money{Minor: -1}, Money{Minor: 1})
+		require.NoError(t, err)
+		assert.Equal(t, Money{Minor: math.MaxInt64}, sum)
+	})
+
+	t.Run("subtraction returns to the start", func(t *testing.T) {
+		start := Money{Minor: 1000}
+		m := start
+		for i := 0; i < 1000; i++ {
+			m = m.Add(Money{Minor: 7}).Sub(Money{Minor: 7})
+		}
+		assert.Equal(t, start, m)
+	})
+
+	t.Run("currency is kept", func(t *testing.T) {
+		assert.Equal(t, "EUR", Money{}.Add(Money{Minor: 1, Currency: "EUR"}).Currency)
+		assert.Equal(t, "EUR", Money{Minor: 1, Currency: "EUR"}.Sub(Money{Minor: 1}).Currency)
+	})
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		percent float64
+		want    int64
+	}{
+		{"tax", 1999, 20, 400},
+		{"tax with fraction of a percent", 10000, 8.875, 888},
+		{"discount", 2500, 10, 250},
+		{"half cent rounds up", 2500, 7.5, 188},
+		{"below half cent rounds down", 1001, 10, 100},
+		{"negative rounds away from zero", -2500, 7.5, -188},
+		{"zero percent", 1234, 0, 0},
+		{"full amount", 1234, 100, 1234},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Money{Minor: tt.want}, must(t)(Money{Minor: tt.amount}.Percent(tt.percent)))
+		})
+	}
+
+	t.Run("tax of the sum equals sum of exact line taxes", func(t *testing.T) {
+		// 3 x 0.10 taxed at 10% is 0.03 whether the total or each line is
+		// taxed since no line rounds
+		var total, tax Money
+		for i := 0; i < 3; i++ {
+			line := Money{Minor: 10}
+			total = total.Add(line)
+			tax = tax.Add(must(t)(line.Percent(10)))
+		}
+		assert.Equal(t, tax, must(t)(total.Percent(10)))
+	})
+
+	t.Run("discounted total with tax", func(t *testing.T) {
+		subtotal := must(t)(Money{Minor: 1999}.Mul(3))
+		discounted := subtotal.Sub(must(t)(subtotal.Percent(15)))
+		total := discounted.Add(must(t)(discounted.Percent(8.25)))
+		assert.Equal(t, Money{Minor: 5097}, discounted)
+		assert.Equal(t, Money{Minor: 5518}, total)
+	})
+}
+
+func TestMoneyMin(t *testing.T) {
+	assert.Equal(t, Money{Minor: 1}, Money{Minor: 1}.Min(Money{Minor: 2}))
+	assert.Equal(t, Money{Minor: 1}, Money{Minor: 2}.Min(Money{Minor: 1}))
+}
+
+func TestFromMajor(t *testing.T) {
+	assert.Equal(t, Money{Minor: 1299}, FromMajor(float64(float32(12.99)), ""))
+	assert.Equal(t, Money{Minor: 30}, FromMajor(0.1+0.2, ""))
+	assert.Equal(t, Money{Minor: -5}, FromMajor(-0.045, ""))
+	assert.Equal(t, Money{Minor: 1500, Currency: "JPY"}, FromMajor(1500, "JPY"))
+}
+
+func TestMoneyIn(t *testing.T) {
+	assert.Equal(t, Money{Minor: 5, Currency: "JPY"}, Money{Minor: 499}.In("JPY"))
+	assert.Equal(t, Money{Minor: -5, Currency: "JPY"}, Money{Minor: -450}.In("JPY"))
+	assert.Equal(t, Money{Minor: 4, Currency: "JPY"}, Money{Minor: 449}.In("JPY"))
+	assert.Equal(t, Money{Minor: 4990, Currency: "KWD"}, Money{Minor: 499}.In("KWD"))
+	assert.Equal(t, Money{Minor: 499, Currency: "EUR"}, Money{Minor: 499}.In("EUR"))
+	assert.Equal(t, Money{Minor: math.MaxInt64, Currency: "KWD"}, Money{Minor: math.MaxInt64 / 2}.In("KWD"))
+}
+
+func TestMoneyJSON(t *testing.T) {
+	t.Run("should be written as number", func(t *testing.T) {
+		data, err := json.Marshal(LineItem{UnitPrice: Money{Minor: 1250}})
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"unit_price":12.50`)
+
+		data, err = json.Marshal(Money{Minor: 1200, Currency: "JPY"})
+		require.NoError(t, err)
+		assert.Equal(t, `1200`, string(data))
+	})
+
+	t.Run("should round-trip", func(t *testing.T) {
+		for _, m := range []Money{{Minor: 1234}, {Minor: -1}, {Minor: 5}} {
+			data, err := json.Marshal(m)
+			require.NoError(t, err)
+			var got Money
+			require.NoError(t, json.Unmarshal(data, &got))
+			assert.Equal(t, m, got)
+		}
+	})
+
+	t.Run("should read strings with currency", func(t *testing.T) {
+		tests := map[string]Money{`"12.34"`: {Minor: 1234}, `"12.34 EUR"`: {Minor: 1234, Currency: "EUR"}, `"1200 JPY"`: {Minor: 1200, Currency: "JPY"}}
+		for in, want := range tests {
+			var got Money
+			require.NoError(t, json.Unmarshal([]byte(in), &got), in)
+			assert.Equal(t, want, got, in)
+		}
+	})
+
+	t.Run("should read numbers", func(t *testing.T) {
+		tests := map[string]int64{`12.5`: 1250, `12`: 1200, `0.30000000000000004`: 30, `38.970001220703125`: 3897}
+		for in, want := range tests {
+			var got Money
+			require.NoError(t, json.Unmarshal([]byte(in), &got), in)
+			assert.Equal(t, Money{Minor: want}, got, in)
+		}
+	})
+
+	t.Run("null should be left as is", func(t *testing.T) {
+		var item struct {
+			Discount *Money `json:"discount"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(`{"discount":null}`), &item))
+		assert.Nil(t, item.Discount)
+	})
+
+	t.Run("invalid strings should be rejected", func(t *testing.T) {
+		var got Money
+		assert.ErrorIs(t, json.Unmarshal([]byte(`"12.345"`), &got), ErrInvalidMoney)
+		assert.ErrorIs(t, json.Unmarshal([]byte(`"abc"`), &got), ErrInvalidMoney)
+		assert.Error(t, json.Unmarshal([]byte(`true`), &got))
+	})
+}
+
+func TestCartJSON(t *testing.T) {
+	jpy := "JPY"
+
+	t.Run("amounts should be read in the currency of the cart", func(t *testing.T) {
+		var cart Cart
+		require.NoError(t, json.Unmarshal([]byte(`{"currency":"JPY","total":1200,"items":[{"item_id":1,"unit_price":"600","quantity":2}],"discount":"100 JPY"}`), &cart))
+		assert.Equal(t, Money{Minor: 1200, Currency: "JPY"}, cart.Total)
+		assert.Equal(t, Money{Minor: 600, Currency: "JPY"}, cart.LineItems[0].UnitPrice)
+		assert.Equal(t, &Money{Minor: 100, Currency: "JPY"}, cart.Discount)
+	})
+
+	t.Run("should round-trip with currency", func(t *testing.T) {
+		cart := Cart{Currency: &jpy, Total: Money{Minor: 1200, Currency: "JPY"}, LineItems: []LineItem{{ItemID: 1, UnitPrice: Money{Minor: 600, Currency: "JPY"}, Quantity: 2}}}
+		data, err := json.Marshal(cart)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"total":1200`)
+		assert.Contains(t, string(data), `"currency":"JPY"`)
+
+		var got Cart
+		require.NoError(t, json.Unmarshal(data, &got))
+		assert.Equal(t, cart, got)
+	})
+
+	t.Run("currency of older carts should be taken from amounts", func(t *testing.T) {
+		var cart Cart
+		require.NoError(t, json.Unmarshal([]byte(`{"total":"12.34 EUR","items":[{"item_id":1,"unit_price":"12.34 EUR","quantity":1}]}`), &cart))
+		require.NotNil(t, cart.Currency)
+		assert.Equal(t, "EUR", *cart.Currency)
+		assert.Equal(t, Money{Minor: 1234, Currency: "EUR"}, cart.Total)
+	})
+
+	t.Run("with currency should name the currency of amounts", func(t *testing.T) {
+		cart := &Cart{Total: Money{Minor: 1200, Currency: "JPY"}}
+		named := cart.WithCurrency()
+		require.NotNil(t, named.Currency)
+		assert.Equal(t, "JPY", *named.Currency)
+		assert.Nil(t, cart.Currency, "cart should be copied")
+		assert.Same(t, named, named.WithCurrency())
+	})
+}
+
+// must fails the test when an amount can't be computed
+func must(t *testing.T) func(Money, error) Money {
+	return func(m Money, err error) Money {
+		t.Helper()
+		require.NoError(t, err)
+		return m
+	}
+}
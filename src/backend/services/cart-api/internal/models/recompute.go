@@ -0,0 +1,58 @@
+package models
+
+// RecomputeTotalsResp is one page of the batch total reconciliation, Cursor
+// is passed to the next request and is zero once all carts were visited
+type RecomputeTotalsResp struct {
+	Corrected []string `json:"corrected"`
+	Cursor    uint64   `json:"cursor"`
+}
+
+// CartCountResp is the number of active carts
+type CartCountResp struct {
+	Count int64 `json:"count" example:"42"`
+}
+
+// CartSummary is the total and number of items of a cart, ItemCount sums
+// quantities of line items
+type CartSummary struct {
+	Total     Money `json:"total" swaggertype:"number" example:"25.00"`
+	ItemCount int   `json:"item_count" example:"3"`
+}
+
+// BulkCreateCartsReq creates Count carts having up to MaxItems random line
+// items each, it is meant for load tests
+type BulkCreateCartsReq struct {
+	Count    int `json:"count" example:"100"`
+	MaxItems int `json:"max_items,omitempty" example:"5"`
+}
+
+// BulkCreateCartsResp lists ids of created carts
+type BulkCreateCartsResp struct {
+	IDs []string `json:"ids"`
+}
+
+// BatchAddItemReq adds Item to every cart of CartIDs, or with All to carts of
+// one SCAN page at the cursor query parameter which don't have the product
+// yet
+type BatchAddItemReq struct {
+	CartIDs []string `json:"cart_ids,omitempty"`
+	All     bool     `json:"all,omitempty"`
+	Item    LineItem `json:"item"`
+}
+
+// BatchCartResult is an outcome of one cart of a batch operation, Status is
+// the http status the cart would get on its own
+type BatchCartResult struct {
+	CartID    string `json:"cart_id"`
+	Status    int    `json:"status"`
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// BatchCartsResp lists outcomes of carts of a batch operation, Cursor is
+// passed to the next request of a scan and is zero once all carts were
+// visited
+type BatchCartsResp struct {
+	Results []BatchCartResult `json:"results"`
+	Cursor  uint64            `json:"cursor"`
+}
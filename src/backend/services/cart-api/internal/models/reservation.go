@@ -0,0 +1,7 @@
+package models
+
+// ReservationResp is quantity of a product soft reserved by carts
+type ReservationResp struct {
+	ProductID int `json:"product_id" example:"42"`
+	Quantity  int `json:"quantity" example:"3"`
+}
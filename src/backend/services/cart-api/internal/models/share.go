@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// ShareCartResp is returned when a read-only snapshot of a cart is created
+type ShareCartResp struct {
+	Token     string    `json:"token" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError is a failed validation of one field of a request, Field is the
+// json path of the field, e.g. items[0].quantity
+type FieldError struct {
+	Field   string `json:"field" example:"items[0].quantity"`
+	Message string `json:"message" example:"must not be negative"`
+
+	err error
+}
+
+// NewFieldError creates error of field from err, errors.Is keeps matching
+// err through ValidationErrors
+func NewFieldError(field string, err error) FieldError {
+	return FieldError{Field: field, Message: err.Error(), err: err}
+}
+
+// ValidationErrors are every failed validation of a request, so clients can
+// report all invalid fields at once instead of the first one
+type ValidationErrors []FieldError
+
+// Error implements error.
+func (e ValidationErrors) Error() string {
+	fields := make([]string, len(e))
+	for i, f := range e {
+		fields[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(fields, "; ")
+}
+
+// Unwrap returns errors the field errors were created from.
+func (e ValidationErrors) Unwrap() []error {
+	var errs []error
+	for _, f := range e {
+		if f.err != nil {
+			errs = append(errs, f.err)
+		}
+	}
+	return errs
+}
+
+// Nested returns the errors with fields nested under field
+func (e ValidationErrors) Nested(field string) ValidationErrors {
+	nested := make(ValidationErrors, len(e))
+	for i, f := range e {
+		f.Field = field + "." + f.Field
+		nested[i] = f
+	}
+	return nested
+}
+
+// ErrNegative is returned for amounts and quantities below zero
+var ErrNegative = errors.New("must not be negative")
+
+// ErrQuantityTooLarge is returned for quantities above MaxItemQuantity
+var ErrQuantityTooLarge = fmt.Errorf("must not be above %d", MaxItemQuantity)
+
+// ValidateLineItems validates every item of the list named field, fields of
+// items are reported as field[index].name
+func ValidateLineItems(field string, items []LineItem) ValidationErrors {
+	var errs ValidationErrors
+	for i, item := range items {
+		var itemErrs ValidationErrors
+		if errors.As(item.Validate(), &itemErrs) {
+			errs = append(errs, itemErrs.Nested(fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	}
+	return errs
+}
+
+var _ error = ValidationErrors(nil)
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrSameID = errors.New("alias and cart id are the same")
+
+// aliasesKey is a hash of historical cart id to the current one
+const aliasesKey = "aliases"
+
+// Alias makes Get of oldID resolve to the cart newID, used while migrating
+// carts to a new id scheme
+func (r *CartRepository) Alias(ctx context.Context, oldID, newID string) error {
+	if oldID == newID {
+		return ErrSameID
+	}
+	if err := r.client.HSet(ctx, r.key(ctx, aliasesKey), oldID, newID).Err(); err != nil {
+		return fmt.Errorf("error setting alias %s of %s: %w", oldID, newID, err)
+	}
+	return nil
+}
+
+// ResolveAlias returns current id of the historical cart id or
+// ErrCartNotFound when the id has no alias, the cart itself may be gone
+func (r *CartRepository) ResolveAlias(ctx context.Context, cartID string) (string, error) {
+	return r.resolveAlias(ctx, r.reader, cartID)
+}
+
+// resolveAlias returns current id of the historical cart id, aliases are a
+// single hop so a migrated id is never aliased again
+func (r *CartRepository) resolveAlias(ctx context.Context, c redis.Cmdable, cartID string) (string, error) {
+	id, err := c.HGet(ctx, r.key(ctx, aliasesKey), cartID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", ErrCartNotFound
+		}
+		return "", fmt.Errorf("error getting alias %s: %w", cartID, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+	require.NoError(t, repo.Update(ctx, cart))
+	require.NoError(t, repo.Alias(ctx, "legacy-1", cart.ID.String()))
+
+	t.Run("direct hit should return cart", func(t *testing.T) {
+		result, err := repo.Get(ctx, cart.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, cart.ID, result.ID)
+	})
+
+	t.Run("alias hit should return current cart", func(t *testing.T) {
+		result, err := repo.Get(ctx, "legacy-1")
+		assert.NoError(t, err)
+		assert.Equal(t, cart.ID, result.ID)
+		assert.Equal(t, cart.LineItems, result.LineItems)
+	})
+
+	t.Run("resolving alias should return current id", func(t *testing.T) {
+		id, err := repo.ResolveAlias(ctx, "legacy-1")
+		assert.NoError(t, err)
+		assert.Equal(t, cart.ID.String(), id)
+
+		_, err = repo.ResolveAlias(ctx, "legacy-2")
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("double miss should return not found", func(t *testing.T) {
+		_, err := repo.Get(ctx, "legacy-2")
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("alias of deleted cart should return not found", func(t *testing.T) {
+		gone := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, gone))
+		require.NoError(t, repo.Alias(ctx, "legacy-3", gone.ID.String()))
+		require.NoError(t, repo.Delete(ctx, gone.ID.String()))
+
+		_, err := repo.Get(ctx, "legacy-3")
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("aliasing id to itself should fail", func(t *testing.T) {
+		assert.ErrorIs(t, repo.Alias(ctx, "same", "same"), ErrSameID)
+	})
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/tenant"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// archiveKey keys sorted set of archived carts scored by the time they were
+// archived at. The set is shared by tenants, members are cart ids qualified
+// by the tenant
+const archiveKey = "carts:archive"
+
+// sweepBatch bounds archived carts deleted by one round trip of a sweep
+const sweepBatch = 100
+
+// WithArchive keeps completed and cancelled carts for retention after they
+// were archived instead of expiring them with the cart ttl, the sweeper
+// deletes them afterwards. Zero disables archiving
+func WithArchive(retention time.Duration) Option {
+	return func(r *CartRepository) {
+		r.archiveRetention = retention
+	}
+}
+
+// archiveMember is the member of the cart in the archive set
+func archiveMember(ctx context.Context, cartID string) string {
+	if t := tenant.FromContext(ctx); t != "" {
+		return t + ":" + cartID
+	}
+	return cartID
+}
+
+// archive records time the cart was archived at when it is completed or
+// cancelled, writing an archived cart again keeps the time. Carts written
+// active again leave the archive
+func (r *CartRepository) archive(ctx context.Context, pipe redis.Pipeliner, cart *models.Cart) {
+	member := archiveMember(ctx, cart.ID.String())
+	if r.isCartCompleted(*cart) {
+		pipe.ZAddNX(ctx, r.prefix+archiveKey, redis.Z{Score: float64(r.now().UnixMilli()), Member: member})
+		return
+	}
+	pipe.ZRem(ctx, r.prefix+archiveKey, member)
+}
+
+// forgetArchive drops the deleted cart from the archive
+func (r *CartRepository) forgetArchive(ctx context.Context, cartID string) {
+	if r.archiveRetention <= 0 {
+		return
+	}
+	if err := r.client.ZRem(ctx, r.prefix+archiveKey, archiveMember(ctx, cartID)).Err(); err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to forget archived cart")
+	}
+}
+
+// ArchivedCount returns the number of archived carts of every tenant,
+// including those past retention which were not swept yet
+func (r *CartRepository) ArchivedCount(ctx context.Context) (int64, error) {
+	n, err := r.client.ZCard(ctx, r.prefix+archiveKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error counting archived carts: %w", err)
+	}
+	return n, nil
+}
+
+// SweepArchive deletes carts archived longer than retention ago with their
+// versions, history and summary, the number of deleted carts is returned
+func (r *CartRepository) SweepArchive(ctx context.Context) (int, error) {
+	if r.archiveRetention <= 0 {
+		return 0, nil
+	}
+	cutoff := strconv.FormatInt(r.now().Add(-r.archiveRetention).UnixMilli(), 10)
+	swept := 0
+	for {
+		members, err := r.client.ZRangeByScore(ctx, r.prefix+archiveKey, &redis.ZRangeBy{Min: "-inf", Max: cutoff, Count: sweepBatch}).Result()
+		if err != nil {
+			return swept, fmt.Errorf("error reading archived carts: %w", err)
+		}
+		for _, member := range members {
+			if err := r.sweep(ctx, member); err != nil {
+				return swept, err
+			}
+			swept++
+		}
+		if len(members) < sweepBatch {
+			return swept, nil
+		}
+	}
+}
+
+// sweep deletes the archived cart of member, a cart written active again
+// meanwhile is kept
+func (r *CartRepository) sweep(ctx context.Context, member string) error {
+	cartID := member
+	if i := strings.LastIndex(member, ":"); i != -1 {
+		var err error
+		if ctx, err = tenant.NewContext(ctx, member[:i]); err != nil {
+			return fmt.Errorf("archived cart %s: %w", member, err)
+		}
+		cartID = member[i+1:]
+	}
+
+	return r.watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, r.key(ctx, cartID)).Bytes()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("error getting key %s: %w", cartID, err)
+		}
+		keep := false
+		if err == nil {
+			cart, _, err := unmarshalCart(data)
+			if err != nil {
+				return fmt.Errorf("archived cart %s: %w", cartID, err)
+			}
+			keep = !r.isCartCompleted(*cart)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRem(ctx, r.prefix+archiveKey, member)
+			if !keep {
+				pipe.Del(ctx, r.key(ctx, cartID), r.key(ctx, versionsKeyPrefix+cartID), r.key(ctx, historyKeyPrefix+cartID),
+					r.summaryKey(ctx, cartID), r.itemSequenceKey(ctx, cartID))
+			}
+			return nil
+		})
+		return err
+	}, cartID)
+}
+
+// RunArchiveSweeper sweeps the archive every interval until ctx is done, a
+// non positive interval or disabled archiving stops it right away
+func (r *CartRepository) RunArchiveSweeper(ctx context.Context, interval time.Duration) {
+	if r.archiveRetention <= 0 || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		swept, err := r.SweepArchive(ctx)
+		if err != nil {
+			log.Warn().Err(err).Int("swept", swept).Msg("failed to sweep archived carts")
+		} else if swept > 0 {
+			log.Info().Int("swept", swept).Msg("swept archived carts")
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	newArchive := func(t *testing.T) (*CartRepository, *miniredis.Miniredis, *time.Time, func(status models.Status) string) {
+		repo, mr := newTestRepository(t, WithArchive(time.Hour), WithCartTTL(10*time.Minute), WithVersions(5))
+		now := start
+		repo.now = func() time.Time { return now }
+		store := func(status models.Status) string {
+			cart := &models.Cart{ID: uuid.New(), Status: status, LineItems: []models.LineItem{}}
+			require.NoError(t, repo.Update(ctx, cart))
+			return cart.ID.String()
+		}
+		return repo, mr, &now, store
+	}
+
+	t.Run("sweeper should remove expired archives and leave recent ones", func(t *testing.T) {
+		repo, mr, now, store := newArchive(t)
+		expired := store(models.CartStatusCompleted)
+		*now = start.Add(50 * time.Minute)
+		recent := store(models.CartStatusCancelled)
+		active := store(models.CartStatusNew)
+
+		archived, err := repo.ArchivedCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), archived)
+
+		*now = start.Add(70 * time.Minute)
+		swept, err := repo.SweepArchive(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, swept)
+
+		archived, err = repo.ArchivedCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), archived)
+		assert.False(t, mr.Exists(expired))
+		assert.False(t, mr.Exists(versionsKeyPrefix+expired), "versions should be swept with the cart")
+		assert.True(t, mr.Exists(recent))
+		assert.True(t, mr.Exists(versionsKeyPrefix+recent))
+		assert.True(t, mr.Exists(active))
+	})
+
+	t.Run("archived carts should outlive the cart ttl", func(t *testing.T) {
+		_, mr, _, store := newArchive(t)
+		completed := store(models.CartStatusCompleted)
+		active := store(models.CartStatusNew)
+
+		assert.Zero(t, mr.TTL(completed), "archived cart should be kept until swept")
+		assert.Equal(t, 10*time.Minute, mr.TTL(active))
+	})
+
+	t.Run("reactivated and deleted carts should leave the archive", func(t *testing.T) {
+		repo, _, now, store := newArchive(t)
+		reactivated := store(models.CartStatusCompleted)
+		deleted := store(models.CartStatusCompleted)
+		require.NoError(t, repo.Update(ctx, &models.Cart{ID: uuid.MustParse(reactivated), Status: models.CartStatusNew, LineItems: []models.LineItem{}}))
+		require.NoError(t, repo.Delete(ctx, deleted))
+
+		archived, err := repo.ArchivedCount(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, archived)
+
+		*now = start.Add(2 * time.Hour)
+		swept, err := repo.SweepArchive(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, swept)
+		_, err = repo.Get(ctx, reactivated)
+		assert.NoError(t, err)
+	})
+
+	t.Run("sweeper should keep a cart written active after it was read", func(t *testing.T) {
+		repo, mr, now, store := newArchive(t)
+		id := store(models.CartStatusNew)
+		_, err := mr.ZAdd(archiveKey, float64(start.UnixMilli()), id)
+		require.NoError(t, err)
+
+		*now = start.Add(2 * time.Hour)
+		_, err = repo.SweepArchive(ctx)
+		require.NoError(t, err)
+		_, err = repo.Get(ctx, id)
+		assert.NoError(t, err)
+		archived, err := repo.ArchivedCount(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, archived)
+	})
+
+	t.Run("archives of tenants should be swept", func(t *testing.T) {
+		repo, mr, now, _ := newArchive(t)
+		tenantCtx, err := tenant.NewContext(ctx, "acme")
+		require.NoError(t, err)
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusCompleted, LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(tenantCtx, cart))
+
+		*now = start.Add(2 * time.Hour)
+		swept, err := repo.SweepArchive(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, swept)
+		assert.False(t, mr.Exists(repo.key(tenantCtx, cart.ID.String())))
+	})
+
+	t.Run("disabled archive should keep expiring completed carts", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithCartTTL(10*time.Minute))
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusCompleted, LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, cart))
+		assert.Equal(t, 10*time.Minute, mr.TTL(cart.ID.String()))
+
+		swept, err := repo.SweepArchive(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, swept)
+	})
+}
@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrItemInCart is the outcome of carts skipped by AddItemToCarts because
+// they have a line of the product already
+var ErrItemInCart = errors.New("cart has the product already")
+
+// ScanCartIDs returns ids of carts found in one SCAN page starting at cursor
+// and the cursor of the next page. Zero next cursor means the scan is
+// complete, a page may have ids of completed carts
+func (r *CartRepository) ScanCartIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	keys, next, err := r.client.Scan(ctx, cursor, r.key(ctx, "*"), count).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error scanning carts at %d: %w", cursor, err)
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		id := strings.TrimPrefix(key, r.key(ctx, ""))
+		// shared snapshots and other keys live next to carts
+		if _, err := uuid.Parse(id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, next, nil
+}
+
+// AddItemToCarts adds item to every cart like AddItem, best effort: the
+// outcome of every cart is returned at its index and a failing cart does
+// not stop others. Carts are read in one pipeline and written in one
+// MULTI/EXEC, when any of them changes concurrently carts are retried one
+// by one. Duplicate ids get the item once. With once carts having a line of
+// the product are skipped with ErrItemInCart, so repeating the batch, e.g.
+// over a SCAN returning a cart twice, adds the item to every cart once
+func (r *CartRepository) AddItemToCarts(ctx context.Context, cartIDs []string, item models.LineItem, once bool) []error {
+	errs := make([]error, len(cartIDs))
+	added := make([]*models.Cart, len(cartIDs))
+	itemIDs := make([]int, len(cartIDs))
+	first := make(map[string]int, len(cartIDs))
+	var unique []string
+	for i, id := range cartIDs {
+		if _, ok := first[id]; !ok {
+			first[id] = i
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return errs
+	}
+
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cmds, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, id := range unique {
+				pipe.Get(ctx, r.key(ctx, id))
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		var writes []*models.Cart
+		for j, id := range unique {
+			i := first[id]
+			errs[i], added[i] = nil, nil
+			cart, err := r.decodeCmd(cmds[j].(*redis.StringCmd), id)
+			if err == nil {
+				itemIDs[i], err = r.addTo(ctx, cart, item, once)
+			}
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			added[i] = cart
+			writes = append(writes, cart)
+		}
+		if len(writes) == 0 {
+			return nil
+		}
+		return r.setTx(ctx, tx, writes...)
+	}, unique...)
+	if errors.Is(err, redis.TxFailedErr) {
+		for _, id := range unique {
+			i := first[id]
+			var result *models.Cart
+			errs[i] = r.mutate(ctx, id, func(cart *models.Cart) error {
+				itemID, err := r.addTo(ctx, cart, item, once)
+				itemIDs[i], result = itemID, cart
+				return err
+			})
+			// mutate kept derived keys in sync
+			added[i] = nil
+			if errs[i] == nil {
+				r.audit(context.WithoutCancel(ctx), id, itemEntry(models.AuditItemAdded, result, itemIDs[i]))
+			}
+		}
+	} else if err != nil {
+		for _, id := range unique {
+			errs[first[id]], added[first[id]] = err, nil
+		}
+	}
+
+	// committed, derived keys follow even if the caller went away
+	ctx = context.WithoutCancel(ctx)
+	for i, cart := range added {
+		if cart == nil {
+			continue
+		}
+		r.written(ctx, cart)
+		r.audit(ctx, cartIDs[i], itemEntry(models.AuditItemAdded, cart, itemIDs[i]))
+	}
+	for i, id := range cartIDs {
+		errs[i] = errs[first[id]]
+	}
+	return errs
+}
+
+// decodeCmd decodes the cart read by GET, completed carts are missing
+func (r *CartRepository) decodeCmd(cmd *redis.StringCmd, cartID string) (*models.Cart, error) {
+	data, err := cmd.Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("%w: %s", ErrCartNotFound, cartID)
+		}
+		return nil, fmt.Errorf("error getting key %s: %w", cartID, err)
+	}
+	cart, err := r.decodeCart(data)
+	if err != nil {
+		return nil, fmt.Errorf("cart %s: %w", cartID, err)
+	}
+	return cart, nil
+}
+
+// addTo merges item into cart and checks limits, the id of the line is
+// returned. With once a cart having a line of the product is left as it is
+func (r *CartRepository) addTo(ctx context.Context, cart *models.Cart, item models.LineItem, once bool) (int, error) {
+	if once && hasProduct(cart, item.Product()) {
+		return 0, fmt.Errorf("%w: cart %s, product %d", ErrItemInCart, cart.ID, item.Product())
+	}
+	itemID, err := r.mergeItem(ctx, cart, item)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.checkItem(cart, itemID); err != nil {
+		return 0, err
+	}
+	return itemID, nil
+}
+
+func hasProduct(cart *models.Cart, productID int) bool {
+	for _, item := range cart.LineItems {
+		if item.Product() == productID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddItemToCarts(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t, WithLimits(Limits{MaxCartTotal: models.Money{Minor: 1000}}), WithHistory(10))
+	price := models.Money{Minor: 100}
+	sample := models.LineItem{ItemID: 9, UnitPrice: models.Money{}, Quantity: 1, ProductName: "sample"}
+
+	empty := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+	withSample := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 9, Quantity: 1, ProductName: "sample"}}}
+	full := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+	completed := &models.Cart{ID: uuid.New(), Status: models.CartStatusCompleted, LineItems: []models.LineItem{}}
+	for _, cart := range []*models.Cart{empty, withSample, full, completed} {
+		require.NoError(t, repo.Update(ctx, cart))
+	}
+
+	t.Run("every cart should get its own outcome", func(t *testing.T) {
+		missing := uuid.NewString()
+		ids := []string{empty.ID.String(), missing, withSample.ID.String(), completed.ID.String(), empty.ID.String()}
+		errs := repo.AddItemToCarts(ctx, ids, sample, false)
+		require.Len(t, errs, len(ids))
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], ErrCartNotFound)
+		assert.NoError(t, errs[2])
+		assert.ErrorIs(t, errs[3], ErrCartNotFound)
+		assert.NoError(t, errs[4], "duplicate should share the outcome")
+
+		got, err := repo.Get(ctx, empty.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, []models.LineItem{sample}, got.LineItems, "duplicate should get the item once")
+		got, err = repo.Get(ctx, withSample.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 2, got.LineItems[0].Quantity)
+
+		entries, err := repo.History(ctx, empty.ID.String())
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, models.AuditEntry{At: entries[0].At, Action: models.AuditItemAdded, ItemID: 9, Quantity: 1}, entries[0])
+	})
+
+	t.Run("rejected cart should not stop others", func(t *testing.T) {
+		paid := models.LineItem{ItemID: 2, UnitPrice: price, Quantity: 1}
+		errs := repo.AddItemToCarts(ctx, []string{full.ID.String(), withSample.ID.String()}, paid, false)
+		assert.ErrorIs(t, errs[0], models.ErrBusinessRule)
+		assert.NoError(t, errs[1])
+
+		got, err := repo.Get(ctx, full.ID.String())
+		require.NoError(t, err)
+		assert.Len(t, got.LineItems, 1)
+		got, err = repo.Get(ctx, withSample.ID.String())
+		require.NoError(t, err)
+		assert.Len(t, got.LineItems, 2)
+		assert.Equal(t, price, got.Total)
+	})
+
+	t.Run("once should skip carts having the product", func(t *testing.T) {
+		fresh := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, fresh))
+		ids := []string{fresh.ID.String(), withSample.ID.String()}
+
+		errs := repo.AddItemToCarts(ctx, ids, sample, true)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], ErrItemInCart)
+		// a repeated scan page
+		errs = repo.AddItemToCarts(ctx, ids, sample, true)
+		assert.ErrorIs(t, errs[0], ErrItemInCart)
+
+		got, err := repo.Get(ctx, fresh.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, []models.LineItem{sample}, got.LineItems)
+	})
+
+	t.Run("no carts should be a no-op", func(t *testing.T) {
+		assert.Empty(t, repo.AddItemToCarts(ctx, nil, sample, false))
+	})
+
+	t.Run("carts should be indexed for their owner", func(t *testing.T) {
+		owner := "hank"
+		owned := &models.Cart{ID: uuid.New(), UserID: &owner, LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, owned))
+		other := &models.Cart{ID: uuid.New(), UserID: &owner, LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, other))
+
+		errs := repo.AddItemToCarts(ctx, []string{owned.ID.String()}, sample, false)
+		require.NoError(t, errs[0])
+		active, err := repo.CartByUser(ctx, owner)
+		require.NoError(t, err)
+		assert.Equal(t, owned.ID.String(), active)
+	})
+}
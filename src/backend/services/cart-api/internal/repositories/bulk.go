@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// AddItems adds items to the cart in one transaction, summing quantity of
+// items the cart already has. Unless partial is set the first rejected item
+// fails the whole batch, otherwise rejected items are skipped and their
+// errors are returned by index of the item, nil for added ones
+func (r *CartRepository) AddItems(ctx context.Context, cartID string, items []models.LineItem, partial bool) ([]error, error) {
+	var itemErrs []error
+	var entries []models.AuditEntry
+	err := r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		var added []int
+		var err error
+		itemErrs, added, err = r.addItems(ctx, cart, items, partial)
+		if err != nil {
+			return err
+		}
+		entries = entries[:0]
+		for _, itemID := range added {
+			entries = append(entries, itemEntry(models.AuditItemAdded, cart, itemID))
+		}
+		return unchangedIfAllFailed(itemErrs)
+	})
+	if err == nil {
+		r.audit(ctx, cartID, entries...)
+	}
+	return bulkResult(itemErrs, err)
+}
+
+// addItems merges items into cart and returns errors of rejected items by
+// index together with ids of added ones, see AddItems
+func (r *CartRepository) addItems(ctx context.Context, cart *models.Cart, items []models.LineItem, partial bool) ([]error, []int, error) {
+	itemErrs := make([]error, len(items))
+	var added []int
+	for i, item := range items {
+		previous := append([]models.LineItem(nil), cart.LineItems...)
+		itemID, err := r.mergeItem(ctx, cart, item)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = r.checkItem(cart, itemID)
+		if err == nil {
+			added = append(added, itemID)
+			continue
+		}
+		if !partial {
+			return nil, nil, err
+		}
+		cart.LineItems = previous
+		if err := setTotal(cart); err != nil {
+			return nil, nil, err
+		}
+		itemErrs[i] = err
+	}
+	return itemErrs, added, nil
+}
+
+// DeleteItems removes items from the cart in one transaction, missing items
+// fail with ErrItemNotFound the same way as AddItems
+func (r *CartRepository) DeleteItems(ctx context.Context, cartID string, itemIDs []int, partial bool) ([]error, error) {
+	var itemErrs []error
+	var entries []models.AuditEntry
+	err := r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		var err error
+		if itemErrs, err = deleteItems(cart, itemIDs, partial); err != nil {
+			return err
+		}
+		entries = entries[:0]
+		for i, itemID := range itemIDs {
+			if itemErrs[i] == nil {
+				entries = append(entries, models.AuditEntry{Action: models.AuditItemRemoved, ItemID: itemID})
+			}
+		}
+		return unchangedIfAllFailed(itemErrs)
+	})
+	if err == nil {
+		r.audit(ctx, cartID, entries...)
+	}
+	return bulkResult(itemErrs, err)
+}
+
+// deleteItems removes items from cart and returns errors of missing items by
+// index, see DeleteItems
+func deleteItems(cart *models.Cart, itemIDs []int, partial bool) ([]error, error) {
+	itemErrs := make([]error, len(itemIDs))
+	for i, itemID := range itemIDs {
+		index := -1
+		for j, item := range cart.LineItems {
+			if item.ItemID == itemID {
+				index = j
+				break
+			}
+		}
+		if index == -1 {
+			err := fmt.Errorf("%w: item %d in cart %s", ErrItemNotFound, itemID, cart.ID)
+			if !partial {
+				return nil, err
+			}
+			itemErrs[i] = err
+			continue
+		}
+		cart.LineItems = append(cart.LineItems[:index], cart.LineItems[index+1:]...)
+	}
+	if err := setTotal(cart); err != nil {
+		return nil, err
+	}
+	return itemErrs, nil
+}
+
+// unchangedIfAllFailed skips the write when no item of the batch applied
+func unchangedIfAllFailed(itemErrs []error) error {
+	for _, err := range itemErrs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errUnchanged
+}
+
+func bulkResult(itemErrs []error, err error) ([]error, error) {
+	if err != nil && !errors.Is(err, errUnchanged) {
+		return nil, err
+	}
+	return itemErrs, nil
+}
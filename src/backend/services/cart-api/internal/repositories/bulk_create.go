@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// CreateCarts stores carts in one MULTI/EXEC, existing carts of the same id
+// are overwritten. Derived keys follow the commit like for a single write
+func (r *CartRepository) CreateCarts(ctx context.Context, carts []*models.Cart) error {
+	values := make([][]byte, len(carts))
+	for i, cart := range carts {
+		value, err := r.encodeCart(cart)
+		if err != nil {
+			return err
+		}
+		values[i] = value
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, cart := range carts {
+			r.setCart(ctx, pipe, cart, values[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error creating %d carts: %w", len(carts), err)
+	}
+	for _, cart := range carts {
+		r.written(ctx, cart)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateCarts(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	var execs int
+	repo.client.AddHook(cartPipelineCounter{execs: &execs})
+
+	carts := make([]*models.Cart, 10)
+	for i := range carts {
+		carts[i] = &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: i + 1, UnitPrice: models.Money{Minor: 100}, Quantity: 2}}, Total: models.Money{Minor: 200}}
+	}
+	require.NoError(t, repo.CreateCarts(ctx, carts))
+	assert.Equal(t, 1, execs, "carts should be stored in one transaction")
+
+	for _, cart := range carts {
+		stored, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, cart.LineItems, stored.LineItems)
+
+		summary, err := repo.Summary(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, models.CartSummary{Total: models.Money{Minor: 200}, ItemCount: 2}, summary)
+	}
+	count, err := repo.Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), count)
+}
+
+// cartPipelineCounter counts pipelines setting values sent to redis
+type cartPipelineCounter struct {
+	execs *int
+}
+
+func (h cartPipelineCounter) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h cartPipelineCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (h cartPipelineCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if cmd.Name() == "set" {
+				*h.execs++
+				break
+			}
+		}
+		return next(ctx, cmds)
+	}
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddItems(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t, WithLimits(Limits{MaxItemPrice: models.Money{Minor: 5000}}))
+
+	valid := models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}
+	expensive := models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 10000}, Quantity: 1}
+	other := models.LineItem{ItemID: 3, UnitPrice: models.Money{Minor: 500}, Quantity: 2}
+
+	newCart := func(t *testing.T) string {
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+
+	t.Run("atomic batch should fail as a whole", func(t *testing.T) {
+		cartID := newCart(t)
+		_, err := repo.AddItems(ctx, cartID, []models.LineItem{valid, expensive, other}, false)
+		assert.ErrorIs(t, err, ErrItemPriceExceeded)
+
+		cart, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Empty(t, cart.LineItems)
+	})
+
+	t.Run("partial batch should skip rejected items", func(t *testing.T) {
+		cartID := newCart(t)
+		itemErrs, err := repo.AddItems(ctx, cartID, []models.LineItem{valid, expensive, other, valid}, true)
+		require.NoError(t, err)
+		require.Len(t, itemErrs, 4)
+		assert.NoError(t, itemErrs[0])
+		assert.ErrorIs(t, itemErrs[1], ErrItemPriceExceeded)
+		assert.NoError(t, itemErrs[2])
+		assert.NoError(t, itemErrs[3])
+
+		cart, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}, other}, cart.LineItems)
+		assert.Equal(t, models.Money{Minor: 3000}, cart.Total)
+	})
+
+	t.Run("missing cart should fail", func(t *testing.T) {
+		_, err := repo.AddItems(ctx, uuid.NewString(), []models.LineItem{valid}, true)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+}
+
+func TestDeleteItems(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t)
+
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1},
+		{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1},
+	}}
+	require.NoError(t, repo.Update(ctx, cart))
+	cartID := cart.ID.String()
+
+	t.Run("atomic batch with missing item should change nothing", func(t *testing.T) {
+		_, err := repo.DeleteItems(ctx, cartID, []int{1, 42}, false)
+		assert.ErrorIs(t, err, ErrItemNotFound)
+
+		result, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Len(t, result.LineItems, 2)
+	})
+
+	t.Run("partial batch of missing items should not write", func(t *testing.T) {
+		before, err := mr.Get(cartID)
+		require.NoError(t, err)
+		itemErrs, err := repo.DeleteItems(ctx, cartID, []int{41, 42}, true)
+		require.NoError(t, err)
+		assert.ErrorIs(t, itemErrs[0], ErrItemNotFound)
+		assert.ErrorIs(t, itemErrs[1], ErrItemNotFound)
+		after, err := mr.Get(cartID)
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("partial batch should delete existing items", func(t *testing.T) {
+		itemErrs, err := repo.DeleteItems(ctx, cartID, []int{1, 42}, true)
+		require.NoError(t, err)
+		assert.NoError(t, itemErrs[0])
+		assert.ErrorIs(t, itemErrs[1], ErrItemNotFound)
+
+		result, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, []models.LineItem{{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1}}, result.LineItems)
+		assert.Equal(t, models.Money{Minor: 500}, result.Total)
+	})
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelledContext(t *testing.T) {
+	ctx := context.Background()
+	for name, opts := range map[string][]Option{
+		"direct":       nil,
+		"write behind": {WithWriteBehind(time.Hour)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo, _ := newTestRepository(t, opts...)
+			cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 2}}}
+			other := &models.Cart{ID: uuid.New()}
+			require.NoError(t, repo.Update(ctx, cart))
+			require.NoError(t, repo.Update(ctx, other))
+			require.NoError(t, repo.FlushAll(ctx))
+			id := cart.ID.String()
+
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			item := models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 100}, Quantity: 1}
+			operations := map[string]func(ctx context.Context) error{
+				"Get":           func(ctx context.Context) error { _, err := repo.Get(ctx, id); return err },
+				"Update":        func(ctx context.Context) error { return repo.Update(ctx, &models.Cart{ID: cart.ID}) },
+				"Delete":        func(ctx context.Context) error { return repo.Delete(ctx, id) },
+				"DeleteIfMatch": func(ctx context.Context) error { return repo.DeleteIfMatch(ctx, id, []string{"*"}) },
+				"Touch":         func(ctx context.Context) error { return repo.Touch(ctx, id) },
+				"AddItem":       func(ctx context.Context) error { return repo.AddItem(ctx, id, item) },
+				"AddItems": func(ctx context.Context) error {
+					_, err := repo.AddItems(ctx, id, []models.LineItem{item}, false)
+					return err
+				},
+				"UpdateItem":         func(ctx context.Context) error { return repo.UpdateItem(ctx, id, 1, item) },
+				"DeleteItem":         func(ctx context.Context) error { return repo.DeleteItem(ctx, id, 1) },
+				"DeleteItems":        func(ctx context.Context) error { _, err := repo.DeleteItems(ctx, id, []int{1}, false); return err },
+				"DecrementItem":      func(ctx context.Context) error { return repo.DecrementItem(ctx, id, 1) },
+				"AdjustItemQuantity": func(ctx context.Context) error { return repo.AdjustItemQuantity(ctx, id, 1, 1, true) },
+				"MoveItem":           func(ctx context.Context) error { return repo.MoveItem(ctx, id, other.ID.String(), 1) },
+				"RecomputeTotal":     func(ctx context.Context) error { _, err := repo.RecomputeTotal(ctx, id); return err },
+				"RecomputeTotals":    func(ctx context.Context) error { _, _, err := repo.RecomputeTotals(ctx, 0, 10); return err },
+				"RepriceItems": func(ctx context.Context) error {
+					_, _, err := repo.RepriceItems(ctx, 1, models.Money{Minor: 1}, 0, 10)
+					return err
+				},
+				"Share":       func(ctx context.Context) error { _, err := repo.Share(ctx, id, time.Hour); return err },
+				"Transfer":    func(ctx context.Context) error { _, err := repo.Transfer(ctx, id, "", "user-1", false); return err },
+				"Alias":       func(ctx context.Context) error { return repo.Alias(ctx, uuid.NewString(), id) },
+				"Versions":    func(ctx context.Context) error { _, err := repo.Versions(ctx, id); return err },
+				"Count":       func(ctx context.Context) error { _, err := repo.Count(ctx); return err },
+				"Reserved":    func(ctx context.Context) error { _, err := repo.Reserved(ctx, 1); return err },
+				"RecentCarts": func(ctx context.Context) error { _, err := repo.RecentCarts(ctx, "user-1", 10); return err },
+				"NamedCarts":  func(ctx context.Context) error { _, err := repo.NamedCarts(ctx, "user-1"); return err },
+				"Import": func(ctx context.Context) error {
+					_, err := repo.Import(ctx, []models.Cart{{ID: uuid.New()}})
+					return err
+				},
+				"Ping": func(ctx context.Context) error { return repo.Ping(ctx) },
+			}
+			for op, f := range operations {
+				t.Run(op, func(t *testing.T) {
+					started := time.Now()
+					assert.ErrorIs(t, f(cancelled), context.Canceled)
+					assert.Less(t, time.Since(started), time.Second)
+				})
+			}
+
+			stored, err := repo.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, cart.LineItems, stored.LineItems, "cancelled operations should not change the cart")
+		})
+	}
+}
+
+// cancelAfter cancels the context of the request once redis ran command
+type cancelAfter struct {
+	command string
+	cancel  context.CancelFunc
+}
+
+func (h cancelAfter) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h cancelAfter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if cmd.Name() == h.command {
+			h.cancel()
+		}
+		return err
+	}
+}
+
+func (h cancelAfter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestCancelAfterWriteKeepsDerivedKeys(t *testing.T) {
+	repo, _ := newTestRepository(t, WithReservations(time.Hour), WithVersions(5))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	repo.client.AddHook(cancelAfter{command: "set", cancel: cancel})
+
+	userID := "user-1"
+	cart := &models.Cart{ID: uuid.New(), UserID: &userID, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 3}}}
+	require.NoError(t, repo.Update(ctx, cart))
+	require.Error(t, ctx.Err())
+
+	background := context.Background()
+	count, err := repo.Count(background)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "count index should include the stored cart")
+
+	reserved, err := repo.Reserved(background, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, reserved, "reservations should follow the stored cart")
+
+	versions, err := repo.Versions(background, cart.ID.String())
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	recent, err := repo.RecentCarts(background, userID, 10)
+	require.NoError(t, err)
+	assert.Len(t, recent, 1)
+}
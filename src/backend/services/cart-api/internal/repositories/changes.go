@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// changesKeyPrefix prefixes pub/sub channels of carts, every write of a cart
+// publishes the encoded cart and deletes publish an empty message
+const changesKeyPrefix = "changes:"
+
+func (r *CartRepository) changesChannel(ctx context.Context, cartID string) string {
+	return r.key(ctx, changesKeyPrefix+cartID)
+}
+
+// publishChange queues the snapshot of the written cart on pipe, it is
+// published when the transaction of the write commits
+func (r *CartRepository) publishChange(ctx context.Context, pipe redis.Pipeliner, cartID string, value []byte) {
+	pipe.Publish(ctx, r.changesChannel(ctx, cartID), value)
+}
+
+// publishDeleted tells watchers the cart is gone, watchers only miss the end
+// of the stream so failures are logged and don't fail the delete
+func (r *CartRepository) publishDeleted(ctx context.Context, cartID string) {
+	if err := r.client.Publish(ctx, r.changesChannel(ctx, cartID), "").Err(); err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to publish deleted cart")
+	}
+}
+
+// Watch streams snapshots of the cart written after the call, watchers on
+// any instance see writes of every instance. The channel is closed when ctx
+// is done or the cart is deleted, completed or cancelled. A watcher not
+// keeping up skips to the latest snapshot. Every watch holds a redis
+// connection
+func (r *CartRepository) Watch(ctx context.Context, cartID string) (<-chan *models.Cart, error) {
+	pubsub := r.client.Subscribe(ctx, r.changesChannel(ctx, cartID))
+	// writes after the subscription is confirmed are never missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("error watching cart %s: %w", cartID, err)
+	}
+
+	changes := make(chan *models.Cart, 1)
+	go func() {
+		defer close(changes)
+		defer pubsub.Close()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok || message.Payload == "" {
+					return
+				}
+				cart, err := r.decodeCart([]byte(message.Payload))
+				if errors.Is(err, ErrCartNotFound) {
+					return
+				}
+				if err != nil {
+					log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to decode watched cart")
+					continue
+				}
+				// only this goroutine sends, the latest snapshot always fits
+				select {
+				case <-changes:
+				default:
+				}
+				changes <- cart
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	ctx := context.Background()
+	next := func(t *testing.T, changes <-chan *models.Cart) (*models.Cart, bool) {
+		select {
+		case cart, ok := <-changes:
+			return cart, ok
+		case <-time.After(time.Second):
+			require.FailNow(t, "no change")
+			return nil, false
+		}
+	}
+	setup := func(t *testing.T) (*CartRepository, *models.Cart, <-chan *models.Cart) {
+		repo, _ := newTestRepository(t)
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, cart))
+		watchCtx, cancel := context.WithCancel(ctx)
+		t.Cleanup(cancel)
+		changes, err := repo.Watch(watchCtx, cart.ID.String())
+		require.NoError(t, err)
+		return repo, cart, changes
+	}
+
+	t.Run("writes should be streamed", func(t *testing.T) {
+		repo, cart, changes := setup(t)
+		require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, Quantity: 2}))
+
+		got, ok := next(t, changes)
+		require.True(t, ok)
+		require.Len(t, got.LineItems, 1)
+		assert.Equal(t, 2, got.LineItems[0].Quantity)
+	})
+
+	t.Run("deleted and completed carts should end the stream", func(t *testing.T) {
+		repo, cart, changes := setup(t)
+		require.NoError(t, repo.Delete(ctx, cart.ID.String()))
+		_, ok := next(t, changes)
+		assert.False(t, ok)
+
+		repo, cart, changes = setup(t)
+		cart.Status = models.CartStatusCompleted
+		require.NoError(t, repo.Update(ctx, cart))
+		_, ok = next(t, changes)
+		assert.False(t, ok)
+	})
+
+	t.Run("writes of other tenants should not be streamed", func(t *testing.T) {
+		repo, cart, changes := setup(t)
+		tenantCtx, err := tenant.NewContext(ctx, "acme")
+		require.NoError(t, err)
+		require.NoError(t, repo.Update(tenantCtx, &models.Cart{ID: cart.ID, LineItems: []models.LineItem{{ItemID: 7, Quantity: 1}}}))
+		require.NoError(t, repo.Touch(ctx, cart.ID.String()))
+		require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, Quantity: 1}))
+
+		got, ok := next(t, changes)
+		require.True(t, ok)
+		assert.Equal(t, 1, got.LineItems[0].ItemID)
+	})
+
+	t.Run("cancelled watch should close the stream", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		watchCtx, cancel := context.WithCancel(ctx)
+		changes, err := repo.Watch(watchCtx, uuid.NewString())
+		require.NoError(t, err)
+		cancel()
+		_, ok := next(t, changes)
+		assert.False(t, ok)
+	})
+}
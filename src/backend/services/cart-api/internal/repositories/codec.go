@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func init() {
+	msgpack.Register(models.Money{}, encodeMoney, decodeMoney)
+}
+
+// Codec serializes carts stored in redis
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec stores carts as JSON, it is the default for readability
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec stores carts as msgpack which is smaller and faster to parse,
+// field names follow the json tags
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// encodeMoney writes Money as a decimal string naming its currency, e.g.
+// "12.34 EUR", msgpack carts don't go through the JSON methods of the cart
+// which keep the currency in its own field
+func encodeMoney(enc *msgpack.Encoder, v reflect.Value) error {
+	return enc.EncodeString(v.Interface().(models.Money).String())
+}
+
+// decodeMoney reads Money from a decimal string, carts written before Money
+// keep prices and totals as float numbers of major units
+func decodeMoney(dec *msgpack.Decoder, v reflect.Value) error {
+	raw, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return err
+	}
+	var m models.Money
+	switch value := raw.(type) {
+	case nil:
+	case string:
+		if m, err = models.ParseMoney(value); err != nil {
+			return err
+		}
+	case float64:
+		m = models.FromMajor(value, "")
+	case int64:
+		m = models.FromMajor(float64(value), "")
+	case uint64:
+		m = models.FromMajor(float64(value), "")
+	default:
+		return fmt.Errorf("%w: %T", models.ErrInvalidMoney, raw)
+	}
+	v.Set(reflect.ValueOf(m))
+	return nil
+}
+
+// NewCodec returns codec by name, either "json" or "msgpack"
+func NewCodec(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "msgpack":
+		return MsgpackCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown cart codec %q", name)
+}
+
+// WithCodec sets codec used for writing carts, reading detects the format
+// of stored value so switching codecs keeps existing carts readable
+func WithCodec(c Codec) Option {
+	return func(r *CartRepository) {
+		r.codec = c
+	}
+}
+
+// detectCodec picks codec by the first byte, JSON carts are objects while
+// msgpack encodes structs as maps which never start with '{'
+func detectCodec(data []byte) Codec {
+	if len(data) > 0 && data[0] == '{' {
+		return JSONCodec{}
+	}
+	return MsgpackCodec{}
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func codecTestCart() *models.Cart {
+	userID := "user-1"
+	discount := models.Money{Minor: 250}
+	return &models.Cart{
+		ID: uuid.New(),
+		LineItems: []models.LineItem{{
+			ItemID:      1,
+			UnitPrice:   models.Money{Minor: 2000},
+			Quantity:    2,
+			ProductName: "Plov",
+			Attributes:  map[string]interface{}{"spicy": "yes"},
+			ImageURL:    "https://cdn.example.com/plov.png",
+			DisplayName: "Tashkent Plov",
+		}},
+		Total:    models.Money{Minor: 4000},
+		UserID:   &userID,
+		Discount: &discount,
+		Status:   models.CartStatusNew,
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	for _, name := range []string{"json", "msgpack"} {
+		t.Run(name+" should round-trip cart", func(t *testing.T) {
+			codec, err := NewCodec(name)
+			assert.NoError(t, err)
+
+			cart := codecTestCart()
+			data, err := codec.Marshal(cart)
+			assert.NoError(t, err)
+
+			var result models.Cart
+			assert.NoError(t, codec.Unmarshal(data, &result))
+			assert.Equal(t, cart, &result)
+			assert.IsType(t, codec, detectCodec(data))
+		})
+	}
+
+	t.Run("unknown codec should return error", func(t *testing.T) {
+		_, err := NewCodec("xml")
+		assert.Error(t, err)
+	})
+}
+
+func TestCodecReadCompat(t *testing.T) {
+	ctx := context.Background()
+	jsonRepo, mr := newTestRepository(t)
+	msgpackRepo := NewCartRepository(jsonRepo.client, WithCodec(MsgpackCodec{}))
+
+	t.Run("msgpack repository should read carts stored as json", func(t *testing.T) {
+		cart := codecTestCart()
+		assert.NoError(t, jsonRepo.Update(ctx, cart))
+
+		result, err := msgpackRepo.Get(ctx, cart.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, cart, result)
+	})
+
+	t.Run("json repository should read carts stored as msgpack", func(t *testing.T) {
+		cart := codecTestCart()
+		assert.NoError(t, msgpackRepo.Update(ctx, cart))
+
+		stored, err := mr.Get(cart.ID.String())
+		assert.NoError(t, err)
+		assert.NotEqual(t, byte('{'), stored[0])
+
+		result, err := jsonRepo.Get(ctx, cart.ID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, cart, result)
+	})
+}
+
+func benchmarkCodec(b *testing.B, codec Codec) {
+	cart := bigCart(50)
+	data, err := codec.Marshal(cart)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ReportMetric(float64(len(data)), "stored-bytes")
+		for i := 0; i < b.N; i++ {
+			if _, err := codec.Marshal(cart); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var result models.Cart
+			if err := codec.Unmarshal(data, &result); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkJSONCodec(b *testing.B) { benchmarkCodec(b, JSONCodec{}) }
+
+func BenchmarkMsgpackCodec(b *testing.B) { benchmarkCodec(b, MsgpackCodec{}) }
+
+func TestDisplayMetadataRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	cart := &models.Cart{ID: uuid.New()}
+	cartID := cart.ID.String()
+	assert.NoError(t, repo.Update(ctx, cart))
+
+	item := models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1, ImageURL: "https://cdn.example.com/a.png", DisplayName: "A"}
+	assert.NoError(t, repo.AddItem(ctx, cartID, item))
+
+	item.ImageURL, item.DisplayName = "https://cdn.example.com/b.png", "B"
+	assert.NoError(t, repo.UpdateItem(ctx, cartID, 1, item))
+
+	result, err := repo.Get(ctx, cartID)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/b.png", result.LineItems[0].ImageURL)
+	assert.Equal(t, "B", result.LineItems[0].DisplayName)
+}
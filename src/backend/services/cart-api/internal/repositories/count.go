@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// countKey keys sorted set of carts scored by the time they expire at, a
+// plain counter can't see carts expiring by ttl while the set drops them
+// by score. Members are cart ids so concurrent writes of a cart count once
+const countKey = "carts:count"
+
+// expiresAt is the score of a cart written now, carts without ttl never
+// expire
+func (r *CartRepository) expiresAt() float64 {
+	if r.cartTTL <= 0 {
+		return math.Inf(1)
+	}
+	return float64(r.now().Add(r.cartTTL).UnixMilli())
+}
+
+// countCart queues counting the cart until it expires on pipe, completed
+// and cancelled carts are no longer counted. It is queued in the MULTI
+// writing the cart so a delete racing the write can't leave it counted
+func (r *CartRepository) countCart(ctx context.Context, pipe redis.Pipeliner, cart *models.Cart) {
+	if r.isCartCompleted(*cart) {
+		r.uncountCart(ctx, pipe, cart.ID.String())
+		return
+	}
+	pipe.ZAdd(ctx, r.key(ctx, countKey), redis.Z{Score: r.expiresAt(), Member: cart.ID.String()})
+}
+
+// uncountCart queues removal of the cart from the count on pipe, it is
+// queued with the DEL of the cart
+func (r *CartRepository) uncountCart(ctx context.Context, pipe redis.Pipeliner, cartID string) {
+	pipe.ZRem(ctx, r.key(ctx, countKey), cartID)
+}
+
+// touchCount moves expiry of a counted cart after its ttl was refreshed,
+// carts deleted meanwhile are no longer members and stay uncounted
+func (r *CartRepository) touchCount(ctx context.Context, cartID string) {
+	err := r.client.ZAddXX(ctx, r.key(ctx, countKey), redis.Z{Score: r.expiresAt(), Member: cartID}).Err()
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to count touched cart")
+	}
+}
+
+// Count returns the number of carts which are not expired, completed or
+// cancelled. Expired carts are dropped from the count first, buffered
+// writes are counted once flushed
+func (r *CartRepository) Count(ctx context.Context) (int64, error) {
+	key := r.key(ctx, countKey)
+	var card *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(r.now().UnixMilli(), 10))
+		card = pipe.ZCard(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error counting carts: %w", err)
+	}
+	return card.Val(), nil
+}
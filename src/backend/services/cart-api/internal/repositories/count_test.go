@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/tenant"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCount(t *testing.T) {
+	ctx := context.Background()
+	count := func(t *testing.T, repo *CartRepository) int64 {
+		n, err := repo.Count(ctx)
+		require.NoError(t, err)
+		return n
+	}
+	newCart := func() *models.Cart {
+		return &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+	}
+
+	t.Run("create should increment and delete should decrement", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		first, second := newCart(), newCart()
+		require.NoError(t, repo.Update(ctx, first))
+		require.NoError(t, repo.Update(ctx, second))
+		assert.Equal(t, int64(2), count(t, repo))
+
+		require.NoError(t, repo.AddItem(ctx, first.ID.String(), models.LineItem{ItemID: 1, Quantity: 1}))
+		assert.Equal(t, int64(2), count(t, repo), "updates should not count again")
+
+		require.NoError(t, repo.Delete(ctx, first.ID.String()))
+		require.NoError(t, repo.Delete(ctx, first.ID.String()))
+		assert.Equal(t, int64(1), count(t, repo), "deleting twice should not count twice")
+	})
+
+	t.Run("completed cart should not be counted", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		cart := newCart()
+		require.NoError(t, repo.Update(ctx, cart))
+		cart.Status = models.CartStatusCompleted
+		require.NoError(t, repo.Update(ctx, cart))
+		assert.Zero(t, count(t, repo))
+	})
+
+	t.Run("concurrent creates and deletes should stay accurate", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		carts := make([]*models.Cart, 50)
+		for i := range carts {
+			carts[i] = newCart()
+		}
+		var wg sync.WaitGroup
+		for _, cart := range carts {
+			wg.Add(2)
+			go func(cart *models.Cart) {
+				defer wg.Done()
+				assert.NoError(t, repo.Update(ctx, cart))
+			}(cart)
+			// the same cart written concurrently counts once
+			go func(cart *models.Cart) {
+				defer wg.Done()
+				assert.NoError(t, repo.Update(ctx, cart))
+			}(cart)
+		}
+		wg.Wait()
+		assert.Equal(t, int64(len(carts)), count(t, repo))
+
+		for _, cart := range carts[:20] {
+			wg.Add(1)
+			go func(cart *models.Cart) {
+				defer wg.Done()
+				assert.NoError(t, repo.Delete(ctx, cart.ID.String()))
+			}(cart)
+		}
+		wg.Wait()
+		assert.Equal(t, int64(30), count(t, repo))
+	})
+
+	t.Run("expired carts should not be counted", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithCartTTL(time.Hour))
+		now := time.Unix(1700000000, 0)
+		repo.now = func() time.Time { return now }
+		expiring, touched := newCart(), newCart()
+		require.NoError(t, repo.Update(ctx, expiring))
+		require.NoError(t, repo.Update(ctx, touched))
+
+		now = now.Add(30 * time.Minute)
+		mr.FastForward(30 * time.Minute)
+		require.NoError(t, repo.Touch(ctx, touched.ID.String()))
+
+		now = now.Add(45 * time.Minute)
+		mr.FastForward(45 * time.Minute)
+		assert.Equal(t, int64(1), count(t, repo))
+
+		n, err := repo.client.ZCard(ctx, countKey).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n, "expired carts should be dropped from the index")
+	})
+
+	t.Run("count should change in the transaction of the write", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		pipelines := &recordPipelines{}
+		repo.client.AddHook(pipelines)
+		cart := newCart()
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.Delete(ctx, cart.ID.String()))
+
+		assert.Contains(t, pipelines.containing("set"), "zadd", "a delete must not run between the write and its count")
+		assert.Contains(t, pipelines.containing("del"), "zrem", "a write must not count the cart between its delete and uncount")
+	})
+
+	t.Run("carts should be counted per tenant", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		acme, err := tenant.NewContext(ctx, "acme")
+		require.NoError(t, err)
+		require.NoError(t, repo.Update(acme, newCart()))
+		n, err := repo.Count(acme)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		assert.Zero(t, count(t, repo))
+	})
+}
+
+// recordPipelines records names of commands of every pipeline
+type recordPipelines struct {
+	mu        sync.Mutex
+	pipelines [][]string
+}
+
+func (h *recordPipelines) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *recordPipelines) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (h *recordPipelines) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+		}
+		h.mu.Lock()
+		h.pipelines = append(h.pipelines, names)
+		h.mu.Unlock()
+		return next(ctx, cmds)
+	}
+}
+
+// containing returns commands of the first pipeline running command
+func (h *recordPipelines) containing(command string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, names := range h.pipelines {
+		for _, name := range names {
+			if name == command {
+				return names
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// UpdateCoupons applies fn to the cart atomically and returns the written
+// cart, so coupons are checked against the items they are stored with. fn
+// can be retried when the cart is modified concurrently so it must not have
+// side effects
+func (r *CartRepository) UpdateCoupons(ctx context.Context, cartID string, fn func(cart *models.Cart) error) (*models.Cart, error) {
+	var result *models.Cart
+	err := r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		if err := fn(cart); err != nil {
+			return err
+		}
+		result = cart
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
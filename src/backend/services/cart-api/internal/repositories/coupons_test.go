@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateCoupons(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	t.Run("items added concurrently should be kept", func(t *testing.T) {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+		require.NoError(t, repo.Update(ctx, cart))
+		cartID := cart.ID.String()
+
+		calls := 0
+		updated, err := repo.UpdateCoupons(ctx, cartID, func(cart *models.Cart) error {
+			calls++
+			if calls == 1 {
+				require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1}))
+			}
+			cart.Coupons = append(cart.Coupons, "TEN")
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls, "the update should be retried on the changed cart")
+		assert.Equal(t, []string{"TEN"}, updated.Coupons)
+
+		stored, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TEN"}, stored.Coupons)
+		assert.Len(t, stored.LineItems, 2)
+	})
+
+	t.Run("missing cart should not be found", func(t *testing.T) {
+		_, err := repo.UpdateCoupons(ctx, uuid.NewString(), func(cart *models.Cart) error { return nil })
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+}
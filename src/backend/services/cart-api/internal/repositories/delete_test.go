@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteIfMatch(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t)
+
+	stored := func(t *testing.T) *models.Cart {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 1}}}
+		require.NoError(t, repo.Update(ctx, cart))
+		got, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		return got
+	}
+
+	t.Run("matching etag should delete the cart and its versions", func(t *testing.T) {
+		cart := stored(t)
+		id := cart.ID.String()
+		require.NoError(t, repo.DeleteIfMatch(ctx, id, []string{models.ETag(cart)}))
+
+		_, err := repo.Get(ctx, id)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+		assert.False(t, mr.Exists(versionsKeyPrefix+id))
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("stale etag should keep the cart", func(t *testing.T) {
+		cart := stored(t)
+		id := cart.ID.String()
+		etag := models.ETag(cart)
+		require.NoError(t, repo.AddItem(ctx, id, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+
+		assert.ErrorIs(t, repo.DeleteIfMatch(ctx, id, []string{etag}), ErrETagMismatch)
+		_, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing cart should return ErrCartNotFound", func(t *testing.T) {
+		assert.ErrorIs(t, repo.DeleteIfMatch(ctx, uuid.NewString(), []string{"*"}), ErrCartNotFound)
+	})
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// DiscontinueItems removes lines of the product from carts found in one SCAN
+// page starting at cursor so the product can't be checked out anymore,
+// updated carts and the cursor of the next page are returned. Zero next
+// cursor means the scan is complete
+func (r *CartRepository) DiscontinueItems(ctx context.Context, productID int, cursor uint64, count int64) ([]*models.Cart, uint64, error) {
+	ids, next, err := r.ScanCartIDs(ctx, cursor, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updated := []*models.Cart{}
+	for _, id := range ids {
+		var result *models.Cart
+		var removed []int
+		err := r.mutate(ctx, id, func(cart *models.Cart) error {
+			var err error
+			result = cart
+			if removed, err = removeProduct(cart, productID); err != nil {
+				return err
+			}
+			if len(removed) == 0 {
+				return errUnchanged
+			}
+			return nil
+		})
+		if errors.Is(err, ErrCartNotFound) || errors.Is(err, errUnchanged) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		entries := make([]models.AuditEntry, len(removed))
+		for i, itemID := range removed {
+			entries[i] = models.AuditEntry{Action: models.AuditItemRemoved, ItemID: itemID}
+		}
+		r.audit(context.WithoutCancel(ctx), id, entries...)
+		updated = append(updated, result)
+	}
+	return updated, next, nil
+}
+
+// removeProduct removes lines of the product from cart and recalculates its
+// total, ids of removed lines are returned
+func removeProduct(cart *models.Cart, productID int) ([]int, error) {
+	var removed []int
+	kept := cart.LineItems[:0]
+	for _, item := range cart.LineItems {
+		if item.Product() == productID {
+			removed = append(removed, item.ItemID)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	cart.LineItems = kept
+	return removed, setTotal(cart)
+}
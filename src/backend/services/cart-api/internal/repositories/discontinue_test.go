@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscontinueItems(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t, WithItemIDStrategy(ItemIDSequence), WithHistory(10))
+
+	affected := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+	other := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+	for _, cart := range []*models.Cart{affected, other} {
+		require.NoError(t, repo.Update(ctx, cart))
+	}
+	require.NoError(t, repo.AddItem(ctx, affected.ID.String(), models.LineItem{ProductID: 7, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+	require.NoError(t, repo.AddItem(ctx, affected.ID.String(), models.LineItem{ProductID: 8, UnitPrice: models.Money{Minor: 300}, Quantity: 2}))
+	require.NoError(t, repo.AddItem(ctx, other.ID.String(), models.LineItem{ProductID: 8, UnitPrice: models.Money{Minor: 300}, Quantity: 1}))
+
+	var updated []*models.Cart
+	var cursor uint64
+	for {
+		carts, next, err := repo.DiscontinueItems(ctx, 7, cursor, 2)
+		require.NoError(t, err)
+		updated = append(updated, carts...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	require.Len(t, updated, 1)
+	assert.Equal(t, affected.ID, updated[0].ID)
+
+	result, err := repo.Get(ctx, affected.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, updated[0].LineItems, result.LineItems)
+	require.Len(t, result.LineItems, 1)
+	assert.Equal(t, 8, result.LineItems[0].Product())
+	assert.Equal(t, models.Money{Minor: 600}, result.Total)
+
+	entries, err := repo.History(ctx, affected.ID.String())
+	require.NoError(t, err)
+	last := entries[len(entries)-1]
+	assert.Equal(t, models.AuditEntry{At: last.At, Action: models.AuditItemRemoved, ItemID: 1}, last)
+
+	untouched, err := repo.Get(ctx, other.ID.String())
+	require.NoError(t, err)
+	assert.Len(t, untouched.LineItems, 1)
+}
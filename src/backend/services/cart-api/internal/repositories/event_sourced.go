@@ -0,0 +1,567 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Event sourced carts are a stream of their changes, the snapshot next to it
+// is the cart folded up to an entry of the stream
+const (
+	eventsKeyPrefix   = "events:"
+	snapshotKeyPrefix = "snapshot:"
+)
+
+// EventSourcedRepository stores carts as redis streams of item events instead
+// of single values, the cart is derived by folding the events since the last
+// snapshot. The stream doubles as the complete history of the cart.
+//
+// Carts are validated the same way as by CartRepository and counted, owners
+// and names of carts are indexed as well. Side indexes such as versions,
+// recent carts and reservations are not maintained
+type EventSourcedRepository struct {
+	carts         *CartRepository
+	snapshotEvery int
+}
+
+// NewEventSourcedRepository creates repository snapshotting carts every
+// snapshotEvery events, zero never snapshots. Options configure limits,
+// policies, ids and ttl of carts as for NewCartRepository
+func NewEventSourcedRepository(client *redis.Client, snapshotEvery int, opts ...Option) *EventSourcedRepository {
+	return &EventSourcedRepository{carts: NewCartRepository(client, opts...), snapshotEvery: snapshotEvery}
+}
+
+// cartEvent is an entry of the stream of a cart, data is the line item of
+// item events and the encoded cart of cart_replaced
+type cartEvent struct {
+	Type  string
+	Data  []byte
+	Actor string
+	At    time.Time
+}
+
+// cartChange is the cart after events appended to its stream, pending is a
+// number of events since the snapshot before them
+type cartChange struct {
+	cartID  string
+	cart    *models.Cart
+	pending int
+	events  []cartEvent
+	// claimName points the name of the cart to it in the same MULTI
+	claimName bool
+}
+
+func (r *EventSourcedRepository) eventsKey(ctx context.Context, cartID string) string {
+	return r.carts.key(ctx, eventsKeyPrefix+cartID)
+}
+
+func (r *EventSourcedRepository) snapshotKey(ctx context.Context, cartID string) string {
+	return r.carts.key(ctx, snapshotKeyPrefix+cartID)
+}
+
+// Get folds the events of the cart since its last snapshot
+func (r *EventSourcedRepository) Get(ctx context.Context, cartID string) (*models.Cart, error) {
+	cart, _, err := r.fold(ctx, r.carts.client, cartID)
+	return cart, err
+}
+
+// fold returns the cart at the end of its stream together with a number of
+// events folded after the snapshot, completed carts are treated as missing
+func (r *EventSourcedRepository) fold(ctx context.Context, c redis.Cmdable, cartID string) (*models.Cart, int, error) {
+	snapshot, err := c.HGetAll(ctx, r.snapshotKey(ctx, cartID)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting snapshot of cart %s: %w", cartID, err)
+	}
+	var cart *models.Cart
+	start := "-"
+	if last, ok := snapshot["last"]; ok {
+		if cart, _, err = unmarshalCart([]byte(snapshot["cart"])); err != nil {
+			return nil, 0, fmt.Errorf("invalid snapshot of cart %s: %w", cartID, err)
+		}
+		start = last
+	}
+	messages, err := c.XRange(ctx, r.eventsKey(ctx, cartID), start, "+").Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading events of cart %s: %w", cartID, err)
+	}
+	if len(messages) > 0 && messages[0].ID == snapshot["last"] {
+		// the range includes the entry the snapshot was taken at
+		messages = messages[1:]
+	}
+	for _, message := range messages {
+		event, err := parseEvent(message)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cart %s: %w", cartID, err)
+		}
+		if cart, err = applyEvent(cart, event); err != nil {
+			return nil, 0, fmt.Errorf("cart %s: event %s: %w", cartID, message.ID, err)
+		}
+	}
+	if cart == nil || r.carts.isCartCompleted(*cart) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrCartNotFound, cartID)
+	}
+	if err := setTotal(cart); err != nil {
+		return nil, 0, fmt.Errorf("cart %s: %w", cartID, err)
+	}
+	return cart, len(messages), nil
+}
+
+// applyEvent returns cart after the event, cart is nil until it is created
+func applyEvent(cart *models.Cart, event cartEvent) (*models.Cart, error) {
+	if event.Type == models.AuditCartReplaced {
+		replaced, _, err := unmarshalCart(event.Data)
+		return replaced, err
+	}
+	if cart == nil {
+		return nil, errors.New("item event before the cart was created")
+	}
+	var item models.LineItem
+	if err := json.Unmarshal(event.Data, &item); err != nil {
+		return nil, fmt.Errorf("invalid item: %w", err)
+	}
+	switch event.Type {
+	case models.AuditItemAdded:
+		cart.LineItems = append(cart.LineItems, item)
+	case models.AuditItemUpdated:
+		for i := range cart.LineItems {
+			if cart.LineItems[i].ItemID == item.ItemID {
+				cart.LineItems[i] = item
+			}
+		}
+	case models.AuditItemRemoved:
+		if err := removeLine(cart, item.ItemID); err != nil && !errors.Is(err, ErrItemNotFound) {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown event %q", event.Type)
+	}
+	return cart, nil
+}
+
+func parseEvent(message redis.XMessage) (cartEvent, error) {
+	field := func(name string) string {
+		value, _ := message.Values[name].(string)
+		return value
+	}
+	event := cartEvent{Type: field("type"), Data: []byte(field("data")), Actor: field("actor")}
+	if at := field("at"); at != "" {
+		var err error
+		if event.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+			return cartEvent{}, fmt.Errorf("invalid time of event %s: %w", message.ID, err)
+		}
+	}
+	return event, nil
+}
+
+// itemEvents returns events turning before into after, only line items are
+// compared as item mutations change nothing else
+func (r *EventSourcedRepository) itemEvents(ctx context.Context, before, after *models.Cart) ([]cartEvent, error) {
+	diff := models.DiffCarts(before, after)
+	var events []cartEvent
+	add := func(eventType string, item models.LineItem) error {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("error marshalling item %d: %w", item.ItemID, err)
+		}
+		events = append(events, r.event(ctx, eventType, data))
+		return nil
+	}
+	for _, item := range diff.Removed {
+		if err := add(models.AuditItemRemoved, models.LineItem{ItemID: item.ItemID}); err != nil {
+			return nil, err
+		}
+	}
+	for _, change := range diff.Changed {
+		if err := add(models.AuditItemUpdated, change.After); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range diff.Added {
+		if err := add(models.AuditItemAdded, item); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func (r *EventSourcedRepository) event(ctx context.Context, eventType string, data []byte) cartEvent {
+	return cartEvent{Type: eventType, Data: data, Actor: models.ActorFromContext(ctx), At: r.carts.now().UTC()}
+}
+
+// watch runs fn in optimistic transaction over streams and snapshots of
+// carts, retrying when any of them was modified before fn commits
+func (r *EventSourcedRepository) watch(ctx context.Context, fn func(tx *redis.Tx) error, cartIDs ...string) error {
+	keys := make([]string, 0, 2*len(cartIDs))
+	for _, id := range cartIDs {
+		keys = append(keys, r.eventsKey(ctx, id), r.snapshotKey(ctx, id))
+	}
+	for i := 0; i < maxTxRetries; i++ {
+		err := r.carts.client.Watch(ctx, fn, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("error updating carts %v: %w", cartIDs, redis.TxFailedErr)
+}
+
+// mutate applies fn to a copy of the folded cart and appends events of the
+// items fn changed, fn can be retried so it must not have side effects
+func (r *EventSourcedRepository) mutate(ctx context.Context, cartID string, fn func(cart *models.Cart) error) error {
+	return r.watch(ctx, func(tx *redis.Tx) error {
+		before, pending, err := r.fold(ctx, tx, cartID)
+		if err != nil {
+			return err
+		}
+		after := *before
+		after.LineItems = append([]models.LineItem(nil), before.LineItems...)
+		if err := fn(&after); err != nil {
+			return err
+		}
+		events, err := r.itemEvents(ctx, before, &after)
+		if err != nil {
+			return err
+		}
+		return r.commit(ctx, tx, cartChange{cartID: cartID, cart: &after, pending: pending, events: events})
+	}, cartID)
+}
+
+// commit appends events of changes in a single MULTI/EXEC, carts which
+// reached snapshotEvery events since their snapshot are snapshotted after
+func (r *EventSourcedRepository) commit(ctx context.Context, tx *redis.Tx, changes ...cartChange) error {
+	appended := make([][]*redis.StringCmd, len(changes))
+	_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, change := range changes {
+			key := r.eventsKey(ctx, change.cartID)
+			for _, event := range change.events {
+				appended[i] = append(appended[i], pipe.XAdd(ctx, &redis.XAddArgs{
+					Stream: key,
+					Values: []interface{}{"type", event.Type, "data", event.Data, "actor", event.Actor, "at", event.At.Format(time.RFC3339Nano)},
+				}))
+			}
+			value, err := r.carts.encodeCart(change.cart)
+			if err != nil {
+				return err
+			}
+			r.carts.publishChange(ctx, pipe, change.cartID, value)
+			r.carts.countCart(ctx, pipe, change.cart)
+			if change.claimName {
+				r.carts.claimName(ctx, pipe, change.cart)
+			}
+			if r.carts.cartTTL > 0 {
+				pipe.PExpire(ctx, key, r.carts.cartTTL)
+				pipe.PExpire(ctx, r.snapshotKey(ctx, change.cartID), r.carts.cartTTL)
+				pipe.PExpire(ctx, r.carts.itemSequenceKey(ctx, change.cartID), r.carts.cartTTL)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	for i, change := range changes {
+		if n := len(appended[i]); n > 0 && r.snapshotEvery > 0 && change.pending+n >= r.snapshotEvery {
+			r.snapshot(ctx, change.cartID, appended[i][n-1].Val(), change.cart)
+		}
+		r.carts.indexOwner(ctx, change.cart)
+	}
+	return nil
+}
+
+// snapshot stores cart folded up to the event last, snapshots only shorten
+// folding so failures are logged and don't fail the mutation
+func (r *EventSourcedRepository) snapshot(ctx context.Context, cartID, last string, cart *models.Cart) {
+	value, err := r.carts.encodeCart(cart)
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to encode cart snapshot")
+		return
+	}
+	key := r.snapshotKey(ctx, cartID)
+	_, err = r.carts.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "last", last, "cart", value)
+		if r.carts.cartTTL > 0 {
+			pipe.PExpire(ctx, key, r.carts.cartTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to snapshot cart")
+	}
+}
+
+// Update appends the whole cart as a cart_replaced event, creating the cart
+// when it has no stream yet
+func (r *EventSourcedRepository) Update(ctx context.Context, cart *models.Cart) error {
+	return r.replace(ctx, cart, false)
+}
+
+// replace appends a cart_replaced event of the cart, with claimName the
+// free name of the cart is claimed in the same MULTI, see
+// CartRepository.CreateNamed
+func (r *EventSourcedRepository) replace(ctx context.Context, cart *models.Cart, claimName bool) error {
+	value, err := r.carts.encodeCart(cart)
+	if err != nil {
+		return err
+	}
+	cartID := cart.ID.String()
+	return r.watch(ctx, func(tx *redis.Tx) error {
+		if claimName {
+			err := r.carts.checkName(ctx, tx, cart, func(ctx context.Context, tx *redis.Tx, cartID string) (*models.Cart, error) {
+				cart, _, err := r.fold(ctx, tx, cartID)
+				return cart, err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		pending, err := tx.XLen(ctx, r.eventsKey(ctx, cartID)).Result()
+		if err != nil {
+			return fmt.Errorf("error reading events of cart %s: %w", cartID, err)
+		}
+		event := r.event(ctx, models.AuditCartReplaced, value)
+		// the replacing event makes older ones irrelevant, counting all of
+		// them only snapshots sooner
+		return r.commit(ctx, tx, cartChange{cartID: cartID, cart: cart, pending: int(pending), events: []cartEvent{event}, claimName: claimName})
+	}, cartID)
+}
+
+// Delete removes the stream of the cart together with its history
+func (r *EventSourcedRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.carts.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.eventsKey(ctx, id), r.snapshotKey(ctx, id), r.carts.itemSequenceKey(ctx, id))
+		r.carts.uncountCart(ctx, pipe, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	r.carts.publishDeleted(ctx, id)
+	return nil
+}
+
+// DeleteIfMatch removes the cart only when its current ETag is one of etags,
+// see CartRepository.DeleteIfMatch
+func (r *EventSourcedRepository) DeleteIfMatch(ctx context.Context, id string, etags []string) error {
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cart, _, err := r.fold(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !matchesETag(cart, etags) {
+			return ErrETagMismatch
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, r.eventsKey(ctx, id), r.snapshotKey(ctx, id), r.carts.itemSequenceKey(ctx, id))
+			r.carts.uncountCart(ctx, pipe, id)
+			return nil
+		})
+		return err
+	}, id)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	r.carts.publishDeleted(ctx, id)
+	return nil
+}
+
+// Watch streams snapshots of the cart folded after each commit, see
+// CartRepository.Watch
+func (r *EventSourcedRepository) Watch(ctx context.Context, cartID string) (<-chan *models.Cart, error) {
+	return r.carts.Watch(ctx, cartID)
+}
+
+// CheckCart validates every line item of a cart which is written as a whole
+func (r *EventSourcedRepository) CheckCart(cart *models.Cart) error {
+	return r.carts.CheckCart(cart)
+}
+
+// AddItem adds the item to the cart, summing quantity of its product
+func (r *EventSourcedRepository) AddItem(ctx context.Context, cartID string, item models.LineItem) error {
+	return r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		itemID, err := r.carts.mergeItem(ctx, cart, item)
+		if err != nil {
+			return err
+		}
+		return r.carts.checkItem(cart, itemID)
+	})
+}
+
+// UpdateItem replaces details and quantity of the item
+func (r *EventSourcedRepository) UpdateItem(ctx context.Context, cartID string, itemID int, item models.LineItem) error {
+	return r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		if err := updateLine(cart, itemID, item); err != nil {
+			return err
+		}
+		return r.carts.checkItem(cart, itemID)
+	})
+}
+
+// DeleteItem removes the item from the cart
+func (r *EventSourcedRepository) DeleteItem(ctx context.Context, cartID string, itemID int) error {
+	return r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		return removeLine(cart, itemID)
+	})
+}
+
+// DecrementItem decrements quantity of the item by one, see
+// CartRepository.DecrementItem
+func (r *EventSourcedRepository) DecrementItem(ctx context.Context, cartID string, itemID int) error {
+	return r.AdjustItemQuantity(ctx, cartID, itemID, -1, true)
+}
+
+// AdjustItemQuantity adds delta to quantity of the item, see
+// CartRepository.AdjustItemQuantity
+func (r *EventSourcedRepository) AdjustItemQuantity(ctx context.Context, cartID string, itemID int, delta int, clamp bool) error {
+	return r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		if err := adjustQuantity(cart, itemID, delta, clamp); err != nil {
+			return err
+		}
+		if delta <= 0 {
+			return nil
+		}
+		return r.carts.checkItem(cart, itemID)
+	})
+}
+
+// AddItems adds items in one transaction, see CartRepository.AddItems
+func (r *EventSourcedRepository) AddItems(ctx context.Context, cartID string, items []models.LineItem, partial bool) ([]error, error) {
+	var itemErrs []error
+	err := r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		var err error
+		if itemErrs, _, err = r.carts.addItems(ctx, cart, items, partial); err != nil {
+			return err
+		}
+		return unchangedIfAllFailed(itemErrs)
+	})
+	return bulkResult(itemErrs, err)
+}
+
+// DeleteItems removes items in one transaction, see CartRepository.DeleteItems
+func (r *EventSourcedRepository) DeleteItems(ctx context.Context, cartID string, itemIDs []int, partial bool) ([]error, error) {
+	var itemErrs []error
+	err := r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		var err error
+		if itemErrs, err = deleteItems(cart, itemIDs, partial); err != nil {
+			return err
+		}
+		return unchangedIfAllFailed(itemErrs)
+	})
+	return bulkResult(itemErrs, err)
+}
+
+// MoveItem moves the item between carts in one transaction, see
+// CartRepository.MoveItem
+func (r *EventSourcedRepository) MoveItem(ctx context.Context, sourceID, targetID string, itemID int) error {
+	if sourceID == targetID {
+		return ErrSameCart
+	}
+	return r.watch(ctx, func(tx *redis.Tx) error {
+		changes := make([]cartChange, 2)
+		for i, id := range []string{sourceID, targetID} {
+			cart, pending, err := r.fold(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			changes[i] = cartChange{cartID: id, cart: cart, pending: pending}
+		}
+		source, target := *changes[0].cart, *changes[1].cart
+		source.LineItems = append([]models.LineItem(nil), source.LineItems...)
+		target.LineItems = append([]models.LineItem(nil), target.LineItems...)
+
+		var item models.LineItem
+		for _, line := range source.LineItems {
+			if line.ItemID == itemID {
+				item = line
+			}
+		}
+		if err := removeLine(&source, itemID); err != nil {
+			return err
+		}
+		targetItemID, err := r.carts.mergeItem(ctx, &target, item)
+		if err != nil {
+			return err
+		}
+		if err := r.carts.checkItem(&target, targetItemID); err != nil {
+			return err
+		}
+
+		for i, after := range []*models.Cart{&source, &target} {
+			if changes[i].events, err = r.itemEvents(ctx, changes[i].cart, after); err != nil {
+				return err
+			}
+			changes[i].cart = after
+		}
+		return r.commit(ctx, tx, changes...)
+	}, sourceID, targetID)
+}
+
+// Touch refreshes ttl of the cart without changing it, see
+// CartRepository.Touch
+func (r *EventSourcedRepository) Touch(ctx context.Context, cartID string) error {
+	if _, err := r.Get(ctx, cartID); err != nil {
+		return err
+	}
+	if r.carts.cartTTL <= 0 {
+		return nil
+	}
+	var touched *redis.BoolCmd
+	_, err := r.carts.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		touched = pipe.PExpire(ctx, r.eventsKey(ctx, cartID), r.carts.cartTTL)
+		pipe.PExpire(ctx, r.snapshotKey(ctx, cartID), r.carts.cartTTL)
+		pipe.PExpire(ctx, r.carts.itemSequenceKey(ctx, cartID), r.carts.cartTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error touching cart %s: %w", cartID, err)
+	}
+	if !touched.Val() {
+		// deleted after it was read
+		return ErrCartNotFound
+	}
+	r.carts.touchCount(context.WithoutCancel(ctx), cartID)
+	return nil
+}
+
+// Summary returns total and item count of the folded cart
+func (r *EventSourcedRepository) Summary(ctx context.Context, cartID string) (models.CartSummary, error) {
+	cart, err := r.Get(ctx, cartID)
+	if err != nil {
+		return models.CartSummary{}, err
+	}
+	return summarize(cart)
+}
+
+// History returns every event of the cart as an audit entry, oldest first.
+// The quantity of removed items is zero
+func (r *EventSourcedRepository) History(ctx context.Context, cartID string) ([]models.AuditEntry, error) {
+	messages, err := r.carts.client.XRange(ctx, r.eventsKey(ctx, cartID), "-", "+").Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading history of cart %s: %w", cartID, err)
+	}
+	entries := make([]models.AuditEntry, 0, len(messages))
+	for _, message := range messages {
+		event, err := parseEvent(message)
+		if err != nil {
+			return nil, fmt.Errorf("cart %s: %w", cartID, err)
+		}
+		entry := models.AuditEntry{At: event.At, Action: event.Type, Actor: event.Actor}
+		if event.Type != models.AuditCartReplaced {
+			var item models.LineItem
+			if err := json.Unmarshal(event.Data, &item); err != nil {
+				return nil, fmt.Errorf("invalid event %s of cart %s: %w", message.ID, cartID, err)
+			}
+			entry.ItemID, entry.Quantity = item.ItemID, item.Quantity
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
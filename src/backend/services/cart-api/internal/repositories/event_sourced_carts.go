@@ -0,0 +1,262 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Count returns the number of carts which are not expired, completed or
+// cancelled, see CartRepository.Count
+func (r *EventSourcedRepository) Count(ctx context.Context) (int64, error) {
+	return r.carts.Count(ctx)
+}
+
+// ScanCartIDs scans ids of carts having a stream, see
+// CartRepository.ScanCartIDs
+func (r *EventSourcedRepository) ScanCartIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	prefix := r.eventsKey(ctx, "")
+	keys, next, err := r.carts.client.Scan(ctx, cursor, prefix+"*", count).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error scanning carts at %d: %w", cursor, err)
+	}
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		id := strings.TrimPrefix(key, prefix)
+		if _, err := uuid.Parse(id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, next, nil
+}
+
+// AddItemToCarts adds item to every cart like AddItem, the outcome of every
+// cart is returned at its index. Carts are changed one by one, duplicate
+// ids get the item once, see CartRepository.AddItemToCarts for once
+func (r *EventSourcedRepository) AddItemToCarts(ctx context.Context, cartIDs []string, item models.LineItem, once bool) []error {
+	errs := make([]error, len(cartIDs))
+	first := make(map[string]int, len(cartIDs))
+	for i, id := range cartIDs {
+		if j, ok := first[id]; ok {
+			errs[i] = errs[j]
+			continue
+		}
+		first[id] = i
+		errs[i] = r.mutate(ctx, id, func(cart *models.Cart) error {
+			_, err := r.carts.addTo(ctx, cart, item, once)
+			return err
+		})
+	}
+	return errs
+}
+
+// CreateCarts appends a cart_replaced event to the stream of every cart,
+// carts are created one by one so earlier ones stay when a later one fails
+func (r *EventSourcedRepository) CreateCarts(ctx context.Context, carts []*models.Cart) error {
+	for _, cart := range carts {
+		if err := r.Update(ctx, cart); err != nil {
+			return fmt.Errorf("error creating cart %s: %w", cart.ID, err)
+		}
+	}
+	return nil
+}
+
+// RepriceItems updates the unit price of the product in a batch of scanned
+// carts, see CartRepository.RepriceItems
+func (r *EventSourcedRepository) RepriceItems(ctx context.Context, productID int, price models.Money, cursor uint64, count int64) ([]string, uint64, error) {
+	ids, next, err := r.ScanCartIDs(ctx, cursor, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updated := []string{}
+	for _, id := range ids {
+		err := r.mutate(ctx, id, func(cart *models.Cart) error {
+			return repriceItem(cart, productID, price)
+		})
+		if errors.Is(err, ErrCartNotFound) || errors.Is(err, errUnchanged) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		updated = append(updated, id)
+	}
+	return updated, next, nil
+}
+
+// DiscontinueItems removes lines of the product from a batch of scanned
+// carts, see CartRepository.DiscontinueItems
+func (r *EventSourcedRepository) DiscontinueItems(ctx context.Context, productID int, cursor uint64, count int64) ([]*models.Cart, uint64, error) {
+	ids, next, err := r.ScanCartIDs(ctx, cursor, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updated := []*models.Cart{}
+	for _, id := range ids {
+		var result *models.Cart
+		err := r.mutate(ctx, id, func(cart *models.Cart) error {
+			result = cart
+			removed, err := removeProduct(cart, productID)
+			if err != nil {
+				return err
+			}
+			if len(removed) == 0 {
+				return errUnchanged
+			}
+			return nil
+		})
+		if errors.Is(err, ErrCartNotFound) || errors.Is(err, errUnchanged) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		updated = append(updated, result)
+	}
+	return updated, next, nil
+}
+
+// RecomputeTotal returns the cart, totals of folded carts are always
+// computed from their line items
+func (r *EventSourcedRepository) RecomputeTotal(ctx context.Context, cartID string) (*models.Cart, error) {
+	return r.Get(ctx, cartID)
+}
+
+// RecomputeTotals scans a batch of carts like CartRepository.RecomputeTotals,
+// folded totals are never stale so none is corrected
+func (r *EventSourcedRepository) RecomputeTotals(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	_, next, err := r.ScanCartIDs(ctx, cursor, count)
+	if err != nil {
+		return nil, 0, err
+	}
+	return []string{}, next, nil
+}
+
+// CreateNamed stores new cart under its name for its owner, see
+// CartRepository.CreateNamed
+func (r *EventSourcedRepository) CreateNamed(ctx context.Context, cart *models.Cart) error {
+	return r.replace(ctx, cart, true)
+}
+
+// UpdateCoupons applies fn to the folded cart and appends it as a
+// cart_replaced event, see CartRepository.UpdateCoupons
+func (r *EventSourcedRepository) UpdateCoupons(ctx context.Context, cartID string, fn func(cart *models.Cart) error) (*models.Cart, error) {
+	var result *models.Cart
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cart, pending, err := r.fold(ctx, tx, cartID)
+		if err != nil {
+			return err
+		}
+		if err := fn(cart); err != nil {
+			return err
+		}
+		value, err := r.carts.encodeCart(cart)
+		if err != nil {
+			return err
+		}
+		result = cart
+		event := r.event(ctx, models.AuditCartReplaced, value)
+		return r.commit(ctx, tx, cartChange{cartID: cartID, cart: cart, pending: pending, events: []cartEvent{event}})
+	}, cartID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// NamedCarts returns named carts of the user ordered by name
+func (r *EventSourcedRepository) NamedCarts(ctx context.Context, userID string) ([]*models.Cart, error) {
+	return r.carts.namedCarts(ctx, userID, r.Get)
+}
+
+// activeCart returns id of the active cart of the user, see
+// CartRepository.CartByUser
+func (r *EventSourcedRepository) activeCart(ctx context.Context, c redis.Cmdable, userID string) (string, error) {
+	cartID, err := c.Get(ctx, r.carts.key(ctx, userKeyPrefix+userID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", ErrCartNotFound
+		}
+		return "", fmt.Errorf("error getting cart of user %s: %w", userID, err)
+	}
+	if _, _, err := r.fold(ctx, c, cartID); err != nil {
+		return "", err
+	}
+	return cartID, nil
+}
+
+// Transfer moves the cart to the user to by a cart_replaced event, see
+// CartRepository.Transfer. The user index follows the commit
+func (r *EventSourcedRepository) Transfer(ctx context.Context, cartID, from, to string, replace bool) (*models.Cart, error) {
+	var result *models.Cart
+	var previous string
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cart, pending, err := r.fold(ctx, tx, cartID)
+		if err != nil {
+			return err
+		}
+		owner := ownerOf(cart)
+		previous = owner
+		if from != "" && from != owner {
+			return fmt.Errorf("%w: cart %s", ErrNotOwner, cartID)
+		}
+
+		if !replace {
+			if err := tx.Watch(ctx, r.carts.key(ctx, userKeyPrefix+to)).Err(); err != nil {
+				return err
+			}
+			active, err := r.activeCart(ctx, tx, to)
+			if err != nil && !errors.Is(err, ErrCartNotFound) {
+				return err
+			}
+			if active != "" && active != cartID {
+				return fmt.Errorf("%w: user %s", ErrActiveCart, to)
+			}
+		}
+
+		cart.UserID = &to
+		value, err := r.carts.encodeCart(cart)
+		if err != nil {
+			return err
+		}
+		result = cart
+		event := r.event(ctx, models.AuditCartReplaced, value)
+		return r.commit(ctx, tx, cartChange{cartID: cartID, cart: cart, pending: pending, events: []cartEvent{event}})
+	}, cartID)
+	if err != nil {
+		return nil, err
+	}
+	if previous != "" && previous != to {
+		r.forgetOwner(context.WithoutCancel(ctx), previous, cartID)
+	}
+	return result, nil
+}
+
+// forgetOwner drops the active cart of the user when it is still cartID
+func (r *EventSourcedRepository) forgetOwner(ctx context.Context, userID, cartID string) {
+	key := r.carts.key(ctx, userKeyPrefix+userID)
+	err := r.carts.client.Watch(ctx, func(tx *redis.Tx) error {
+		indexed, err := tx.Get(ctx, key).Result()
+		if err != nil || indexed != cartID {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil && err != redis.Nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to forget cart owner")
+	}
+}
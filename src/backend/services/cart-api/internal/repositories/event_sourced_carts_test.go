@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSourcedRepositoryCarts(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestEventSourcedRepository(t, 0)
+	price := models.Money{Minor: 100}
+
+	newCart := func(t *testing.T, user string) *models.Cart {
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, LineItems: []models.LineItem{{ItemID: 1, ProductID: 7, UnitPrice: price, Quantity: 1}}}
+		if user != "" {
+			cart.UserID = &user
+		}
+		require.NoError(t, repo.Update(ctx, cart))
+		return cart
+	}
+
+	t.Run("carts should be counted until deleted", func(t *testing.T) {
+		before, err := repo.Count(ctx)
+		require.NoError(t, err)
+		cart := newCart(t, "")
+		n, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before+1, n)
+
+		require.NoError(t, repo.Delete(ctx, cart.ID.String()))
+		n, err = repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before, n)
+	})
+
+	t.Run("reprice should change scanned carts", func(t *testing.T) {
+		cart := newCart(t, "")
+		newPrice := models.Money{Minor: 300}
+		var updated []string
+		var cursor uint64
+		for {
+			ids, next, err := repo.RepriceItems(ctx, 7, newPrice, cursor, 10)
+			require.NoError(t, err)
+			updated = append(updated, ids...)
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+		assert.Contains(t, updated, cart.ID.String())
+
+		got, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, newPrice, got.LineItems[0].UnitPrice)
+		assert.Equal(t, newPrice, got.Total)
+	})
+
+	t.Run("discontinue should remove lines of the product", func(t *testing.T) {
+		cart := newCart(t, "")
+		removed, _, err := repo.DiscontinueItems(ctx, 7, 0, 100)
+		require.NoError(t, err)
+		var ids []uuid.UUID
+		for _, c := range removed {
+			ids = append(ids, c.ID)
+		}
+		assert.Contains(t, ids, cart.ID)
+
+		got, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Empty(t, got.LineItems)
+	})
+
+	t.Run("batch add should report missing carts", func(t *testing.T) {
+		cart := newCart(t, "")
+		errs := repo.AddItemToCarts(ctx, []string{cart.ID.String(), uuid.NewString(), cart.ID.String()}, models.LineItem{ItemID: 2, UnitPrice: price, Quantity: 1}, false)
+		require.Len(t, errs, 3)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], ErrCartNotFound)
+		assert.NoError(t, errs[2])
+
+		got, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Len(t, got.LineItems, 2)
+	})
+
+	t.Run("coupons should be appended as the replaced cart", func(t *testing.T) {
+		cart := newCart(t, "")
+		_, err := repo.UpdateCoupons(ctx, cart.ID.String(), func(cart *models.Cart) error {
+			cart.Coupons = []string{"TEN"}
+			return nil
+		})
+		require.NoError(t, err)
+
+		stored, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TEN"}, stored.Coupons)
+		assert.Len(t, stored.LineItems, 1)
+	})
+
+	t.Run("named carts should be unique per user", func(t *testing.T) {
+		user := "lists-user"
+		cart := &models.Cart{ID: uuid.New(), UserID: &user, Name: "weekly", Status: models.CartStatusNew}
+		require.NoError(t, repo.CreateNamed(ctx, cart))
+		err := repo.CreateNamed(ctx, &models.Cart{ID: uuid.New(), UserID: &user, Name: "weekly", Status: models.CartStatusNew})
+		assert.ErrorIs(t, err, ErrDuplicateName)
+
+		named, err := repo.NamedCarts(ctx, user)
+		require.NoError(t, err)
+		require.Len(t, named, 1)
+		assert.Equal(t, cart.ID, named[0].ID)
+	})
+
+	t.Run("transfer should change the owner", func(t *testing.T) {
+		active := newCart(t, "transfer-to")
+		cart := newCart(t, "transfer-from")
+
+		_, err := repo.Transfer(ctx, cart.ID.String(), "someone", "transfer-to", false)
+		assert.ErrorIs(t, err, ErrNotOwner)
+		_, err = repo.Transfer(ctx, cart.ID.String(), "transfer-from", "transfer-to", false)
+		assert.ErrorIs(t, err, ErrActiveCart)
+
+		require.NoError(t, repo.Delete(ctx, active.ID.String()))
+		transferred, err := repo.Transfer(ctx, cart.ID.String(), "transfer-from", "transfer-to", false)
+		require.NoError(t, err)
+		assert.Equal(t, "transfer-to", *transferred.UserID)
+
+		got, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, "transfer-to", *got.UserID)
+		_, err = repo.activeCart(ctx, repo.carts.client, "transfer-from")
+		assert.ErrorIs(t, err, ErrCartNotFound)
+		id, err := repo.activeCart(ctx, repo.carts.client, "transfer-to")
+		require.NoError(t, err)
+		assert.Equal(t, cart.ID.String(), id)
+	})
+}
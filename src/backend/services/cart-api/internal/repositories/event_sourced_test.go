@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEventSourcedRepository(tb testing.TB, snapshotEvery int, opts ...Option) (*EventSourcedRepository, *miniredis.Miniredis) {
+	tb.Helper()
+	mr := miniredis.RunT(tb)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tb.Cleanup(func() { _ = client.Close() })
+	return NewEventSourcedRepository(client, snapshotEvery, opts...), mr
+}
+
+// cartStore is what event sourced and single value repositories share
+type cartStore interface {
+	Get(ctx context.Context, cartID string) (*models.Cart, error)
+	Update(ctx context.Context, cart *models.Cart) error
+	AddItem(ctx context.Context, cartID string, item models.LineItem) error
+	UpdateItem(ctx context.Context, cartID string, itemID int, item models.LineItem) error
+	DeleteItem(ctx context.Context, cartID string, itemID int) error
+	DecrementItem(ctx context.Context, cartID string, itemID int) error
+	AdjustItemQuantity(ctx context.Context, cartID string, itemID int, delta int, clamp bool) error
+	AddItems(ctx context.Context, cartID string, items []models.LineItem, partial bool) ([]error, error)
+	DeleteItems(ctx context.Context, cartID string, itemIDs []int, partial bool) ([]error, error)
+}
+
+func TestEventSourcedRepository(t *testing.T) {
+	ctx := context.Background()
+	price := models.Money{Minor: 100}
+
+	// apply makes the same changes through repo, the cart folded from events
+	// must equal the cart a single value repository stores
+	apply := func(t *testing.T, repo cartStore, cart *models.Cart) *models.Cart {
+		cartID := cart.ID.String()
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: price, Quantity: 1, ProductName: "tea"}))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 2, UnitPrice: price, Quantity: 2}))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: price, Quantity: 2}))
+		require.NoError(t, repo.UpdateItem(ctx, cartID, 2, models.LineItem{UnitPrice: models.Money{Minor: 250}, Quantity: 4, ProductName: "coffee"}))
+		require.NoError(t, repo.AdjustItemQuantity(ctx, cartID, 2, -1, false))
+		require.NoError(t, repo.DecrementItem(ctx, cartID, 1))
+		_, err := repo.AddItems(ctx, cartID, []models.LineItem{{ItemID: 3, UnitPrice: price, Quantity: 1}, {ItemID: 4, UnitPrice: price, Quantity: 1}}, false)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteItem(ctx, cartID, 3))
+		_, err = repo.DeleteItems(ctx, cartID, []int{4, 9}, true)
+		require.NoError(t, err)
+
+		result, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("folding events should reconstruct the cart", func(t *testing.T) {
+		repo, _ := newTestEventSourcedRepository(t, 0)
+		userID := "user-1"
+		cart := &models.Cart{ID: uuid.New(), UserID: &userID, LineItems: []models.LineItem{}}
+		got := apply(t, repo, cart)
+
+		expected, _ := newTestRepository(t)
+		want := apply(t, expected, &models.Cart{ID: cart.ID, UserID: &userID, LineItems: []models.LineItem{}})
+		assert.Equal(t, want, got)
+		assert.Equal(t, []models.LineItem{
+			{ItemID: 1, UnitPrice: price, Quantity: 2, ProductName: "tea"},
+			{ItemID: 2, UnitPrice: models.Money{Minor: 250}, Quantity: 3, ProductName: "coffee"},
+		}, got.LineItems)
+		assert.Equal(t, int64(950), got.Total.Minor)
+	})
+
+	t.Run("snapshots should not change the folded cart", func(t *testing.T) {
+		repo, mr := newTestEventSourcedRepository(t, 3)
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		got := apply(t, repo, cart)
+
+		key := snapshotKeyPrefix + cart.ID.String()
+		require.True(t, mr.Exists(key), "snapshot should be taken after 3 events")
+		unsnapshotted, _ := newTestEventSourcedRepository(t, 0)
+		assert.Equal(t, apply(t, unsnapshotted, &models.Cart{ID: cart.ID, LineItems: []models.LineItem{}}), got)
+	})
+
+	t.Run("replacing should reset items", func(t *testing.T) {
+		repo, _ := newTestEventSourcedRepository(t, 0)
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		apply(t, repo, cart)
+
+		replaced := &models.Cart{ID: cart.ID, LineItems: []models.LineItem{{ItemID: 7, UnitPrice: price, Quantity: 1}}}
+		require.NoError(t, repo.Update(ctx, replaced))
+		got, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, replaced.LineItems, got.LineItems)
+		assert.Equal(t, price, got.Total)
+	})
+
+	t.Run("history should list events", func(t *testing.T) {
+		repo, _ := newTestEventSourcedRepository(t, 2)
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		repo.carts.now = func() time.Time { return now }
+		cartID := uuid.New()
+		require.NoError(t, repo.Update(ctx, &models.Cart{ID: cartID, LineItems: []models.LineItem{}}))
+		actorCtx := models.ContextWithActor(ctx, "user-1")
+		require.NoError(t, repo.AddItem(actorCtx, cartID.String(), models.LineItem{ItemID: 5, UnitPrice: price, Quantity: 2}))
+		require.NoError(t, repo.AdjustItemQuantity(actorCtx, cartID.String(), 5, 1, false))
+		require.NoError(t, repo.DeleteItem(ctx, cartID.String(), 5))
+
+		entries, err := repo.History(ctx, cartID.String())
+		require.NoError(t, err)
+		assert.Equal(t, []models.AuditEntry{
+			{At: now, Action: models.AuditCartReplaced},
+			{At: now, Action: models.AuditItemAdded, Actor: "user-1", ItemID: 5, Quantity: 2},
+			{At: now, Action: models.AuditItemUpdated, Actor: "user-1", ItemID: 5, Quantity: 3},
+			{At: now, Action: models.AuditItemRemoved, ItemID: 5},
+		}, entries)
+	})
+
+	t.Run("moved items should be removed and added by events", func(t *testing.T) {
+		repo, _ := newTestEventSourcedRepository(t, 0)
+		source, target := uuid.New(), uuid.New()
+		require.NoError(t, repo.Update(ctx, &models.Cart{ID: source, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: price, Quantity: 1}}}))
+		require.NoError(t, repo.Update(ctx, &models.Cart{ID: target, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: price, Quantity: 2}}}))
+
+		require.NoError(t, repo.MoveItem(ctx, source.String(), target.String(), 1))
+		assert.ErrorIs(t, repo.MoveItem(ctx, source.String(), target.String(), 1), ErrItemNotFound)
+
+		moved, err := repo.Get(ctx, source.String())
+		require.NoError(t, err)
+		assert.Empty(t, moved.LineItems)
+		merged, err := repo.Get(ctx, target.String())
+		require.NoError(t, err)
+		assert.Equal(t, []models.LineItem{{ItemID: 1, UnitPrice: price, Quantity: 3}}, merged.LineItems)
+	})
+
+	t.Run("rejected items should not append events", func(t *testing.T) {
+		repo, mr := newTestEventSourcedRepository(t, 0, WithLimits(Limits{MaxCartTotal: models.Money{Minor: 150}}))
+		cartID := uuid.New()
+		require.NoError(t, repo.Update(ctx, &models.Cart{ID: cartID, LineItems: []models.LineItem{}}))
+
+		err := repo.AddItem(ctx, cartID.String(), models.LineItem{ItemID: 1, UnitPrice: price, Quantity: 2})
+		assert.ErrorIs(t, err, models.ErrBusinessRule)
+		entries, err := mr.Stream(eventsKeyPrefix + cartID.String())
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("deleted and completed carts should be missing", func(t *testing.T) {
+		repo, mr := newTestEventSourcedRepository(t, 0, WithCartTTL(time.Hour))
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, UnitPrice: price, Quantity: 1}))
+		assert.Equal(t, time.Hour, mr.TTL(eventsKeyPrefix+cart.ID.String()))
+
+		require.NoError(t, repo.Delete(ctx, cart.ID.String()))
+		_, err := repo.Get(ctx, cart.ID.String())
+		assert.ErrorIs(t, err, ErrCartNotFound)
+		assert.ErrorIs(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, Quantity: 1}), ErrCartNotFound)
+
+		completed := &models.Cart{ID: uuid.New(), Status: models.CartStatusCompleted, LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, completed))
+		_, err = repo.Get(ctx, completed.ID.String())
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+}
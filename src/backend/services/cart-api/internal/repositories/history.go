@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// The audit log of a cart is a list of entries oldest first next to it
+const historyKeyPrefix = "history:"
+
+// WithHistory keeps last n audit entries of every cart, zero disables the
+// audit log
+func WithHistory(n int) Option {
+	return func(r *CartRepository) {
+		r.history = n
+	}
+}
+
+// audit appends entries to the audit log of the cart with the actor of ctx,
+// the log only serves investigations so failures are logged and don't fail
+// the mutation. The log outlives a deleted cart until it expires
+func (r *CartRepository) audit(ctx context.Context, cartID string, entries ...models.AuditEntry) {
+	if r.history <= 0 || len(entries) == 0 {
+		return
+	}
+	actor := models.ActorFromContext(ctx)
+	values := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		entry.At = r.now().UTC()
+		entry.Actor = actor
+		value, err := json.Marshal(entry)
+		if err != nil {
+			log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to encode audit entry")
+			return
+		}
+		values[i] = value
+	}
+	ctx = context.WithoutCancel(ctx)
+	key := r.key(ctx, historyKeyPrefix+cartID)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, values...)
+		pipe.LTrim(ctx, key, int64(-r.history), -1)
+		if r.cartTTL > 0 {
+			pipe.PExpire(ctx, key, r.cartTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to record audit entry")
+	}
+}
+
+// itemEntry returns entry of action on the item of cart with its resulting
+// quantity, the action is removal when the cart has no such item anymore
+func itemEntry(action string, cart *models.Cart, itemID int) models.AuditEntry {
+	for _, item := range cart.LineItems {
+		if item.ItemID == itemID {
+			return models.AuditEntry{Action: action, ItemID: itemID, Quantity: item.Quantity}
+		}
+	}
+	return models.AuditEntry{Action: models.AuditItemRemoved, ItemID: itemID}
+}
+
+// History returns the audit log of the cart, oldest entry first
+func (r *CartRepository) History(ctx context.Context, cartID string) ([]models.AuditEntry, error) {
+	values, err := r.reader.LRange(ctx, r.key(ctx, historyKeyPrefix+cartID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading history of cart %s: %w", cartID, err)
+	}
+	entries := make([]models.AuditEntry, 0, len(values))
+	for _, value := range values {
+		var entry models.AuditEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry of cart %s: %w", cartID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
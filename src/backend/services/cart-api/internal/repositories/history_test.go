@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	ctx := models.ContextWithActor(context.Background(), "user-1")
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newRepo := func(t *testing.T, n int) (*CartRepository, string) {
+		repo, _ := newTestRepository(t, WithHistory(n))
+		repo.now = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, cart))
+		return repo, cart.ID.String()
+	}
+	actions := func(entries []models.AuditEntry) []string {
+		var actions []string
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+		}
+		return actions
+	}
+
+	t.Run("mutations should append entries in order", func(t *testing.T) {
+		repo, cartID := newRepo(t, 100)
+		item := models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 2}
+		require.NoError(t, repo.AddItem(ctx, cartID, item))
+		require.NoError(t, repo.AddItem(context.Background(), cartID, item))
+		require.NoError(t, repo.UpdateItem(ctx, cartID, 1, models.LineItem{UnitPrice: models.Money{Minor: 100}, Quantity: 5}))
+		require.NoError(t, repo.DecrementItem(ctx, cartID, 1))
+		_, err := repo.AddItems(ctx, cartID, []models.LineItem{{ItemID: 2, UnitPrice: models.Money{Minor: 100}, Quantity: 1}}, false)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteItem(ctx, cartID, 1))
+		require.NoError(t, repo.Delete(ctx, cartID))
+
+		entries, err := repo.History(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			models.AuditItemAdded, models.AuditItemAdded, models.AuditItemUpdated, models.AuditItemUpdated,
+			models.AuditItemAdded, models.AuditItemRemoved, models.AuditCartDeleted,
+		}, actions(entries))
+
+		assert.Equal(t, models.AuditEntry{At: entries[0].At, Action: models.AuditItemAdded, Actor: "user-1", ItemID: 1, Quantity: 2}, entries[0])
+		assert.Empty(t, entries[1].Actor, "changes without actor should be recorded without one")
+		assert.Equal(t, 4, entries[1].Quantity)
+		assert.Equal(t, 5, entries[2].Quantity)
+		assert.Equal(t, 4, entries[3].Quantity)
+		assert.Equal(t, 2, entries[4].ItemID)
+		for i := 1; i < len(entries); i++ {
+			assert.True(t, entries[i].At.After(entries[i-1].At))
+		}
+	})
+
+	t.Run("decrement to zero should be recorded as removal", func(t *testing.T) {
+		repo, cartID := newRepo(t, 100)
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		require.NoError(t, repo.DecrementItem(ctx, cartID, 1))
+
+		entries, err := repo.History(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{models.AuditItemAdded, models.AuditItemRemoved}, actions(entries))
+	})
+
+	t.Run("log should keep the newest entries", func(t *testing.T) {
+		repo, cartID := newRepo(t, 3)
+		for quantity := 1; quantity <= 5; quantity++ {
+			require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		}
+
+		entries, err := repo.History(ctx, cartID)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, 3, entries[0].Quantity)
+		assert.Equal(t, 5, entries[2].Quantity)
+	})
+
+	t.Run("disabled history should record nothing", func(t *testing.T) {
+		repo, cartID := newRepo(t, 0)
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+
+		entries, err := repo.History(ctx, cartID)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// itemSequenceKeyPrefix keys the last item id given to a line of the cart
+// with ItemIDSequence, it lives as long as the cart
+const itemSequenceKeyPrefix = "itemseq:"
+
+// ItemIDStrategy decides the ItemID of line items added to a cart. Either
+// way the id of a line never changes while the line exists, so ids read
+// from the cart can be used to update and delete its lines
+type ItemIDStrategy string
+
+const (
+	// ItemIDFromProduct uses the product as item id, the default. The cart
+	// has one line per product and item_id of an added item is its product
+	ItemIDFromProduct ItemIDStrategy = "product"
+	// ItemIDSequence numbers lines of every cart in the order their products
+	// were added, the product is kept in product_id. An added item names its
+	// product with product_id, or item_id for older clients. Ids are never
+	// reused within a cart, a stale client can't change a line added after
+	// the one it read was removed
+	ItemIDSequence ItemIDStrategy = "sequence"
+)
+
+// ParseItemIDStrategy parses name of a strategy, empty is ItemIDFromProduct
+func ParseItemIDStrategy(name string) (ItemIDStrategy, error) {
+	switch strategy := ItemIDStrategy(name); strategy {
+	case "", ItemIDFromProduct:
+		return ItemIDFromProduct, nil
+	case ItemIDSequence:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown item id strategy %q", name)
+}
+
+// WithItemIDStrategy assigns ids of added line items with strategy
+func WithItemIDStrategy(strategy ItemIDStrategy) Option {
+	return func(r *CartRepository) {
+		r.itemID = strategy
+	}
+}
+
+// mergeItem adds newItem to the cart or sums the quantity when the cart
+// already has a line of the product, total is recalculated. It returns the
+// item id of the line
+func (r *CartRepository) mergeItem(ctx context.Context, cart *models.Cart, newItem models.LineItem) (int, error) {
+	product := newItem.Product()
+	for i, item := range cart.LineItems {
+		if item.Product() == product {
+			cart.LineItems[i].Quantity += newItem.Quantity
+			return item.ItemID, setTotal(cart)
+		}
+	}
+
+	if r.itemID == ItemIDSequence {
+		id, err := r.allocateItemID(ctx, cart)
+		if err != nil {
+			return 0, err
+		}
+		newItem.ItemID = id
+		newItem.ProductID = product
+	} else {
+		newItem.ItemID = product
+	}
+	cart.LineItems = append(cart.LineItems, newItem)
+	return newItem.ItemID, setTotal(cart)
+}
+
+func (r *CartRepository) itemSequenceKey(ctx context.Context, cartID string) string {
+	return r.key(ctx, itemSequenceKeyPrefix+cartID)
+}
+
+// allocateItemID increments the item sequence of the cart. The sequence
+// is raised above ids the cart already has, e.g. of carts written before
+// sequential ids or by a full update, INCRBY keeps concurrent allocations
+// distinct. Ids of retried or failed mutations are skipped, not reused
+func (r *CartRepository) allocateItemID(ctx context.Context, cart *models.Cart) (int, error) {
+	key := r.itemSequenceKey(ctx, cart.ID.String())
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		if r.cartTTL > 0 {
+			pipe.PExpire(ctx, key, r.cartTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error allocating item id of cart %s: %w", cart.ID, err)
+	}
+	id := incr.Val()
+	if floor := int64(nextItemID(cart.LineItems)); id < floor {
+		if id, err = r.client.IncrBy(ctx, key, floor-id).Result(); err != nil {
+			return 0, fmt.Errorf("error allocating item id of cart %s: %w", cart.ID, err)
+		}
+	}
+	return int(id), nil
+}
+
+// nextItemID returns the id following the highest id of items
+func nextItemID(items []models.LineItem) int {
+	next := 1
+	for _, item := range items {
+		next = max(next, item.ItemID+1)
+	}
+	return next
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemIDStrategy(t *testing.T) {
+	ctx := context.Background()
+
+	newCart := func(t *testing.T, repo *CartRepository) string {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+	lines := func(t *testing.T, repo *CartRepository, cartID string) map[int]models.LineItem {
+		cart, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		byID := make(map[int]models.LineItem, len(cart.LineItems))
+		for _, item := range cart.LineItems {
+			byID[item.ItemID] = item
+		}
+		return byID
+	}
+
+	t.Run("product ids should be item ids by default", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		cartID := newCart(t, repo)
+
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ProductID: 3, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 2}))
+
+		items := lines(t, repo, cartID)
+		require.Len(t, items, 2)
+		assert.Equal(t, 3, items[7].Quantity)
+		assert.Equal(t, 3, items[3].Product())
+	})
+
+	t.Run("sequence should number lines per cart", func(t *testing.T) {
+		repo, _ := newTestRepository(t, WithItemIDStrategy(ItemIDSequence))
+		cartID := newCart(t, repo)
+
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ProductID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		// older clients name the product with item_id
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 3, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ProductID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+
+		items := lines(t, repo, cartID)
+		require.Len(t, items, 2)
+		assert.Equal(t, models.LineItem{ItemID: 1, ProductID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 2}, items[1])
+		assert.Equal(t, models.LineItem{ItemID: 2, ProductID: 3, UnitPrice: models.Money{Minor: 100}, Quantity: 1}, items[2])
+		assert.Equal(t, items, lines(t, repo, cartID), "ids should be stable across reads")
+
+		require.NoError(t, repo.UpdateItem(ctx, cartID, 2, models.LineItem{UnitPrice: models.Money{Minor: 100}, Quantity: 5}))
+		require.NoError(t, repo.DeleteItem(ctx, cartID, 1))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ProductID: 9, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+
+		items = lines(t, repo, cartID)
+		require.Len(t, items, 2)
+		assert.Equal(t, 5, items[2].Quantity)
+		assert.Equal(t, 3, items[2].Product())
+		assert.Equal(t, 9, items[3].Product())
+	})
+
+	t.Run("sequence should not reuse ids of deleted items", func(t *testing.T) {
+		repo, _ := newTestRepository(t, WithItemIDStrategy(ItemIDSequence))
+		cartID := newCart(t, repo)
+
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ProductID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		require.NoError(t, repo.DeleteItem(ctx, cartID, 1))
+		require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ProductID: 8, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+
+		items := lines(t, repo, cartID)
+		require.Len(t, items, 1)
+		assert.Equal(t, 8, items[2].Product())
+		assert.ErrorIs(t, repo.UpdateItem(ctx, cartID, 1, models.LineItem{Quantity: 2}), ErrItemNotFound, "stale id should not reach the new item")
+	})
+
+	t.Run("sequence should start above ids the cart has", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithItemIDStrategy(ItemIDSequence), WithCartTTL(time.Hour))
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 5, UnitPrice: models.Money{Minor: 100}, Quantity: 1}}}
+		require.NoError(t, repo.Update(ctx, cart))
+
+		require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ProductID: 8, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		items := lines(t, repo, cart.ID.String())
+		assert.Equal(t, 8, items[6].Product())
+
+		key := itemSequenceKeyPrefix + cart.ID.String()
+		assert.Equal(t, time.Hour, mr.TTL(key), "sequence should expire with the cart")
+		require.NoError(t, repo.Delete(ctx, cart.ID.String()))
+		assert.False(t, mr.Exists(key), "sequence should be deleted with the cart")
+	})
+
+	t.Run("moved items should get an id of the target", func(t *testing.T) {
+		repo, _ := newTestRepository(t, WithItemIDStrategy(ItemIDSequence))
+		source, target := newCart(t, repo), newCart(t, repo)
+		require.NoError(t, repo.AddItem(ctx, source, models.LineItem{ProductID: 5, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		require.NoError(t, repo.AddItem(ctx, source, models.LineItem{ProductID: 7, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+		require.NoError(t, repo.AddItem(ctx, target, models.LineItem{ProductID: 8, UnitPrice: models.Money{Minor: 100}, Quantity: 1}))
+
+		require.NoError(t, repo.MoveItem(ctx, source, target, 2))
+
+		items := lines(t, repo, target)
+		require.Len(t, items, 2)
+		assert.Equal(t, 7, items[2].Product())
+	})
+}
+
+func TestParseItemIDStrategy(t *testing.T) {
+	for name, want := range map[string]ItemIDStrategy{"": ItemIDFromProduct, "product": ItemIDFromProduct, "sequence": ItemIDSequence} {
+		strategy, err := ParseItemIDStrategy(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, strategy)
+	}
+	_, err := ParseItemIDStrategy("random")
+	assert.Error(t, err)
+}
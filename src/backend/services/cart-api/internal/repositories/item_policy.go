@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// ErrItemQuantityExceeded is returned when a line item goes above a per-order
+// cap of its product
+var ErrItemQuantityExceeded error = models.NewBusinessRule("item_quantity_exceeded", "item quantity exceeds the product limit")
+
+// ItemPolicy is consulted with the resulting line item whenever a mutation
+// adds or updates it, returning an error rejects the mutation
+type ItemPolicy interface {
+	Check(item models.LineItem) error
+}
+
+// StaticItemPolicy caps quantity per order by product id
+type StaticItemPolicy map[int]int
+
+func (p StaticItemPolicy) Check(item models.LineItem) error {
+	max, ok := p[item.Product()]
+	if ok && item.Quantity > max {
+		return fmt.Errorf("%w: quantity %d of product %d is above %d", ErrItemQuantityExceeded, item.Quantity, item.Product(), max)
+	}
+	return nil
+}
+
+// WithItemPolicy rejects item mutations not allowed by policy
+func WithItemPolicy(policy ItemPolicy) Option {
+	return func(r *CartRepository) {
+		r.policy = policy
+	}
+}
+
+// checkItem validates the line item of cart after it was changed
+func (r *CartRepository) checkItem(cart *models.Cart, itemID int) error {
+	for _, item := range cart.LineItems {
+		if item.ItemID == itemID {
+			return r.checkLine(item, cart.Total, cart.CurrencyOf())
+		}
+	}
+	return nil
+}
+
+// CheckCart validates every line item of a cart which is written as a whole,
+// Update stores carts as they are so callers replacing the items check them
+// first
+func (r *CartRepository) CheckCart(cart *models.Cart) error {
+	total, err := calculateTotalPrice(cart.LineItems)
+	if err != nil {
+		return err
+	}
+	for _, item := range cart.LineItems {
+		if err := r.checkLine(item, total, cart.CurrencyOf()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CartRepository) checkLine(item models.LineItem, total models.Money, currency string) error {
+	if err := r.limits.check(item, total, currency); err != nil {
+		return err
+	}
+	if r.policy != nil {
+		return r.policy.Check(item)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItemPolicy(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t, WithItemPolicy(StaticItemPolicy{1: 2}))
+
+	newCart := func(t *testing.T) string {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		assert.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+
+	t.Run("AddItem within cap should be accepted", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}))
+	})
+
+	t.Run("AddItem crossing cap across adds should be rejected", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+		err := repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2})
+		assert.ErrorIs(t, err, ErrItemQuantityExceeded)
+		assert.ErrorContains(t, err, "above 2")
+
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, cart.LineItems[0].Quantity)
+	})
+
+	t.Run("UpdateItem over cap should be rejected", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+		err := repo.UpdateItem(ctx, cartID, 1, models.LineItem{UnitPrice: models.Money{Minor: 1000}, Quantity: 3})
+		assert.ErrorIs(t, err, ErrItemQuantityExceeded)
+	})
+
+	t.Run("products without cap should not be limited", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 1000}, Quantity: 100}))
+	})
+}
+
+func TestCheckCart(t *testing.T) {
+	repo, _ := newTestRepository(t,
+		WithItemPolicy(StaticItemPolicy{1: 2}),
+		WithLimits(Limits{MaxItemPrice: models.Money{Minor: 1000}, MaxCartTotal: models.Money{Minor: 2500}}))
+	check := func(items ...models.LineItem) error {
+		return repo.CheckCart(&models.Cart{ID: uuid.New(), LineItems: items})
+	}
+
+	assert.NoError(t, check(models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}))
+	assert.NoError(t, check())
+	assert.ErrorIs(t, check(models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 3}), ErrItemQuantityExceeded)
+	assert.ErrorIs(t, check(models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 1500}, Quantity: 1}), ErrItemPriceExceeded)
+	// every line is within limits but the total of them is not
+	assert.ErrorIs(t, check(
+		models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+		models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 1000}, Quantity: 1},
+	), ErrCartTotalExceeded)
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyPrefix(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t, WithKeyPrefix("cart:"))
+
+	cart := &models.Cart{ID: uuid.New(), Total: models.Money{Minor: 99900}, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}}}
+	cartID := cart.ID.String()
+	assert.NoError(t, repo.Update(ctx, cart))
+
+	t.Run("keys should be prefixed", func(t *testing.T) {
+		assert.True(t, mr.Exists("cart:"+cartID))
+		assert.False(t, mr.Exists(cartID))
+	})
+
+	t.Run("lookups should honor the prefix", func(t *testing.T) {
+		got, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, cart.ID, got.ID)
+
+		assert.NoError(t, repo.DecrementItem(ctx, cartID, 1))
+		got, err = repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, got.LineItems[0].Quantity)
+	})
+
+	t.Run("shared snapshots should be prefixed", func(t *testing.T) {
+		token, err := repo.Share(ctx, cartID, time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, mr.Exists("cart:share:"+token))
+
+		_, err = repo.GetShared(ctx, token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("scan should only visit prefixed carts", func(t *testing.T) {
+		other := &models.Cart{ID: uuid.New(), Total: models.Money{Minor: 99900}, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 1}}}
+		assert.NoError(t, unprefixed(t, mr).Update(ctx, other))
+		assert.NoError(t, repo.Update(ctx, &models.Cart{ID: cart.ID, Total: models.Money{Minor: 99900}, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}}}))
+
+		corrected, _, err := repo.RecomputeTotals(ctx, 0, 100)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{cartID}, corrected)
+	})
+
+	t.Run("delete should honor the prefix", func(t *testing.T) {
+		assert.NoError(t, repo.Delete(ctx, cartID))
+		assert.False(t, mr.Exists("cart:"+cartID))
+	})
+}
+
+// unprefixed returns repository sharing mr without a key prefix
+func unprefixed(t *testing.T, mr *miniredis.Miniredis) *CartRepository {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewCartRepository(client)
+}
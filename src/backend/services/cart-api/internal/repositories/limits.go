@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// Business rules enforced by Limits
+var (
+	ErrItemPriceExceeded error = models.NewBusinessRule("item_price_exceeded", "item price exceeds the limit")
+	ErrCartTotalExceeded error = models.NewBusinessRule("cart_total_exceeded", "cart total exceeds the limit")
+)
+
+// Limits guards carts against fat-finger or fraudulent prices, zero value
+// disables a limit. Limits without currency are taken in the currency of
+// every cart, e.g. 500 caps a EUR cart at 500.00 and a JPY cart at 500
+type Limits struct {
+	MaxItemPrice models.Money
+	MaxCartTotal models.Money
+}
+
+// WithLimits rejects item mutations breaking any of limits
+func WithLimits(limits Limits) Option {
+	return func(r *CartRepository) {
+		r.limits = limits
+	}
+}
+
+// check compares the item and total of a cart in currency with the limits
+// in minor units of the currency
+func (l Limits) check(item models.LineItem, total models.Money, currency string) error {
+	maxPrice, maxTotal := l.MaxItemPrice.In(currency), l.MaxCartTotal.In(currency)
+	if maxPrice.Minor > 0 && item.UnitPrice.Minor > maxPrice.Minor {
+		return fmt.Errorf("%w: price %s of item %d is above %s", ErrItemPriceExceeded, item.UnitPrice, item.ItemID, maxPrice)
+	}
+	if maxTotal.Minor > 0 && total.Minor > maxTotal.Minor {
+		return fmt.Errorf("%w: total %s is above %s", ErrCartTotalExceeded, total, maxTotal)
+	}
+	return nil
+}
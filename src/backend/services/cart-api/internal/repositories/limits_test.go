@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t, WithLimits(Limits{MaxItemPrice: models.Money{Minor: 10000}, MaxCartTotal: models.Money{Minor: 25000}}))
+
+	newCart := func(t *testing.T) string {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		assert.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+
+	t.Run("AddItem should reject overpriced item", func(t *testing.T) {
+		cartID := newCart(t)
+		err := repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 10001}, Quantity: 1})
+		assert.ErrorIs(t, err, ErrItemPriceExceeded)
+
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Empty(t, cart.LineItems)
+	})
+
+	t.Run("UpdateItem should reject overpriced item", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+
+		err := repo.UpdateItem(ctx, cartID, 1, models.LineItem{UnitPrice: models.Money{Minor: 50000}, Quantity: 1})
+		assert.ErrorIs(t, err, ErrItemPriceExceeded)
+	})
+
+	t.Run("AddItem should reject total crossing the cap across multiple adds", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 10000}, Quantity: 1}))
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 5000}, Quantity: 3}))
+
+		err := repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 10000}, Quantity: 1})
+		assert.ErrorIs(t, err, ErrCartTotalExceeded)
+
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 25000}, cart.Total)
+	})
+
+	t.Run("limits should be taken in minor units of the cart currency", func(t *testing.T) {
+		jpy := "JPY"
+		cart := &models.Cart{ID: uuid.New(), Currency: &jpy, LineItems: []models.LineItem{}}
+		assert.NoError(t, repo.Update(ctx, cart))
+		cartID := cart.ID.String()
+
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100, Currency: jpy}, Quantity: 1}))
+		err := repo.AddItem(ctx, cartID, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 101, Currency: jpy}, Quantity: 1})
+		assert.ErrorIs(t, err, ErrItemPriceExceeded)
+	})
+
+	t.Run("zero limits should be disabled", func(t *testing.T) {
+		unlimited, _ := newTestRepository(t)
+		cart := &models.Cart{ID: uuid.New()}
+		assert.NoError(t, unlimited.Update(ctx, cart))
+		assert.NoError(t, unlimited.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1e8}, Quantity: 10}))
+	})
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrDuplicateName = errors.New("user already has a cart with this name")
+
+// listsKeyPrefix keys hash of cart names of a user to cart ids
+const listsKeyPrefix = "lists:"
+
+// CreateNamed stores new cart under its name for its owner, names are unique
+// per user among carts which still exist. Named carts don't replace the
+// active cart of the user. The cart is written in the MULTI claiming the
+// name so a concurrent create never sees the name without its cart
+func (r *CartRepository) CreateNamed(ctx context.Context, cart *models.Cart) error {
+	value, err := r.encodeCart(cart)
+	if err != nil {
+		return err
+	}
+	err = r.watch(ctx, func(tx *redis.Tx) error {
+		if err := r.checkName(ctx, tx, cart, r.getTx); err != nil {
+			return err
+		}
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			r.claimName(ctx, pipe, cart)
+			r.setCart(ctx, pipe, cart, value)
+			return nil
+		})
+		return err
+	}, cart.ID.String())
+	if err != nil {
+		return err
+	}
+	r.written(ctx, cart)
+	return nil
+}
+
+// checkName watches names of the owner of the cart and fails unless the name
+// of the cart is free, i.e. no cart read by get still has it
+func (r *CartRepository) checkName(ctx context.Context, tx *redis.Tx, cart *models.Cart, get func(context.Context, *redis.Tx, string) (*models.Cart, error)) error {
+	owner := ownerOf(cart)
+	if owner == "" || cart.Name == "" {
+		return fmt.Errorf("cart %s needs an owner and a name", cart.ID)
+	}
+	key := r.key(ctx, listsKeyPrefix+owner)
+	if err := tx.Watch(ctx, key).Err(); err != nil {
+		return err
+	}
+	cartID, err := tx.HGet(ctx, key, cart.Name).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting carts of user %s: %w", owner, err)
+	}
+	// entries of deleted, checked out or transferred carts are stale
+	existing, err := get(ctx, tx, cartID)
+	if err == nil && ownerOf(existing) == owner && existing.Name == cart.Name {
+		return fmt.Errorf("%w: %q", ErrDuplicateName, cart.Name)
+	}
+	if err != nil && !errors.Is(err, ErrCartNotFound) {
+		return err
+	}
+	return nil
+}
+
+// claimName queues pointing the name of the cart to it, see checkName
+func (r *CartRepository) claimName(ctx context.Context, pipe redis.Pipeliner, cart *models.Cart) {
+	pipe.HSet(ctx, r.key(ctx, listsKeyPrefix+ownerOf(cart)), cart.Name, cart.ID.String())
+}
+
+// NamedCarts returns named carts of the user ordered by name
+func (r *CartRepository) NamedCarts(ctx context.Context, userID string) ([]*models.Cart, error) {
+	return r.namedCarts(ctx, userID, r.Get)
+}
+
+// namedCarts reads carts of the names of the user by get
+func (r *CartRepository) namedCarts(ctx context.Context, userID string, get func(context.Context, string) (*models.Cart, error)) ([]*models.Cart, error) {
+	names, err := r.reader.HGetAll(ctx, r.key(ctx, listsKeyPrefix+userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error getting carts of user %s: %w", userID, err)
+	}
+	carts := make([]*models.Cart, 0, len(names))
+	for name, cartID := range names {
+		cart, err := get(ctx, cartID)
+		if errors.Is(err, ErrCartNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ownerOf(cart) != userID || cart.Name != name {
+			continue
+		}
+		carts = append(carts, cart)
+	}
+	sort.Slice(carts, func(i, j int) bool { return carts[i].Name < carts[j].Name })
+	return carts, nil
+}
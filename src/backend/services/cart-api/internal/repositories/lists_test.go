@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedCarts(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	named := func(owner, name string) *models.Cart {
+		return &models.Cart{ID: uuid.New(), UserID: &owner, Name: name, LineItems: []models.LineItem{}}
+	}
+
+	t.Run("user should keep two named carts", func(t *testing.T) {
+		active := named("alice", "")
+		require.NoError(t, repo.Update(ctx, active))
+		weekly, party := named("alice", "Weekly groceries"), named("alice", "Party")
+		require.NoError(t, repo.CreateNamed(ctx, weekly))
+		require.NoError(t, repo.CreateNamed(ctx, party))
+
+		carts, err := repo.NamedCarts(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, carts, 2)
+		assert.Equal(t, party, carts[0])
+		assert.Equal(t, weekly, carts[1])
+
+		cartID, err := repo.CartByUser(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, active.ID.String(), cartID, "named carts should not replace the active cart")
+	})
+
+	t.Run("name should be unique per user", func(t *testing.T) {
+		require.NoError(t, repo.CreateNamed(ctx, named("bob", "Weekly groceries")))
+		assert.ErrorIs(t, repo.CreateNamed(ctx, named("bob", "Weekly groceries")), ErrDuplicateName)
+		require.NoError(t, repo.CreateNamed(ctx, named("carol", "Weekly groceries")))
+	})
+
+	t.Run("name of deleted cart should be reusable", func(t *testing.T) {
+		old := named("dave", "Weekly groceries")
+		require.NoError(t, repo.CreateNamed(ctx, old))
+		require.NoError(t, repo.Delete(ctx, old.ID.String()))
+
+		carts, err := repo.NamedCarts(ctx, "dave")
+		require.NoError(t, err)
+		assert.Empty(t, carts)
+
+		renewed := named("dave", "Weekly groceries")
+		require.NoError(t, repo.CreateNamed(ctx, renewed))
+		carts, err = repo.NamedCarts(ctx, "dave")
+		require.NoError(t, err)
+		assert.Equal(t, []*models.Cart{renewed}, carts)
+	})
+
+	t.Run("cart should be written in the transaction claiming its name", func(t *testing.T) {
+		pipelines := &recordPipelines{}
+		repo.client.AddHook(pipelines)
+		require.NoError(t, repo.CreateNamed(ctx, named("frank", "Weekly groceries")))
+
+		assert.Contains(t, pipelines.containing("hset"), "set", "a create must not see the name without its cart")
+	})
+
+	t.Run("cart without owner or name should be rejected", func(t *testing.T) {
+		assert.Error(t, repo.CreateNamed(ctx, named("erin", "")))
+		assert.Error(t, repo.CreateNamed(ctx, named(anonymousUserID, "Weekly groceries")))
+	})
+}
@@ -0,0 +1,17 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRepository returns repository backed by in-process miniredis
+func newTestRepository(tb testing.TB, opts ...Option) (*CartRepository, *miniredis.Miniredis) {
+	tb.Helper()
+	mr := miniredis.RunT(tb)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tb.Cleanup(func() { _ = client.Close() })
+	return NewCartRepository(client, opts...), mr
+}
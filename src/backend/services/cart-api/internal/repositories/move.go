@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrItemNotFound = errors.New("item not found")
+	ErrSameCart     = errors.New("source and target carts are the same")
+)
+
+// MoveItem atomically removes the item from the source cart and adds it to
+// the target cart, summing quantity when the target already has its
+// product. The item gets an id of the target cart
+func (r *CartRepository) MoveItem(ctx context.Context, sourceID, targetID string, itemID int) error {
+	if sourceID == targetID {
+		return ErrSameCart
+	}
+
+	var moved []*models.Cart
+	var added models.AuditEntry
+	move := func(tx *redis.Tx) error {
+		source, err := r.getTx(ctx, tx, sourceID)
+		if err != nil {
+			return err
+		}
+		target, err := r.getTx(ctx, tx, targetID)
+		if err != nil {
+			return err
+		}
+
+		index := -1
+		for i, item := range source.LineItems {
+			if item.ItemID == itemID {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return fmt.Errorf("%w: item %d in cart %s", ErrItemNotFound, itemID, sourceID)
+		}
+		item := source.LineItems[index]
+		source.LineItems = append(source.LineItems[:index], source.LineItems[index+1:]...)
+		if err := setTotal(source); err != nil {
+			return err
+		}
+
+		targetItemID, err := r.mergeItem(ctx, target, item)
+		if err != nil {
+			return err
+		}
+		if err := r.checkItem(target, targetItemID); err != nil {
+			return err
+		}
+		added = itemEntry(models.AuditItemAdded, target, targetItemID)
+		moved = []*models.Cart{source, target}
+		return r.setTx(ctx, tx, source, target)
+	}
+
+	if err := r.watch(ctx, move, sourceID, targetID); err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	for _, cart := range moved {
+		r.written(ctx, cart)
+	}
+	r.audit(ctx, sourceID, models.AuditEntry{Action: models.AuditItemRemoved, ItemID: itemID})
+	r.audit(ctx, targetID, added)
+	return nil
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func countItem(cart *models.Cart, itemID int) int {
+	count := 0
+	for _, item := range cart.LineItems {
+		if item.ItemID == itemID {
+			count++
+		}
+	}
+	return count
+}
+
+func TestMoveItem(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	newCart := func(t *testing.T, lineItems ...models.LineItem) string {
+		cart := &models.Cart{ID: uuid.New(), LineItems: lineItems}
+		assert.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+
+	t.Run("item should appear exactly once in target", func(t *testing.T) {
+		source := newCart(t, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1})
+		target := newCart(t)
+
+		assert.NoError(t, repo.MoveItem(ctx, source, target, 1))
+
+		sourceCart, err := repo.Get(ctx, source)
+		assert.NoError(t, err)
+		targetCart, err := repo.Get(ctx, target)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 0, countItem(sourceCart, 1))
+		assert.Equal(t, 1, countItem(targetCart, 1))
+		assert.Equal(t, models.Money{Minor: 500}, sourceCart.Total)
+		assert.Equal(t, models.Money{Minor: 2000}, targetCart.Total)
+	})
+
+	t.Run("moving into cart having the item should sum quantity", func(t *testing.T) {
+		source := newCart(t, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2})
+		target := newCart(t, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1})
+
+		assert.NoError(t, repo.MoveItem(ctx, source, target, 1))
+
+		targetCart, err := repo.Get(ctx, target)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, countItem(targetCart, 1))
+		assert.Equal(t, 3, targetCart.LineItems[0].Quantity)
+	})
+
+	t.Run("missing source or target should return ErrCartNotFound", func(t *testing.T) {
+		cartID := newCart(t, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1})
+
+		assert.ErrorIs(t, repo.MoveItem(ctx, uuid.NewString(), cartID, 1), ErrCartNotFound)
+		assert.ErrorIs(t, repo.MoveItem(ctx, cartID, uuid.NewString(), 1), ErrCartNotFound)
+
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, countItem(cart, 1), "failed move must not remove the item")
+	})
+
+	t.Run("missing item should return ErrItemNotFound", func(t *testing.T) {
+		assert.ErrorIs(t, repo.MoveItem(ctx, newCart(t), newCart(t), 1), ErrItemNotFound)
+	})
+
+	t.Run("same cart should return ErrSameCart", func(t *testing.T) {
+		cartID := newCart(t, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1})
+		assert.ErrorIs(t, repo.MoveItem(ctx, cartID, cartID, 1), ErrSameCart)
+	})
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// ErrNegativeQuantity is returned when a delta would take quantity below zero
+// and clamping is disabled
+var ErrNegativeQuantity error = models.NewBusinessRule("negative_quantity", "quantity would become negative")
+
+// ErrQuantityTooLarge is returned when a delta would take quantity above
+// models.MaxItemQuantity
+var ErrQuantityTooLarge error = models.NewBusinessRule("quantity_too_large", "quantity would be above the maximum")
+
+// DecrementItem atomically decrements quantity of the item by one, the item
+// is removed from the cart when quantity reaches zero
+func (r *CartRepository) DecrementItem(ctx context.Context, cartID string, itemID int) error {
+	var entry models.AuditEntry
+	err := r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		if err := adjustQuantity(cart, itemID, -1, true); err != nil {
+			return err
+		}
+		entry = itemEntry(models.AuditItemUpdated, cart, itemID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.audit(ctx, cartID, entry)
+	return nil
+}
+
+// AdjustItemQuantity atomically adds delta to quantity of the item, the item
+// is removed when quantity reaches zero. With clamp a delta going below zero
+// removes the item too, otherwise ErrNegativeQuantity is returned. A delta
+// going above models.MaxItemQuantity returns ErrQuantityTooLarge
+func (r *CartRepository) AdjustItemQuantity(ctx context.Context, cartID string, itemID int, delta int, clamp bool) error {
+	var entry models.AuditEntry
+	err := r.mutate(ctx, cartID, func(cart *models.Cart) error {
+		if err := adjustQuantity(cart, itemID, delta, clamp); err != nil {
+			return err
+		}
+		entry = itemEntry(models.AuditItemUpdated, cart, itemID)
+		if delta <= 0 {
+			return nil
+		}
+		return r.checkItem(cart, itemID)
+	})
+	if err != nil {
+		return err
+	}
+	r.audit(ctx, cartID, entry)
+	return nil
+}
+
+// adjustQuantity adds delta to quantity of the item, removing it once the
+// quantity drops to zero, or below when clamp is set
+func adjustQuantity(cart *models.Cart, itemID int, delta int, clamp bool) error {
+	for i, item := range cart.LineItems {
+		if item.ItemID != itemID {
+			continue
+		}
+		// compared before adding so a huge delta can't overflow
+		if delta > models.MaxItemQuantity-item.Quantity {
+			return fmt.Errorf("%w: item %d has quantity %d, delta %d, maximum %d", ErrQuantityTooLarge, itemID, item.Quantity, delta, models.MaxItemQuantity)
+		}
+		quantity := item.Quantity + delta
+		if quantity < 0 && !clamp {
+			return fmt.Errorf("%w: item %d has quantity %d, delta %d", ErrNegativeQuantity, itemID, item.Quantity, delta)
+		}
+		if quantity <= 0 {
+			cart.LineItems = append(cart.LineItems[:i], cart.LineItems[i+1:]...)
+		} else {
+			cart.LineItems[i].Quantity = quantity
+		}
+		return setTotal(cart)
+	}
+	return fmt.Errorf("%w: item %d in cart %s", ErrItemNotFound, itemID, cart.ID)
+}
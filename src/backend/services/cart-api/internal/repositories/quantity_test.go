@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecrementItem(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	newCart := func(t *testing.T, quantity int) string {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: quantity}}}
+		assert.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+
+	t.Run("decrement above one should reduce quantity", func(t *testing.T) {
+		cartID := newCart(t, 3)
+		assert.NoError(t, repo.DecrementItem(ctx, cartID, 1))
+
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, cart.LineItems[0].Quantity)
+		assert.Equal(t, models.Money{Minor: 2000}, cart.Total)
+	})
+
+	t.Run("decrement to zero should remove the item", func(t *testing.T) {
+		cartID := newCart(t, 1)
+		assert.NoError(t, repo.DecrementItem(ctx, cartID, 1))
+
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Empty(t, cart.LineItems)
+		assert.Zero(t, cart.Total)
+	})
+
+	t.Run("decrement on missing item should return ErrItemNotFound", func(t *testing.T) {
+		assert.ErrorIs(t, repo.DecrementItem(ctx, newCart(t, 1), 2), ErrItemNotFound)
+	})
+
+	t.Run("decrement on missing cart should return ErrCartNotFound", func(t *testing.T) {
+		assert.ErrorIs(t, repo.DecrementItem(ctx, uuid.NewString(), 1), ErrCartNotFound)
+	})
+
+	t.Run("concurrent decrements should not lose updates", func(t *testing.T) {
+		cartID := newCart(t, 10)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, repo.DecrementItem(ctx, cartID, 1))
+			}()
+		}
+		wg.Wait()
+
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, cart.LineItems[0].Quantity)
+	})
+}
+
+func TestAdjustItemQuantity(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t, WithLimits(Limits{MaxCartTotal: models.Money{Minor: 10000}}))
+
+	newCart := func(t *testing.T, quantity int) string {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: quantity}}}
+		assert.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+	quantity := func(t *testing.T, cartID string) int {
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		if len(cart.LineItems) == 0 {
+			return 0
+		}
+		return cart.LineItems[0].Quantity
+	}
+
+	t.Run("positive delta should increase quantity", func(t *testing.T) {
+		cartID := newCart(t, 1)
+		assert.NoError(t, repo.AdjustItemQuantity(ctx, cartID, 1, 3, false))
+		assert.Equal(t, 4, quantity(t, cartID))
+	})
+
+	t.Run("negative delta should decrease quantity", func(t *testing.T) {
+		cartID := newCart(t, 5)
+		assert.NoError(t, repo.AdjustItemQuantity(ctx, cartID, 1, -2, false))
+		assert.Equal(t, 3, quantity(t, cartID))
+	})
+
+	t.Run("delta reaching zero should remove the item", func(t *testing.T) {
+		cartID := newCart(t, 2)
+		assert.NoError(t, repo.AdjustItemQuantity(ctx, cartID, 1, -2, false))
+		assert.Equal(t, 0, quantity(t, cartID))
+	})
+
+	t.Run("delta crossing zero should clamp", func(t *testing.T) {
+		cartID := newCart(t, 2)
+		assert.NoError(t, repo.AdjustItemQuantity(ctx, cartID, 1, -5, true))
+		assert.Equal(t, 0, quantity(t, cartID))
+	})
+
+	t.Run("delta crossing zero should be rejected without clamp", func(t *testing.T) {
+		cartID := newCart(t, 2)
+		assert.ErrorIs(t, repo.AdjustItemQuantity(ctx, cartID, 1, -5, false), ErrNegativeQuantity)
+		assert.Equal(t, 2, quantity(t, cartID))
+	})
+
+	t.Run("positive delta should respect limits", func(t *testing.T) {
+		cartID := newCart(t, 1)
+		assert.ErrorIs(t, repo.AdjustItemQuantity(ctx, cartID, 1, 10, false), ErrCartTotalExceeded)
+		assert.Equal(t, 1, quantity(t, cartID))
+	})
+
+	t.Run("delta above the maximum quantity should be rejected", func(t *testing.T) {
+		cartID := newCart(t, 2)
+		err := repo.AdjustItemQuantity(ctx, cartID, 1, math.MaxInt, false)
+		assert.ErrorIs(t, err, ErrQuantityTooLarge)
+		var rule *models.BusinessRule
+		assert.ErrorAs(t, err, &rule)
+		assert.Equal(t, 2, quantity(t, cartID))
+	})
+}
@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cartRateKeyPrefix keys counters of mutations of a cart, one per window
+const cartRateKeyPrefix = "ratelimit:cart:"
+
+// CountCartMutation counts a mutation of the cart in the current fixed
+// window, it returns mutations counted in the window so far and the time
+// left until the window ends. Counters expire with their window
+func (r *CartRepository) CountCartMutation(ctx context.Context, cartID string, window time.Duration) (int64, time.Duration, error) {
+	now := r.now()
+	start := now.Truncate(window)
+	key := r.key(ctx, cartRateKeyPrefix+cartID+":"+strconv.FormatInt(start.UnixMilli(), 10))
+
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.PExpire(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("error counting mutations of cart %s: %w", cartID, err)
+	}
+	return incr.Val(), start.Add(window).Sub(now), nil
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountCartMutation(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 250*int(time.Millisecond), time.UTC)
+	repo.now = func() time.Time { return now }
+
+	count := func(t *testing.T, cartID string) (int64, time.Duration) {
+		n, reset, err := repo.CountCartMutation(ctx, cartID, time.Second)
+		require.NoError(t, err)
+		return n, reset
+	}
+
+	n, reset := count(t, "cart-1")
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 750*time.Millisecond, reset)
+	n, _ = count(t, "cart-1")
+	assert.Equal(t, int64(2), n)
+	n, _ = count(t, "cart-2")
+	assert.Equal(t, int64(1), n, "carts should be counted separately")
+
+	key := cartRateKeyPrefix + "cart-1:" + "1709294400000"
+	assert.Equal(t, time.Second, mr.TTL(key))
+
+	now = now.Add(time.Second)
+	n, _ = count(t, "cart-1")
+	assert.Equal(t, int64(1), n, "next window should start from zero")
+}
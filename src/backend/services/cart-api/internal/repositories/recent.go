@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// recentKeyPrefix keys sorted set of carts of a user scored by update time
+const recentKeyPrefix = "recent:"
+
+// MaxRecentCarts bounds carts remembered per user, older ones are dropped
+const MaxRecentCarts = 50
+
+// indexRecent scores the cart of its owner with the update time, the index
+// is a lookup aid so failures are logged and don't fail the mutation
+func (r *CartRepository) indexRecent(ctx context.Context, cart *models.Cart) {
+	owner := ownerOf(cart)
+	if owner == "" {
+		return
+	}
+	key := r.key(ctx, recentKeyPrefix+owner)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(r.now().UnixMilli()), Member: cart.ID.String()})
+		pipe.ZRemRangeByRank(ctx, key, 0, -MaxRecentCarts-1)
+		if r.cartTTL > 0 {
+			pipe.PExpire(ctx, key, r.cartTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cart.ID.String()).Msg("failed to index recent cart")
+	}
+}
+
+// forgetRecent drops the cart from recent carts of the user
+func (r *CartRepository) forgetRecent(ctx context.Context, userID, cartID string) {
+	if err := r.client.ZRem(ctx, r.key(ctx, recentKeyPrefix+userID), cartID).Err(); err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to forget recent cart")
+	}
+}
+
+// RecentCarts returns up to limit carts of the user, most recently updated
+// first. Deleted, checked out and transferred carts are skipped
+func (r *CartRepository) RecentCarts(ctx context.Context, userID string, limit int) ([]*models.Cart, error) {
+	scored, err := r.recentIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return recentCarts(ctx, r.Get, userID, scored, limit)
+}
+
+// recentIDs returns recent carts of the user scored by update time, most
+// recent first
+func (r *CartRepository) recentIDs(ctx context.Context, userID string) ([]redis.Z, error) {
+	scored, err := r.reader.ZRevRangeWithScores(ctx, r.key(ctx, recentKeyPrefix+userID), 0, MaxRecentCarts-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error getting recent carts of user %s: %w", userID, err)
+	}
+	return scored, nil
+}
+
+// recentCarts reads up to limit carts of scored ids still owned by the user
+func recentCarts(ctx context.Context, get func(context.Context, string) (*models.Cart, error), userID string, scored []redis.Z, limit int) ([]*models.Cart, error) {
+	carts := make([]*models.Cart, 0, min(limit, len(scored)))
+	for _, z := range scored {
+		if len(carts) == limit {
+			break
+		}
+		id, _ := z.Member.(string)
+		cart, err := get(ctx, id)
+		if errors.Is(err, ErrCartNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ownerOf(cart) != userID {
+			continue
+		}
+		carts = append(carts, cart)
+	}
+	return carts, nil
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentCarts(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+	clock := time.Unix(1700000000, 0)
+	repo.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	newCart := func(t *testing.T, owner string) *models.Cart {
+		cart := &models.Cart{ID: uuid.New(), UserID: &owner, Status: models.CartStatusNew}
+		require.NoError(t, repo.Update(ctx, cart))
+		return cart
+	}
+	ids := func(carts []*models.Cart) []string {
+		result := make([]string, len(carts))
+		for i, cart := range carts {
+			result[i] = cart.ID.String()
+		}
+		return result
+	}
+
+	t.Run("carts should be ordered by recency", func(t *testing.T) {
+		first, second, third := newCart(t, "alice"), newCart(t, "alice"), newCart(t, "alice")
+		newCart(t, "bob")
+		require.NoError(t, repo.AddItem(ctx, first.ID.String(), models.LineItem{ItemID: 1, Quantity: 1}))
+
+		carts, err := repo.RecentCarts(ctx, "alice", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{first.ID.String(), third.ID.String(), second.ID.String()}, ids(carts))
+	})
+
+	t.Run("limit should be enforced", func(t *testing.T) {
+		newCart(t, "carol")
+		second, third := newCart(t, "carol"), newCart(t, "carol")
+
+		carts, err := repo.RecentCarts(ctx, "carol", 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{third.ID.String(), second.ID.String()}, ids(carts))
+	})
+
+	t.Run("index should keep at most MaxRecentCarts", func(t *testing.T) {
+		for i := 0; i < MaxRecentCarts+5; i++ {
+			newCart(t, "dave")
+		}
+		n, err := repo.client.ZCard(ctx, recentKeyPrefix+"dave").Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(MaxRecentCarts), n)
+	})
+
+	t.Run("deleted, completed and transferred carts should be skipped", func(t *testing.T) {
+		deleted, completed, transferred, kept := newCart(t, "erin"), newCart(t, "erin"), newCart(t, "erin"), newCart(t, "erin")
+		require.NoError(t, repo.Delete(ctx, deleted.ID.String()))
+		completed.Status = models.CartStatusCompleted
+		require.NoError(t, repo.Update(ctx, completed))
+		_, err := repo.Transfer(ctx, transferred.ID.String(), "erin", "frank", true)
+		require.NoError(t, err)
+
+		carts, err := repo.RecentCarts(ctx, "erin", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{kept.ID.String()}, ids(carts))
+
+		carts, err = repo.RecentCarts(ctx, "frank", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{transferred.ID.String()}, ids(carts))
+	})
+
+	t.Run("moved to and mutated carts should be indexed", func(t *testing.T) {
+		source, target, mutated := newCart(t, "gina"), newCart(t, "gina"), newCart(t, "gina")
+		require.NoError(t, repo.AddItem(ctx, mutated.ID.String(), models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 100}, Quantity: 2}))
+		require.NoError(t, repo.AddItem(ctx, source.ID.String(), models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 2}))
+
+		require.NoError(t, repo.MoveItem(ctx, source.ID.String(), target.ID.String(), 1))
+		carts, err := repo.RecentCarts(ctx, "gina", 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{target.ID.String()}, ids(carts))
+		active, err := repo.CartByUser(ctx, "gina")
+		require.NoError(t, err)
+		assert.Equal(t, target.ID.String(), active)
+
+		require.NoError(t, repo.DecrementItem(ctx, mutated.ID.String(), 2))
+		carts, err = repo.RecentCarts(ctx, "gina", 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{mutated.ID.String()}, ids(carts))
+		active, err = repo.CartByUser(ctx, "gina")
+		require.NoError(t, err)
+		assert.Equal(t, mutated.ID.String(), active)
+	})
+
+	t.Run("unknown user should have no carts", func(t *testing.T) {
+		carts, err := repo.RecentCarts(ctx, "nobody", 10)
+		require.NoError(t, err)
+		assert.Empty(t, carts)
+	})
+}
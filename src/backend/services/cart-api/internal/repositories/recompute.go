@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// RecomputeTotal recalculates total of the cart from its line items and
+// persists it when the stored one drifted, the corrected cart is returned
+func (r *CartRepository) RecomputeTotal(ctx context.Context, cartID string) (*models.Cart, error) {
+	cart, _, err := r.recomputeTotal(ctx, cartID)
+	return cart, err
+}
+
+// RecomputeTotals recomputes totals of carts found in one SCAN page starting
+// at cursor, ids of corrected carts and the cursor of the next page are
+// returned. Zero next cursor means the scan is complete
+func (r *CartRepository) RecomputeTotals(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	ids, next, err := r.ScanCartIDs(ctx, cursor, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	corrected := []string{}
+	for _, id := range ids {
+		_, changed, err := r.recomputeTotal(ctx, id)
+		if errors.Is(err, ErrCartNotFound) || errors.Is(err, models.ErrMoneyOverflow) {
+			// overflowing carts can't be corrected, writes reject them
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if changed {
+			corrected = append(corrected, id)
+		}
+	}
+	return corrected, next, nil
+}
+
+func (r *CartRepository) recomputeTotal(ctx context.Context, cartID string) (*models.Cart, bool, error) {
+	var result *models.Cart
+	var changed bool
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cart, err := r.getTx(ctx, tx, cartID)
+		if err != nil {
+			return err
+		}
+		result, changed = cart, false
+		total, err := calculateTotalPrice(cart.LineItems)
+		if err != nil {
+			return err
+		}
+		if total == cart.Total {
+			return nil
+		}
+		cart.Total, changed = total, true
+		return r.setTx(ctx, tx, cart)
+	}, cartID)
+	if err != nil {
+		return nil, false, err
+	}
+	return result, changed, nil
+}
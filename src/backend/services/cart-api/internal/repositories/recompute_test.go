@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecomputeTotal(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	drifted := func(t *testing.T) string {
+		cart := &models.Cart{ID: uuid.New(), Total: models.Money{Minor: 99900}, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}}}
+		assert.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+
+	t.Run("wrong stored total should be corrected", func(t *testing.T) {
+		cartID := drifted(t)
+		cart, err := repo.RecomputeTotal(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 2000}, cart.Total)
+
+		stored, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 2000}, stored.Total)
+	})
+
+	t.Run("missing cart should return ErrCartNotFound", func(t *testing.T) {
+		_, err := repo.RecomputeTotal(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("batch should correct drifted carts only", func(t *testing.T) {
+		wrong := drifted(t)
+		correct := &models.Cart{ID: uuid.New(), Total: models.Money{Minor: 1000}, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+		assert.NoError(t, repo.Update(ctx, correct))
+		_, err := repo.Share(ctx, correct.ID.String(), time.Hour)
+		assert.NoError(t, err)
+
+		var corrected []string
+		var cursor uint64
+		for {
+			ids, next, err := repo.RecomputeTotals(ctx, cursor, 100)
+			assert.NoError(t, err)
+			corrected = append(corrected, ids...)
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+		assert.Contains(t, corrected, wrong)
+		assert.NotContains(t, corrected, correct.ID.String())
+
+		stored, err := repo.Get(ctx, wrong)
+		assert.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 2000}, stored.Total)
+	})
+}
@@ -2,50 +2,132 @@ package repositories
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/tenant"
 	"github.com/redis/go-redis/v9"
 )
 
 // CartRepository implementation of redis repositor
 type CartRepository struct {
 	client *redis.Client
+	reader *redis.Client
+	limits Limits
+	policy ItemPolicy
+	codec  Codec
+	prefix string
+	itemID ItemIDStrategy
+
+	cartTTL          time.Duration
+	reservationTTL   time.Duration
+	archiveRetention time.Duration
+	versions         int
+	history          int
+	buffer           *writeBehind
+	now              func() time.Time
 }
 
+// Option configures optional behaviour of CartRepository
+type Option func(*CartRepository)
+
 // NewCartRepository creates new instance of repository
-func NewCartRepository(client *redis.Client) *CartRepository {
-	return &CartRepository{client: client}
+func NewCartRepository(client *redis.Client, opts ...Option) *CartRepository {
+	r := &CartRepository{client: client, codec: JSONCodec{}, now: time.Now}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.reader == nil {
+		r.reader = client
+	}
+	return r
 }
 
 var ErrCartNotFound = errors.New("cart not found")
 
-// Get returns cart otherwise nill
+// WithKeyPrefix namespaces every key of the repository with prefix, so
+// multiple environments can share one redis instance
+func WithKeyPrefix(prefix string) Option {
+	return func(r *CartRepository) {
+		r.prefix = prefix
+	}
+}
+
+// tenantKeyPrefix namespaces keys of a tenant, the fixed segment keeps
+// tenant ids like "summary" or "share" from colliding with other keys
+const tenantKeyPrefix = "tenant:"
+
+// key returns redis key of id, keys are namespaced by the tenant of ctx
+func (r *CartRepository) key(ctx context.Context, id string) string {
+	if t := tenant.FromContext(ctx); t != "" {
+		return r.prefix + tenantKeyPrefix + t + ":" + id
+	}
+	return r.prefix + id
+}
+
+// Get returns cart otherwise nill, the whole cart is stored as a single
+// value so reading it is one round trip to redis, historical ids missing
+// directly are resolved through aliases. With a read replica the cart is
+// read from it and can lag behind the primary
 func (r *CartRepository) Get(ctx context.Context, cartID string) (*models.Cart, error) {
-	var (
-		result models.Cart
-		data   []byte
-	)
-	data, err := r.client.Get(ctx, cartID).Bytes()
+	return r.read(ctx, r.reader, cartID)
+}
+
+// read returns the cart from c, buffered writes of the cart are flushed first
+func (r *CartRepository) read(ctx context.Context, c redis.Cmdable, cartID string) (*models.Cart, error) {
+	c, err := r.readerAfterFlush(ctx, c, cartID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.Get(ctx, r.key(ctx, cartID)).Bytes()
+	if err == redis.Nil {
+		var current string
+		if current, err = r.resolveAlias(ctx, c, cartID); err != nil {
+			return nil, err
+		}
+		cartID = current
+		if c, err = r.readerAfterFlush(ctx, c, cartID); err != nil {
+			return nil, err
+		}
+		data, err = c.Get(ctx, r.key(ctx, cartID)).Bytes()
+	}
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrCartNotFound
 		}
-		return nil, fmt.Errorf("error getting key %s: %v", cartID, err)
+		return nil, fmt.Errorf("error getting key %s: %w", cartID, err)
+	}
+	cart, migrated, err := r.decodeStored(data)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		r.rewrite(ctx, cartID, data, cart)
 	}
+	return cart, nil
+}
+
+// decodeCart unmarshals stored cart, completed carts are treated as missing
+func (r *CartRepository) decodeCart(data []byte) (*models.Cart, error) {
+	cart, _, err := r.decodeStored(data)
+	return cart, err
+}
 
-	err = json.Unmarshal(data, &result)
+// decodeStored is decodeCart which also reports whether the cart was stored
+// with an older schema version and migrated
+func (r *CartRepository) decodeStored(data []byte) (*models.Cart, bool, error) {
+	result, migrated, err := unmarshalCart(data)
 	if err != nil {
-		return nil, fmt.Errorf("error marshalling %v to %v", data, result)
+		return nil, false, fmt.Errorf("error unmarshalling %v: %w", data, err)
 	}
 
-	if r.isCartCompleted(result) {
-		return nil, ErrCartNotFound
+	if r.isCartCompleted(*result) {
+		return nil, false, ErrCartNotFound
 	}
 
-	return &result, err
+	return result, migrated, nil
 }
 
 func (r *CartRepository) isCartCompleted(cart models.Cart) bool {
@@ -55,100 +137,275 @@ func (r *CartRepository) isCartCompleted(cart models.Cart) bool {
 	return false
 }
 
-func calculateTotalPrice(items []models.LineItem) float64 {
-	var totalPrice float64
-	for _, item := range items {
-		totalPrice += float64(item.UnitPrice) * float64(item.Quantity)
+// calculateTotalPrice sums line totals of items, models.ErrMoneyOverflow is
+// returned when the total doesn't fit in minor units
+func calculateTotalPrice(items []models.LineItem) (models.Money, error) {
+	lines := make([]models.Money, len(items))
+	for i, item := range items {
+		line, err := item.UnitPrice.Mul(item.Quantity)
+		if err != nil {
+			return models.Money{}, err
+		}
+		lines[i] = line
+	}
+	return models.Sum(lines...)
+}
+
+// setTotal recalculates total of the cart from its line items
+func setTotal(cart *models.Cart) error {
+	total, err := calculateTotalPrice(cart.LineItems)
+	if err != nil {
+		return err
 	}
-	return totalPrice
+	cart.Total = total
+	return nil
 }
 
 func (r *CartRepository) AddItem(ctx context.Context, cartID string, newItem models.LineItem) error {
 	// Fetch the existing cart
-	existingCart, err := r.Get(ctx, cartID)
+	existingCart, err := r.current(ctx, cartID)
 	if err != nil {
 		return err
 	}
 
-	foundIndex := -1
-	for i, item := range existingCart.LineItems {
-		if item.ItemID == newItem.ItemID {
-			foundIndex = i
-			break
-		}
+	itemID, err := r.mergeItem(ctx, existingCart, newItem)
+	if err != nil {
+		return err
 	}
-	if foundIndex > -1 {
-		existingCart.LineItems[foundIndex].Quantity += newItem.Quantity
-	} else {
-		existingCart.LineItems = append(existingCart.LineItems, newItem)
+	if err := r.checkItem(existingCart, itemID); err != nil {
+		return err
+	}
+	if err := r.Update(ctx, existingCart); err != nil {
+		return err
 	}
-	existingCart.Total = calculateTotalPrice(existingCart.LineItems)
-	return r.Update(ctx, existingCart)
+	r.audit(ctx, cartID, itemEntry(models.AuditItemAdded, existingCart, itemID))
+	return nil
 }
 
 func (r *CartRepository) UpdateItem(ctx context.Context, cartID string, itemID int, newLineItem models.LineItem) error {
 	// Fetch the existing cart
-	existingCart, err := r.Get(ctx, cartID)
+	existingCart, err := r.current(ctx, cartID)
 	if err != nil {
 		return err
 	}
 
 	// Update the cart in Redis
-	for i, bi := range existingCart.LineItems {
+	if err := updateLine(existingCart, itemID, newLineItem); err != nil {
+		return err
+	}
+	if err := r.checkItem(existingCart, itemID); err != nil {
+		return err
+	}
+	if err := r.Update(ctx, existingCart); err != nil {
+		return err
+	}
+	r.audit(ctx, cartID, itemEntry(models.AuditItemUpdated, existingCart, itemID))
+	return nil
+}
+
+func (r *CartRepository) DeleteItem(ctx context.Context, cartID string, itemID int) error {
+	// Fetch the existing cart
+	existingCart, err := r.current(ctx, cartID)
+	if err != nil {
+		return err
+	}
+
+	// Update the cart in Redis
+	if err := removeLine(existingCart, itemID); err != nil {
+		return err
+	}
+	if err := r.Update(ctx, existingCart); err != nil {
+		return err
+	}
+	r.audit(ctx, cartID, models.AuditEntry{Action: models.AuditItemRemoved, ItemID: itemID})
+	return nil
+}
+
+// updateLine replaces details and quantity of the item with those of
+// newLineItem, ids of the item are kept
+func updateLine(cart *models.Cart, itemID int, newLineItem models.LineItem) error {
+	found := false
+	for i, bi := range cart.LineItems {
 		if bi.ItemID == itemID {
-			existingItem := existingCart.LineItems[i]
+			found = true
+			existingItem := cart.LineItems[i]
 			existingItem.Quantity = newLineItem.Quantity
 			existingItem.UnitPrice = newLineItem.UnitPrice
 			existingItem.Image = newLineItem.Image
 			existingItem.ProductName = newLineItem.ProductName
 			existingItem.ProductDescription = newLineItem.ProductDescription
 			existingItem.Attributes = newLineItem.Attributes
-			existingCart.LineItems[i] = existingItem
+			existingItem.ImageURL = newLineItem.ImageURL
+			existingItem.DisplayName = newLineItem.DisplayName
+			cart.LineItems[i] = existingItem
 		}
 	}
-	existingCart.Total = calculateTotalPrice(existingCart.LineItems)
-	return r.Update(ctx, existingCart)
-}
-
-func (r *CartRepository) DeleteItem(ctx context.Context, cartID string, itemID int) error {
-	// Fetch the existing cart
-	existingCart, err := r.Get(ctx, cartID)
-	if err != nil {
-		return err
+	if !found {
+		return fmt.Errorf("%w: item %d in cart %s", ErrItemNotFound, itemID, cart.ID)
 	}
+	return setTotal(cart)
+}
 
-	// Update the cart in Redis
+// removeLine removes the item from cart
+func removeLine(cart *models.Cart, itemID int) error {
 	updatedItems := []models.LineItem{}
-	for _, bi := range existingCart.LineItems {
+	for _, bi := range cart.LineItems {
 		if bi.ItemID != itemID {
 			updatedItems = append(updatedItems, bi)
 		}
 	}
-	existingCart.LineItems = updatedItems
-	existingCart.Total = calculateTotalPrice(existingCart.LineItems)
-	return r.Update(ctx, existingCart)
+	if len(updatedItems) == len(cart.LineItems) {
+		return fmt.Errorf("%w: item %d in cart %s", ErrItemNotFound, itemID, cart.ID)
+	}
+	cart.LineItems = updatedItems
+	return setTotal(cart)
 }
 
-// Update updates or creates new Cart
+// Update updates or creates new Cart, with write behind the write is buffered
 func (r *CartRepository) Update(ctx context.Context, item *models.Cart) error {
-	value, err := json.Marshal(item)
-
+	value, err := r.encodeCart(item)
 	if err != nil {
-		return fmt.Errorf("error marshalling %v", item)
+		return err
+	}
+	if r.buffer != nil {
+		// buffering makes no redis call which would notice the caller is gone
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.bufferWrite(ctx, item, value, true)
+		return nil
 	}
+	return r.write(ctx, item, value)
+}
 
-	err = r.client.Set(ctx, item.ID.String(), value, 0).Err()
+// write stores the encoded cart and updates data derived from it
+func (r *CartRepository) write(ctx context.Context, item *models.Cart, value []byte) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.setCart(ctx, pipe, item, value)
+		return nil
+	})
 	if err != nil {
 		v := string(value)
 		if len(v) > 15 {
 			v = v[0:12] + "..."
 		}
-		return fmt.Errorf("error setting key %s to %s: %v", item.ID, v, err)
+		return fmt.Errorf("error setting key %s to %s: %w", item.ID, v, err)
 	}
-	return err
+	r.written(ctx, item)
+	return nil
+}
+
+// written updates data derived from the stored cart. They are updated even
+// when the caller went away meanwhile, a cart stored without its reservations
+// or count entry would disagree with them until it is written again
+func (r *CartRepository) written(ctx context.Context, item *models.Cart) {
+	ctx = context.WithoutCancel(ctx)
+	r.syncReservations(ctx, item)
+	r.recordVersion(ctx, item)
+	r.indexOwner(ctx, item)
+	r.indexRecent(ctx, item)
+}
+
+// encodeCart marshals the cart with the current schema version, the
+// breakdown, discounts and availability of responses are left out
+func (r *CartRepository) encodeCart(cart *models.Cart) ([]byte, error) {
+	if cart.Breakdown != nil || cart.Discounts != nil || hasAvailability(cart.LineItems) {
+		stripped := *cart
+		stripped.Breakdown, stripped.Discounts = nil, nil
+		stripped.LineItems = make([]models.LineItem, len(cart.LineItems))
+		for i, item := range cart.LineItems {
+			item.Available, item.AvailableQuantity = nil, nil
+			stripped.LineItems[i] = item
+		}
+		cart = &stripped
+	}
+	value, err := r.codec.Marshal(storedCart{SchemaVersion: schemaVersion, Cart: cart})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling %v", cart)
+	}
+	return value, nil
+}
+
+func hasAvailability(items []models.LineItem) bool {
+	for _, item := range items {
+		if item.Available != nil || item.AvailableQuantity != nil {
+			return true
+		}
+	}
+	return false
 }
 
 // Delete removes existing Cart
 func (r *CartRepository) Delete(ctx context.Context, id string) error {
-	return r.client.Del(ctx, id).Err()
+	// a cancelled delete must not drop the buffered write either
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// a flush past takePending would write the cart again after the DEL
+	unlock := r.lockCart(ctx, id)
+	r.takePending(ctx, id)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.key(ctx, id), r.key(ctx, versionsKeyPrefix+id), r.summaryKey(ctx, id), r.itemSequenceKey(ctx, id))
+		r.uncountCart(ctx, pipe, id)
+		return nil
+	})
+	unlock()
+	if err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	r.releaseReservations(ctx, id)
+	r.forgetArchive(ctx, id)
+	r.publishDeleted(ctx, id)
+	r.audit(ctx, id, models.AuditEntry{Action: models.AuditCartDeleted})
+	return nil
+}
+
+// ErrETagMismatch is returned by DeleteIfMatch when the cart changed since
+// the client read it
+var ErrETagMismatch = errors.New("cart etag does not match")
+
+// DeleteIfMatch removes the cart only when its current ETag is one of etags,
+// "*" matches any version. The check and the delete are one transaction so a
+// concurrent write makes the delete fail instead of being lost
+func (r *CartRepository) DeleteIfMatch(ctx context.Context, id string, etags []string) error {
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cart, err := r.getTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !matchesETag(cart, etags) {
+			return ErrETagMismatch
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, r.key(ctx, id), r.key(ctx, versionsKeyPrefix+id), r.summaryKey(ctx, id), r.itemSequenceKey(ctx, id))
+			r.uncountCart(ctx, pipe, id)
+			return nil
+		})
+		return err
+	}, id)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithoutCancel(ctx)
+	r.releaseReservations(ctx, id)
+	r.forgetArchive(ctx, id)
+	r.publishDeleted(ctx, id)
+	r.audit(ctx, id, models.AuditEntry{Action: models.AuditCartDeleted})
+	return nil
+}
+
+func matchesETag(cart *models.Cart, etags []string) bool {
+	current := models.ETag(cart)
+	for _, etag := range etags {
+		if etag == "*" || etag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// Ping checks redis is reachable
+func (r *CartRepository) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
 }
@@ -60,7 +60,7 @@ func (r *RedisConnMock) Receive() (reply interface{}, err error) {
 
 var items = []models.LineItem{{
 	ItemID:      1,
-	UnitPrice:   20,
+	UnitPrice:   models.Money{Minor: 2000},
 	Quantity:    1,
 	Image:       "picture",
 	ProductName: "foodName",
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WithReadReplica serves reads of carts, shared carts and versions from a
+// read-only replica while writes go to the primary. Reads can lag behind
+// writes, mutations and transactions always read the primary so they never
+// build on a stale cart
+func WithReadReplica(reader *redis.Client) Option {
+	return func(r *CartRepository) {
+		r.reader = reader
+	}
+}
+
+// readerAfterFlush flushes a buffered write of the cart, the primary is
+// returned to read from when a write was pending so the read sees it
+// regardless of replication lag
+func (r *CartRepository) readerAfterFlush(ctx context.Context, c redis.Cmdable, cartID string) (redis.Cmdable, error) {
+	if r.hasPending(ctx, cartID) {
+		c = r.client
+	}
+	return c, r.flush(ctx, cartID)
+}
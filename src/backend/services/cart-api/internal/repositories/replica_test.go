@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadReplica(t *testing.T) {
+	ctx := context.Background()
+	replica := miniredis.RunT(t)
+	reader := redis.NewClient(&redis.Options{Addr: replica.Addr()})
+	t.Cleanup(func() { _ = reader.Close() })
+	repo, primary := newTestRepository(t, WithReadReplica(reader))
+
+	// replicate copies the primary to the replica like replication catching up
+	replicate := func(t *testing.T, key string) {
+		value, err := primary.Get(key)
+		require.NoError(t, err)
+		require.NoError(t, replica.Set(key, value))
+	}
+
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+	id := cart.ID.String()
+
+	t.Run("writes should hit the primary", func(t *testing.T) {
+		require.NoError(t, repo.Update(ctx, cart))
+		assert.True(t, primary.Exists(id))
+		assert.False(t, replica.Exists(id))
+	})
+
+	t.Run("reads should hit the replica", func(t *testing.T) {
+		_, err := repo.Get(ctx, id)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+
+		replicate(t, id)
+		result, err := repo.Get(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, cart.LineItems, result.LineItems)
+	})
+
+	t.Run("mutations should read the primary", func(t *testing.T) {
+		require.NoError(t, repo.AddItem(ctx, id, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+		require.NoError(t, repo.AddItem(ctx, id, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+
+		stale, err := repo.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stale.LineItems[0].Quantity)
+
+		replicate(t, id)
+		result, err := repo.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.LineItems[0].Quantity)
+	})
+
+	t.Run("buffered writes should be read back from the primary", func(t *testing.T) {
+		buffered, _ := newTestRepository(t, WithReadReplica(reader), WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, buffered.Update(ctx, cart))
+
+		_, err := buffered.Get(ctx, cart.ID.String())
+		assert.NoError(t, err)
+	})
+}
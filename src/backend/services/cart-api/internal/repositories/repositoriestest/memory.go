@@ -0,0 +1,529 @@
+// Package repositoriestest provides an in-memory cart repository for tests
+// which don't need a redis server
+package repositoriestest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+)
+
+// MemoryRepository keeps carts in a map and mirrors observable behaviour of
+// repositories.CartRepository: carts are copied in and out like they are
+// serialized, completed and cancelled carts read as missing, added items
+// merge into the line of their product and errors wrap the same sentinel
+// errors. Limits are not enforced
+type MemoryRepository struct {
+	mu       sync.Mutex
+	carts    map[string][]byte
+	watchers map[string][]chan *models.Cart
+	itemID   repositories.ItemIDStrategy
+	// sequences are the last item ids given with repositories.ItemIDSequence
+	sequences map[string]int
+}
+
+// Option configures MemoryRepository
+type Option func(*MemoryRepository)
+
+// WithItemIDStrategy assigns ids of added line items with strategy, see
+// repositories.WithItemIDStrategy
+func WithItemIDStrategy(strategy repositories.ItemIDStrategy) Option {
+	return func(m *MemoryRepository) {
+		m.itemID = strategy
+	}
+}
+
+// NewMemoryRepository creates empty repository
+func NewMemoryRepository(opts ...Option) *MemoryRepository {
+	m := &MemoryRepository{
+		carts:     make(map[string][]byte),
+		watchers:  make(map[string][]chan *models.Cart),
+		itemID:    repositories.ItemIDFromProduct,
+		sequences: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Get returns copy of stored cart
+func (m *MemoryRepository) Get(ctx context.Context, cartID string) (*models.Cart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(cartID)
+}
+
+// Update stores copy of cart as is, creating it when missing
+func (m *MemoryRepository) Update(ctx context.Context, cart *models.Cart) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.set(cart)
+}
+
+// CheckCart accepts every cart since limits are not enforced
+func (m *MemoryRepository) CheckCart(cart *models.Cart) error {
+	return nil
+}
+
+// Delete removes the cart, deleting missing cart is not an error
+func (m *MemoryRepository) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.carts, id)
+	delete(m.sequences, id)
+	m.unwatch(id, nil)
+	return nil
+}
+
+// DeleteIfMatch removes the cart when its current ETag is one of etags or
+// etags has "*"
+func (m *MemoryRepository) DeleteIfMatch(ctx context.Context, id string, etags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cart, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	current := models.ETag(cart)
+	for _, etag := range etags {
+		if etag == "*" || etag == current {
+			delete(m.carts, id)
+			delete(m.sequences, id)
+			m.unwatch(id, nil)
+			return nil
+		}
+	}
+	return repositories.ErrETagMismatch
+}
+
+// Touch only checks the cart exists, carts never expire
+func (m *MemoryRepository) Touch(ctx context.Context, cartID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.get(cartID)
+	return err
+}
+
+// AddItem adds item to the cart or sums the quantity when it already has a
+// line of the product
+func (m *MemoryRepository) AddItem(ctx context.Context, cartID string, newItem models.LineItem) error {
+	return m.mutate(cartID, func(cart *models.Cart) error {
+		m.addLine(cart, newItem)
+		return nil
+	})
+}
+
+// addLine adds newItem to the cart or sums the quantity of the line of its
+// product, new lines get their id like repositories.CartRepository gives it.
+// It is called with the lock held
+func (m *MemoryRepository) addLine(cart *models.Cart, newItem models.LineItem) {
+	product := newItem.Product()
+	if index := indexOfProduct(cart, product); index != -1 {
+		cart.LineItems[index].Quantity += newItem.Quantity
+		return
+	}
+	if m.itemID == repositories.ItemIDSequence {
+		id := cart.ID.String()
+		m.sequences[id] = max(m.sequences[id]+1, nextItemID(cart.LineItems))
+		newItem.ItemID = m.sequences[id]
+		newItem.ProductID = product
+	} else {
+		newItem.ItemID = product
+	}
+	cart.LineItems = append(cart.LineItems, newItem)
+}
+
+// AddItems adds every item like AddItem in one step, limits are not enforced
+// so items are never rejected
+func (m *MemoryRepository) AddItems(ctx context.Context, cartID string, items []models.LineItem, partial bool) ([]error, error) {
+	err := m.mutate(cartID, func(cart *models.Cart) error {
+		for _, newItem := range items {
+			m.addLine(cart, newItem)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return make([]error, len(items)), nil
+}
+
+// DeleteItems removes items, missing ones fail the batch unless partial is set
+func (m *MemoryRepository) DeleteItems(ctx context.Context, cartID string, itemIDs []int, partial bool) ([]error, error) {
+	itemErrs := make([]error, len(itemIDs))
+	err := m.mutate(cartID, func(cart *models.Cart) error {
+		for i, itemID := range itemIDs {
+			index := indexOf(cart, itemID)
+			if index == -1 {
+				itemErrs[i] = fmt.Errorf("%w: item %d in cart %s", repositories.ErrItemNotFound, itemID, cartID)
+				if !partial {
+					return itemErrs[i]
+				}
+				continue
+			}
+			cart.LineItems = append(cart.LineItems[:index], cart.LineItems[index+1:]...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return itemErrs, nil
+}
+
+// UpdateItem replaces details and quantity of the item keeping its ids, or
+// returns repositories.ErrItemNotFound
+func (m *MemoryRepository) UpdateItem(ctx context.Context, cartID string, itemID int, newItem models.LineItem) error {
+	return m.mutate(cartID, func(cart *models.Cart) error {
+		index := indexOf(cart, itemID)
+		if index == -1 {
+			return fmt.Errorf("%w: item %d in cart %s", repositories.ErrItemNotFound, itemID, cartID)
+		}
+		line := &cart.LineItems[index]
+		newItem.ItemID, newItem.ProductID = line.ItemID, line.ProductID
+		*line = newItem
+		return nil
+	})
+}
+
+// DeleteItem removes the item or returns repositories.ErrItemNotFound
+func (m *MemoryRepository) DeleteItem(ctx context.Context, cartID string, itemID int) error {
+	return m.mutate(cartID, func(cart *models.Cart) error {
+		index := indexOf(cart, itemID)
+		if index == -1 {
+			return fmt.Errorf("%w: item %d in cart %s", repositories.ErrItemNotFound, itemID, cartID)
+		}
+		cart.LineItems = append(cart.LineItems[:index], cart.LineItems[index+1:]...)
+		return nil
+	})
+}
+
+// MoveItem moves the item into the target cart, summing quantity when the
+// target already has a line of the product
+func (m *MemoryRepository) MoveItem(ctx context.Context, sourceID, targetID string, itemID int) error {
+	if sourceID == targetID {
+		return repositories.ErrSameCart
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source, err := m.get(sourceID)
+	if err != nil {
+		return err
+	}
+	target, err := m.get(targetID)
+	if err != nil {
+		return err
+	}
+
+	index := indexOf(source, itemID)
+	if index == -1 {
+		return fmt.Errorf("%w: item %d in cart %s", repositories.ErrItemNotFound, itemID, sourceID)
+	}
+	item := source.LineItems[index]
+	source.LineItems = append(source.LineItems[:index], source.LineItems[index+1:]...)
+
+	m.addLine(target, item)
+	if err := setTotal(source); err != nil {
+		return err
+	}
+	if err := setTotal(target); err != nil {
+		return err
+	}
+
+	if err := m.set(source); err != nil {
+		return err
+	}
+	return m.set(target)
+}
+
+// DecrementItem decrements quantity by one, removing the item at zero
+func (m *MemoryRepository) DecrementItem(ctx context.Context, cartID string, itemID int) error {
+	return m.AdjustItemQuantity(ctx, cartID, itemID, -1, true)
+}
+
+// AdjustItemQuantity adds delta to quantity, removing the item at zero. A
+// delta going below zero is clamped or returns repositories.ErrNegativeQuantity,
+// one going above models.MaxItemQuantity returns
+// repositories.ErrQuantityTooLarge
+func (m *MemoryRepository) AdjustItemQuantity(ctx context.Context, cartID string, itemID int, delta int, clamp bool) error {
+	return m.mutate(cartID, func(cart *models.Cart) error {
+		index := indexOf(cart, itemID)
+		if index == -1 {
+			return fmt.Errorf("%w: item %d in cart %s", repositories.ErrItemNotFound, itemID, cartID)
+		}
+		if delta > models.MaxItemQuantity-cart.LineItems[index].Quantity {
+			return fmt.Errorf("%w: item %d has quantity %d, delta %d", repositories.ErrQuantityTooLarge, itemID, cart.LineItems[index].Quantity, delta)
+		}
+		quantity := cart.LineItems[index].Quantity + delta
+		if quantity < 0 && !clamp {
+			return fmt.Errorf("%w: item %d has quantity %d, delta %d", repositories.ErrNegativeQuantity, itemID, cart.LineItems[index].Quantity, delta)
+		}
+		cart.LineItems[index].Quantity = quantity
+		if quantity <= 0 {
+			cart.LineItems = append(cart.LineItems[:index], cart.LineItems[index+1:]...)
+		}
+		return nil
+	})
+}
+
+// UpdateCoupons applies fn to the cart and returns the stored cart
+func (m *MemoryRepository) UpdateCoupons(ctx context.Context, cartID string, fn func(cart *models.Cart) error) (*models.Cart, error) {
+	var result *models.Cart
+	err := m.mutate(cartID, func(cart *models.Cart) error {
+		result = cart
+		return fn(cart)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RepriceItems sets unit price of the product in every cart at once, the
+// returned cursor is always zero
+func (m *MemoryRepository) RepriceItems(ctx context.Context, productID int, price models.Money, cursor uint64, count int64) ([]string, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	updated := []string{}
+	for id := range m.carts {
+		cart, err := m.get(id)
+		if err != nil {
+			continue
+		}
+		index := indexOfProduct(cart, productID)
+		if index == -1 || cart.LineItems[index].UnitPrice == price {
+			continue
+		}
+		cart.LineItems[index].UnitPrice = price
+		if err := setTotal(cart); err != nil {
+			return nil, 0, err
+		}
+		if err := m.set(cart); err != nil {
+			return nil, 0, err
+		}
+		updated = append(updated, id)
+	}
+	return updated, 0, nil
+}
+
+// DiscontinueItems removes lines of the product from every cart at once, the
+// returned cursor is always zero
+func (m *MemoryRepository) DiscontinueItems(ctx context.Context, productID int, cursor uint64, count int64) ([]*models.Cart, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	updated := []*models.Cart{}
+	for id := range m.carts {
+		cart, err := m.get(id)
+		if err != nil {
+			continue
+		}
+		kept := cart.LineItems[:0]
+		for _, item := range cart.LineItems {
+			if item.Product() != productID {
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == len(cart.LineItems) {
+			continue
+		}
+		cart.LineItems = kept
+		if err := setTotal(cart); err != nil {
+			return nil, 0, err
+		}
+		if err := m.set(cart); err != nil {
+			return nil, 0, err
+		}
+		updated = append(updated, cart)
+	}
+	return updated, 0, nil
+}
+
+// ScanCartIDs returns ids of all carts in one page, the returned cursor is
+// always zero
+func (m *MemoryRepository) ScanCartIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.carts))
+	for id := range m.carts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, 0, nil
+}
+
+// AddItemToCarts adds item to every cart like AddItem, the outcome of every
+// cart is returned at its index and duplicate ids get the item once. With
+// once carts having a line of the product fail with
+// repositories.ErrItemInCart
+func (m *MemoryRepository) AddItemToCarts(ctx context.Context, cartIDs []string, item models.LineItem, once bool) []error {
+	errs := make([]error, len(cartIDs))
+	first := make(map[string]int, len(cartIDs))
+	for i, id := range cartIDs {
+		if j, ok := first[id]; ok {
+			errs[i] = errs[j]
+			continue
+		}
+		first[id] = i
+		errs[i] = m.mutate(id, func(cart *models.Cart) error {
+			if once && indexOfProduct(cart, item.Product()) != -1 {
+				return fmt.Errorf("%w: cart %s, product %d", repositories.ErrItemInCart, id, item.Product())
+			}
+			m.addLine(cart, item)
+			return nil
+		})
+	}
+	return errs
+}
+
+// mutate applies fn to the stored cart and saves it with recalculated total
+func (m *MemoryRepository) mutate(cartID string, fn func(*models.Cart) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cart, err := m.get(cartID)
+	if err != nil {
+		return err
+	}
+	if err := fn(cart); err != nil {
+		return err
+	}
+	if err := setTotal(cart); err != nil {
+		return err
+	}
+	return m.set(cart)
+}
+
+func (m *MemoryRepository) get(cartID string) (*models.Cart, error) {
+	data, ok := m.carts[cartID]
+	if !ok {
+		return nil, repositories.ErrCartNotFound
+	}
+	var cart models.Cart
+	if err := json.Unmarshal(data, &cart); err != nil {
+		return nil, err
+	}
+	if cart.Status == models.CartStatusCompleted || cart.Status == models.CartStatusCancelled {
+		return nil, repositories.ErrCartNotFound
+	}
+	return &cart, nil
+}
+
+func (m *MemoryRepository) set(cart *models.Cart) error {
+	data, err := json.Marshal(cart.WithCurrency())
+	if err != nil {
+		return err
+	}
+	id := cart.ID.String()
+	m.carts[id] = data
+	for _, changes := range m.watchers[id] {
+		snapshot, err := m.get(id)
+		if err != nil {
+			// completed and cancelled carts end the watch like missing ones
+			m.unwatch(id, nil)
+			break
+		}
+		select {
+		case <-changes:
+		default:
+		}
+		changes <- snapshot
+	}
+	return nil
+}
+
+// Watch streams copies of the cart stored after the call until ctx is done
+// or the cart is deleted, completed or cancelled, see
+// repositories.CartRepository.Watch
+func (m *MemoryRepository) Watch(ctx context.Context, cartID string) (<-chan *models.Cart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changes := make(chan *models.Cart, 1)
+	m.watchers[cartID] = append(m.watchers[cartID], changes)
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.unwatch(cartID, changes)
+	}()
+	return changes, nil
+}
+
+// Watchers returns the number of open watches of the cart
+func (m *MemoryRepository) Watchers(cartID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.watchers[cartID])
+}
+
+// unwatch closes the watch of the cart, nil closes every watch of it
+func (m *MemoryRepository) unwatch(cartID string, changes chan *models.Cart) {
+	watchers := m.watchers[cartID][:0]
+	for _, watcher := range m.watchers[cartID] {
+		if changes == nil || watcher == changes {
+			close(watcher)
+			continue
+		}
+		watchers = append(watchers, watcher)
+	}
+	if len(watchers) == 0 {
+		delete(m.watchers, cartID)
+		return
+	}
+	m.watchers[cartID] = watchers
+}
+
+func indexOf(cart *models.Cart, itemID int) int {
+	for i, item := range cart.LineItems {
+		if item.ItemID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOfProduct(cart *models.Cart, productID int) int {
+	for i, item := range cart.LineItems {
+		if item.Product() == productID {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextItemID returns the id following the highest id of items
+func nextItemID(items []models.LineItem) int {
+	next := 1
+	for _, item := range items {
+		next = max(next, item.ItemID+1)
+	}
+	return next
+}
+
+// setTotal recalculates total of the cart like the redis repository,
+// failing with models.ErrMoneyOverflow
+func setTotal(cart *models.Cart) error {
+	lines := make([]models.Money, len(cart.LineItems))
+	for i, item := range cart.LineItems {
+		line, err := item.UnitPrice.Mul(item.Quantity)
+		if err != nil {
+			return err
+		}
+		lines[i] = line
+	}
+	total, err := models.Sum(lines...)
+	if err != nil {
+		return err
+	}
+	cart.Total = total
+	return nil
+}
@@ -0,0 +1,219 @@
+package repositoriestest
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/handlers"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/internal/repositories"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ handlers.GetCreateDeleter = (*MemoryRepository)(nil)
+
+// implementations returns the in-memory repository alongside the redis one
+// backed by miniredis so every scenario is checked against both, with
+// either item id strategy
+func implementations(t *testing.T) map[string]handlers.GetCreateDeleter {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return map[string]handlers.GetCreateDeleter{
+		"memory":          NewMemoryRepository(),
+		"redis":           repositories.NewCartRepository(client),
+		"memory sequence": NewMemoryRepository(WithItemIDStrategy(repositories.ItemIDSequence)),
+		"redis sequence":  repositories.NewCartRepository(client, repositories.WithItemIDStrategy(repositories.ItemIDSequence)),
+	}
+}
+
+func newCart(items ...models.LineItem) *models.Cart {
+	userID := "user"
+	return &models.Cart{ID: uuid.New(), UserID: &userID, Status: models.CartStatusNew, LineItems: items}
+}
+
+func TestParity(t *testing.T) {
+	ctx := context.Background()
+	apple := models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 200}, Quantity: 1, ProductName: "apple"}
+	pear := models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 300}, Quantity: 2, ProductName: "pear"}
+
+	for name, repo := range implementations(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("missing cart", func(t *testing.T) {
+				_, err := repo.Get(ctx, uuid.NewString())
+				assert.ErrorIs(t, err, repositories.ErrCartNotFound)
+				assert.ErrorIs(t, repo.AddItem(ctx, uuid.NewString(), apple), repositories.ErrCartNotFound)
+			})
+
+			t.Run("add item merges", func(t *testing.T) {
+				cart := newCart()
+				require.NoError(t, repo.Update(ctx, cart))
+				require.NoError(t, repo.AddItem(ctx, cart.ID.String(), apple))
+				require.NoError(t, repo.AddItem(ctx, cart.ID.String(), apple))
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				assert.Len(t, got.LineItems, 1)
+				assert.Equal(t, 2, got.LineItems[0].Quantity)
+				assert.Equal(t, models.Money{Minor: 400}, got.Total)
+			})
+
+			t.Run("update item", func(t *testing.T) {
+				cart := newCart(apple)
+				require.NoError(t, repo.Update(ctx, cart))
+				updated := apple
+				updated.Quantity = 5
+				require.NoError(t, repo.UpdateItem(ctx, cart.ID.String(), apple.ItemID, updated))
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				assert.Equal(t, 5, got.LineItems[0].Quantity)
+				assert.Equal(t, models.Money{Minor: 1000}, got.Total)
+
+				assert.ErrorIs(t, repo.UpdateItem(ctx, cart.ID.String(), 404, updated), repositories.ErrItemNotFound)
+			})
+
+			t.Run("items merge by product", func(t *testing.T) {
+				line := models.LineItem{ItemID: 7, ProductID: apple.ItemID, UnitPrice: apple.UnitPrice, Quantity: 1}
+				cart := newCart(line)
+				require.NoError(t, repo.Update(ctx, cart))
+				require.NoError(t, repo.AddItem(ctx, cart.ID.String(), apple))
+				_, err := repo.AddItems(ctx, cart.ID.String(), []models.LineItem{apple}, false)
+				require.NoError(t, err)
+
+				updated := apple
+				updated.Quantity = 4
+				require.NoError(t, repo.UpdateItem(ctx, cart.ID.String(), line.ItemID, updated))
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				require.Len(t, got.LineItems, 1)
+				assert.Equal(t, line.ItemID, got.LineItems[0].ItemID)
+				assert.Equal(t, apple.ItemID, got.LineItems[0].ProductID)
+				assert.Equal(t, 4, got.LineItems[0].Quantity)
+
+				target := newCart()
+				require.NoError(t, repo.Update(ctx, target))
+				require.NoError(t, repo.AddItem(ctx, target.ID.String(), pear))
+				require.NoError(t, repo.MoveItem(ctx, cart.ID.String(), target.ID.String(), line.ItemID))
+				require.NoError(t, repo.AddItem(ctx, target.ID.String(), apple))
+				got, err = repo.Get(ctx, target.ID.String())
+				require.NoError(t, err)
+				require.Len(t, got.LineItems, 2)
+				assert.Equal(t, pear.ItemID, got.LineItems[0].Product())
+				assert.Equal(t, apple.ItemID, got.LineItems[1].Product())
+				assert.Equal(t, 5, got.LineItems[1].Quantity)
+			})
+
+			t.Run("delete item", func(t *testing.T) {
+				cart := newCart(apple, pear)
+				require.NoError(t, repo.Update(ctx, cart))
+				require.NoError(t, repo.DeleteItem(ctx, cart.ID.String(), apple.ItemID))
+				assert.ErrorIs(t, repo.DeleteItem(ctx, cart.ID.String(), apple.ItemID), repositories.ErrItemNotFound)
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				assert.Equal(t, []models.LineItem{pear}, got.LineItems)
+				assert.Equal(t, models.Money{Minor: 600}, got.Total)
+			})
+
+			t.Run("bulk items", func(t *testing.T) {
+				cart := newCart(apple)
+				require.NoError(t, repo.Update(ctx, cart))
+				itemErrs, err := repo.AddItems(ctx, cart.ID.String(), []models.LineItem{apple, pear}, false)
+				require.NoError(t, err)
+				assert.Equal(t, []error{nil, nil}, itemErrs)
+
+				_, err = repo.DeleteItems(ctx, cart.ID.String(), []int{apple.ItemID, 42}, false)
+				assert.ErrorIs(t, err, repositories.ErrItemNotFound)
+
+				itemErrs, err = repo.DeleteItems(ctx, cart.ID.String(), []int{apple.ItemID, 42}, true)
+				require.NoError(t, err)
+				assert.NoError(t, itemErrs[0])
+				assert.ErrorIs(t, itemErrs[1], repositories.ErrItemNotFound)
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				require.Len(t, got.LineItems, 1)
+				assert.Equal(t, pear.ItemID, got.LineItems[0].Product())
+				assert.Equal(t, models.Money{Minor: 600}, got.Total)
+			})
+
+			t.Run("decrement item", func(t *testing.T) {
+				cart := newCart(apple)
+				require.NoError(t, repo.Update(ctx, cart))
+				require.NoError(t, repo.DecrementItem(ctx, cart.ID.String(), apple.ItemID))
+				assert.ErrorIs(t, repo.DecrementItem(ctx, cart.ID.String(), apple.ItemID), repositories.ErrItemNotFound)
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				assert.Empty(t, got.LineItems)
+			})
+
+			t.Run("adjust item quantity", func(t *testing.T) {
+				cart := newCart(pear)
+				require.NoError(t, repo.Update(ctx, cart))
+				require.NoError(t, repo.AdjustItemQuantity(ctx, cart.ID.String(), pear.ItemID, 3, false))
+				assert.ErrorIs(t, repo.AdjustItemQuantity(ctx, cart.ID.String(), pear.ItemID, -10, false), repositories.ErrNegativeQuantity)
+				assert.ErrorIs(t, repo.AdjustItemQuantity(ctx, cart.ID.String(), pear.ItemID, math.MaxInt, false), repositories.ErrQuantityTooLarge)
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				assert.Equal(t, 5, got.LineItems[0].Quantity)
+
+				require.NoError(t, repo.AdjustItemQuantity(ctx, cart.ID.String(), pear.ItemID, -10, true))
+				got, err = repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				assert.Empty(t, got.LineItems)
+			})
+
+			t.Run("move item", func(t *testing.T) {
+				source, target := newCart(apple, pear), newCart(apple)
+				require.NoError(t, repo.Update(ctx, source))
+				require.NoError(t, repo.Update(ctx, target))
+				assert.ErrorIs(t, repo.MoveItem(ctx, source.ID.String(), source.ID.String(), apple.ItemID), repositories.ErrSameCart)
+				require.NoError(t, repo.MoveItem(ctx, source.ID.String(), target.ID.String(), apple.ItemID))
+				assert.ErrorIs(t, repo.MoveItem(ctx, source.ID.String(), target.ID.String(), apple.ItemID), repositories.ErrItemNotFound)
+
+				got, err := repo.Get(ctx, target.ID.String())
+				require.NoError(t, err)
+				assert.Equal(t, 2, got.LineItems[0].Quantity)
+				got, err = repo.Get(ctx, source.ID.String())
+				require.NoError(t, err)
+				assert.Equal(t, []models.LineItem{pear}, got.LineItems)
+			})
+
+			t.Run("completed cart is missing", func(t *testing.T) {
+				cart := newCart(apple)
+				cart.Status = models.CartStatusCompleted
+				require.NoError(t, repo.Update(ctx, cart))
+				_, err := repo.Get(ctx, cart.ID.String())
+				assert.ErrorIs(t, err, repositories.ErrCartNotFound)
+			})
+
+			t.Run("returned cart is a copy", func(t *testing.T) {
+				cart := newCart(apple)
+				require.NoError(t, repo.Update(ctx, cart))
+				cart.LineItems[0].Quantity = 100
+
+				got, err := repo.Get(ctx, cart.ID.String())
+				require.NoError(t, err)
+				assert.Equal(t, 1, got.LineItems[0].Quantity)
+			})
+
+			t.Run("delete", func(t *testing.T) {
+				cart := newCart()
+				require.NoError(t, repo.Update(ctx, cart))
+				require.NoError(t, repo.Delete(ctx, cart.ID.String()))
+				_, err := repo.Get(ctx, cart.ID.String())
+				assert.ErrorIs(t, err, repositories.ErrCartNotFound)
+			})
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jurabek/cart-api/internal/models"
+)
+
+// errUnchanged aborts a mutation of a cart which needs no write
+var errUnchanged = errors.New("cart unchanged")
+
+// RepriceItems sets unit price of the product in carts found in one SCAN page
+// starting at cursor and recalculates their totals, ids of updated carts and
+// the cursor of the next page are returned. Zero next cursor means the scan
+// is complete
+func (r *CartRepository) RepriceItems(ctx context.Context, productID int, price models.Money, cursor uint64, count int64) ([]string, uint64, error) {
+	ids, next, err := r.ScanCartIDs(ctx, cursor, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	updated := []string{}
+	for _, id := range ids {
+		err := r.mutate(ctx, id, func(cart *models.Cart) error {
+			return repriceItem(cart, productID, price)
+		})
+		if errors.Is(err, ErrCartNotFound) || errors.Is(err, errUnchanged) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		updated = append(updated, id)
+	}
+	return updated, next, nil
+}
+
+func repriceItem(cart *models.Cart, productID int, price models.Money) error {
+	changed := false
+	for i, item := range cart.LineItems {
+		if item.Product() == productID && item.UnitPrice != price {
+			cart.LineItems[i].UnitPrice = price
+			changed = true
+		}
+	}
+	if !changed {
+		return errUnchanged
+	}
+	return setTotal(cart)
+}
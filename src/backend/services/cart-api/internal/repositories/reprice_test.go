@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepriceItems(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	withItem := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{
+		{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2},
+		{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1},
+	}}
+	withoutItem := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1}}}
+	completed := &models.Cart{ID: uuid.New(), Status: models.CartStatusCompleted, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+	for _, cart := range []*models.Cart{withItem, withoutItem, completed} {
+		require.NoError(t, repo.Update(ctx, cart))
+	}
+	_, err := repo.Share(ctx, withItem.ID.String(), 0)
+	require.NoError(t, err)
+
+	var updated []string
+	var cursor uint64
+	for {
+		ids, next, err := repo.RepriceItems(ctx, 1, models.Money{Minor: 700}, cursor, 2)
+		require.NoError(t, err)
+		updated = append(updated, ids...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, []string{withItem.ID.String()}, updated)
+
+	result, err := repo.Get(ctx, withItem.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, models.Money{Minor: 700}, result.LineItems[0].UnitPrice)
+	assert.Equal(t, models.Money{Minor: 500}, result.LineItems[1].UnitPrice)
+	assert.Equal(t, models.Money{Minor: 1900}, result.Total)
+
+	t.Run("repricing again should change nothing", func(t *testing.T) {
+		ids, _, err := repo.RepriceItems(ctx, 1, models.Money{Minor: 700}, 0, 100)
+		assert.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
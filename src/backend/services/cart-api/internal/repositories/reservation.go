@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Reservations of a product are kept in a hash of cart id to quantity and a
+// sorted set of cart id by expiry, every cart remembers reserved products
+const (
+	reservationKeyPrefix       = "reservations:"
+	reservationExpiryKeySuffix = ":expiry"
+	cartReservationsKeyPrefix  = "reservations:cart:"
+)
+
+// WithReservations soft reserves quantity of items in carts for ttl, every
+// change of the cart refreshes the expiry. Reservations are released when
+// the cart is deleted, checked out or they expire
+func WithReservations(ttl time.Duration) Option {
+	return func(r *CartRepository) {
+		r.reservationTTL = ttl
+	}
+}
+
+func (r *CartRepository) reservationKeys(ctx context.Context, productID int) (string, string) {
+	key := r.key(ctx, reservationKeyPrefix+strconv.Itoa(productID))
+	return key, key + reservationExpiryKeySuffix
+}
+
+// Reserved returns quantity of the product reserved by carts, expired
+// reservations are released first
+func (r *CartRepository) Reserved(ctx context.Context, productID int) (int, error) {
+	quantities, expiry := r.reservationKeys(ctx, productID)
+	now := strconv.FormatInt(r.now().UnixMilli(), 10)
+
+	expired, err := r.client.ZRangeByScore(ctx, expiry, &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error reading reservations of %d: %w", productID, err)
+	}
+	if len(expired) > 0 {
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, quantities, expired...)
+			pipe.ZRem(ctx, expiry, toInterfaces(expired)...)
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error releasing reservations of %d: %w", productID, err)
+		}
+	}
+
+	values, err := r.client.HVals(ctx, quantities).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error reading reservations of %d: %w", productID, err)
+	}
+	var total int
+	for _, v := range values {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid reservation of %d: %w", productID, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// syncReservations makes reservations of the cart match its line items,
+// reservations are soft so failures are logged and don't fail the mutation
+func (r *CartRepository) syncReservations(ctx context.Context, cart *models.Cart) {
+	if r.reservationTTL <= 0 {
+		return
+	}
+	var items []models.LineItem
+	if !r.isCartCompleted(*cart) {
+		items = cart.LineItems
+	}
+	if err := r.reserve(ctx, cart.ID.String(), items); err != nil {
+		log.Warn().Err(err).Str("cart_id", cart.ID.String()).Msg("failed to sync reservations")
+	}
+}
+
+// releaseReservations drops all reservations of the cart
+func (r *CartRepository) releaseReservations(ctx context.Context, cartID string) {
+	if r.reservationTTL <= 0 {
+		return
+	}
+	if err := r.reserve(ctx, cartID, nil); err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to release reservations")
+	}
+}
+
+func (r *CartRepository) reserve(ctx context.Context, cartID string, items []models.LineItem) error {
+	cartKey := r.key(ctx, cartReservationsKeyPrefix+cartID)
+	previous, err := r.client.SMembers(ctx, cartKey).Result()
+	if err != nil {
+		return fmt.Errorf("error reading reservations of cart %s: %w", cartID, err)
+	}
+
+	current := make(map[string]bool, len(items))
+	expiry := float64(r.now().Add(r.reservationTTL).UnixMilli())
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, item := range items {
+			if item.Quantity <= 0 {
+				continue
+			}
+			quantities, expiries := r.reservationKeys(ctx, item.Product())
+			pipe.HSet(ctx, quantities, cartID, item.Quantity)
+			pipe.ZAdd(ctx, expiries, redis.Z{Score: expiry, Member: cartID})
+			pipe.SAdd(ctx, cartKey, item.Product())
+			current[strconv.Itoa(item.Product())] = true
+		}
+		for _, product := range previous {
+			if current[product] {
+				continue
+			}
+			productID, err := strconv.Atoi(product)
+			if err != nil {
+				continue
+			}
+			quantities, expiries := r.reservationKeys(ctx, productID)
+			pipe.HDel(ctx, quantities, cartID)
+			pipe.ZRem(ctx, expiries, cartID)
+			pipe.SRem(ctx, cartKey, product)
+		}
+		if len(current) > 0 {
+			pipe.PExpire(ctx, cartKey, r.reservationTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error updating reservations of cart %s: %w", cartID, err)
+	}
+	return nil
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservations(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t, WithReservations(time.Hour))
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	newCart := func(t *testing.T) string {
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		assert.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+	reserved := func(t *testing.T, productID int) int {
+		n, err := repo.Reserved(ctx, productID)
+		assert.NoError(t, err)
+		return n
+	}
+
+	t.Run("AddItem should reserve quantity", func(t *testing.T) {
+		first, second := newCart(t), newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, first, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}))
+		assert.NoError(t, repo.AddItem(ctx, first, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+		assert.NoError(t, repo.AddItem(ctx, second, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 4}))
+		assert.Equal(t, 7, reserved(t, 1))
+
+		assert.NoError(t, repo.DecrementItem(ctx, second, 1))
+		assert.Equal(t, 6, reserved(t, 1))
+	})
+
+	t.Run("DeleteItem should release the item", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}))
+		assert.NoError(t, repo.DeleteItem(ctx, cartID, 2))
+		assert.Equal(t, 0, reserved(t, 2))
+	})
+
+	t.Run("Delete should release the cart", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 3, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}))
+		assert.NoError(t, repo.Delete(ctx, cartID))
+		assert.Equal(t, 0, reserved(t, 3))
+	})
+
+	t.Run("checkout should release the cart", func(t *testing.T) {
+		cartID := newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 4, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}))
+		cart, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		cart.Status = models.CartStatusCompleted
+		assert.NoError(t, repo.Update(ctx, cart))
+		assert.Equal(t, 0, reserved(t, 4))
+	})
+
+	t.Run("expired reservations should be released", func(t *testing.T) {
+		stale, fresh := newCart(t), newCart(t)
+		assert.NoError(t, repo.AddItem(ctx, stale, models.LineItem{ItemID: 5, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}))
+		now = now.Add(50 * time.Minute)
+		assert.NoError(t, repo.AddItem(ctx, fresh, models.LineItem{ItemID: 5, UnitPrice: models.Money{Minor: 1000}, Quantity: 3}))
+		assert.Equal(t, 5, reserved(t, 5))
+
+		now = now.Add(20 * time.Minute)
+		assert.Equal(t, 3, reserved(t, 5))
+	})
+}
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripCounter counts every trip to redis, a pipeline counts as one
+type roundTripCounter struct {
+	trips atomic.Int64
+}
+
+func (c *roundTripCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (c *roundTripCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		c.trips.Add(1)
+		return next(ctx, cmd)
+	}
+}
+
+func (c *roundTripCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		c.trips.Add(1)
+		return next(ctx, cmds)
+	}
+}
+
+func bigCart(n int) *models.Cart {
+	cart := &models.Cart{ID: uuid.New()}
+	for i := 0; i < n; i++ {
+		cart.LineItems = append(cart.LineItems, models.LineItem{ItemID: i, UnitPrice: models.Money{Minor: 150}, Quantity: 2, ProductName: "item"})
+	}
+	return cart
+}
+
+func TestGetRoundTrips(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	cart := bigCart(50)
+	assert.NoError(t, repo.Update(ctx, cart))
+
+	counter := &roundTripCounter{}
+	repo.client.AddHook(counter)
+
+	result, err := repo.Get(ctx, cart.ID.String())
+	assert.NoError(t, err)
+	assert.Len(t, result.LineItems, 50)
+	assert.Equal(t, int64(1), counter.trips.Load(), "Get must read the whole cart in a single round trip")
+}
+
+func BenchmarkGet(b *testing.B) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(b)
+	cart := bigCart(50)
+	if err := repo.Update(ctx, cart); err != nil {
+		b.Fatal(err)
+	}
+	id := cart.ID.String()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.Get(ctx, id); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// schemaVersion is the version of carts written by this code, carts stored
+// before versioning have no version and are version 1
+const schemaVersion = 4
+
+// migrations upgrade a cart decoded from version k to version k+1, fields
+// dropped by a version stay on the models until every cart is rewritten
+var migrations = map[int]func(cart *models.Cart){
+	// version 1 carts only had img, version 2 exposes absolute urls as image_url
+	1: func(cart *models.Cart) {
+		for i, item := range cart.LineItems {
+			if item.ImageURL != "" || item.Image == "" {
+				continue
+			}
+			item.ImageURL = item.Image
+			if !errors.Is(item.Validate(), models.ErrInvalidImageURL) {
+				cart.LineItems[i] = item
+			}
+		}
+	},
+	// version 2 carts keep prices and totals as float numbers, Money reads
+	// them so the cart only needs rewriting with decimal strings
+	2: func(cart *models.Cart) {},
+	// version 3 carts keep amounts as decimal strings naming their currency,
+	// version 4 writes numbers and the currency of the cart instead
+	3: func(cart *models.Cart) {},
+}
+
+// storedCart is the stored representation of a cart, the version is kept
+// next to the cart fields so unversioned carts decode as version 0
+type storedCart struct {
+	SchemaVersion int `json:"schema_version"`
+	*models.Cart
+}
+
+// MarshalJSON writes the version before fields of the cart, the JSON methods
+// of the embedded cart would leave it out
+func (s storedCart) MarshalJSON() ([]byte, error) {
+	cart, err := json.Marshal(s.Cart.WithCurrency())
+	if err != nil {
+		return nil, err
+	}
+	version := `{"schema_version":` + strconv.Itoa(s.SchemaVersion)
+	if len(cart) <= 2 {
+		return []byte(version + "}"), nil
+	}
+	return append([]byte(version+","), cart[1:]...), nil
+}
+
+// UnmarshalJSON reads the version next to fields of the cart
+func (s *storedCart) UnmarshalJSON(data []byte) error {
+	var version struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &version); err != nil {
+		return err
+	}
+	s.SchemaVersion = version.SchemaVersion
+	if s.Cart == nil {
+		s.Cart = &models.Cart{}
+	}
+	return json.Unmarshal(data, s.Cart)
+}
+
+// unmarshalCart decodes stored cart and upgrades it to schemaVersion,
+// migrated reports whether the stored value is outdated. Carts written by a
+// newer version are returned as is
+func unmarshalCart(data []byte) (cart *models.Cart, migrated bool, err error) {
+	stored := storedCart{Cart: &models.Cart{}}
+	if err := detectCodec(data).Unmarshal(data, &stored); err != nil {
+		return nil, false, err
+	}
+	version := stored.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	for ; version < schemaVersion; version++ {
+		migrations[version](stored.Cart)
+		migrated = true
+	}
+	return stored.Cart, migrated, nil
+}
+
+// rewrite replaces outdated stored value of the cart with the migrated one
+// unless it was changed meanwhile, failures are logged since the cart is
+// migrated again on the next read
+func (r *CartRepository) rewrite(ctx context.Context, cartID string, old []byte, cart *models.Cart) {
+	value, err := r.encodeCart(cart)
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to encode migrated cart")
+		return
+	}
+	key := r.key(ctx, cartID)
+	err = r.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil || (err == nil && string(current) != string(old)) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetArgs(ctx, key, value, redis.SetArgs{KeepTTL: true})
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cartID).Msg("failed to rewrite migrated cart")
+	}
+}
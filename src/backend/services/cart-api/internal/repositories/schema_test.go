@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaMigration(t *testing.T) {
+	ctx := context.Background()
+	for _, codec := range []Codec{JSONCodec{}, MsgpackCodec{}} {
+		t.Run(codecName(codec)+" v1 cart should be upgraded and rewritten", func(t *testing.T) {
+			repo, mr := newTestRepository(t, WithCodec(codec))
+			cart := &models.Cart{
+				ID:     uuid.New(),
+				Status: models.CartStatusNew,
+				LineItems: []models.LineItem{
+					{ItemID: 1, Quantity: 1, Image: "https://cdn.example.com/plov.png"},
+					{ItemID: 2, Quantity: 1, Image: "plov.png"},
+				},
+			}
+			// version 1 carts were the bare model without schema_version
+			v1, err := codec.Marshal(cart)
+			require.NoError(t, err)
+			require.NoError(t, mr.Set(cart.ID.String(), string(v1)))
+			mr.SetTTL(cart.ID.String(), time.Hour)
+
+			got, err := repo.Get(ctx, cart.ID.String())
+			require.NoError(t, err)
+			assert.Equal(t, "https://cdn.example.com/plov.png", got.LineItems[0].ImageURL)
+			assert.Empty(t, got.LineItems[1].ImageURL, "relative img is not a valid image_url")
+
+			data, err := mr.Get(cart.ID.String())
+			require.NoError(t, err)
+			var stored storedCart
+			require.NoError(t, codec.Unmarshal([]byte(data), &stored))
+			assert.Equal(t, schemaVersion, stored.SchemaVersion)
+			assert.Equal(t, got, stored.Cart)
+			assert.Equal(t, time.Hour, mr.TTL(cart.ID.String()))
+
+			_, migrated, err := unmarshalCart([]byte(data))
+			require.NoError(t, err)
+			assert.False(t, migrated)
+		})
+
+		t.Run(codecName(codec)+" v2 cart with float prices should be read exactly", func(t *testing.T) {
+			repo, mr := newTestRepository(t, WithCodec(codec))
+			id := uuid.New()
+			// version 2 carts kept unit prices as float32 and totals as float64
+			v2, err := codec.Marshal(map[string]interface{}{
+				"schema_version": 2,
+				"id":             id,
+				"status":         int(models.CartStatusNew),
+				"items":          []map[string]interface{}{{"item_id": 1, "unit_price": float32(12.99), "quantity": 3}},
+				"total":          float64(float32(12.99)) * 3,
+				"discount":       float32(0.1),
+			})
+			require.NoError(t, err)
+			require.NoError(t, mr.Set(id.String(), string(v2)))
+
+			got, err := repo.Get(ctx, id.String())
+			require.NoError(t, err)
+			assert.Equal(t, models.Money{Minor: 1299}, got.LineItems[0].UnitPrice)
+			assert.Equal(t, models.Money{Minor: 3897}, got.Total)
+			assert.Equal(t, &models.Money{Minor: 10}, got.Discount)
+
+			data, err := mr.Get(id.String())
+			require.NoError(t, err)
+			_, migrated, err := unmarshalCart([]byte(data))
+			require.NoError(t, err)
+			assert.False(t, migrated)
+		})
+	}
+
+	t.Run("cart changed meanwhile should not be overwritten", func(t *testing.T) {
+		repo, mr := newTestRepository(t)
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+		v1, err := JSONCodec{}.Marshal(cart)
+		require.NoError(t, err)
+		require.NoError(t, mr.Set(cart.ID.String(), "changed"))
+
+		decoded, migrated, err := unmarshalCart(v1)
+		require.NoError(t, err)
+		assert.True(t, migrated)
+		repo.rewrite(ctx, cart.ID.String(), v1, decoded)
+
+		data, err := mr.Get(cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, "changed", data)
+	})
+
+	t.Run("current version should be written", func(t *testing.T) {
+		repo, mr := newTestRepository(t)
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+		require.NoError(t, repo.Update(ctx, cart))
+
+		data, err := mr.Get(cart.ID.String())
+		require.NoError(t, err)
+		assert.Contains(t, data, `"schema_version":4`)
+	})
+
+	t.Run("currency should be stored with the cart", func(t *testing.T) {
+		repo, mr := newTestRepository(t)
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, Total: models.Money{Minor: 1200, Currency: "JPY"}}
+		require.NoError(t, repo.Update(ctx, cart))
+
+		data, err := mr.Get(cart.ID.String())
+		require.NoError(t, err)
+		assert.Contains(t, data, `"total":1200`)
+		assert.Contains(t, data, `"currency":"JPY"`)
+
+		got, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 1200, Currency: "JPY"}, got.Total)
+	})
+
+	t.Run("version 3 amounts should be read", func(t *testing.T) {
+		repo, mr := newTestRepository(t)
+		id := uuid.New()
+		require.NoError(t, mr.Set(id.String(), `{"schema_version":3,"id":"`+id.String()+`","items":[{"item_id":1,"unit_price":"600 JPY","quantity":2}],"total":"1200 JPY","status":1}`))
+
+		got, err := repo.Get(ctx, id.String())
+		require.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 1200, Currency: "JPY"}, got.Total)
+		assert.Equal(t, models.Money{Minor: 600, Currency: "JPY"}, got.LineItems[0].UnitPrice)
+	})
+
+	t.Run("breakdown should not be stored", func(t *testing.T) {
+		repo, mr := newTestRepository(t)
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, Breakdown: &models.TotalBreakdown{Total: models.Money{Minor: 500}}}
+		require.NoError(t, repo.Update(ctx, cart))
+
+		data, err := mr.Get(cart.ID.String())
+		require.NoError(t, err)
+		assert.NotContains(t, data, "breakdown")
+		assert.NotNil(t, cart.Breakdown, "the cart of the caller should be kept")
+	})
+
+	t.Run("availability should not be stored", func(t *testing.T) {
+		repo, mr := newTestRepository(t)
+		available, quantity := true, 3
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, LineItems: []models.LineItem{
+			{ItemID: 1, Quantity: 1, Available: &available, AvailableQuantity: &quantity},
+		}}
+		require.NoError(t, repo.Update(ctx, cart))
+
+		data, err := mr.Get(cart.ID.String())
+		require.NoError(t, err)
+		assert.NotContains(t, data, "available")
+		assert.NotNil(t, cart.LineItems[0].Available, "the cart of the caller should be kept")
+	})
+
+	t.Run("discounts should not be stored", func(t *testing.T) {
+		repo, _ := newTestRepository(t)
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, LineItems: []models.LineItem{}, Coupons: []string{"TEN"},
+			Discounts: []models.AppliedDiscount{{Code: "TEN", Amount: models.Money{Minor: 100}}}}
+		require.NoError(t, repo.Update(ctx, cart))
+
+		stored, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"TEN"}, stored.Coupons)
+		assert.Nil(t, stored.Discounts)
+	})
+}
+
+func codecName(c Codec) string {
+	if _, ok := c.(MsgpackCodec); ok {
+		return "msgpack"
+	}
+	return "json"
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// ImportFile stores carts of the json array at path, see Import
+func (r *CartRepository) ImportFile(ctx context.Context, path string) ([]string, error) {
+	carts, err := readSeedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Import(ctx, carts)
+}
+
+func readSeedFile(path string) ([]models.Cart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading carts: %w", err)
+	}
+	var carts []models.Cart
+	if err := json.Unmarshal(data, &carts); err != nil {
+		return nil, fmt.Errorf("error decoding carts of %s: %w", path, err)
+	}
+	return carts, nil
+}
+
+// Import stores carts whose id is not in redis yet, existing carts are left
+// as they are so importing a dev dataset again keeps local changes. Totals
+// are computed from line items. Ids of stored carts are returned
+func (r *CartRepository) Import(ctx context.Context, carts []models.Cart) ([]string, error) {
+	imported := []string{}
+	for i := range carts {
+		cart := &carts[i]
+		if cart.ID == uuid.Nil {
+			return imported, errors.New("error importing cart without id")
+		}
+		if err := setTotal(cart); err != nil {
+			return imported, fmt.Errorf("error importing cart %s: %w", cart.ID, err)
+		}
+		value, err := r.encodeCart(cart)
+		if err != nil {
+			return imported, err
+		}
+		stored, err := r.client.SetNX(ctx, r.key(ctx, cart.ID.String()), value, r.cartTTL).Result()
+		if err != nil {
+			return imported, fmt.Errorf("error importing cart %s: %w", cart.ID, err)
+		}
+		if !stored {
+			continue
+		}
+		// SETNX can't be queued with the summary and count, they follow the cart
+		if _, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			r.setSummary(ctx, pipe, cart, r.cartTTL)
+			r.countCart(ctx, pipe, cart)
+			return nil
+		}); err != nil {
+			return imported, fmt.Errorf("error importing cart %s: %w", cart.ID, err)
+		}
+		r.written(ctx, cart)
+		imported = append(imported, cart.ID.String())
+	}
+	return imported, nil
+}
+
+// ImportFile appends carts of the json array at path, see Import
+func (r *EventSourcedRepository) ImportFile(ctx context.Context, path string) ([]string, error) {
+	carts, err := readSeedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Import(ctx, carts)
+}
+
+// Import appends a cart_replaced event of carts which have no stream yet,
+// see CartRepository.Import
+func (r *EventSourcedRepository) Import(ctx context.Context, carts []models.Cart) ([]string, error) {
+	imported := []string{}
+	for i := range carts {
+		cart := &carts[i]
+		if cart.ID == uuid.Nil {
+			return imported, errors.New("error importing cart without id")
+		}
+		if err := setTotal(cart); err != nil {
+			return imported, fmt.Errorf("error importing cart %s: %w", cart.ID, err)
+		}
+		value, err := r.carts.encodeCart(cart)
+		if err != nil {
+			return imported, err
+		}
+		cartID := cart.ID.String()
+		var stored bool
+		err = r.watch(ctx, func(tx *redis.Tx) error {
+			n, err := tx.Exists(ctx, r.eventsKey(ctx, cartID), r.snapshotKey(ctx, cartID)).Result()
+			if err != nil || n > 0 {
+				return err
+			}
+			event := r.event(ctx, models.AuditCartReplaced, value)
+			if err := r.commit(ctx, tx, cartChange{cartID: cartID, cart: cart, events: []cartEvent{event}}); err != nil {
+				return err
+			}
+			stored = true
+			return nil
+		}, cartID)
+		if err != nil {
+			return imported, fmt.Errorf("error importing cart %s: %w", cart.ID, err)
+		}
+		if stored {
+			imported = append(imported, cartID)
+		}
+	}
+	return imported, nil
+}
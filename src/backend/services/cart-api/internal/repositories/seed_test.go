@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportFile(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	existing := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 9, UnitPrice: models.Money{Minor: 100}, Quantity: 1}}}
+	require.NoError(t, repo.Update(ctx, existing))
+
+	first, second := uuid.NewString(), uuid.NewString()
+	path := filepath.Join(t.TempDir(), "carts.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"id": "`+first+`", "user_id": "dev", "items": [
+			{"item_id": 1, "product_name": "Margherita", "unit_price": "12.50", "quantity": 2},
+			{"item_id": 2, "product_name": "Cola", "unit_price": 2.5, "quantity": 1}
+		]},
+		{"id": "`+second+`", "items": []},
+		{"id": "`+existing.ID.String()+`", "items": [{"item_id": 1, "unit_price": "1.00", "quantity": 5}]}
+	]`), 0o600))
+
+	imported, err := repo.ImportFile(ctx, path)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{first, second}, imported)
+
+	t.Run("imported carts should be gettable", func(t *testing.T) {
+		cart, err := repo.Get(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, "dev", *cart.UserID)
+		assert.Len(t, cart.LineItems, 2)
+		assert.Equal(t, models.Money{Minor: 2750}, cart.Total)
+
+		_, err = repo.Get(ctx, second)
+		assert.NoError(t, err)
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("existing carts should be skipped", func(t *testing.T) {
+		cart, err := repo.Get(ctx, existing.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 9, cart.LineItems[0].ItemID)
+	})
+
+	t.Run("importing again should store nothing", func(t *testing.T) {
+		imported, err := repo.ImportFile(ctx, path)
+		require.NoError(t, err)
+		assert.Empty(t, imported)
+	})
+
+	t.Run("invalid files should fail", func(t *testing.T) {
+		_, err := repo.ImportFile(ctx, filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err)
+
+		invalid := filepath.Join(t.TempDir(), "invalid.json")
+		require.NoError(t, os.WriteFile(invalid, []byte(`[{"items": []}]`), 0o600))
+		_, err = repo.ImportFile(ctx, invalid)
+		assert.Error(t, err)
+	})
+}
+
+func TestEventSourcedImportFile(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestEventSourcedRepository(t, 0)
+
+	existing := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 9, UnitPrice: models.Money{Minor: 100}, Quantity: 1}}}
+	require.NoError(t, repo.Update(ctx, existing))
+
+	first := uuid.NewString()
+	path := filepath.Join(t.TempDir(), "carts.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"id": "`+first+`", "items": [{"item_id": 1, "unit_price": "12.50", "quantity": 2}]},
+		{"id": "`+existing.ID.String()+`", "items": [{"item_id": 1, "unit_price": "1.00", "quantity": 5}]}
+	]`), 0o600))
+
+	imported, err := repo.ImportFile(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first}, imported)
+
+	t.Run("imported carts should be folded from their stream", func(t *testing.T) {
+		cart, err := repo.Get(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, models.Money{Minor: 2500}, cart.Total)
+
+		count, err := repo.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("existing carts should be skipped", func(t *testing.T) {
+		cart, err := repo.Get(ctx, existing.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 9, cart.LineItems[0].ItemID)
+
+		imported, err := repo.ImportFile(ctx, path)
+		require.NoError(t, err)
+		assert.Empty(t, imported)
+	})
+}
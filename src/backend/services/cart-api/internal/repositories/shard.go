@@ -0,0 +1,490 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrCrossShard = errors.New("carts are stored on different shards")
+
+// DefaultRingReplicas is the number of points every node owns on a Ring
+const DefaultRingReplicas = 160
+
+// Ring maps keys to nodes by consistent hashing. Every node owns replicas
+// points on the ring and a key belongs to the first point following its
+// hash, so adding or removing a node only remaps keys of the points it
+// gains or loses, about 1/n of them
+type Ring struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewRing creates ring of nodes with replicas points per node
+func NewRing(nodes []string, replicas int) *Ring {
+	r := &Ring{owners: make(map[uint32]string, len(nodes)*replicas)}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			point := crc32.ChecksumIEEE([]byte(node + "#" + strconv.Itoa(i)))
+			// the smallest node keeps colliding points so the ring doesn't
+			// depend on order of the nodes
+			if owner, ok := r.owners[point]; ok {
+				if node < owner {
+					r.owners[point] = node
+				}
+				continue
+			}
+			r.points = append(r.points, point)
+			r.owners[point] = node
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Node returns node owning key, empty for ring without nodes
+func (r *Ring) Node(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// ShardedRepository spreads carts across repositories of several redis
+// nodes, every cart id is routed to one node by consistent hashing.
+//
+// Nodes are not rebalanced: carts stay where they were written, so after
+// adding or removing a node the carts remapped to another node read as
+// missing until they expire or are migrated offline. Moving items between
+// carts of different nodes fails with ErrCrossShard since it can't be
+// atomic. Data derived from a cart like shares, versions, reservations and
+// user indexes lives next to the cart on its node, lookups by user, share
+// token or product fan out to every node and scans walk the nodes one
+// after another
+type ShardedRepository struct {
+	ring   *Ring
+	shards map[string]*CartRepository
+	// nodes in scan order
+	nodes []string
+}
+
+// NewShardedRepository creates repository routing carts across shards
+// which are keyed by node name
+func NewShardedRepository(shards map[string]*CartRepository) *ShardedRepository {
+	nodes := make([]string, 0, len(shards))
+	for node := range shards {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return &ShardedRepository{ring: NewRing(nodes, DefaultRingReplicas), shards: shards, nodes: nodes}
+}
+
+// shard returns repository storing the cart
+func (s *ShardedRepository) shard(cartID string) *CartRepository {
+	return s.shards[s.ring.Node(cartID)]
+}
+
+func (s *ShardedRepository) Get(ctx context.Context, cartID string) (*models.Cart, error) {
+	return s.shard(cartID).Get(ctx, cartID)
+}
+
+func (s *ShardedRepository) Update(ctx context.Context, cart *models.Cart) error {
+	return s.shard(cart.ID.String()).Update(ctx, cart)
+}
+
+func (s *ShardedRepository) CheckCart(cart *models.Cart) error {
+	return s.shard(cart.ID.String()).CheckCart(cart)
+}
+
+func (s *ShardedRepository) Delete(ctx context.Context, id string) error {
+	return s.shard(id).Delete(ctx, id)
+}
+
+func (s *ShardedRepository) DeleteIfMatch(ctx context.Context, id string, etags []string) error {
+	return s.shard(id).DeleteIfMatch(ctx, id, etags)
+}
+
+func (s *ShardedRepository) Watch(ctx context.Context, cartID string) (<-chan *models.Cart, error) {
+	return s.shard(cartID).Watch(ctx, cartID)
+}
+
+func (s *ShardedRepository) Summary(ctx context.Context, cartID string) (models.CartSummary, error) {
+	return s.shard(cartID).Summary(ctx, cartID)
+}
+
+func (s *ShardedRepository) History(ctx context.Context, cartID string) ([]models.AuditEntry, error) {
+	return s.shard(cartID).History(ctx, cartID)
+}
+
+func (s *ShardedRepository) Touch(ctx context.Context, cartID string) error {
+	return s.shard(cartID).Touch(ctx, cartID)
+}
+
+func (s *ShardedRepository) AddItem(ctx context.Context, cartID string, item models.LineItem) error {
+	return s.shard(cartID).AddItem(ctx, cartID, item)
+}
+
+func (s *ShardedRepository) AddItems(ctx context.Context, cartID string, items []models.LineItem, partial bool) ([]error, error) {
+	return s.shard(cartID).AddItems(ctx, cartID, items, partial)
+}
+
+func (s *ShardedRepository) UpdateItem(ctx context.Context, cartID string, itemID int, item models.LineItem) error {
+	return s.shard(cartID).UpdateItem(ctx, cartID, itemID, item)
+}
+
+func (s *ShardedRepository) DeleteItem(ctx context.Context, cartID string, itemID int) error {
+	return s.shard(cartID).DeleteItem(ctx, cartID, itemID)
+}
+
+func (s *ShardedRepository) DeleteItems(ctx context.Context, cartID string, itemIDs []int, partial bool) ([]error, error) {
+	return s.shard(cartID).DeleteItems(ctx, cartID, itemIDs, partial)
+}
+
+func (s *ShardedRepository) DecrementItem(ctx context.Context, cartID string, itemID int) error {
+	return s.shard(cartID).DecrementItem(ctx, cartID, itemID)
+}
+
+func (s *ShardedRepository) AdjustItemQuantity(ctx context.Context, cartID string, itemID int, delta int, clamp bool) error {
+	return s.shard(cartID).AdjustItemQuantity(ctx, cartID, itemID, delta, clamp)
+}
+
+func (s *ShardedRepository) UpdateCoupons(ctx context.Context, cartID string, fn func(cart *models.Cart) error) (*models.Cart, error) {
+	return s.shard(cartID).UpdateCoupons(ctx, cartID, fn)
+}
+
+// MoveItem moves the item between carts of the same shard
+func (s *ShardedRepository) MoveItem(ctx context.Context, sourceID, targetID string, itemID int) error {
+	source := s.shard(sourceID)
+	if source != s.shard(targetID) {
+		return fmt.Errorf("%w: %s and %s", ErrCrossShard, sourceID, targetID)
+	}
+	return source.MoveItem(ctx, sourceID, targetID, itemID)
+}
+
+// Ping checks every shard is reachable
+func (s *ShardedRepository) Ping(ctx context.Context) error {
+	for node, shard := range s.shards {
+		if err := shard.Ping(ctx); err != nil {
+			return fmt.Errorf("shard %s: %w", node, err)
+		}
+	}
+	return nil
+}
+
+// FlushAll writes buffered carts of every shard
+func (s *ShardedRepository) FlushAll(ctx context.Context) error {
+	var errs []error
+	for node, shard := range s.shards {
+		if err := shard.FlushAll(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", node, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Count sums carts of every shard
+func (s *ShardedRepository) Count(ctx context.Context) (int64, error) {
+	var total int64
+	for node, shard := range s.shards {
+		n, err := shard.Count(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("shard %s: %w", node, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// ArchivedCount sums archived carts of every shard
+func (s *ShardedRepository) ArchivedCount(ctx context.Context) (int64, error) {
+	var total int64
+	for node, shard := range s.shards {
+		n, err := shard.ArchivedCount(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("shard %s: %w", node, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (s *ShardedRepository) CountCartMutation(ctx context.Context, cartID string, window time.Duration) (int64, time.Duration, error) {
+	return s.shard(cartID).CountCartMutation(ctx, cartID, window)
+}
+
+func (s *ShardedRepository) VersionByETag(ctx context.Context, cartID, etag string) (*models.Cart, error) {
+	return s.shard(cartID).VersionByETag(ctx, cartID, etag)
+}
+
+func (s *ShardedRepository) VersionAt(ctx context.Context, cartID string, t time.Time) (*models.Cart, error) {
+	return s.shard(cartID).VersionAt(ctx, cartID, t)
+}
+
+func (s *ShardedRepository) RecomputeTotal(ctx context.Context, cartID string) (*models.Cart, error) {
+	return s.shard(cartID).RecomputeTotal(ctx, cartID)
+}
+
+// Share stores the snapshot on the shard of the cart
+func (s *ShardedRepository) Share(ctx context.Context, cartID string, ttl time.Duration) (string, error) {
+	return s.shard(cartID).Share(ctx, cartID, ttl)
+}
+
+// GetShared looks the token up on every shard
+func (s *ShardedRepository) GetShared(ctx context.Context, token string) (*models.Cart, error) {
+	for _, node := range s.nodes {
+		cart, err := s.shards[node].GetShared(ctx, token)
+		if errors.Is(err, ErrShareNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", node, err)
+		}
+		return cart, nil
+	}
+	return nil, ErrShareNotFound
+}
+
+// Reserved sums reservations of the product on every shard
+func (s *ShardedRepository) Reserved(ctx context.Context, productID int) (int, error) {
+	var total int
+	for _, node := range s.nodes {
+		n, err := s.shards[node].Reserved(ctx, productID)
+		if err != nil {
+			return 0, fmt.Errorf("shard %s: %w", node, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Transfer moves the cart on its shard. Active carts of to stored on other
+// shards are checked before and forgotten after the transfer, neither is
+// part of its transaction
+func (s *ShardedRepository) Transfer(ctx context.Context, cartID, from, to string, replace bool) (*models.Cart, error) {
+	owner := s.shard(cartID)
+	if !replace {
+		for _, node := range s.nodes {
+			if s.shards[node] == owner {
+				continue
+			}
+			active, err := s.shards[node].CartByUser(ctx, to)
+			if err != nil && !errors.Is(err, ErrCartNotFound) {
+				return nil, fmt.Errorf("shard %s: %w", node, err)
+			}
+			if active != "" && active != cartID {
+				return nil, fmt.Errorf("%w: user %s", ErrActiveCart, to)
+			}
+		}
+	}
+	cart, err := owner.Transfer(ctx, cartID, from, to, replace)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range s.nodes {
+		if s.shards[node] != owner {
+			s.shards[node].forgetOwner(context.WithoutCancel(ctx), to)
+		}
+	}
+	return cart, nil
+}
+
+// RecentCarts merges recent carts of the user on every shard by update time
+func (s *ShardedRepository) RecentCarts(ctx context.Context, userID string, limit int) ([]*models.Cart, error) {
+	var scored []redis.Z
+	for _, node := range s.nodes {
+		z, err := s.shards[node].recentIDs(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", node, err)
+		}
+		scored = append(scored, z...)
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
+	return recentCarts(ctx, s.Get, userID, scored, limit)
+}
+
+// CreateNamed claims the name on the shard of the cart after checking named
+// carts of the owner on every shard, concurrent creates of the same name on
+// different shards may both succeed
+func (s *ShardedRepository) CreateNamed(ctx context.Context, cart *models.Cart) error {
+	if owner := ownerOf(cart); owner != "" && cart.Name != "" {
+		named, err := s.NamedCarts(ctx, owner)
+		if err != nil {
+			return err
+		}
+		for _, existing := range named {
+			if existing.Name == cart.Name && existing.ID != cart.ID {
+				return fmt.Errorf("%w: %q", ErrDuplicateName, cart.Name)
+			}
+		}
+	}
+	return s.shard(cart.ID.String()).CreateNamed(ctx, cart)
+}
+
+// NamedCarts merges named carts of the user on every shard ordered by name
+func (s *ShardedRepository) NamedCarts(ctx context.Context, userID string) ([]*models.Cart, error) {
+	var carts []*models.Cart
+	for _, node := range s.nodes {
+		named, err := s.shards[node].NamedCarts(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", node, err)
+		}
+		carts = append(carts, named...)
+	}
+	sort.SliceStable(carts, func(i, j int) bool { return carts[i].Name < carts[j].Name })
+	if carts == nil {
+		carts = []*models.Cart{}
+	}
+	return carts, nil
+}
+
+// AddItemToCarts adds item to carts of every shard in one batch per shard,
+// outcomes keep the order of cartIDs
+func (s *ShardedRepository) AddItemToCarts(ctx context.Context, cartIDs []string, item models.LineItem, once bool) []error {
+	errs := make([]error, len(cartIDs))
+	for shard, indexes := range s.group(cartIDs) {
+		ids := make([]string, len(indexes))
+		for j, i := range indexes {
+			ids[j] = cartIDs[i]
+		}
+		for j, err := range shard.AddItemToCarts(ctx, ids, item, once) {
+			errs[indexes[j]] = err
+		}
+	}
+	return errs
+}
+
+// CreateCarts creates carts of every shard in one transaction per shard,
+// carts of other shards may already be stored when it fails
+func (s *ShardedRepository) CreateCarts(ctx context.Context, carts []*models.Cart) error {
+	ids := make([]string, len(carts))
+	for i, cart := range carts {
+		ids[i] = cart.ID.String()
+	}
+	for shard, indexes := range s.group(ids) {
+		batch := make([]*models.Cart, len(indexes))
+		for j, i := range indexes {
+			batch[j] = carts[i]
+		}
+		if err := shard.CreateCarts(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// group returns indexes of cart ids by their shard
+func (s *ShardedRepository) group(cartIDs []string) map[*CartRepository][]int {
+	groups := make(map[*CartRepository][]int)
+	for i, id := range cartIDs {
+		shard := s.shard(id)
+		groups[shard] = append(groups[shard], i)
+	}
+	return groups
+}
+
+// ImportFile stores seed carts of the file on their shards like Import
+func (s *ShardedRepository) ImportFile(ctx context.Context, path string) ([]string, error) {
+	carts, err := readSeedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return s.Import(ctx, carts)
+}
+
+// Import stores every cart on its shard like CartRepository.Import
+func (s *ShardedRepository) Import(ctx context.Context, carts []models.Cart) ([]string, error) {
+	imported := []string{}
+	for i := range carts {
+		ids, err := s.shard(carts[i].ID.String()).Import(ctx, carts[i:i+1])
+		imported = append(imported, ids...)
+		if err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// shardCursorBits is the width of the redis cursor in a sharded scan cursor,
+// the bits above it select the shard being scanned. Cursors stay below 1<<53
+// so JSON clients reading numbers as float64, e.g. javascript, get them
+// exactly, which leaves room for 1<<13 shards
+const shardCursorBits = 40
+
+// maxScanShards is the number of shards a sharded scan cursor can address
+const maxScanShards = 1 << (53 - shardCursorBits)
+
+// scan runs one step of a scan walking shards one after another. The cursor
+// joins the position in nodes with the SCAN cursor of that shard, which
+// stays far below 1<<shardCursorBits as it is bounded by the keyspace size
+func (s *ShardedRepository) scan(cursor uint64, step func(shard *CartRepository, cursor uint64) (uint64, error)) (uint64, error) {
+	if len(s.nodes) > maxScanShards {
+		return 0, fmt.Errorf("scan of %d shards, at most %d are supported", len(s.nodes), maxScanShards)
+	}
+	i := int(cursor >> shardCursorBits)
+	if i >= len(s.nodes) {
+		return 0, nil
+	}
+	next, err := step(s.shards[s.nodes[i]], cursor&(1<<shardCursorBits-1))
+	if err != nil {
+		return 0, fmt.Errorf("shard %s: %w", s.nodes[i], err)
+	}
+	if next >= 1<<shardCursorBits {
+		return 0, fmt.Errorf("shard %s: scan cursor %d is wider than %d bits", s.nodes[i], next, shardCursorBits)
+	}
+	if next == 0 {
+		if i++; i == len(s.nodes) {
+			return 0, nil
+		}
+	}
+	return uint64(i)<<shardCursorBits | next, nil
+}
+
+// ScanCartIDs scans cart ids of every shard, the cursor is 0 once all of
+// them are scanned
+func (s *ShardedRepository) ScanCartIDs(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	var ids []string
+	next, err := s.scan(cursor, func(shard *CartRepository, cursor uint64) (next uint64, err error) {
+		ids, next, err = shard.ScanCartIDs(ctx, cursor, count)
+		return next, err
+	})
+	return ids, next, err
+}
+
+func (s *ShardedRepository) RecomputeTotals(ctx context.Context, cursor uint64, count int64) ([]string, uint64, error) {
+	var corrected []string
+	next, err := s.scan(cursor, func(shard *CartRepository, cursor uint64) (next uint64, err error) {
+		corrected, next, err = shard.RecomputeTotals(ctx, cursor, count)
+		return next, err
+	})
+	return corrected, next, err
+}
+
+func (s *ShardedRepository) RepriceItems(ctx context.Context, productID int, price models.Money, cursor uint64, count int64) ([]string, uint64, error) {
+	var updated []string
+	next, err := s.scan(cursor, func(shard *CartRepository, cursor uint64) (next uint64, err error) {
+		updated, next, err = shard.RepriceItems(ctx, productID, price, cursor, count)
+		return next, err
+	})
+	return updated, next, err
+}
+
+func (s *ShardedRepository) DiscontinueItems(ctx context.Context, productID int, cursor uint64, count int64) ([]*models.Cart, uint64, error) {
+	var updated []*models.Cart
+	next, err := s.scan(cursor, func(shard *CartRepository, cursor uint64) (next uint64, err error) {
+		updated, next, err = shard.DiscontinueItems(ctx, productID, cursor, count)
+		return next, err
+	})
+	return updated, next, err
+}
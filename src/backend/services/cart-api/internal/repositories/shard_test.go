@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ringKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = uuid.NewString()
+	}
+	return keys
+}
+
+func TestRing(t *testing.T) {
+	nodes := []string{"redis-a:6379", "redis-b:6379", "redis-c:6379"}
+	keys := ringKeys(10000)
+
+	t.Run("id should always map to the same node", func(t *testing.T) {
+		ring := NewRing(nodes, DefaultRingReplicas)
+		reversed := NewRing([]string{nodes[2], nodes[1], nodes[0]}, DefaultRingReplicas)
+		for _, key := range keys {
+			node := ring.Node(key)
+			assert.Equal(t, node, ring.Node(key))
+			assert.Equal(t, node, reversed.Node(key), "order of nodes should not matter")
+		}
+	})
+
+	t.Run("keys should spread across nodes", func(t *testing.T) {
+		ring := NewRing(nodes, DefaultRingReplicas)
+		counts := map[string]int{}
+		for _, key := range keys {
+			counts[ring.Node(key)]++
+		}
+		for _, node := range nodes {
+			assert.InDelta(t, len(keys)/len(nodes), counts[node], float64(len(keys))*0.1, node)
+		}
+	})
+
+	t.Run("added node should only take over keys", func(t *testing.T) {
+		before := NewRing(nodes, DefaultRingReplicas)
+		after := NewRing(append(nodes[:len(nodes):len(nodes)], "redis-d:6379"), DefaultRingReplicas)
+		remapped := 0
+		for _, key := range keys {
+			if node := after.Node(key); node != before.Node(key) {
+				assert.Equal(t, "redis-d:6379", node)
+				remapped++
+			}
+		}
+		// ideally a quarter of keys moves to the fourth node
+		assert.InDelta(t, len(keys)/4, remapped, float64(len(keys))*0.1)
+	})
+
+	t.Run("removed node should only give away its keys", func(t *testing.T) {
+		before := NewRing(nodes, DefaultRingReplicas)
+		after := NewRing(nodes[:2], DefaultRingReplicas)
+		for _, key := range keys {
+			if node := before.Node(key); node != nodes[2] {
+				assert.Equal(t, node, after.Node(key))
+			}
+		}
+	})
+
+	t.Run("empty ring should have no node", func(t *testing.T) {
+		assert.Empty(t, NewRing(nil, DefaultRingReplicas).Node("key"))
+	})
+}
+
+func TestShardedRepository(t *testing.T) {
+	ctx := context.Background()
+	shards := map[string]*CartRepository{}
+	for i := 0; i < 3; i++ {
+		repo, _ := newTestRepository(t)
+		shards[fmt.Sprintf("shard-%d", i)] = repo
+	}
+	sharded := NewShardedRepository(shards)
+
+	newCart := func(t *testing.T) *models.Cart {
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 200}, Quantity: 1}}}
+		require.NoError(t, sharded.Update(ctx, cart))
+		return cart
+	}
+
+	t.Run("cart should be stored on its shard only", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			cart := newCart(t)
+			owner := sharded.ring.Node(cart.ID.String())
+			for node, shard := range shards {
+				_, err := shard.Get(ctx, cart.ID.String())
+				if node == owner {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorIs(t, err, ErrCartNotFound)
+				}
+			}
+
+			require.NoError(t, sharded.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 200}, Quantity: 1}))
+			got, err := sharded.Get(ctx, cart.ID.String())
+			require.NoError(t, err)
+			assert.Equal(t, 2, got.LineItems[0].Quantity)
+		}
+	})
+
+	t.Run("move between shards should fail", func(t *testing.T) {
+		source := newCart(t)
+		target := newCart(t)
+		for sharded.shard(target.ID.String()) == sharded.shard(source.ID.String()) {
+			target = newCart(t)
+		}
+		assert.ErrorIs(t, sharded.MoveItem(ctx, source.ID.String(), target.ID.String(), 1), ErrCrossShard)
+
+		for sharded.shard(target.ID.String()) != sharded.shard(source.ID.String()) {
+			target = newCart(t)
+		}
+		assert.NoError(t, sharded.MoveItem(ctx, source.ID.String(), target.ID.String(), 1))
+	})
+
+	t.Run("delete should remove cart from its shard", func(t *testing.T) {
+		cart := newCart(t)
+		require.NoError(t, sharded.Delete(ctx, cart.ID.String()))
+		_, err := sharded.Get(ctx, cart.ID.String())
+		assert.ErrorIs(t, err, ErrCartNotFound)
+		assert.NoError(t, sharded.Ping(ctx))
+	})
+	t.Run("count should sum every shard", func(t *testing.T) {
+		before, err := sharded.Count(ctx)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			newCart(t)
+		}
+		after, err := sharded.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, before+10, after)
+	})
+}
+
+func TestShardedRepositoryFanOut(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	shards := map[string]*CartRepository{}
+	for i := 0; i < 3; i++ {
+		repo, _ := newTestRepository(t)
+		repo.now = func() time.Time { return now }
+		shards[fmt.Sprintf("shard-%d", i)] = repo
+	}
+	sharded := NewShardedRepository(shards)
+
+	// userCarts creates a cart of the user on every shard
+	userCarts := func(t *testing.T, user string, name func(i int) string) []*models.Cart {
+		var carts []*models.Cart
+		seen := map[*CartRepository]bool{}
+		for len(seen) < len(shards) {
+			cart := &models.Cart{ID: uuid.New(), UserID: &user, Status: models.CartStatusNew}
+			if seen[sharded.shard(cart.ID.String())] {
+				continue
+			}
+			seen[sharded.shard(cart.ID.String())] = true
+			now = now.Add(time.Second)
+			cart.Name = name(len(carts))
+			if cart.Name != "" {
+				require.NoError(t, sharded.CreateNamed(ctx, cart))
+			} else {
+				require.NoError(t, sharded.Update(ctx, cart))
+			}
+			carts = append(carts, cart)
+		}
+		return carts
+	}
+
+	t.Run("scan should walk every shard", func(t *testing.T) {
+		created := map[string]bool{}
+		for i := 0; i < 30; i++ {
+			cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+			require.NoError(t, sharded.Update(ctx, cart))
+			created[cart.ID.String()] = true
+		}
+
+		scanned := map[string]bool{}
+		var cursor uint64
+		for {
+			ids, next, err := sharded.ScanCartIDs(ctx, cursor, 4)
+			require.NoError(t, err)
+			for _, id := range ids {
+				scanned[id] = true
+			}
+			if next == 0 {
+				break
+			}
+			assert.Less(t, next, uint64(1)<<53, "cursor should be exact as a JSON number")
+			cursor = next
+		}
+		for id := range created {
+			assert.True(t, scanned[id], id)
+		}
+	})
+
+	t.Run("recent carts should merge shards by update time", func(t *testing.T) {
+		carts := userCarts(t, "recent-user", func(int) string { return "" })
+		recent, err := sharded.RecentCarts(ctx, "recent-user", 10)
+		require.NoError(t, err)
+		require.Len(t, recent, len(carts))
+		for i, cart := range recent {
+			assert.Equal(t, carts[len(carts)-1-i].ID, cart.ID)
+		}
+	})
+
+	t.Run("named carts should be unique across shards", func(t *testing.T) {
+		names := []string{"c", "a", "b"}
+		userCarts(t, "lists-user", func(i int) string { return names[i] })
+		named, err := sharded.NamedCarts(ctx, "lists-user")
+		require.NoError(t, err)
+		require.Len(t, named, 3)
+		assert.Equal(t, []string{"a", "b", "c"}, []string{named[0].Name, named[1].Name, named[2].Name})
+
+		user := "lists-user"
+		err = sharded.CreateNamed(ctx, &models.Cart{ID: uuid.New(), UserID: &user, Name: "b"})
+		assert.ErrorIs(t, err, ErrDuplicateName)
+	})
+
+	t.Run("shared cart should be found on its shard", func(t *testing.T) {
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+		require.NoError(t, sharded.Update(ctx, cart))
+		token, err := sharded.Share(ctx, cart.ID.String(), time.Hour)
+		require.NoError(t, err)
+
+		shared, err := sharded.GetShared(ctx, token)
+		require.NoError(t, err)
+		assert.Equal(t, cart.ID, shared.ID)
+		_, err = sharded.GetShared(ctx, "missing")
+		assert.ErrorIs(t, err, ErrShareNotFound)
+	})
+
+	t.Run("transfer should see active cart on another shard", func(t *testing.T) {
+		active := userCarts(t, "transfer-user", func(int) string { return "" })[0]
+		cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+		for sharded.shard(cart.ID.String()) == sharded.shard(active.ID.String()) {
+			cart.ID = uuid.New()
+		}
+		require.NoError(t, sharded.Update(ctx, cart))
+
+		_, err := sharded.Transfer(ctx, cart.ID.String(), "", "transfer-user", false)
+		assert.ErrorIs(t, err, ErrActiveCart)
+
+		_, err = sharded.Transfer(ctx, cart.ID.String(), "", "transfer-user", true)
+		require.NoError(t, err)
+		_, err = sharded.shard(active.ID.String()).CartByUser(ctx, "transfer-user")
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("batch add should keep order of carts", func(t *testing.T) {
+		var ids []string
+		for i := 0; i < 6; i++ {
+			cart := &models.Cart{ID: uuid.New(), Status: models.CartStatusNew}
+			require.NoError(t, sharded.Update(ctx, cart))
+			ids = append(ids, cart.ID.String())
+		}
+		ids = append(ids, uuid.NewString())
+
+		errs := sharded.AddItemToCarts(ctx, ids, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 1}, false)
+		require.Len(t, errs, len(ids))
+		for i, err := range errs[:len(errs)-1] {
+			assert.NoError(t, err, i)
+		}
+		assert.ErrorIs(t, errs[len(errs)-1], ErrCartNotFound)
+	})
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrShareNotFound = errors.New("shared cart not found")
+
+const shareKeyPrefix = "share:"
+
+// Share stores an immutable snapshot of the cart under a random token which
+// expires after ttl
+func (r *CartRepository) Share(ctx context.Context, cartID string, ttl time.Duration) (string, error) {
+	cart, err := r.Get(ctx, cartID)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+
+	value, err := r.encodeCart(cart)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.client.Set(ctx, r.key(ctx, shareKeyPrefix+token), value, ttl).Err(); err != nil {
+		return "", fmt.Errorf("error setting shared cart %s: %w", cartID, err)
+	}
+	return token, nil
+}
+
+// GetShared returns snapshot created by Share
+func (r *CartRepository) GetShared(ctx context.Context, token string) (*models.Cart, error) {
+	data, err := r.reader.Get(ctx, r.key(ctx, shareKeyPrefix+token)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrShareNotFound
+		}
+		return nil, fmt.Errorf("error getting shared cart %s: %w", token, err)
+	}
+
+	result, _, err := unmarshalCart(data)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling shared cart %s: %w", token, err)
+	}
+	return result, nil
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating share token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
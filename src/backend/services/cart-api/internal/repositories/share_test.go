@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShare(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t)
+
+	cart := &models.Cart{ID: uuid.New(), LineItems: items}
+	assert.NoError(t, repo.Update(ctx, cart))
+
+	t.Run("given existing cart Share should store a snapshot", func(t *testing.T) {
+		token, err := repo.Share(ctx, cart.ID.String(), time.Hour)
+		assert.NoError(t, err)
+		assert.Len(t, token, 32)
+
+		shared, err := repo.GetShared(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, cart.ID, shared.ID)
+		assert.Equal(t, cart.LineItems, shared.LineItems)
+	})
+
+	t.Run("snapshot should not change when cart is mutated", func(t *testing.T) {
+		token, err := repo.Share(ctx, cart.ID.String(), time.Hour)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 500}, Quantity: 1}))
+
+		shared, err := repo.GetShared(ctx, token)
+		assert.NoError(t, err)
+		assert.Len(t, shared.LineItems, 1)
+	})
+
+	t.Run("snapshot should expire after ttl", func(t *testing.T) {
+		token, err := repo.Share(ctx, cart.ID.String(), time.Minute)
+		assert.NoError(t, err)
+
+		mr.FastForward(2 * time.Minute)
+
+		_, err = repo.GetShared(ctx, token)
+		assert.ErrorIs(t, err, ErrShareNotFound)
+	})
+
+	t.Run("given missing cart Share should return ErrCartNotFound", func(t *testing.T) {
+		_, err := repo.Share(ctx, uuid.NewString(), time.Hour)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("given unknown token GetShared should return ErrShareNotFound", func(t *testing.T) {
+		_, err := repo.GetShared(ctx, "unknown")
+		assert.ErrorIs(t, err, ErrShareNotFound)
+	})
+}
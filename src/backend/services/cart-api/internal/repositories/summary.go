@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// summaryKeyPrefix keys a hash of total and item count next to every cart,
+// written in the same transaction as the cart so reading them doesn't
+// decode the cart
+const summaryKeyPrefix = "summary:"
+
+func (r *CartRepository) summaryKey(ctx context.Context, cartID string) string {
+	return r.key(ctx, summaryKeyPrefix+cartID)
+}
+
+// summarize computes the summary from line items
+func summarize(cart *models.Cart) (models.CartSummary, error) {
+	total, err := calculateTotalPrice(cart.LineItems)
+	if err != nil {
+		return models.CartSummary{}, err
+	}
+	summary := models.CartSummary{Total: total}
+	for _, item := range cart.LineItems {
+		summary.ItemCount += item.Quantity
+	}
+	return summary, nil
+}
+
+// setCart queues the write of the encoded cart and its summary on pipe,
+// summaries of completed carts are removed as the carts read as missing
+func (r *CartRepository) setCart(ctx context.Context, pipe redis.Pipeliner, cart *models.Cart, value []byte) {
+	id := cart.ID.String()
+	ttl := r.cartTTL
+	if r.archiveRetention > 0 {
+		// archived carts are kept until swept
+		if r.isCartCompleted(*cart) {
+			ttl = 0
+		}
+		r.archive(ctx, pipe, cart)
+	}
+	pipe.Set(ctx, r.key(ctx, id), value, ttl)
+	r.publishChange(ctx, pipe, id, value)
+	r.setSummary(ctx, pipe, cart, r.cartTTL)
+	r.countCart(ctx, pipe, cart)
+	if r.itemID == ItemIDSequence && r.cartTTL > 0 {
+		pipe.PExpire(ctx, r.itemSequenceKey(ctx, id), r.cartTTL)
+	}
+}
+
+func (r *CartRepository) setSummary(ctx context.Context, pipe redis.Pipeliner, cart *models.Cart, ttl time.Duration) {
+	key := r.summaryKey(ctx, cart.ID.String())
+	if r.isCartCompleted(*cart) {
+		pipe.Del(ctx, key)
+		return
+	}
+	summary, err := summarize(cart)
+	if err != nil {
+		// the summary is recomputed on read and fails like the cart
+		pipe.Del(ctx, key)
+		return
+	}
+	pipe.HSet(ctx, key, "total", summary.Total.String(), "item_count", summary.ItemCount)
+	if ttl > 0 {
+		pipe.PExpire(ctx, key, ttl)
+	} else {
+		pipe.Persist(ctx, key)
+	}
+}
+
+// Summary returns total and item count of the cart without reading it.
+// Missing summaries, e.g. of carts stored by older versions, are recomputed
+// from the cart and stored for its remaining ttl
+func (r *CartRepository) Summary(ctx context.Context, cartID string) (models.CartSummary, error) {
+	c, err := r.readerAfterFlush(ctx, r.reader, cartID)
+	if err != nil {
+		return models.CartSummary{}, err
+	}
+	fields, err := c.HGetAll(ctx, r.summaryKey(ctx, cartID)).Result()
+	if err != nil {
+		return models.CartSummary{}, fmt.Errorf("error getting summary of %s: %w", cartID, err)
+	}
+	if summary, ok := parseSummary(fields); ok {
+		return summary, nil
+	}
+	return r.recomputeSummary(ctx, cartID)
+}
+
+func parseSummary(fields map[string]string) (models.CartSummary, bool) {
+	total, err := models.ParseMoney(fields["total"])
+	if err != nil {
+		return models.CartSummary{}, false
+	}
+	count, err := strconv.Atoi(fields["item_count"])
+	if err != nil {
+		return models.CartSummary{}, false
+	}
+	return models.CartSummary{Total: total, ItemCount: count}, true
+}
+
+// recomputeSummary stores summary of the cart as it is in redis, the cart
+// is watched so a concurrent write isn't overwritten with an older summary
+func (r *CartRepository) recomputeSummary(ctx context.Context, cartID string) (models.CartSummary, error) {
+	var summary models.CartSummary
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cart, err := r.getTx(ctx, tx, cartID)
+		if err != nil {
+			return err
+		}
+		ttl, err := tx.PTTL(ctx, r.key(ctx, cartID)).Result()
+		if err != nil {
+			return fmt.Errorf("error getting ttl of %s: %w", cartID, err)
+		}
+		if summary, err = summarize(cart); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			r.setSummary(ctx, pipe, cart, ttl)
+			return nil
+		})
+		return err
+	}, cartID)
+	return summary, err
+}
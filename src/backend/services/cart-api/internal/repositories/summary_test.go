@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t, WithCartTTL(time.Hour))
+
+	// consistent compares the cached summary with one computed from the cart
+	consistent := func(t *testing.T, id string, want models.CartSummary) {
+		t.Helper()
+		assert.True(t, mr.Exists(summaryKeyPrefix+id), "summary should be cached")
+		got, err := repo.Summary(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		cart, err := repo.Get(ctx, id)
+		require.NoError(t, err)
+		summary, err := summarize(cart)
+		require.NoError(t, err)
+		assert.Equal(t, summary, got, "cached summary should match recomputation")
+	}
+
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1250}, Quantity: 2}}}
+	require.NoError(t, repo.Update(ctx, cart))
+	id := cart.ID.String()
+	consistent(t, id, models.CartSummary{Total: models.Money{Minor: 2500}, ItemCount: 2})
+
+	t.Run("add item", func(t *testing.T) {
+		require.NoError(t, repo.AddItem(ctx, id, models.LineItem{ItemID: 2, UnitPrice: models.Money{Minor: 300}, Quantity: 3}))
+		consistent(t, id, models.CartSummary{Total: models.Money{Minor: 3400}, ItemCount: 5})
+	})
+
+	t.Run("update item", func(t *testing.T) {
+		require.NoError(t, repo.UpdateItem(ctx, id, 2, models.LineItem{UnitPrice: models.Money{Minor: 300}, Quantity: 1}))
+		consistent(t, id, models.CartSummary{Total: models.Money{Minor: 2800}, ItemCount: 3})
+	})
+
+	t.Run("transactional mutation", func(t *testing.T) {
+		require.NoError(t, repo.AdjustItemQuantity(ctx, id, 1, 2, false))
+		consistent(t, id, models.CartSummary{Total: models.Money{Minor: 5300}, ItemCount: 5})
+	})
+
+	t.Run("move item", func(t *testing.T) {
+		target := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, target))
+		require.NoError(t, repo.MoveItem(ctx, id, target.ID.String(), 2))
+		consistent(t, id, models.CartSummary{Total: models.Money{Minor: 5000}, ItemCount: 4})
+		consistent(t, target.ID.String(), models.CartSummary{Total: models.Money{Minor: 300}, ItemCount: 1})
+	})
+
+	t.Run("delete item", func(t *testing.T) {
+		require.NoError(t, repo.DeleteItem(ctx, id, 1))
+		consistent(t, id, models.CartSummary{Total: models.Money{}, ItemCount: 0})
+	})
+
+	t.Run("summary should expire with the cart", func(t *testing.T) {
+		assert.Equal(t, mr.TTL(id), mr.TTL(summaryKeyPrefix+id))
+		mr.FastForward(time.Minute)
+		require.NoError(t, repo.Touch(ctx, id))
+		assert.Equal(t, time.Hour, mr.TTL(summaryKeyPrefix+id))
+	})
+
+	t.Run("delete should remove summary", func(t *testing.T) {
+		require.NoError(t, repo.Delete(ctx, id))
+		assert.False(t, mr.Exists(summaryKeyPrefix+id))
+		_, err := repo.Summary(ctx, id)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("completed cart should have no summary", func(t *testing.T) {
+		completed := &models.Cart{ID: uuid.New(), Status: models.CartStatusCompleted, LineItems: []models.LineItem{{ItemID: 1, Quantity: 1}}}
+		require.NoError(t, repo.Update(ctx, completed))
+		assert.False(t, mr.Exists(summaryKeyPrefix+completed.ID.String()))
+		_, err := repo.Summary(ctx, completed.ID.String())
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("missing summary should be recomputed", func(t *testing.T) {
+		older := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 199}, Quantity: 3}}}
+		require.NoError(t, repo.Update(ctx, older))
+		mr.Del(summaryKeyPrefix + older.ID.String())
+		mr.FastForward(10 * time.Minute)
+
+		got, err := repo.Summary(ctx, older.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, models.CartSummary{Total: models.Money{Minor: 597}, ItemCount: 3}, got)
+		assert.Equal(t, mr.TTL(older.ID.String()), mr.TTL(summaryKeyPrefix+older.ID.String()), "recomputed summary should expire with the cart")
+	})
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/jurabek/cart-api/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	repo, mr := newTestRepository(t, WithKeyPrefix("cart:"))
+	acme, err := tenant.NewContext(context.Background(), "acme")
+	assert.NoError(t, err)
+	globex, err := tenant.NewContext(context.Background(), "globex")
+	assert.NoError(t, err)
+
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+	cartID := cart.ID.String()
+	assert.NoError(t, repo.Update(acme, cart))
+
+	t.Run("keys should be namespaced by tenant", func(t *testing.T) {
+		assert.True(t, mr.Exists("cart:tenant:acme:"+cartID))
+		assert.False(t, mr.Exists("cart:"+cartID))
+	})
+
+	t.Run("tenant named like a keyspace should not collide with it", func(t *testing.T) {
+		summary, err := tenant.NewContext(context.Background(), "summary")
+		assert.NoError(t, err)
+		other := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+		assert.NoError(t, repo.Update(context.Background(), other))
+		assert.True(t, mr.Exists("cart:"+summaryKeyPrefix+other.ID.String()))
+		_, err = repo.Get(summary, other.ID.String())
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("other tenants should not see the cart", func(t *testing.T) {
+		_, err := repo.Get(globex, cartID)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+		_, err = repo.Get(context.Background(), cartID)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+		assert.ErrorIs(t, repo.DecrementItem(globex, cartID, 1), ErrCartNotFound)
+	})
+
+	t.Run("owning tenant should read and mutate the cart", func(t *testing.T) {
+		assert.NoError(t, repo.AddItem(acme, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+		got, err := repo.Get(acme, cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, got.LineItems[0].Quantity)
+	})
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrNotOwner   = errors.New("cart is owned by another user")
+	ErrActiveCart = errors.New("user already has an active cart")
+)
+
+// userKeyPrefix keys the id of the cart owned by a user
+const userKeyPrefix = "users:"
+
+// anonymousUserID owns carts created without a user, it is never indexed
+const anonymousUserID = "anonymous"
+
+func ownerOf(cart *models.Cart) string {
+	if cart.UserID == nil || *cart.UserID == anonymousUserID {
+		return ""
+	}
+	return *cart.UserID
+}
+
+// indexOwner points the user of the cart to it, the index is a lookup aid
+// so failures are logged and don't fail the mutation. Named carts are kept
+// besides the active cart and never indexed
+func (r *CartRepository) indexOwner(ctx context.Context, cart *models.Cart) {
+	owner := ownerOf(cart)
+	if owner == "" || cart.Name != "" || r.isCartCompleted(*cart) {
+		return
+	}
+	if err := r.client.Set(ctx, r.key(ctx, userKeyPrefix+owner), cart.ID.String(), r.cartTTL).Err(); err != nil {
+		log.Warn().Err(err).Str("cart_id", cart.ID.String()).Msg("failed to index cart owner")
+	}
+}
+
+// forgetOwner drops the active cart of the user
+func (r *CartRepository) forgetOwner(ctx context.Context, userID string) {
+	if err := r.client.Del(ctx, r.key(ctx, userKeyPrefix+userID)).Err(); err != nil {
+		log.Warn().Err(err).Str("user_id", userID).Msg("failed to forget cart owner")
+	}
+}
+
+// CartByUser returns id of the active cart owned by the user, entries of
+// deleted or checked out carts are ignored
+func (r *CartRepository) CartByUser(ctx context.Context, userID string) (string, error) {
+	return r.activeCart(ctx, r.reader, userID)
+}
+
+func (r *CartRepository) activeCart(ctx context.Context, c redis.Cmdable, userID string) (string, error) {
+	cartID, err := c.Get(ctx, r.key(ctx, userKeyPrefix+userID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", ErrCartNotFound
+		}
+		return "", fmt.Errorf("error getting cart of user %s: %w", userID, err)
+	}
+	data, err := c.Get(ctx, r.key(ctx, cartID)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return "", ErrCartNotFound
+		}
+		return "", fmt.Errorf("error getting key %s: %w", cartID, err)
+	}
+	if _, err := r.decodeCart(data); err != nil {
+		return "", err
+	}
+	return cartID, nil
+}
+
+// Transfer moves the cart to the user to, updating the owner and the user
+// index in one transaction. from must be the current owner unless it is
+// empty, which transfers on behalf of an admin. Unless replace is set
+// a transfer to a user having another active cart fails with ErrActiveCart
+func (r *CartRepository) Transfer(ctx context.Context, cartID, from, to string, replace bool) (*models.Cart, error) {
+	var result *models.Cart
+	var previous string
+	transfer := func(tx *redis.Tx) error {
+		cart, err := r.getTx(ctx, tx, cartID)
+		if err != nil {
+			return err
+		}
+		owner := ownerOf(cart)
+		previous = owner
+		if from != "" && from != owner {
+			return fmt.Errorf("%w: cart %s", ErrNotOwner, cartID)
+		}
+
+		if !replace {
+			active, err := r.activeCart(ctx, tx, to)
+			if err != nil && !errors.Is(err, ErrCartNotFound) {
+				return err
+			}
+			if active != "" && active != cartID {
+				return fmt.Errorf("%w: user %s", ErrActiveCart, to)
+			}
+		}
+
+		// the previous owner keeps pointing to the cart until the transaction
+		// drops it, watching prevents dropping an index changed meanwhile
+		var ownerKey string
+		if owner != "" && owner != to {
+			ownerKey = r.key(ctx, userKeyPrefix+owner)
+			if err := tx.Watch(ctx, ownerKey).Err(); err != nil {
+				return err
+			}
+			indexed, err := tx.Get(ctx, ownerKey).Result()
+			if err != nil && err != redis.Nil {
+				return fmt.Errorf("error getting cart of user %s: %w", owner, err)
+			}
+			if indexed != cartID {
+				ownerKey = ""
+			}
+		}
+
+		cart.UserID = &to
+		value, err := r.encodeCart(cart)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			r.setCart(ctx, pipe, cart, value)
+			pipe.Set(ctx, r.key(ctx, userKeyPrefix+to), cartID, r.cartTTL)
+			if ownerKey != "" {
+				pipe.Del(ctx, ownerKey)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result = cart
+		return nil
+	}
+
+	if err := r.watch(ctx, transfer, cartID, userKeyPrefix+to); err != nil {
+		return nil, err
+	}
+	ctx = context.WithoutCancel(ctx)
+	r.recordVersion(ctx, result)
+	r.indexRecent(ctx, result)
+	if previous != "" && previous != to {
+		r.forgetRecent(ctx, previous, cartID)
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransfer(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRepository(t)
+
+	newCart := func(t *testing.T, owner string) string {
+		cart := &models.Cart{ID: uuid.New(), UserID: &owner, LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}}}
+		require.NoError(t, repo.Update(ctx, cart))
+		return cart.ID.String()
+	}
+
+	t.Run("owner should transfer cart and move index", func(t *testing.T) {
+		cartID := newCart(t, "alice")
+		indexed, err := repo.CartByUser(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, cartID, indexed)
+
+		cart, err := repo.Transfer(ctx, cartID, "alice", "bob", false)
+		require.NoError(t, err)
+		assert.Equal(t, "bob", *cart.UserID)
+
+		stored, err := repo.Get(ctx, cartID)
+		require.NoError(t, err)
+		assert.Equal(t, "bob", *stored.UserID)
+
+		indexed, err = repo.CartByUser(ctx, "bob")
+		assert.NoError(t, err)
+		assert.Equal(t, cartID, indexed)
+		_, err = repo.CartByUser(ctx, "alice")
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+
+	t.Run("other user should not transfer cart", func(t *testing.T) {
+		cartID := newCart(t, "carol")
+		_, err := repo.Transfer(ctx, cartID, "mallory", "mallory", false)
+		assert.ErrorIs(t, err, ErrNotOwner)
+
+		indexed, err := repo.CartByUser(ctx, "carol")
+		assert.NoError(t, err)
+		assert.Equal(t, cartID, indexed)
+	})
+
+	t.Run("admin should transfer any cart", func(t *testing.T) {
+		cartID := newCart(t, "dave")
+		cart, err := repo.Transfer(ctx, cartID, "", "erin", false)
+		assert.NoError(t, err)
+		assert.Equal(t, "erin", *cart.UserID)
+	})
+
+	t.Run("target having active cart should conflict", func(t *testing.T) {
+		cartID := newCart(t, "frank")
+		existing := newCart(t, "grace")
+
+		_, err := repo.Transfer(ctx, cartID, "frank", "grace", false)
+		assert.ErrorIs(t, err, ErrActiveCart)
+
+		indexed, err := repo.CartByUser(ctx, "grace")
+		assert.NoError(t, err)
+		assert.Equal(t, existing, indexed)
+	})
+
+	t.Run("replace should repoint target having active cart", func(t *testing.T) {
+		cartID := newCart(t, "heidi")
+		newCart(t, "ivan")
+
+		_, err := repo.Transfer(ctx, cartID, "heidi", "ivan", true)
+		assert.NoError(t, err)
+
+		indexed, err := repo.CartByUser(ctx, "ivan")
+		assert.NoError(t, err)
+		assert.Equal(t, cartID, indexed)
+	})
+
+	t.Run("deleted cart of target should not conflict", func(t *testing.T) {
+		cartID := newCart(t, "judy")
+		stale := newCart(t, "ken")
+		require.NoError(t, repo.Delete(ctx, stale))
+
+		_, err := repo.Transfer(ctx, cartID, "judy", "ken", false)
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing cart should return not found", func(t *testing.T) {
+		_, err := repo.Transfer(ctx, uuid.NewString(), "", "bob", false)
+		assert.ErrorIs(t, err, ErrCartNotFound)
+	})
+}
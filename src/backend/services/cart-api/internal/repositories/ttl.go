@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WithCartTTL expires carts which were not changed or touched for ttl, zero
+// keeps carts forever
+func WithCartTTL(ttl time.Duration) Option {
+	return func(r *CartRepository) {
+		r.cartTTL = ttl
+	}
+}
+
+// Touch refreshes ttl of the cart without changing it, ErrCartNotFound is
+// returned for missing, completed and cancelled carts
+func (r *CartRepository) Touch(ctx context.Context, cartID string) error {
+	if _, err := r.read(ctx, r.client, cartID); err != nil {
+		return err
+	}
+	if r.cartTTL <= 0 {
+		return nil
+	}
+	var touched *redis.BoolCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		touched = pipe.PExpire(ctx, r.key(ctx, cartID), r.cartTTL)
+		pipe.PExpire(ctx, r.summaryKey(ctx, cartID), r.cartTTL)
+		pipe.PExpire(ctx, r.itemSequenceKey(ctx, cartID), r.cartTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error touching key %s: %w", cartID, err)
+	}
+	if !touched.Val() {
+		// deleted after it was read
+		return ErrCartNotFound
+	}
+	r.touchCount(context.WithoutCancel(ctx), cartID)
+	return nil
+}
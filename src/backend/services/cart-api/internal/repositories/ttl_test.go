@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTouch(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t, WithCartTTL(time.Hour))
+
+	cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 2}}}
+	cartID := cart.ID.String()
+	assert.NoError(t, repo.Update(ctx, cart))
+	assert.Equal(t, time.Hour, mr.TTL(cartID))
+
+	t.Run("Touch should extend the ttl without changing the cart", func(t *testing.T) {
+		before, err := mr.Get(cartID)
+		assert.NoError(t, err)
+
+		mr.FastForward(50 * time.Minute)
+		assert.Equal(t, 10*time.Minute, mr.TTL(cartID))
+
+		assert.NoError(t, repo.Touch(ctx, cartID))
+		assert.Equal(t, time.Hour, mr.TTL(cartID))
+		after, err := mr.Get(cartID)
+		assert.NoError(t, err)
+		assert.Equal(t, before, after)
+
+		mr.FastForward(50 * time.Minute)
+		assert.True(t, mr.Exists(cartID))
+	})
+
+	t.Run("Touch should return ErrCartNotFound for missing cart", func(t *testing.T) {
+		assert.ErrorIs(t, repo.Touch(ctx, uuid.NewString()), ErrCartNotFound)
+	})
+
+	t.Run("expired cart should be missing", func(t *testing.T) {
+		mr.FastForward(2 * time.Hour)
+		assert.ErrorIs(t, repo.Touch(ctx, cartID), ErrCartNotFound)
+	})
+}
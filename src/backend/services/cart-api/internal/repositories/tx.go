@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxTxRetries bounds optimistic transaction retries when a watched key
+// is changed concurrently
+const maxTxRetries = 5
+
+// watch runs fn in optimistic transaction over carts, retrying when any of
+// watched carts was modified before fn commits
+func (r *CartRepository) watch(ctx context.Context, fn func(tx *redis.Tx) error, cartIDs ...string) error {
+	keys := make([]string, len(cartIDs))
+	for i, id := range cartIDs {
+		if err := r.flush(ctx, id); err != nil {
+			return err
+		}
+		keys[i] = r.key(ctx, id)
+	}
+	for i := 0; i < maxTxRetries; i++ {
+		err := r.client.Watch(ctx, fn, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("error updating carts %v: %w", cartIDs, redis.TxFailedErr)
+}
+
+// getTx reads the cart within a watched transaction
+func (r *CartRepository) getTx(ctx context.Context, tx *redis.Tx, cartID string) (*models.Cart, error) {
+	return r.decodeCmd(tx.Get(ctx, r.key(ctx, cartID)), cartID)
+}
+
+// setTx writes carts in a single MULTI/EXEC, fails if watched keys changed
+func (r *CartRepository) setTx(ctx context.Context, tx *redis.Tx, carts ...*models.Cart) error {
+	_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, cart := range carts {
+			value, err := r.encodeCart(cart)
+			if err != nil {
+				return err
+			}
+			r.setCart(ctx, pipe, cart, value)
+		}
+		return nil
+	})
+	return err
+}
+
+// mutate applies fn to the cart atomically, fn can be retried when the cart
+// is modified concurrently so it must not have side effects
+func (r *CartRepository) mutate(ctx context.Context, cartID string, fn func(cart *models.Cart) error) error {
+	var result *models.Cart
+	err := r.watch(ctx, func(tx *redis.Tx) error {
+		cart, err := r.getTx(ctx, tx, cartID)
+		if err != nil {
+			return err
+		}
+		if err := fn(cart); err != nil {
+			return err
+		}
+		result = cart
+		return r.setTx(ctx, tx, cart)
+	}, cartID)
+	if err != nil {
+		return err
+	}
+	r.written(ctx, result)
+	return nil
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Recent versions of a cart are kept newest first in a list next to it
+const versionsKeyPrefix = "versions:"
+
+var ErrVersionNotFound = errors.New("cart version not found")
+
+// CartVersion is a cart as it was stored at a time
+type CartVersion struct {
+	ETag string       `json:"etag"`
+	At   time.Time    `json:"at"`
+	Cart *models.Cart `json:"cart"`
+}
+
+// WithVersions keeps last n versions of every cart, zero disables history
+func WithVersions(n int) Option {
+	return func(r *CartRepository) {
+		r.versions = n
+	}
+}
+
+// recordVersion prepends the cart to its history, the history only serves
+// diffs so failures are logged and don't fail the mutation
+func (r *CartRepository) recordVersion(ctx context.Context, cart *models.Cart) {
+	if r.versions <= 0 {
+		return
+	}
+	value, err := json.Marshal(CartVersion{ETag: models.ETag(cart), At: r.now().UTC(), Cart: cart})
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cart.ID.String()).Msg("failed to encode cart version")
+		return
+	}
+	key := r.key(ctx, versionsKeyPrefix+cart.ID.String())
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, value)
+		pipe.LTrim(ctx, key, 0, int64(r.versions-1))
+		if r.cartTTL > 0 {
+			pipe.PExpire(ctx, key, r.cartTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("cart_id", cart.ID.String()).Msg("failed to record cart version")
+	}
+}
+
+// Versions returns recorded versions of the cart, newest first
+func (r *CartRepository) Versions(ctx context.Context, cartID string) ([]CartVersion, error) {
+	values, err := r.reader.LRange(ctx, r.key(ctx, versionsKeyPrefix+cartID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error reading versions of cart %s: %w", cartID, err)
+	}
+	versions := make([]CartVersion, 0, len(values))
+	for _, value := range values {
+		var v CartVersion
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, fmt.Errorf("invalid version of cart %s: %w", cartID, err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// VersionByETag returns the recorded version of the cart with etag
+func (r *CartRepository) VersionByETag(ctx context.Context, cartID, etag string) (*models.Cart, error) {
+	versions, err := r.Versions(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.ETag == etag {
+			return v.Cart, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s of cart %s", ErrVersionNotFound, etag, cartID)
+}
+
+// VersionAt returns the version of the cart which was current at t
+func (r *CartRepository) VersionAt(ctx context.Context, cartID string, t time.Time) (*models.Cart, error) {
+	versions, err := r.Versions(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if !v.At.After(t) {
+			return v.Cart, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: at %s of cart %s", ErrVersionNotFound, t.Format(time.RFC3339), cartID)
+}
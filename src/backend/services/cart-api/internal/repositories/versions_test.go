@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersions(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRepository(t, WithVersions(2))
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo.now = func() time.Time { return now }
+
+	cart := &models.Cart{ID: uuid.New()}
+	cartID := cart.ID.String()
+	require.NoError(t, repo.Update(ctx, cart))
+	first := models.ETag(cart)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+	now = now.Add(time.Minute)
+	require.NoError(t, repo.AddItem(ctx, cartID, models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 1000}, Quantity: 1}))
+
+	t.Run("history should be capped", func(t *testing.T) {
+		versions, err := repo.Versions(ctx, cartID)
+		assert.NoError(t, err)
+		assert.Len(t, versions, 2)
+		assert.Equal(t, 2, versions[0].Cart.LineItems[0].Quantity)
+		assert.Equal(t, 1, versions[1].Cart.LineItems[0].Quantity)
+
+		_, err = repo.VersionByETag(ctx, cartID, first)
+		assert.ErrorIs(t, err, ErrVersionNotFound)
+	})
+
+	t.Run("VersionAt should return version current at the time", func(t *testing.T) {
+		v, err := repo.VersionAt(ctx, cartID, now.Add(-30*time.Second))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, v.LineItems[0].Quantity)
+
+		_, err = repo.VersionAt(ctx, cartID, now.Add(-time.Hour))
+		assert.ErrorIs(t, err, ErrVersionNotFound)
+	})
+
+	t.Run("VersionByETag should match the stored cart", func(t *testing.T) {
+		current, err := repo.Get(ctx, cartID)
+		assert.NoError(t, err)
+		v, err := repo.VersionByETag(ctx, cartID, models.ETag(current))
+		assert.NoError(t, err)
+		assert.Equal(t, current, v)
+	})
+
+	t.Run("Delete should drop the history", func(t *testing.T) {
+		assert.NoError(t, repo.Delete(ctx, cartID))
+		assert.False(t, mr.Exists(versionsKeyPrefix+cartID))
+	})
+}
@@ -0,0 +1,227 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// writeBehind coalesces writes of a cart within window into one, pending
+// carts are kept encoded so callers can't change them after Update
+type writeBehind struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*pendingWrite
+
+	// locks order flushes and deletes of a cart so an older value never
+	// overwrites a newer one or a deleted cart, carts are flushed in parallel
+	locks map[string]*cartLock
+}
+
+// cartLock serializes flushes of one cart, refs counts holders and waiters
+// so the lock is dropped once unused
+type cartLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+type pendingWrite struct {
+	ctx   context.Context
+	id    string
+	value []byte
+	timer *time.Timer
+}
+
+// WithWriteBehind buffers writes of a cart for window so rapid mutations of
+// it result in a single redis write. Reads in the process see buffered
+// writes, Get flushes the cart first and FlushAll writes every pending cart
+func WithWriteBehind(window time.Duration) Option {
+	return func(r *CartRepository) {
+		if window > 0 {
+			r.buffer = &writeBehind{window: window, pending: map[string]*pendingWrite{}, locks: map[string]*cartLock{}}
+		}
+	}
+}
+
+// bufferWrite delays write of the encoded cart, the flush is scheduled by the
+// first buffered write so a cart is never stale in redis for more than window.
+// Unless overwrite is set a value already pending is kept
+func (r *CartRepository) bufferWrite(ctx context.Context, cart *models.Cart, value []byte, overwrite bool) {
+	key := r.key(ctx, cart.ID.String())
+
+	r.buffer.mu.Lock()
+	defer r.buffer.mu.Unlock()
+	if p, ok := r.buffer.pending[key]; ok {
+		if overwrite {
+			p.value = value
+		}
+		return
+	}
+	r.schedule(key, &pendingWrite{ctx: context.WithoutCancel(ctx), id: cart.ID.String(), value: value})
+}
+
+// schedule buffers p under key and starts the timer flushing it, callers
+// hold the buffer lock
+func (r *CartRepository) schedule(key string, p *pendingWrite) {
+	p.timer = time.AfterFunc(r.buffer.window, func() {
+		if err := r.flush(p.ctx, p.id); err != nil {
+			log.Warn().Err(err).Str("cart_id", p.id).Msg("failed to flush buffered cart")
+		}
+	})
+	r.buffer.pending[key] = p
+}
+
+// requeue buffers p again after its flush failed, a newer pending write of
+// the cart replaces it
+func (r *CartRepository) requeue(p *pendingWrite) {
+	key := r.key(p.ctx, p.id)
+
+	r.buffer.mu.Lock()
+	defer r.buffer.mu.Unlock()
+	if _, ok := r.buffer.pending[key]; ok {
+		return
+	}
+	r.schedule(key, p)
+}
+
+// lockCart locks flushes of the cart until the returned func is called, it
+// is held across the redis write so a flush and a delete of the cart don't
+// interleave
+func (r *CartRepository) lockCart(ctx context.Context, cartID string) func() {
+	if r.buffer == nil {
+		return func() {}
+	}
+	key := r.key(ctx, cartID)
+
+	r.buffer.mu.Lock()
+	l, ok := r.buffer.locks[key]
+	if !ok {
+		l = &cartLock{}
+		r.buffer.locks[key] = l
+	}
+	l.refs++
+	r.buffer.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		r.buffer.mu.Lock()
+		defer r.buffer.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(r.buffer.locks, key)
+		}
+	}
+}
+
+// takePending removes the pending write of the cart
+func (r *CartRepository) takePending(ctx context.Context, cartID string) *pendingWrite {
+	if r.buffer == nil {
+		return nil
+	}
+	key := r.key(ctx, cartID)
+
+	r.buffer.mu.Lock()
+	defer r.buffer.mu.Unlock()
+	p, ok := r.buffer.pending[key]
+	if !ok {
+		return nil
+	}
+	p.timer.Stop()
+	delete(r.buffer.pending, key)
+	return p
+}
+
+// hasPending reports whether a write of the cart is buffered
+func (r *CartRepository) hasPending(ctx context.Context, cartID string) bool {
+	if r.buffer == nil {
+		return false
+	}
+	r.buffer.mu.Lock()
+	defer r.buffer.mu.Unlock()
+	_, ok := r.buffer.pending[r.key(ctx, cartID)]
+	return ok
+}
+
+// buffered returns a copy of the pending cart, nil when none is pending
+func (r *CartRepository) buffered(ctx context.Context, cartID string) (*models.Cart, error) {
+	if r.buffer == nil {
+		return nil, nil
+	}
+	r.buffer.mu.Lock()
+	p, ok := r.buffer.pending[r.key(ctx, cartID)]
+	var value []byte
+	if ok {
+		value = p.value
+	}
+	r.buffer.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	return r.decodeCart(value)
+}
+
+// current returns the cart including buffered writes without flushing them,
+// used by mutations which are buffered themselves
+func (r *CartRepository) current(ctx context.Context, cartID string) (*models.Cart, error) {
+	cart, err := r.buffered(ctx, cartID)
+	if err != nil || cart != nil {
+		return cart, err
+	}
+	return r.read(ctx, r.client, cartID)
+}
+
+// flush writes the pending cart to redis, the cart is buffered again when
+// decoding or the write fails and no newer write is pending so it can be
+// retried
+func (r *CartRepository) flush(ctx context.Context, cartID string) error {
+	if r.buffer == nil {
+		return nil
+	}
+	defer r.lockCart(ctx, cartID)()
+
+	p := r.takePending(ctx, cartID)
+	if p == nil {
+		return nil
+	}
+	// completed and cancelled carts are written too, decodeCart would drop them
+	cart, _, err := unmarshalCart(p.value)
+	if err != nil {
+		r.requeue(p)
+		return fmt.Errorf("error unmarshalling buffered cart %s: %w", cartID, err)
+	}
+	if err := r.write(ctx, cart, p.value); err != nil {
+		r.requeue(p)
+		return err
+	}
+	return nil
+}
+
+// FlushAll writes every buffered cart, called on shutdown
+func (r *CartRepository) FlushAll(ctx context.Context) error {
+	if r.buffer == nil {
+		return nil
+	}
+	r.buffer.mu.Lock()
+	pending := make([]*pendingWrite, 0, len(r.buffer.pending))
+	for _, p := range r.buffer.pending {
+		pending = append(pending, p)
+	}
+	r.buffer.mu.Unlock()
+
+	var failed int
+	for _, p := range pending {
+		if err := r.flush(p.ctx, p.id); err != nil {
+			log.Warn().Err(err).Str("cart_id", p.id).Msg("failed to flush buffered cart")
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("error flushing %d of %d buffered carts", failed, len(pending))
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jurabek/cart-api/internal/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBehind(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rapid increments should coalesce into one write", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, cart))
+		for i := 0; i < 10; i++ {
+			require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 200}, Quantity: 1}))
+		}
+		assert.False(t, mr.Exists(cart.ID.String()))
+		assert.Equal(t, 0, mr.CommandCount())
+
+		result, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 10, result.LineItems[0].Quantity)
+		assert.Equal(t, models.Money{Minor: 2000}, result.Total)
+		assert.True(t, mr.Exists(cart.ID.String()))
+	})
+
+	t.Run("read should flush pending write", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{{ItemID: 1, UnitPrice: models.Money{Minor: 500}, Quantity: 1}}}
+		require.NoError(t, repo.Update(ctx, cart))
+		assert.False(t, mr.Exists(cart.ID.String()))
+
+		_, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+
+		stored, err := NewCartRepository(repo.client).Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, cart.LineItems, stored.LineItems)
+	})
+
+	t.Run("timer should flush pending write", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(10*time.Millisecond))
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, cart))
+		assert.Eventually(t, func() bool { return mr.Exists(cart.ID.String()) }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("rejected mutation should not change buffered cart", func(t *testing.T) {
+		repo, _ := newTestRepository(t, WithWriteBehind(time.Hour), WithLimits(Limits{MaxCartTotal: models.Money{Minor: 1000}}))
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 400}, Quantity: 2}))
+		assert.Error(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 400}, Quantity: 1}))
+
+		result, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.LineItems[0].Quantity)
+	})
+
+	t.Run("transactions should see buffered writes", func(t *testing.T) {
+		repo, _ := newTestRepository(t, WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.AddItem(ctx, cart.ID.String(), models.LineItem{ItemID: 1, UnitPrice: models.Money{Minor: 100}, Quantity: 3}))
+		require.NoError(t, repo.DecrementItem(ctx, cart.ID.String(), 1))
+
+		result, err := repo.Get(ctx, cart.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.LineItems[0].Quantity)
+	})
+
+	t.Run("delete should drop pending write", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.Delete(ctx, cart.ID.String()))
+		assert.NoError(t, repo.FlushAll(ctx))
+		assert.False(t, mr.Exists(cart.ID.String()))
+	})
+
+	t.Run("flush all should write every pending cart", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		first, second := &models.Cart{ID: uuid.New()}, &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, first))
+		require.NoError(t, repo.Update(ctx, second))
+		assert.NoError(t, repo.FlushAll(ctx))
+		assert.True(t, mr.Exists(first.ID.String()))
+		assert.True(t, mr.Exists(second.ID.String()))
+	})
+
+	t.Run("flush should write completed cart", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New(), LineItems: []models.LineItem{}}
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.FlushAll(ctx))
+
+		cart.Status = models.CartStatusCompleted
+		require.NoError(t, repo.Update(ctx, cart))
+		require.NoError(t, repo.FlushAll(ctx))
+		assert.False(t, repo.hasPending(ctx, cart.ID.String()))
+
+		data, err := mr.Get(cart.ID.String())
+		require.NoError(t, err)
+		stored, _, err := unmarshalCart([]byte(data))
+		require.NoError(t, err)
+		assert.Equal(t, models.CartStatusCompleted, stored.Status)
+	})
+
+	t.Run("failed flush should keep the write buffered", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New()}
+		require.NoError(t, repo.Update(ctx, cart))
+
+		mr.SetError("unavailable")
+		assert.Error(t, repo.FlushAll(ctx))
+		mr.SetError("")
+		assert.True(t, repo.hasPending(ctx, cart.ID.String()))
+
+		require.NoError(t, repo.FlushAll(ctx))
+		assert.True(t, mr.Exists(cart.ID.String()))
+	})
+
+	t.Run("delete should wait for a running flush of the cart", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		cart := &models.Cart{ID: uuid.New()}
+		hook := newBlockWrites(cart.ID.String())
+		repo.client.AddHook(hook)
+		require.NoError(t, repo.Update(ctx, cart))
+
+		flushed := make(chan error)
+		go func() { flushed <- repo.flush(ctx, cart.ID.String()) }()
+		<-hook.entered
+
+		deleted := make(chan error)
+		go func() { deleted <- repo.Delete(ctx, cart.ID.String()) }()
+		select {
+		case <-deleted:
+			t.Fatal("delete should wait for the flush writing the cart")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(hook.release)
+		require.NoError(t, <-flushed)
+		require.NoError(t, <-deleted)
+		assert.False(t, mr.Exists(cart.ID.String()), "flushed value must not outlive the delete")
+	})
+
+	t.Run("flushes of different carts should not wait for each other", func(t *testing.T) {
+		repo, mr := newTestRepository(t, WithWriteBehind(time.Hour))
+		blocked, other := &models.Cart{ID: uuid.New()}, &models.Cart{ID: uuid.New()}
+		hook := newBlockWrites(blocked.ID.String())
+		repo.client.AddHook(hook)
+		defer close(hook.release)
+		require.NoError(t, repo.Update(ctx, blocked))
+		require.NoError(t, repo.Update(ctx, other))
+
+		go func() { _ = repo.flush(ctx, blocked.ID.String()) }()
+		<-hook.entered
+		flushed := make(chan error, 1)
+		go func() { flushed <- repo.flush(ctx, other.ID.String()) }()
+		select {
+		case err := <-flushed:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("flush should not wait for the flush of another cart")
+		}
+		assert.True(t, mr.Exists(other.ID.String()))
+	})
+}
+
+// blockWrites holds pipelines setting key until release is closed, entered
+// receives once such a pipeline is held
+type blockWrites struct {
+	key     string
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockWrites(key string) *blockWrites {
+	return &blockWrites{key: key, entered: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (h *blockWrites) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *blockWrites) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (h *blockWrites) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			if args := cmd.Args(); cmd.Name() == "set" && len(args) > 1 && args[1] == h.key {
+				select {
+				case h.entered <- struct{}{}:
+				default:
+				}
+				<-h.release
+				break
+			}
+		}
+		return next(ctx, cmds)
+	}
+}
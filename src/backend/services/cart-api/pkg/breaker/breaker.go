@@ -0,0 +1,135 @@
+package breaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State of the circuit breaker
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	return [...]string{"closed", "half-open", "open"}[s]
+}
+
+// OpenError is returned while the breaker rejects calls
+type OpenError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open, retry after %v", e.RetryAfter)
+}
+
+// Breaker is a minimal consecutive-failures circuit breaker. After
+// maxFailures consecutive failures it opens for openTimeout, then lets a
+// single probe call through (half-open) which either closes or re-opens it.
+type Breaker struct {
+	maxFailures int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// New creates new instance of Breaker
+func New(maxFailures int, openTimeout time.Duration) *Breaker {
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+	return &Breaker{
+		maxFailures: maxFailures,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+// State returns current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+	return b.state
+}
+
+// Allow returns *OpenError when the call must not be executed, every
+// allowed call must be followed by Done with its result or Cancel
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refresh()
+
+	switch b.state {
+	case StateOpen:
+		return &OpenError{RetryAfter: b.openTimeout - b.now().Sub(b.openedAt)}
+	case StateHalfOpen:
+		if b.probing {
+			return &OpenError{RetryAfter: b.openTimeout}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Done records the result of a call allowed by Allow
+func (b *Breaker) Done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+		if success {
+			b.state = StateClosed
+			b.failures = 0
+		} else {
+			b.trip()
+		}
+		return
+	}
+
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.maxFailures {
+		b.trip()
+	}
+}
+
+// Cancel ends a call allowed by Allow without recording a result, e.g. when
+// the caller gave up before the call could prove anything
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *Breaker) trip() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+	b.failures = 0
+}
+
+// refresh moves an open breaker to half-open once openTimeout elapsed
+func (b *Breaker) refresh() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.state = StateHalfOpen
+		b.probing = false
+	}
+}
@@ -0,0 +1,113 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestBreaker() (*Breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := New(3, 10*time.Second)
+	b.now = clock.Now
+	return b, clock
+}
+
+func fail(b *Breaker, times int) {
+	for i := 0; i < times; i++ {
+		if b.Allow() == nil {
+			b.Done(false)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	t.Run("stays closed below failure threshold", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		fail(b, 2)
+		assert.Equal(t, StateClosed, b.State())
+		assert.NoError(t, b.Allow())
+	})
+
+	t.Run("success resets consecutive failures", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		fail(b, 2)
+		assert.NoError(t, b.Allow())
+		b.Done(true)
+		fail(b, 2)
+		assert.Equal(t, StateClosed, b.State())
+	})
+
+	t.Run("opens after threshold and rejects with retry after", func(t *testing.T) {
+		b, clock := newTestBreaker()
+		fail(b, 3)
+		assert.Equal(t, StateOpen, b.State())
+
+		clock.Advance(4 * time.Second)
+		err := b.Allow()
+		var openErr *OpenError
+		assert.ErrorAs(t, err, &openErr)
+		assert.Equal(t, 6*time.Second, openErr.RetryAfter)
+	})
+
+	t.Run("half-open lets a single probe through", func(t *testing.T) {
+		b, clock := newTestBreaker()
+		fail(b, 3)
+		clock.Advance(10 * time.Second)
+		assert.Equal(t, StateHalfOpen, b.State())
+
+		assert.NoError(t, b.Allow())
+		assert.Error(t, b.Allow(), "second call must wait for probe result")
+	})
+
+	t.Run("successful probe closes the breaker", func(t *testing.T) {
+		b, clock := newTestBreaker()
+		fail(b, 3)
+		clock.Advance(10 * time.Second)
+
+		assert.NoError(t, b.Allow())
+		b.Done(true)
+		assert.Equal(t, StateClosed, b.State())
+		assert.NoError(t, b.Allow())
+	})
+
+	t.Run("failed probe opens the breaker again", func(t *testing.T) {
+		b, clock := newTestBreaker()
+		fail(b, 3)
+		clock.Advance(10 * time.Second)
+
+		assert.NoError(t, b.Allow())
+		b.Done(false)
+		assert.Equal(t, StateOpen, b.State())
+		assert.Error(t, b.Allow())
+	})
+
+	t.Run("cancelled call records no result", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		fail(b, 2)
+		assert.NoError(t, b.Allow())
+		b.Cancel()
+		fail(b, 1)
+		assert.Equal(t, StateOpen, b.State())
+	})
+
+	t.Run("cancelled probe lets the next probe through", func(t *testing.T) {
+		b, clock := newTestBreaker()
+		fail(b, 3)
+		clock.Advance(10 * time.Second)
+
+		assert.NoError(t, b.Allow())
+		b.Cancel()
+		assert.Equal(t, StateHalfOpen, b.State())
+		assert.NoError(t, b.Allow())
+	})
+}
@@ -0,0 +1,52 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/dnwe/otelsarama"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Producer publishes messages to kafka topics, each message gets a producer
+// span and carries trace context of ctx in its headers
+type Producer struct {
+	producer   sarama.SyncProducer
+	propagator propagation.TextMapPropagator
+}
+
+// NewProducer wraps producer with otelsarama instrumentation, saramaConfig
+// should be the config producer was created with
+func NewProducer(producer sarama.SyncProducer, saramaConfig *sarama.Config, opts ...otelsarama.Option) *Producer {
+	return &Producer{
+		producer:   otelsarama.WrapSyncProducer(saramaConfig, producer, opts...),
+		propagator: otel.GetTextMapPropagator(),
+	}
+}
+
+// Publish sends value to topic. Messages with equal key are written to the
+// same partition by the hash partitioner and keep their order, empty key
+// leaves partitioning to the producer.
+func (p *Producer) Publish(ctx context.Context, topic string, key string, value []byte) error {
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(value),
+	}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	// producer span started by otelsarama becomes a child of the span in ctx
+	p.propagator.Inject(ctx, otelsarama.NewProducerMessageCarrier(msg))
+
+	if _, _, err := p.producer.SendMessage(msg); err != nil {
+		return fmt.Errorf("error publishing message to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close closes underlying producer
+func (p *Producer) Close() error {
+	return p.producer.Close()
+}
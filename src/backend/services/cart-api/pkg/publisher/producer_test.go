@@ -0,0 +1,74 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/dnwe/otelsarama"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestProducerPublish(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	propagator := propagation.TraceContext{}
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+
+	var sent *sarama.ProducerMessage
+	config := mocks.NewTestConfig()
+	mockProducer := mocks.NewSyncProducer(t, config)
+	mockProducer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		sent = msg
+		return nil
+	})
+
+	p := NewProducer(mockProducer, config, otelsarama.WithTracerProvider(tp), otelsarama.WithPropagators(propagator))
+	p.propagator = propagator
+
+	err := p.Publish(ctx, "carts", "cart-1", []byte(`{"cartId":"cart-1"}`))
+	span.End()
+	assert.NoError(t, err)
+	assert.NoError(t, p.Close())
+
+	t.Run("message should carry topic, key and value", func(t *testing.T) {
+		assert.Equal(t, "carts", sent.Topic)
+		key, _ := sent.Key.Encode()
+		assert.Equal(t, "cart-1", string(key))
+		value, _ := sent.Value.Encode()
+		assert.JSONEq(t, `{"cartId":"cart-1"}`, string(value))
+	})
+
+	t.Run("headers should carry trace context of the caller", func(t *testing.T) {
+		msgCtx := propagator.Extract(context.Background(), otelsarama.NewProducerMessageCarrier(sent))
+		msgSpan := trace.SpanContextFromContext(msgCtx)
+		assert.True(t, msgSpan.IsValid())
+		assert.Equal(t, span.SpanContext().TraceID(), msgSpan.TraceID())
+	})
+
+	t.Run("producer span should be a child of the caller span", func(t *testing.T) {
+		spans := recorder.Ended()
+		assert.Len(t, spans, 2)
+		producerSpan := spans[0]
+		assert.Equal(t, trace.SpanKindProducer, producerSpan.SpanKind())
+		assert.Equal(t, span.SpanContext().SpanID(), producerSpan.Parent().SpanID())
+	})
+}
+
+func TestProducerPublishError(t *testing.T) {
+	config := mocks.NewTestConfig()
+	mockProducer := mocks.NewSyncProducer(t, config)
+	mockProducer.ExpectSendMessageAndFail(fmt.Errorf("broker down"))
+
+	p := NewProducer(mockProducer, config)
+	err := p.Publish(context.Background(), "carts", "", []byte("{}"))
+	assert.ErrorContains(t, err, "broker down")
+	assert.NoError(t, p.Close())
+}
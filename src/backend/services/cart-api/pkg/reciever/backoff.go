@@ -0,0 +1,44 @@
+package reciever
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Default reconnect backoff between failed Consume calls
+const (
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// backoff doubles the delay on every failure up to max and applies equal
+// jitter, so the delay is within [d/2, d) and reconnecting consumers spread out
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+	attempt int
+	jitter  func() float64
+}
+
+func newBackoff(initial, max time.Duration) *backoff {
+	return &backoff{initial: initial, max: max, jitter: rand.Float64}
+}
+
+// Next returns the delay before the next attempt
+func (b *backoff) Next() time.Duration {
+	d := b.initial
+	for i := 0; i < b.attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if d > b.max {
+		d = b.max
+	}
+	b.attempt++
+	half := d / 2
+	return half + time.Duration(b.jitter()*float64(half))
+}
+
+// Reset starts over from the initial delay
+func (b *backoff) Reset() {
+	b.attempt = 0
+}
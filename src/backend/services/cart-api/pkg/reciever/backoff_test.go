@@ -0,0 +1,37 @@
+package reciever
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, time.Second)
+	// upper bound of the jitter makes delays deterministic
+	b.jitter = func() float64 { return 1 }
+
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		delays = append(delays, b.Next())
+	}
+	assert.Equal(t, []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}, delays)
+
+	b.Reset()
+	assert.Equal(t, 100*time.Millisecond, b.Next())
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, time.Second)
+	b.jitter = func() float64 { return 0 }
+	assert.Equal(t, 50*time.Millisecond, b.Next())
+	assert.Equal(t, 100*time.Millisecond, b.Next())
+}
@@ -0,0 +1,76 @@
+package reciever
+
+import (
+	"fmt"
+
+	"github.com/IBM/sarama"
+)
+
+// OffsetFetcher reads committed offsets of a consumer group,
+// sarama.ClusterAdmin implements it
+type OffsetFetcher interface {
+	ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error)
+}
+
+// LagChecker computes lag of a consumer group on a topic
+type LagChecker struct {
+	client  OffsetResolver
+	fetcher OffsetFetcher
+	group   string
+	topic   string
+}
+
+func NewLagChecker(client OffsetResolver, fetcher OffsetFetcher, group, topic string) *LagChecker {
+	return &LagChecker{client: client, fetcher: fetcher, group: group, topic: topic}
+}
+
+// Lag returns per partition difference between the high-water mark and the
+// committed offset of the group. Partitions the group never committed to
+// count every retained message as lag
+func (l *LagChecker) Lag() (map[int32]int64, error) {
+	partitions, err := l.client.Partitions(l.topic)
+	if err != nil {
+		return nil, fmt.Errorf("listing partitions of %s: %w", l.topic, err)
+	}
+	committed, err := l.fetcher.ListConsumerGroupOffsets(l.group, map[string][]int32{l.topic: partitions})
+	if err != nil {
+		return nil, fmt.Errorf("fetching offsets of group %s: %w", l.group, err)
+	}
+
+	lag := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		newest, err := l.client.GetOffset(l.topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("resolving newest offset of %s/%d: %w", l.topic, partition, err)
+		}
+
+		offset := int64(-1)
+		if block := committed.GetBlock(l.topic, partition); block != nil {
+			if block.Err != sarama.ErrNoError {
+				return nil, fmt.Errorf("fetching offset of %s/%d: %w", l.topic, partition, block.Err)
+			}
+			offset = block.Offset
+		}
+		if offset < 0 {
+			if offset, err = l.client.GetOffset(l.topic, partition, sarama.OffsetOldest); err != nil {
+				return nil, fmt.Errorf("resolving oldest offset of %s/%d: %w", l.topic, partition, err)
+			}
+		}
+
+		if newest > offset {
+			lag[partition] = newest - offset
+		} else {
+			lag[partition] = 0
+		}
+	}
+	return lag, nil
+}
+
+// TotalLag sums lag of all partitions
+func TotalLag(lag map[int32]int64) int64 {
+	var total int64
+	for _, n := range lag {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,80 @@
+package reciever
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// LagMonitor periodically computes lag of a LagChecker and exports the last
+// result as a gauge, so metric collection never waits on the brokers
+type LagMonitor struct {
+	checker *LagChecker
+
+	mu  sync.RWMutex
+	lag map[int32]int64
+}
+
+func NewLagMonitor(checker *LagChecker) *LagMonitor {
+	return &LagMonitor{checker: checker}
+}
+
+// Run refreshes lag every interval until ctx is done, a non positive interval
+// disables refreshing
+func (m *LagMonitor) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.refresh()
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *LagMonitor) refresh() {
+	lag, err := m.checker.Lag()
+	if err != nil {
+		log.Warn().Err(err).Str("topic", m.checker.topic).Msg("failed to compute consumer lag")
+		return
+	}
+	m.mu.Lock()
+	m.lag = lag
+	m.mu.Unlock()
+}
+
+// Lag returns lag per partition computed by the last refresh
+func (m *LagMonitor) Lag() map[int32]int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lag
+}
+
+// Observe registers kafka.consumer.lag gauge reporting lag per partition
+func (m *LagMonitor) Observe() error {
+	meter := otel.Meter("cart-api")
+	_, err := meter.Int64ObservableGauge("kafka.consumer.lag",
+		metric.WithDescription("Messages of the topic not yet committed by the consumer group"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for partition, lag := range m.Lag() {
+				o.Observe(lag, metric.WithAttributes(
+					attribute.String("messaging.kafka.consumer.group", m.checker.group),
+					attribute.String("messaging.destination.name", m.checker.topic),
+					attribute.Int("messaging.kafka.destination.partition", int(partition)),
+				))
+			}
+			return nil
+		}),
+	)
+	return err
+}
@@ -0,0 +1,51 @@
+package reciever
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestLagMonitor(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	previous := otel.GetMeterProvider()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { otel.SetMeterProvider(previous) })
+
+	client := &laggingOffsets{
+		newest: map[int32]int64{0: 100, 1: 50, 2: 30},
+		oldest: map[int32]int64{0: 0, 1: 0, 2: 0},
+	}
+	fetcher := &fakeFetcher{offsets: map[int32]int64{0: 90, 1: 45, 2: 30}}
+	monitor := NewLagMonitor(NewLagChecker(client, fetcher, "cart-api", "orders"))
+	require.NoError(t, monitor.Observe())
+
+	monitor.refresh()
+	assert.Equal(t, map[int32]int64{0: 10, 1: 5, 2: 0}, monitor.Lag())
+
+	// a failed refresh keeps the last known lag
+	fetcher.err = errors.New("coordinator not available")
+	monitor.refresh()
+	assert.Equal(t, map[int32]int64{0: 10, 1: 5, 2: 0}, monitor.Lag())
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	gauge := rm.ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, "kafka.consumer.lag", gauge.Name)
+
+	observed := map[int64]int64{}
+	for _, point := range gauge.Data.(metricdata.Gauge[int64]).DataPoints {
+		partition, _ := point.Attributes.Value(attribute.Key("messaging.kafka.destination.partition"))
+		observed[partition.AsInt64()] = point.Value
+	}
+	assert.Equal(t, map[int64]int64{0: 10, 1: 5, 2: 0}, observed)
+}
@@ -0,0 +1,60 @@
+package reciever
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeFetcher struct {
+	offsets map[int32]int64
+	err     error
+}
+
+func (f *fakeFetcher) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := &sarama.OffsetFetchResponse{}
+	for partition, offset := range f.offsets {
+		resp.AddBlock("orders", partition, &sarama.OffsetFetchResponseBlock{Offset: offset})
+	}
+	return resp, nil
+}
+
+// laggingOffsets serves newest and oldest offsets per partition
+type laggingOffsets struct {
+	newest map[int32]int64
+	oldest map[int32]int64
+}
+
+func (f *laggingOffsets) Partitions(topic string) ([]int32, error) {
+	return []int32{0, 1, 2}, nil
+}
+
+func (f *laggingOffsets) GetOffset(topic string, partition int32, ts int64) (int64, error) {
+	if ts == sarama.OffsetOldest {
+		return f.oldest[partition], nil
+	}
+	return f.newest[partition], nil
+}
+
+func TestLagChecker(t *testing.T) {
+	client := &laggingOffsets{
+		newest: map[int32]int64{0: 100, 1: 50, 2: 30},
+		oldest: map[int32]int64{0: 0, 1: 0, 2: 10},
+	}
+	// partition 2 was never committed to
+	fetcher := &fakeFetcher{offsets: map[int32]int64{0: 90, 1: 50, 2: -1}}
+
+	lag, err := NewLagChecker(client, fetcher, "cart-api", "orders").Lag()
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]int64{0: 10, 1: 0, 2: 20}, lag)
+	assert.Equal(t, int64(30), TotalLag(lag))
+
+	fetcher.err = errors.New("coordinator not available")
+	_, err = NewLagChecker(client, fetcher, "cart-api", "orders").Lag()
+	assert.ErrorIs(t, err, fetcher.err)
+}
@@ -2,57 +2,126 @@ package reciever
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/dnwe/otelsarama"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
 )
 
 type MessageReciever struct {
-	consumer sarama.ConsumerGroup
-	topic    string
+	consumer       sarama.ConsumerGroup
+	topic          string
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	workers        int
+	gate           *gate
+	tombstones     MessageHandler
+
+	// replay holds offsets not yet applied by WithReplayOffsets
+	replay map[int32]int64
 }
 
-func NewMessageReciever(consumer sarama.ConsumerGroup, topic string) *MessageReciever {
-	return &MessageReciever{
-		consumer: consumer,
-		topic:    topic,
+// Option configures MessageReciever
+type Option func(*MessageReciever)
+
+// WithBackoff sets the delay after the first failed Consume and the cap the
+// delay doubles up to on consecutive failures
+func WithBackoff(initial, max time.Duration) Option {
+	return func(k *MessageReciever) {
+		k.initialBackoff = initial
+		k.maxBackoff = max
 	}
 }
 
+func NewMessageReciever(consumer sarama.ConsumerGroup, topic string, opts ...Option) *MessageReciever {
+	k := &MessageReciever{
+		consumer:       consumer,
+		topic:          topic,
+		initialBackoff: DefaultInitialBackoff,
+		maxBackoff:     DefaultMaxBackoff,
+		gate:           &gate{},
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
 type Message struct {
+	// Key is the record key, on compacted topics it names what a tombstone
+	// deletes
+	Key        []byte
 	Value      []byte
 	Attributes map[string]string
 }
 
+// newMessage maps kafka record headers into Attributes, when a header is
+// repeated the last value wins
+func newMessage(message *sarama.ConsumerMessage) *Message {
+	attributes := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		if header == nil {
+			continue
+		}
+		attributes[string(header.Key)] = string(header.Value)
+	}
+	return &Message{Key: message.Key, Value: message.Value, Attributes: attributes}
+}
+
 type MessageHandler interface {
 	Handle(ctx context.Context, message *Message) error
 }
 
-// Recieve starts consuming messages from all partitions and sends message as channel
+// Recieve starts consuming messages from all partitions and sends message as channel,
+// failed Consume calls are retried with backoff until ctx is cancelled
 func (k *MessageReciever) Recieve(ctx context.Context, handler MessageHandler) error {
+	b := newBackoff(k.initialBackoff, k.maxBackoff)
 	for {
 		// `Consume` should be called inside an infinite loop, when a
 		// server-side rebalance happens, the consumer session will need to be
 		// recreated to get the new claims
-		consumerGroupHandler := otelsarama.WrapConsumerGroupHandler(&consumerGroupHandler{handler: handler})
+		consumerGroupHandler := otelsarama.WrapConsumerGroupHandler(&consumerGroupHandler{handler: handler, tombstones: k.tombstones, setup: k.setup, workers: k.workers, gate: k.gate})
 		err := k.consumer.Consume(ctx, []string{k.topic}, consumerGroupHandler)
-		if err != nil {
-			return err
-		}
 
 		// check if context was cancelled, signaling that the consumer should stop
 		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil {
+			b.Reset()
+			continue
+		}
+		// closed group never recovers
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
 			return err
 		}
+
+		delay := b.Next()
+		log.Warn().Err(err).Dur("backoff", delay).Str("topic", k.topic).Msg("consume failed, retrying")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 }
 
 type consumerGroupHandler struct {
-	handler MessageHandler
+	handler    MessageHandler
+	tombstones MessageHandler
+	setup      func(session sarama.ConsumerGroupSession)
+	workers    int
+	gate       *gate
 }
 
 func (c *consumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	if c.setup != nil {
+		c.setup(session)
+	}
 	return nil
 }
 
@@ -65,6 +134,9 @@ func (c *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 	// Do not move the code below to a goroutine.
 	// The `ConsumeClaim` itself is called within a goroutine, see:
 	// https://github.com/IBM/sarama/blob/main/consumer_group.go#L27-L29
+	if c.workers > 1 {
+		return c.consumeConcurrently(session, claim)
+	}
 	for {
 		select {
 		case message, ok := <-claim.Messages():
@@ -72,15 +144,11 @@ func (c *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				log.Info().Msg("message channel was closed")
 				return nil
 			}
-			log.Debug().
-				Str("topic", message.Topic).
-				Time("timestamp", message.Timestamp).
-				Str("value", string(message.Value)).
-				Msg("message claimed")
-
-			if err := c.handler.Handle(context.Background(), &Message{Value: message.Value}); err != nil {
-				log.Error().Err(err).Str("topic", message.Topic).Msg("failed to consume message")
+			// a message received before pausing is held until resumed
+			if !c.gate.wait(session.Context()) {
+				return nil
 			}
+			c.handle(message)
 			session.MarkMessage(message, "")
 
 		// Should return when `session.Context()` is done.
@@ -91,3 +159,25 @@ func (c *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 		}
 	}
 }
+
+// handle passes the message to the handler, failed messages are logged and
+// skipped. Tombstones go to the tombstone handler instead
+func (c *consumerGroupHandler) handle(message *sarama.ConsumerMessage) {
+	handler := c.handler
+	if isTombstone(message) {
+		if handler = c.tombstoneHandler(message); handler == nil {
+			return
+		}
+	}
+	log.Debug().
+		Str("topic", message.Topic).
+		Time("timestamp", message.Timestamp).
+		Str("value", string(message.Value)).
+		Msg("message claimed")
+
+	// trace context and baggage, e.g. tenant id, come from record headers
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), otelsarama.NewConsumerMessageCarrier(message))
+	if err := handler.Handle(ctx, newMessage(message)); err != nil {
+		log.Error().Err(err).Str("topic", message.Topic).Msg("failed to consume message")
+	}
+}
@@ -0,0 +1,101 @@
+package reciever
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/jurabek/cart-api/pkg/tenant"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type fakeSession struct {
+	ctx    context.Context
+	marked []*sarama.ConsumerMessage
+}
+
+func (s *fakeSession) Claims() map[string][]int32                                               { return nil }
+func (s *fakeSession) MemberID() string                                                         { return "member" }
+func (s *fakeSession) GenerationID() int32                                                      { return 1 }
+func (s *fakeSession) MarkOffset(topic string, partition int32, offset int64, metadata string)  {}
+func (s *fakeSession) Commit()                                                                  {}
+func (s *fakeSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
+func (s *fakeSession) Context() context.Context                                                 { return s.ctx }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg)
+}
+
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "orders" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// newFakeClaim returns claim which delivers messages and then closes
+func newFakeClaim(messages ...*sarama.ConsumerMessage) *fakeClaim {
+	c := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(messages))}
+	for _, m := range messages {
+		c.messages <- m
+	}
+	close(c.messages)
+	return c
+}
+
+type recordingHandler struct {
+	messages []*Message
+	contexts []context.Context
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, message *Message) error {
+	h.messages = append(h.messages, message)
+	h.contexts = append(h.contexts, ctx)
+	return nil
+}
+
+func TestConsumeClaimAttributes(t *testing.T) {
+	message := &sarama.ConsumerMessage{
+		Topic: "orders",
+		Value: []byte(`{"cartId":"abcd"}`),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("event-type"), Value: []byte("OrderCompleted")},
+			{Key: []byte("traceparent"), Value: []byte("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")},
+		},
+	}
+	handler := &recordingHandler{}
+	session := &fakeSession{ctx: context.Background()}
+
+	err := (&consumerGroupHandler{handler: handler}).ConsumeClaim(session, newFakeClaim(message))
+	assert.NoError(t, err)
+
+	assert.Len(t, handler.messages, 1)
+	assert.Equal(t, message.Value, handler.messages[0].Value)
+	assert.Equal(t, map[string]string{
+		"event-type":  "OrderCompleted",
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+	}, handler.messages[0].Attributes)
+	assert.Len(t, session.marked, 1)
+}
+
+func TestConsumeClaimBaggage(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.Baggage{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	message := &sarama.ConsumerMessage{
+		Topic:   "orders",
+		Value:   []byte(`{}`),
+		Headers: []*sarama.RecordHeader{{Key: []byte("baggage"), Value: []byte(tenant.BaggageKey + "=acme")}},
+	}
+	handler := &recordingHandler{}
+	session := &fakeSession{ctx: context.Background()}
+
+	err := (&consumerGroupHandler{handler: handler}).ConsumeClaim(session, newFakeClaim(message))
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenant.FromContext(handler.contexts[0]))
+}
@@ -0,0 +1,97 @@
+package reciever
+
+import (
+	"context"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// gate holds consumption of claims while paused, the zero value is open
+type gate struct {
+	mu sync.Mutex
+	// resumed is closed on resume, nil when not paused
+	resumed chan struct{}
+}
+
+// pause closes the gate, false when it was already closed
+func (g *gate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// resume opens the gate releasing waiting claims, false when it was open
+func (g *gate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+func (g *gate) paused() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// wait blocks while the gate is closed, false when ctx is done first
+func (g *gate) wait(ctx context.Context) bool {
+	if g == nil {
+		return true
+	}
+	for {
+		g.mu.Lock()
+		resumed := g.resumed
+		g.mu.Unlock()
+		if resumed == nil {
+			return true
+		}
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Pause stops fetching and handling messages until Resume, the consumer
+// stays in the group so its partitions are not rebalanced meanwhile.
+// Messages being handled are finished and marked
+func (k *MessageReciever) Pause() {
+	if k.gate.pause() {
+		k.consumer.PauseAll()
+	}
+}
+
+// Resume continues consumption stopped by Pause
+func (k *MessageReciever) Resume() {
+	if k.gate.resume() {
+		k.consumer.ResumeAll()
+	}
+}
+
+// Paused reports whether consumption is paused
+func (k *MessageReciever) Paused() bool {
+	return k.gate.paused()
+}
+
+// setup prepares new session, partitions claimed by a session are fetched
+// until paused so pause is reapplied after rebalances
+func (k *MessageReciever) setup(session sarama.ConsumerGroupSession) {
+	k.resetOffsets(session)
+	if k.gate.paused() {
+		k.consumer.PauseAll()
+	}
+}
@@ -0,0 +1,86 @@
+package reciever
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pausableGroup counts partition pauses of the consumer group
+type pausableGroup struct {
+	sarama.ConsumerGroup
+	pauses, resumes atomic.Int32
+}
+
+func (g *pausableGroup) PauseAll()  { g.pauses.Add(1) }
+func (g *pausableGroup) ResumeAll() { g.resumes.Add(1) }
+
+func TestPause(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		t.Run("paused consumption should not mark messages until resumed", func(t *testing.T) {
+			group := &pausableGroup{}
+			k := NewMessageReciever(group, "orders", WithWorkers(workers))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			session := &lockedSession{fakeSession: fakeSession{ctx: ctx}}
+			claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+			handler := &consumerGroupHandler{handler: handlerFunc(func(ctx context.Context, message *Message) error { return nil }), workers: k.workers, gate: k.gate}
+
+			done := make(chan error)
+			go func() { done <- handler.ConsumeClaim(session, claim) }()
+
+			claim.messages <- &sarama.ConsumerMessage{Offset: 0}
+			require.Eventually(t, func() bool { return len(session.marked()) == 1 }, time.Second, time.Millisecond)
+
+			k.Pause()
+			k.Pause()
+			assert.True(t, k.Paused())
+			assert.Equal(t, int32(1), group.pauses.Load())
+
+			claim.messages <- &sarama.ConsumerMessage{Offset: 1}
+			claim.messages <- &sarama.ConsumerMessage{Offset: 2}
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, []int64{0}, session.marked())
+
+			k.Resume()
+			assert.False(t, k.Paused())
+			assert.Equal(t, int32(1), group.resumes.Load())
+			require.Eventually(t, func() bool { return len(session.marked()) == 3 }, time.Second, time.Millisecond)
+			assert.Equal(t, []int64{0, 1, 2}, session.marked())
+
+			close(claim.messages)
+			require.NoError(t, <-done)
+		})
+	}
+
+	t.Run("paused claim should return when session ends", func(t *testing.T) {
+		k := NewMessageReciever(&pausableGroup{}, "orders")
+		k.Pause()
+		ctx, cancel := context.WithCancel(context.Background())
+		session := &lockedSession{fakeSession: fakeSession{ctx: ctx}}
+		handler := &consumerGroupHandler{handler: handlerFunc(func(ctx context.Context, message *Message) error { return nil }), gate: k.gate}
+
+		done := make(chan error)
+		go func() { done <- handler.ConsumeClaim(session, newFakeClaim(offsetMessages(1)...)) }()
+		cancel()
+		assert.NoError(t, <-done)
+		assert.Empty(t, session.marked())
+	})
+
+	t.Run("pause should be reapplied to new sessions", func(t *testing.T) {
+		group := &pausableGroup{}
+		k := NewMessageReciever(group, "orders")
+		session := &fakeSession{ctx: context.Background()}
+
+		k.setup(session)
+		assert.Equal(t, int32(0), group.pauses.Load())
+		k.Pause()
+		k.setup(session)
+		assert.Equal(t, int32(2), group.pauses.Load())
+	})
+}
@@ -0,0 +1,88 @@
+package reciever
+
+import (
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// WithWorkers handles up to n messages of a claim concurrently, by default
+// messages are handled one by one. Messages of the same key may then be
+// handled out of order, so only idempotent handlers not relying on ordering
+// should use it. Offsets are still committed in order
+func WithWorkers(n int) Option {
+	return func(k *MessageReciever) {
+		k.workers = n
+	}
+}
+
+// commitTracker marks messages of a claim in offset order as they finish,
+// so the committed offset never passes a message which is still handled
+type commitTracker struct {
+	session sarama.ConsumerGroupSession
+
+	mu      sync.Mutex
+	pending []*sarama.ConsumerMessage
+	done    map[int64]bool
+}
+
+func newCommitTracker(session sarama.ConsumerGroupSession) *commitTracker {
+	return &commitTracker{session: session, done: make(map[int64]bool)}
+}
+
+// start registers the message before it is handed to a worker, messages of
+// a claim are delivered in offset order so pending stays sorted
+func (t *commitTracker) start(message *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, message)
+}
+
+// finish marks every message up to the lowest one still being handled
+func (t *commitTracker) finish(message *sarama.ConsumerMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done[message.Offset] = true
+	for len(t.pending) > 0 && t.done[t.pending[0].Offset] {
+		head := t.pending[0]
+		t.session.MarkMessage(head, "")
+		delete(t.done, head.Offset)
+		t.pending = t.pending[1:]
+	}
+}
+
+// consumeConcurrently is ConsumeClaim handling up to workers messages at a
+// time, it returns after in-flight messages are finished
+func (c *consumerGroupHandler) consumeConcurrently(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	tracker := newCommitTracker(session)
+	slots := make(chan struct{}, c.workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !c.gate.wait(session.Context()) {
+				return nil
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-session.Context().Done():
+				return nil
+			}
+			tracker.start(message)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				c.handle(message)
+				tracker.finish(message)
+			}()
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package reciever
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedSession records marked offsets of concurrent workers
+type lockedSession struct {
+	fakeSession
+	mu     sync.Mutex
+	offset []int64
+}
+
+func (s *lockedSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = append(s.offset, msg.Offset)
+}
+
+func (s *lockedSession) marked() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.offset...)
+}
+
+type handlerFunc func(ctx context.Context, message *Message) error
+
+func (f handlerFunc) Handle(ctx context.Context, message *Message) error { return f(ctx, message) }
+
+func offsetMessages(n int) []*sarama.ConsumerMessage {
+	messages := make([]*sarama.ConsumerMessage, n)
+	for i := range messages {
+		messages[i] = &sarama.ConsumerMessage{Topic: "orders", Offset: int64(i), Value: []byte{byte(i)}}
+	}
+	return messages
+}
+
+func TestConsumeClaimWorkers(t *testing.T) {
+	t.Run("messages should be marked in offset order", func(t *testing.T) {
+		var running, maxRunning atomic.Int32
+		handler := handlerFunc(func(ctx context.Context, message *Message) error {
+			n := running.Add(1)
+			for {
+				max := maxRunning.Load()
+				if n <= max || maxRunning.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+		session := &lockedSession{fakeSession: fakeSession{ctx: context.Background()}}
+
+		err := (&consumerGroupHandler{handler: handler, workers: 4}).ConsumeClaim(session, newFakeClaim(offsetMessages(50)...))
+		require.NoError(t, err)
+
+		marked := session.marked()
+		require.Len(t, marked, 50)
+		for i, offset := range marked {
+			assert.Equal(t, int64(i), offset)
+		}
+		assert.LessOrEqual(t, maxRunning.Load(), int32(4))
+		assert.Greater(t, maxRunning.Load(), int32(1))
+	})
+
+	t.Run("commit should stop at the lowest unfinished offset", func(t *testing.T) {
+		release := make(chan struct{})
+		var handled atomic.Int32
+		handler := handlerFunc(func(ctx context.Context, message *Message) error {
+			if message.Value[0] == 1 {
+				<-release
+			}
+			handled.Add(1)
+			return nil
+		})
+		session := &lockedSession{fakeSession: fakeSession{ctx: context.Background()}}
+
+		done := make(chan error)
+		go func() {
+			done <- (&consumerGroupHandler{handler: handler, workers: 3}).ConsumeClaim(session, newFakeClaim(offsetMessages(5)...))
+		}()
+
+		assert.Eventually(t, func() bool { return handled.Load() == 4 }, time.Second, time.Millisecond)
+		assert.Equal(t, []int64{0}, session.marked())
+
+		close(release)
+		require.NoError(t, <-done)
+		assert.Equal(t, []int64{0, 1, 2, 3, 4}, session.marked())
+	})
+
+	t.Run("cancelled session should wait for in-flight messages", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{})
+		handler := handlerFunc(func(ctx context.Context, message *Message) error {
+			close(started)
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+		session := &lockedSession{fakeSession: fakeSession{ctx: ctx}}
+		claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+		claim.messages <- offsetMessages(1)[0]
+
+		done := make(chan error)
+		go func() {
+			done <- (&consumerGroupHandler{handler: handler, workers: 2}).ConsumeClaim(session, claim)
+		}()
+		<-started
+		cancel()
+		require.NoError(t, <-done)
+		assert.Equal(t, []int64{0}, session.marked())
+	})
+}
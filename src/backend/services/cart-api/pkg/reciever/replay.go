@@ -0,0 +1,64 @@
+package reciever
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/rs/zerolog/log"
+)
+
+// OffsetResolver looks up partitions and offsets of a topic, sarama.Client
+// implements it
+type OffsetResolver interface {
+	Partitions(topic string) ([]int32, error)
+	GetOffset(topic string, partitionID int32, time int64) (int64, error)
+}
+
+// ResolveOffsets returns per partition offset of the first message produced
+// at or after since. Partitions without such message resolve to the newest
+// offset so nothing is replayed from them
+func ResolveOffsets(client OffsetResolver, topic string, since time.Time) (map[int32]int64, error) {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("listing partitions of %s: %w", topic, err)
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := client.GetOffset(topic, partition, since.UnixMilli())
+		if err != nil {
+			return nil, fmt.Errorf("resolving offset of %s/%d: %w", topic, partition, err)
+		}
+		if offset == sarama.OffsetNewest {
+			offset, err = client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return nil, fmt.Errorf("resolving newest offset of %s/%d: %w", topic, partition, err)
+			}
+		}
+		offsets[partition] = offset
+	}
+	return offsets, nil
+}
+
+// WithReplayOffsets rewinds the consumer group to offsets, as returned by
+// ResolveOffsets, on the first session claiming each partition
+func WithReplayOffsets(offsets map[int32]int64) Option {
+	return func(k *MessageReciever) {
+		k.replay = offsets
+	}
+}
+
+// resetOffsets applies pending replay offsets to partitions claimed by
+// session, each partition is rewound once so rebalances don't replay again
+func (k *MessageReciever) resetOffsets(session sarama.ConsumerGroupSession) {
+	for _, partition := range session.Claims()[k.topic] {
+		offset, ok := k.replay[partition]
+		if !ok {
+			continue
+		}
+		session.ResetOffset(k.topic, partition, offset, "")
+		delete(k.replay, partition)
+		log.Info().Str("topic", k.topic).Int32("partition", partition).Int64("offset", offset).Msg("replaying from offset")
+	}
+}
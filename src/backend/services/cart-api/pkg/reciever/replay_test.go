@@ -0,0 +1,82 @@
+package reciever
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOffsets resolves timestamps to offsets per partition, timestamps after
+// the last message resolve to OffsetNewest like the broker does
+type fakeOffsets struct {
+	partitions []int32
+	byTime     map[int32]map[int64]int64
+	newest     map[int32]int64
+	err        error
+}
+
+func (f *fakeOffsets) Partitions(topic string) ([]int32, error) {
+	return f.partitions, nil
+}
+
+func (f *fakeOffsets) GetOffset(topic string, partition int32, ts int64) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if ts == sarama.OffsetNewest {
+		return f.newest[partition], nil
+	}
+	if offset, ok := f.byTime[partition][ts]; ok {
+		return offset, nil
+	}
+	return sarama.OffsetNewest, nil
+}
+
+func TestResolveOffsets(t *testing.T) {
+	since := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := &fakeOffsets{
+		partitions: []int32{0, 1},
+		byTime:     map[int32]map[int64]int64{0: {since.UnixMilli(): 42}},
+		newest:     map[int32]int64{0: 100, 1: 7},
+	}
+
+	offsets, err := ResolveOffsets(client, "orders", since)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]int64{0: 42, 1: 7}, offsets)
+
+	client.err = errors.New("broker down")
+	_, err = ResolveOffsets(client, "orders", since)
+	assert.ErrorIs(t, err, client.err)
+}
+
+type resetRecord struct {
+	partition int32
+	offset    int64
+}
+
+type replaySession struct {
+	fakeSession
+	claims map[string][]int32
+	resets []resetRecord
+}
+
+func (s *replaySession) Claims() map[string][]int32 { return s.claims }
+func (s *replaySession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	s.resets = append(s.resets, resetRecord{partition, offset})
+}
+
+func TestResetOffsetsOnce(t *testing.T) {
+	k := NewMessageReciever(nil, "orders", WithReplayOffsets(map[int32]int64{0: 42, 1: 7}))
+
+	first := &replaySession{claims: map[string][]int32{"orders": {0}}}
+	k.resetOffsets(first)
+	assert.Equal(t, []resetRecord{{0, 42}}, first.resets)
+
+	// after rebalance partition 0 is not rewound again
+	second := &replaySession{claims: map[string][]int32{"orders": {0, 1}}}
+	k.resetOffsets(second)
+	assert.Equal(t, []resetRecord{{1, 7}}, second.resets)
+}
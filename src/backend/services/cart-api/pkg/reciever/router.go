@@ -0,0 +1,64 @@
+package reciever
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EventTypeAttribute is a message attribute the Router dispatches on
+const EventTypeAttribute = "event-type"
+
+// ErrUnknownEventType is returned by Router when no handler is registered
+// for an event type of the message
+var ErrUnknownEventType = errors.New("unknown event type")
+
+// RouterOption configures Router
+type RouterOption func(*Router)
+
+// WithSkipUnknown makes the router log and drop messages of unknown event
+// types instead of returning ErrUnknownEventType
+func WithSkipUnknown() RouterOption {
+	return func(r *Router) {
+		r.skipUnknown = true
+	}
+}
+
+// Router dispatches messages of a single topic carrying multiple event types
+// to a handler registered for the event-type attribute
+type Router struct {
+	handlers    map[string]MessageHandler
+	skipUnknown bool
+}
+
+func NewRouter(opts ...RouterOption) *Router {
+	r := &Router{handlers: make(map[string]MessageHandler)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register sets handler for eventType, registering the same type twice
+// replaces previous handler
+func (r *Router) Register(eventType string, handler MessageHandler) *Router {
+	r.handlers[eventType] = handler
+	return r
+}
+
+var _ MessageHandler = (*Router)(nil)
+
+func (r *Router) Handle(ctx context.Context, message *Message) error {
+	eventType := message.Attributes[EventTypeAttribute]
+	handler, ok := r.handlers[eventType]
+	if !ok {
+		if r.skipUnknown {
+			log.Warn().Str("event_type", eventType).Msg("skipping message of unknown event type")
+			return nil
+		}
+		return fmt.Errorf("%w: %q", ErrUnknownEventType, eventType)
+	}
+	return handler.Handle(ctx, message)
+}
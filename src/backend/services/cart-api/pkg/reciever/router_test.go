@@ -0,0 +1,46 @@
+package reciever
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func eventMessage(eventType string) *Message {
+	return &Message{
+		Value:      []byte(`{}`),
+		Attributes: map[string]string{EventTypeAttribute: eventType},
+	}
+}
+
+func TestRouter(t *testing.T) {
+	completed := &recordingHandler{}
+	cancelled := &recordingHandler{}
+
+	router := NewRouter().
+		Register("OrderCompleted", completed).
+		Register("OrderCancelled", cancelled)
+
+	t.Run("known type", func(t *testing.T) {
+		assert.NoError(t, router.Handle(context.Background(), eventMessage("OrderCompleted")))
+		assert.Len(t, completed.messages, 1)
+		assert.Empty(t, cancelled.messages)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		err := router.Handle(context.Background(), eventMessage("OrderShipped"))
+		assert.ErrorIs(t, err, ErrUnknownEventType)
+	})
+
+	t.Run("missing type", func(t *testing.T) {
+		err := router.Handle(context.Background(), &Message{Value: []byte(`{}`)})
+		assert.ErrorIs(t, err, ErrUnknownEventType)
+	})
+
+	t.Run("skip unknown", func(t *testing.T) {
+		skipping := NewRouter(WithSkipUnknown()).Register("OrderCompleted", completed)
+		assert.NoError(t, skipping.Handle(context.Background(), eventMessage("OrderShipped")))
+		assert.Len(t, completed.messages, 1)
+	})
+}
@@ -0,0 +1,33 @@
+package reciever
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/rs/zerolog/log"
+)
+
+// WithTombstoneHandler passes tombstones, messages with a nil value which
+// delete the key on a compacted topic, to handler. By default tombstones are
+// skipped and never reach the message handler
+func WithTombstoneHandler(handler MessageHandler) Option {
+	return func(k *MessageReciever) {
+		k.tombstones = handler
+	}
+}
+
+// isTombstone reports whether the message deletes its key, an empty but non
+// nil value is a regular message
+func isTombstone(message *sarama.ConsumerMessage) bool {
+	return message.Value == nil
+}
+
+// tombstoneHandler returns handler of the tombstone, nil when it is skipped
+func (c *consumerGroupHandler) tombstoneHandler(message *sarama.ConsumerMessage) MessageHandler {
+	if c.tombstones == nil {
+		log.Debug().
+			Str("topic", message.Topic).
+			Int64("offset", message.Offset).
+			Str("key", string(message.Key)).
+			Msg("skipping tombstone")
+	}
+	return c.tombstones
+}
@@ -0,0 +1,59 @@
+package reciever
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumeClaimTombstone(t *testing.T) {
+	tombstone := &sarama.ConsumerMessage{Topic: "orders", Key: []byte("cart-1"), Offset: 1}
+	empty := &sarama.ConsumerMessage{Topic: "orders", Key: []byte("cart-2"), Value: []byte{}, Offset: 2}
+
+	t.Run("skipped by default", func(t *testing.T) {
+		handler := &recordingHandler{}
+		session := &fakeSession{ctx: context.Background()}
+
+		err := (&consumerGroupHandler{handler: handler}).ConsumeClaim(session, newFakeClaim(tombstone, empty))
+		assert.NoError(t, err)
+
+		assert.Len(t, handler.messages, 1, "only the empty message reaches the handler")
+		assert.Equal(t, []byte("cart-2"), handler.messages[0].Key)
+		assert.Equal(t, []*sarama.ConsumerMessage{tombstone, empty}, session.marked, "skipped tombstones are committed")
+	})
+
+	t.Run("tombstone handler", func(t *testing.T) {
+		handler := &recordingHandler{}
+		tombstones := &recordingHandler{}
+		session := &fakeSession{ctx: context.Background()}
+
+		err := (&consumerGroupHandler{handler: handler, tombstones: tombstones}).ConsumeClaim(session, newFakeClaim(tombstone, empty))
+		assert.NoError(t, err)
+
+		assert.Len(t, tombstones.messages, 1)
+		assert.Equal(t, []byte("cart-1"), tombstones.messages[0].Key)
+		assert.Nil(t, tombstones.messages[0].Value)
+		assert.Len(t, handler.messages, 1)
+		assert.Len(t, session.marked, 2)
+	})
+
+	t.Run("concurrent workers", func(t *testing.T) {
+		handler := &recordingHandler{}
+		tombstones := &recordingHandler{}
+		session := &fakeSession{ctx: context.Background()}
+
+		err := (&consumerGroupHandler{handler: handler, tombstones: tombstones, workers: 2}).ConsumeClaim(session, newFakeClaim(tombstone))
+		assert.NoError(t, err)
+
+		assert.Len(t, tombstones.messages, 1)
+		assert.Empty(t, handler.messages)
+	})
+}
+
+func TestWithTombstoneHandler(t *testing.T) {
+	tombstones := &recordingHandler{}
+	k := NewMessageReciever(nil, "orders", WithTombstoneHandler(tombstones))
+	assert.Same(t, tombstones, k.tombstones)
+}
@@ -0,0 +1,60 @@
+// Package shutdown stops parts of a service one after another when it
+// terminates
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Step is a stage of the shutdown, Stop returns once the stage is done
+type Step struct {
+	Name string
+	// Timeout bounds the step, zero waits as long as the context of Run
+	Timeout time.Duration
+	Stop    func(ctx context.Context) error
+}
+
+// Sequence runs steps in order, e.g. stop accepting requests before
+// closing connections they use. A failed or timed out step doesn't keep
+// later steps from running so every resource is released
+type Sequence []Step
+
+// Run stops steps of the sequence in order and returns their errors joined
+func (s Sequence) Run(ctx context.Context) error {
+	var errs []error
+	for _, step := range s {
+		started := time.Now()
+		if err := step.run(ctx); err != nil {
+			log.Error().Err(err).Str("step", step.Name).Msg("shutdown step failed")
+			errs = append(errs, fmt.Errorf("%s: %w", step.Name, err))
+			continue
+		}
+		log.Info().Str("step", step.Name).Dur("took", time.Since(started)).Msg("shutdown step done")
+	}
+	return errors.Join(errs...)
+}
+
+// run waits for Stop until the timeout even when Stop ignores ctx, e.g.
+// waiting for goroutines
+func (s Step) run(ctx context.Context) error {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Stop(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,97 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a fake of the service parts, it records the order they stop in
+type recorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *recorder) step(name string, stop func(ctx context.Context) error) Step {
+	return Step{Name: name, Timeout: time.Second, Stop: func(ctx context.Context) error {
+		err := stop(ctx)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.stopped = append(r.stopped, name)
+		return err
+	}}
+}
+
+func done(ctx context.Context) error { return nil }
+
+func TestSequence(t *testing.T) {
+	t.Run("steps should stop in order", func(t *testing.T) {
+		r := &recorder{}
+		inFlight := make(chan struct{})
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(inFlight)
+		}()
+		seq := Sequence{
+			r.step("http", done),
+			// draining takes a while, redis must not be closed meanwhile
+			r.step("consumer", func(ctx context.Context) error {
+				<-inFlight
+				return nil
+			}),
+			r.step("redis", done),
+			r.step("otel", done),
+		}
+
+		assert.NoError(t, seq.Run(context.Background()))
+		assert.Equal(t, []string{"http", "consumer", "redis", "otel"}, r.stopped)
+	})
+
+	t.Run("failed step should not stop later steps", func(t *testing.T) {
+		r := &recorder{}
+		errDrain := errors.New("drain failed")
+		seq := Sequence{
+			r.step("consumer", func(ctx context.Context) error { return errDrain }),
+			r.step("redis", done),
+		}
+
+		err := seq.Run(context.Background())
+		assert.ErrorIs(t, err, errDrain)
+		assert.ErrorContains(t, err, "consumer")
+		assert.Equal(t, []string{"consumer", "redis"}, r.stopped)
+	})
+
+	t.Run("step should time out even when it ignores ctx", func(t *testing.T) {
+		r := &recorder{}
+		stuck := make(chan struct{})
+		defer close(stuck)
+		seq := Sequence{
+			{Name: "consumer", Timeout: 10 * time.Millisecond, Stop: func(ctx context.Context) error {
+				<-stuck
+				return nil
+			}},
+			r.step("redis", done),
+		}
+
+		started := time.Now()
+		err := seq.Run(context.Background())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(started), time.Second)
+		assert.Equal(t, []string{"redis"}, r.stopped)
+	})
+
+	t.Run("step should get its own deadline", func(t *testing.T) {
+		var deadline time.Time
+		seq := Sequence{{Name: "http", Timeout: time.Minute, Stop: func(ctx context.Context) error {
+			deadline, _ = ctx.Deadline()
+			return nil
+		}}}
+
+		assert.NoError(t, seq.Run(context.Background()))
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	})
+}
@@ -0,0 +1,230 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// S3Config addresses a bucket of an S3 compatible object storage, e.g. AWS S3
+// or MinIO
+type S3Config struct {
+	// Endpoint is a base url of the storage, e.g. https://s3.eu-west-1.amazonaws.com
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// ErrNotFound is returned when no snapshot matches
+var ErrNotFound = errors.New("snapshot not found")
+
+// S3Store writes and reads objects with path style requests signed by AWS
+// signature version 4
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing snapshot endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("snapshot endpoint %q must be an absolute url", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("snapshot bucket is required")
+	}
+	return &S3Store{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport), Timeout: 10 * time.Second},
+		now:      time.Now,
+	}, nil
+}
+
+// Put stores body under key in the bucket
+func (s *S3Store) Put(ctx context.Context, key string, body []byte) error {
+	u := s.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating snapshot request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("putting snapshot %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("putting snapshot %s: unexpected status %d: %s", key, resp.StatusCode, msg)
+	}
+	return nil
+}
+
+// Latest returns the object with the greatest key under prefix, snapshot
+// keys end with the time they were taken so it is the most recent one.
+// ErrNotFound is returned when there is none
+func (s *S3Store) Latest(ctx context.Context, prefix string) ([]byte, error) {
+	var latest, token string
+	for {
+		page, err := s.list(ctx, prefix, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			if object.Key > latest {
+				latest = object.Key
+			}
+		}
+		if !page.IsTruncated {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	if latest == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, prefix)
+	}
+	return s.get(ctx, latest)
+}
+
+// listPage is a ListObjectsV2 response
+type listPage struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *S3Store) list(ctx context.Context, prefix, token string) (*listPage, error) {
+	u := s.objectURL("")
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	// signature v4 wants spaces encoded as %20
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	body, err := s.read(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots %s: %w", prefix, err)
+	}
+	page := &listPage{}
+	if err := xml.Unmarshal(body, page); err != nil {
+		return nil, fmt.Errorf("listing snapshots %s: %w", prefix, err)
+	}
+	return page, nil
+}
+
+func (s *S3Store) get(ctx context.Context, key string) ([]byte, error) {
+	body, err := s.read(ctx, s.objectURL(key))
+	if err != nil {
+		return nil, fmt.Errorf("getting snapshot %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// read sends a signed GET request and returns the response body
+func (s *S3Store) read(ctx context.Context, u *url.URL) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, nil)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, msg)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// objectURL is a path style url of key in the bucket, the bucket itself when
+// key is empty
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket + "/" + key
+	return &u
+}
+
+// sign adds x-amz headers and Authorization to req, see
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := hexSHA256(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	// content type is signed only when the request has a body
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		signedHeaders = "content-type;" + signedHeaders
+		canonicalHeaders = "content-type:" + contentType + "\n" + canonicalHeaders
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,101 @@
+package snapshot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3StorePut(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		if r.URL.Path == "/snapshots/denied.json" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "snapshots",
+		Region:    "eu-west-1",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+	})
+	require.NoError(t, err)
+	store.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, store.Put(context.Background(), "abcd/20240301T120000Z.json", []byte(`{"id":"abcd"}`)))
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, "/snapshots/abcd/20240301T120000Z.json", got.URL.Path)
+	assert.Equal(t, `{"id":"abcd"}`, string(body))
+	assert.Equal(t, "20240301T120000Z", got.Header.Get("X-Amz-Date"))
+	assert.Equal(t, hexSHA256(body), got.Header.Get("X-Amz-Content-Sha256"))
+	assert.Contains(t, got.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential=AKID/20240301/eu-west-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=")
+
+	assert.Error(t, store.Put(context.Background(), "denied.json", nil))
+}
+
+func TestNewS3Store(t *testing.T) {
+	_, err := NewS3Store(S3Config{Endpoint: "localhost:9000", Bucket: "snapshots"})
+	assert.Error(t, err)
+
+	_, err = NewS3Store(S3Config{Endpoint: "http://localhost:9000"})
+	assert.Error(t, err)
+}
+
+func TestS3StoreLatest(t *testing.T) {
+	objects := map[string]string{
+		"abcd/20240301T120000.000000000Z.json": `{"id":"old"}`,
+		"abcd/20240302T120000.000000000Z.json": `{"id":"new"}`,
+	}
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.URL.Path == "/snapshots/" {
+			query := r.URL.Query()
+			switch {
+			case query.Get("prefix") != "abcd/":
+				w.Write([]byte(`<ListBucketResult><IsTruncated>false</IsTruncated></ListBucketResult>`))
+			case query.Get("continuation-token") == "":
+				w.Write([]byte(`<ListBucketResult><Contents><Key>abcd/20240302T120000.000000000Z.json</Key></Contents>` +
+					`<IsTruncated>true</IsTruncated><NextContinuationToken>next page</NextContinuationToken></ListBucketResult>`))
+			default:
+				w.Write([]byte(`<ListBucketResult><Contents><Key>abcd/20240301T120000.000000000Z.json</Key></Contents>` +
+					`<IsTruncated>false</IsTruncated></ListBucketResult>`))
+			}
+			return
+		}
+		body, ok := objects[r.URL.Path[len("/snapshots/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Bucket: "snapshots", Region: "eu-west-1", AccessKey: "AKID", SecretKey: "secret"})
+	require.NoError(t, err)
+
+	body, err := store.Latest(context.Background(), "abcd/")
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"new"}`, string(body))
+	require.Len(t, requests, 3)
+	assert.Equal(t, "list-type=2&prefix=abcd%2F", requests[0].URL.RawQuery)
+	assert.Equal(t, "continuation-token=next%20page&list-type=2&prefix=abcd%2F", requests[1].URL.RawQuery)
+	assert.Contains(t, requests[0].Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date,")
+
+	_, err = store.Latest(context.Background(), "missing/")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,46 @@
+// Package tenant carries tenant id in OpenTelemetry baggage so it flows
+// through traces, http calls and kafka messages
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+const (
+	// Header is a http request header carrying tenant id
+	Header = "X-Tenant-ID"
+	// BaggageKey is a baggage member carrying tenant id
+	BaggageKey = "tenant.id"
+)
+
+// validID keeps tenant ids safe to use in redis keys
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// FromContext returns tenant id of ctx, empty when there is none
+func FromContext(ctx context.Context) string {
+	id := baggage.FromContext(ctx).Member(BaggageKey).Value()
+	if !validID.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+// NewContext returns ctx with tenant id set in its baggage
+func NewContext(ctx context.Context, id string) (context.Context, error) {
+	if !validID.MatchString(id) {
+		return ctx, fmt.Errorf("invalid tenant id %q", id)
+	}
+	member, err := baggage.NewMember(BaggageKey, id)
+	if err != nil {
+		return ctx, fmt.Errorf("invalid tenant id %q: %w", id, err)
+	}
+	b, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx, fmt.Errorf("invalid tenant id %q: %w", id, err)
+	}
+	return baggage.ContextWithBaggage(ctx, b), nil
+}
@@ -0,0 +1,19 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+
+	ctx, err := NewContext(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", FromContext(ctx))
+
+	_, err = NewContext(context.Background(), "acme:*")
+	assert.Error(t, err)
+}
@@ -1,18 +1,29 @@
 import { z } from 'zod';
 
+// Amounts are numbers of major units, older carts wrote decimal strings
+// optionally followed by the currency, e.g. "12.34 EUR"
+const money = z.union([
+  z.number(),
+  z
+    .string()
+    .regex(/^\s*-?\d+(\.\d+)?(\s+[A-Za-z]{3})?\s*$/, 'invalid money amount')
+    .transform((s) => Number(s.trim().split(/\s+/)[0]))
+]);
+
 export const CartItemScheme = z.object({
   item_id: z.number(),
   product_name: z.string(),
   product_description: z.string(),
   img: z.string(),
   quantity: z.number(),
-  unit_price: z.number()
+  unit_price: money
 });
 
 export const CartScheme = z.object({
   id: z.string().uuid(),
   user_id: z.string(),
-  total: z.number(),
+  total: money,
+  currency: z.string().optional(),
   items: z.array(CartItemScheme)
 });
 